@@ -89,6 +89,7 @@ func init() {
 	Cmd.Flags().IntVar(&flagEpp, flagEppName, 0, "")
 	Cmd.Flags().StringVar(&flagGovernor, flagGovernorName, "", "")
 	Cmd.Flags().StringVar(&flagElc, flagElcName, "", "")
+	Cmd.Flags().BoolVar(&common.FlagDryRun, common.FlagDryRunName, false, "")
 
 	common.AddTargetFlags(Cmd)
 
@@ -164,6 +165,15 @@ func getFlagGroups() []common.FlagGroup {
 		GroupName: "Configuration Options",
 		Flags:     flags,
 	})
+	groups = append(groups, common.FlagGroup{
+		GroupName: "Other Options",
+		Flags: []common.Flag{
+			{
+				Name: common.FlagDryRunName,
+				Help: common.DryRunHelp + " Values that are computed from what is read on the target, e.g., MSR bits that are kept, are described rather than written in the plan.",
+			},
+		},
+	})
 	groups = append(groups, common.GetTargetFlagGroup())
 	return groups
 }
@@ -206,14 +216,26 @@ func runCmd(cmd *cobra.Command, args []string) error {
 	// appContext is the application context that holds common data and resources.
 	appContext := cmd.Context().Value(common.AppContext{}).(common.AppContext)
 	localTempDir := appContext.TempDir
-	// get the targets
-	myTargets, targetErrs, err := common.GetTargets(cmd, true, true, localTempDir)
+	// get the targets, a dry run reports the privileges that are needed instead of checking for them
+	myTargets, targetErrs, err := common.GetTargets(cmd, !common.FlagDryRun, !common.FlagDryRun, localTempDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		slog.Error(err.Error())
 		cmd.SilenceUsage = true
 		return err
 	}
+	// print what would be done on the targets, instead of doing it
+	if common.FlagDryRun {
+		if err := common.DryRunPlans(myTargets, targetErrs, func(myTarget target.Target) (script.Plan, error) {
+			return planTarget(cmd, myTarget)
+		}, appContext.OutputDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			slog.Error(err.Error())
+			cmd.SilenceUsage = true
+			return err
+		}
+		return nil
+	}
 	// check for errors in target connections
 	for _, err := range targetErrs {
 		if err != nil {
@@ -318,11 +340,7 @@ func runCmd(cmd *cobra.Command, args []string) error {
 }
 
 func printConfig(myTargets []target.Target, localTempDir string) (err error) {
-	scriptNames := report.GetScriptNamesForTable(report.ConfigurationTableName)
-	var scriptsToRun []script.ScriptDefinition
-	for _, scriptName := range scriptNames {
-		scriptsToRun = append(scriptsToRun, script.GetScriptByName(scriptName))
-	}
+	scriptsToRun := configurationScripts()
 	for _, myTarget := range myTargets {
 		multiSpinner := progress.NewMultiSpinner()
 		err = multiSpinner.AddSpinner(myTarget.GetName())
@@ -363,106 +381,12 @@ func printConfig(myTargets []target.Target, localTempDir string) (err error) {
 
 func setCoreCount(cores int, myTarget target.Target, localTempDir string) (string, error) {
 	fmt.Printf("set core count per processor to %d on %s\n", cores, myTarget.GetName())
-	setScript := script.ScriptDefinition{
-		Name: "set core count",
-		Script: fmt.Sprintf(`
-desired_core_count_per_socket=%d
-num_cpus=$(ls /sys/devices/system/cpu/ | grep -E "^cpu[0-9]+$" | wc -l)
-num_threads=$(lscpu | grep 'Thread(s) per core' | awk '{print $NF}')
-num_sockets=$(lscpu | grep 'Socket(s)' | awk '{print $NF}')
-num_cores_per_socket=$((num_cpus / num_sockets / num_threads))
-
-# if desired core count is greater than current core count, exit
-if [[ $desired_core_count_per_socket -gt $num_cores_per_socket ]]; then
-	echo "requested core count ($desired_core_count_per_socket) is greater than physical cores ($num_cores_per_socket)"
-	exit 1
-fi
-
-# enable all logical CPUs
-echo 1 | tee /sys/devices/system/cpu/cpu*/online > /dev/null
-
-# if no cores to disable, exit
-num_cores_to_disable_per_socket=$((num_cores_per_socket - desired_core_count_per_socket))
-if [[ $num_cores_to_disable_per_socket -eq 0 ]]; then
-    echo "no cpus to off-line"
-    exit 0
-fi
-
-# get lines from cpuinfo that match the fields we need
-proc_cpuinfo_filtered=$(grep -E '(processor|core id|physical id)' /proc/cpuinfo)
-
-# loop through each line of text in proc_cpuinfo_filtered, creating a new record for each logical CPU
-while IFS= read -r line; do
-    # if line contains 'processor', start a new record
-    if [[ $line =~ "processor" ]]; then
-        # if record isn't empty (is empty first time through loop), put the record in the list of cpuinfo records
-        if [[ -n "$record" ]]; then
-            cpuinfo+=("$record")
-        fi
-        record="$line"$'\n'
-    else
-        record+="$line"$'\n'
-    fi
-done <<< "$proc_cpuinfo_filtered"
-# add the last record
-if [[ -n "$record" ]]; then
-    cpuinfo+=("$record")
-fi
-
-# build a unique list of core ids from the records
-core_ids=()
-for record in "${cpuinfo[@]}"; do
-    core_id=$(echo "$record" | grep 'core id' | awk '{print $NF}')
-    found=0
-    for id in "${core_ids[@]}"; do
-        if [[ "$id" == "$core_id" ]]; then
-            found=1
-            break
-        fi
-    done
-    if [[ $found -eq 0 ]]; then
-        core_ids+=("$core_id")
-    fi
-done
-
-# disable logical CPUs to reach the desired core count per socket
-for ((socket=0; socket<num_sockets; socket++)); do
-    offlined_cores=0
-    # loop through core_ids in reverse order to off-line the highest numbered cores first
-    for ((i=${#core_ids[@]}-1; i>=0; i--)); do
-        core=${core_ids[i]}
-        if [[ $offlined_cores -eq $num_cores_to_disable_per_socket ]]; then
-            break
-        fi
-        offlined_cores=$((offlined_cores+1))
-        # find record that matches socket and core and off-line the logical CPU
-        for record in "${cpuinfo[@]}"; do
-            processor=$(echo "$record" | grep 'processor' | awk '{print $NF}')
-            core_id=$(echo "$record" | grep 'core id' | awk '{print $NF}')
-            physical_id=$(echo "$record" | grep 'physical id' | awk '{print $NF}')
-            if [[ $physical_id -eq $socket && $core_id -eq $core ]]; then
-                echo "Off-lining processor $processor (socket $physical_id, core $core_id)"
-                echo 0 | tee /sys/devices/system/cpu/cpu"$processor"/online > /dev/null
-                num_disabled_cores=$((num_disabled_cores+1))
-            fi
-        done
-    done
-done
-`, cores),
-		Superuser: true,
-	}
-	return runScript(myTarget, setScript, localTempDir)
+	return runScript(myTarget, setCoreCountScript(cores), localTempDir)
 }
 
 func setLlcSize(llcSize float64, myTarget target.Target, localTempDir string) {
 	fmt.Printf("set LLC size to %.2f MB on %s\n", llcSize, myTarget.GetName())
-	scripts := []script.ScriptDefinition{}
-	scripts = append(scripts, script.GetScriptByName(script.LscpuScriptName))
-	scripts = append(scripts, script.GetScriptByName(script.LspciBitsScriptName))
-	scripts = append(scripts, script.GetScriptByName(script.LspciDevicesScriptName))
-	scripts = append(scripts, script.GetScriptByName(script.L3WaySizeName))
-
-	outputs, err := script.RunScripts(myTarget, scripts, true, localTempDir)
+	outputs, err := script.RunScripts(myTarget, llcSizeReadScripts(), true, localTempDir)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		slog.Error("failed to run scripts on target", slog.String("target", myTarget.GetName()), slog.String("error", err.Error()))
@@ -502,16 +426,7 @@ func setLlcSize(llcSize float64, myTarget target.Target, localTempDir string) {
 		return
 	}
 	// set the LLC size
-	setScript := script.ScriptDefinition{
-		Name:          "set LLC size",
-		Script:        fmt.Sprintf("wrmsr -a 0xC90 %d", cacheWays[waysToSet]),
-		Superuser:     true,
-		Architectures: []string{"x86_64"},
-		Families:      []string{"6"}, // Intel only
-		Depends:       []string{"wrmsr"},
-		Lkms:          []string{"msr"},
-	}
-	_, err = runScript(myTarget, setScript, localTempDir)
+	_, err = runScript(myTarget, setLlcSizeScript(strconv.FormatInt(cacheWays[waysToSet], 10)), localTempDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: failed to set LLC size: %v\n", err)
 	}
@@ -519,20 +434,7 @@ func setLlcSize(llcSize float64, myTarget target.Target, localTempDir string) {
 
 func setCoreFrequency(coreFrequency float64, myTarget target.Target, localTempDir string) {
 	fmt.Printf("set core frequency to %.1f GHz on %s\n", coreFrequency, myTarget.GetName())
-	freqInt := uint64(coreFrequency * 10)
-	var msr uint64
-	for i := 0; i < 8; i++ {
-		msr = msr | freqInt<<uint(i*8)
-	}
-	setScript := script.ScriptDefinition{
-		Name:          "set frequency bins",
-		Script:        fmt.Sprintf("wrmsr -a 0x1AD %d", msr),
-		Superuser:     true,
-		Architectures: []string{"x86_64"},
-		Families:      []string{"6"}, // Intel only
-		Depends:       []string{"wrmsr"},
-	}
-	_, err := runScript(myTarget, setScript, localTempDir)
+	_, err := runScript(myTarget, setCoreFrequencyScript(coreFrequency), localTempDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: failed to set core frequency: %v\n", err)
 	}
@@ -546,24 +448,7 @@ func setUncoreFrequency(maxFreq bool, uncoreFrequency float64, myTarget target.T
 		minmax = "min"
 	}
 	fmt.Printf("set uncore %s frequency to %.1f GHz on %s\n", minmax, uncoreFrequency, myTarget.GetName())
-	scripts := []script.ScriptDefinition{}
-	scripts = append(scripts, script.GetScriptByName(script.LscpuScriptName))
-	scripts = append(scripts, script.GetScriptByName(script.LspciBitsScriptName))
-	scripts = append(scripts, script.GetScriptByName(script.LspciDevicesScriptName))
-	scripts = append(scripts, script.GetScriptByName(script.UncoreMaxFromMSRScriptName))
-	scripts = append(scripts, script.GetScriptByName(script.UncoreMinFromMSRScriptName))
-	scripts = append(scripts, script.GetScriptByName(script.UncoreMaxFromTPMIScriptName))
-	scripts = append(scripts, script.GetScriptByName(script.UncoreMinFromTPMIScriptName))
-	scripts = append(scripts, script.ScriptDefinition{
-		Name:          "get uncore frequency MSR",
-		Script:        "rdmsr 0x620",
-		Lkms:          []string{"msr"},
-		Architectures: []string{"x86_64"},
-		Families:      []string{"6"}, // Intel only
-		Depends:       []string{"rdmsr"},
-		Superuser:     true,
-	})
-	outputs, err := script.RunScripts(myTarget, scripts, true, localTempDir)
+	outputs, err := script.RunScripts(myTarget, uncoreFrequencyReadScripts(), true, localTempDir)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		slog.Error("failed to run scripts on target", slog.String("target", myTarget.GetName()), slog.String("error", err.Error()))
@@ -581,23 +466,8 @@ func setUncoreFrequency(maxFreq bool, uncoreFrequency float64, myTarget target.T
 		slog.Error("failed to get target model", slog.String("error", err.Error()))
 		return
 	}
-	if targetFamily == "6" && (targetModel == "173" || targetModel == "175") { //Intel, GNR and SRF only
-		value := uint64(uncoreFrequency * 10)
-		var bits string
-		if maxFreq {
-			bits = "8:14" // bits 8:14 are the max frequency
-		} else {
-			bits = "15:21" // bits 15:21 are the min frequency
-		}
-		setScript := script.ScriptDefinition{
-			Name:          "write max and min uncore frequency TPMI",
-			Script:        fmt.Sprintf("pcm-tpmi 2 0x18 -d -b %s -w %d", bits, value),
-			Architectures: []string{"x86_64"},
-			Families:      []string{"6"}, // Intel only
-			Depends:       []string{"pcm-tpmi"},
-			Superuser:     true,
-		}
-		_, err = runScript(myTarget, setScript, localTempDir)
+	if uncoreFrequencyFromTPMI(targetFamily, targetModel) {
+		_, err = runScript(myTarget, setUncoreFrequencyTPMIScript(maxFreq, uncoreFrequency), localTempDir)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: failed to set uncore frequency: %v\n", err)
 		}
@@ -622,16 +492,7 @@ func setUncoreFrequency(maxFreq bool, uncoreFrequency float64, myTarget target.T
 			// add in the new frequency value
 			newVal = newVal | newFreq<<8
 		}
-		setScript := script.ScriptDefinition{
-			Name:          "set uncore frequency MSR",
-			Script:        fmt.Sprintf("wrmsr -a 0x620 %d", newVal),
-			Superuser:     true,
-			Architectures: []string{"x86_64"},
-			Families:      []string{"6"}, // Intel only
-			Lkms:          []string{"msr"},
-			Depends:       []string{"wrmsr"},
-		}
-		_, err = runScript(myTarget, setScript, localTempDir)
+		_, err = runScript(myTarget, setUncoreFrequencyMSRScript(strconv.FormatUint(newVal, 10)), localTempDir)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: failed to set uncore frequency: %v\n", err)
 		}
@@ -640,16 +501,7 @@ func setUncoreFrequency(maxFreq bool, uncoreFrequency float64, myTarget target.T
 
 func setPower(power int, myTarget target.Target, localTempDir string) {
 	fmt.Printf("set power to %d Watts on %s\n", power, myTarget.GetName())
-	readScript := script.ScriptDefinition{
-		Name:          "get power MSR",
-		Script:        "rdmsr 0x610",
-		Superuser:     true,
-		Architectures: []string{"x86_64"},
-		Families:      []string{"6"}, // Intel only
-		Lkms:          []string{"msr"},
-		Depends:       []string{"rdmsr"},
-	}
-	readOutput, err := script.RunScript(myTarget, readScript, localTempDir)
+	readOutput, err := script.RunScript(myTarget, msrReadScript("get power MSR", "0x610"), localTempDir)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		slog.Error("failed to run script on target", slog.String("target", myTarget.GetName()), slog.String("error", err.Error()))
@@ -664,16 +516,7 @@ func setPower(power int, myTarget target.Target, localTempDir string) {
 			newVal := uint64(msrInt) & 0xFFFFFFFFFFFFC000
 			// add in the new power value
 			newVal = newVal | uint64(power*8)
-			setScript := script.ScriptDefinition{
-				Name:          "set tdp",
-				Script:        fmt.Sprintf("wrmsr -a 0x610 %d", newVal),
-				Superuser:     true,
-				Architectures: []string{"x86_64"},
-				Families:      []string{"6"}, // Intel only
-				Lkms:          []string{"msr"},
-				Depends:       []string{"wrmsr"},
-			}
-			_, err := runScript(myTarget, setScript, localTempDir)
+			_, err := runScript(myTarget, msrSetScript("set tdp", "0x610", strconv.FormatUint(newVal, 10)), localTempDir)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error: failed to set power: %v\n", err)
 			}
@@ -683,16 +526,7 @@ func setPower(power int, myTarget target.Target, localTempDir string) {
 
 func setEpb(epb int, myTarget target.Target, localTempDir string) {
 	fmt.Printf("set energy performance bias (EPB) to %d on %s\n", epb, myTarget.GetName())
-	setScript := script.ScriptDefinition{
-		Name:          "set epb",
-		Script:        fmt.Sprintf("wrmsr -a 0x1B0 %d", epb),
-		Superuser:     true,
-		Architectures: []string{"x86_64"},
-		Families:      []string{"6"}, // Intel only
-		Lkms:          []string{"msr"},
-		Depends:       []string{"wrmsr"},
-	}
-	_, err := runScript(myTarget, setScript, localTempDir)
+	_, err := runScript(myTarget, msrSetScript("set epb", "0x1B0", strconv.Itoa(epb)), localTempDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: failed to set EPB: %v\n", err)
 	}
@@ -705,16 +539,7 @@ func setEpp(epp int, myTarget target.Target, localTempDir string) {
 	// Reference: 15.4.4 Managing HWP in the Intel SDM
 
 	// get the current value of the IAEW_HWP_REQUEST MSR that includes the current EPP valid value in bit 60
-	getScript := script.ScriptDefinition{
-		Name:          "get epp msr",
-		Script:        "rdmsr 0x774", // IA32_HWP_REQUEST
-		Architectures: []string{"x86_64"},
-		Families:      []string{"6"}, // Intel only
-		Lkms:          []string{"msr"},
-		Depends:       []string{"rdmsr"},
-		Superuser:     true,
-	}
-	stdout, err := runScript(myTarget, getScript, localTempDir)
+	stdout, err := runScript(myTarget, msrReadScript("get epp msr", "0x774"), localTempDir) // IA32_HWP_REQUEST
 	if err != nil {
 		return
 	}
@@ -727,32 +552,14 @@ func setEpp(epp int, myTarget target.Target, localTempDir string) {
 	// clear bit 60 in the IA32_HWP_REQUEST MSR value
 	maskedValue := msrValue & 0xEFFFFFFFFFFFFFFF
 	// write it back to the MSR
-	setScript := script.ScriptDefinition{
-		Name:          "set epp valid",
-		Script:        fmt.Sprintf("wrmsr -a 0x774 %d", maskedValue),
-		Superuser:     true,
-		Architectures: []string{"x86_64"},
-		Families:      []string{"6"}, // Intel only
-		Lkms:          []string{"msr"},
-		Depends:       []string{"wrmsr"},
-	}
-	_, err = runScript(myTarget, setScript, localTempDir)
+	_, err = runScript(myTarget, msrSetScript("set epp valid", "0x774", strconv.FormatUint(maskedValue, 10)), localTempDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: failed to set EPP valid: %v\n", err)
 		return
 	}
 
 	// get the current value of the IA32_HWP_REQUEST_PKG MSR that includes the current package EPP value
-	getScript = script.ScriptDefinition{
-		Name:          "get epp pkg msr",
-		Script:        "rdmsr 0x772", // IA32_HWP_REQUEST_PKG
-		Architectures: []string{"x86_64"},
-		Families:      []string{"6"}, // Intel only
-		Lkms:          []string{"msr"},
-		Depends:       []string{"rdmsr"},
-		Superuser:     true,
-	}
-	stdout, err = runScript(myTarget, getScript, localTempDir)
+	stdout, err = runScript(myTarget, msrReadScript("get epp pkg msr", "0x772"), localTempDir) // IA32_HWP_REQUEST_PKG
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: failed to get EPP: %v\n", err)
 		return
@@ -768,16 +575,7 @@ func setEpp(epp int, myTarget target.Target, localTempDir string) {
 	// put the EPP value in bits 24-31
 	eppValue := maskedValue | uint64(epp)<<24
 	// write it back to the MSR
-	setScript = script.ScriptDefinition{
-		Name:          "set epp",
-		Script:        fmt.Sprintf("wrmsr -a 0x772 %d", eppValue),
-		Superuser:     true,
-		Architectures: []string{"x86_64"},
-		Families:      []string{"6"}, // Intel only
-		Lkms:          []string{"msr"},
-		Depends:       []string{"wrmsr"},
-	}
-	_, err = runScript(myTarget, setScript, localTempDir)
+	_, err = runScript(myTarget, msrSetScript("set epp", "0x772", strconv.FormatUint(eppValue, 10)), localTempDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: failed to set EPP: %v\n", err)
 	}
@@ -785,12 +583,7 @@ func setEpp(epp int, myTarget target.Target, localTempDir string) {
 
 func setGovernor(governor string, myTarget target.Target, localTempDir string) {
 	fmt.Printf("set governor to %s on %s\n", governor, myTarget.GetName())
-	setScript := script.ScriptDefinition{
-		Name:      "set governor",
-		Script:    fmt.Sprintf("echo %s | tee /sys/devices/system/cpu/cpu*/cpufreq/scaling_governor", governor),
-		Superuser: true,
-	}
-	_, err := runScript(myTarget, setScript, localTempDir)
+	_, err := runScript(myTarget, setGovernorScript(governor), localTempDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: failed to set governor: %v\n", err)
 	}
@@ -798,31 +591,62 @@ func setGovernor(governor string, myTarget target.Target, localTempDir string) {
 
 func setElc(elc string, myTarget target.Target, localTempDir string) {
 	fmt.Printf("set efficiency latency control (ELC) mode to %s on %s\n", elc, myTarget.GetName())
-	var mode string
-	if elc == elcOptions[0] {
-		mode = "latency-optimized-mode"
-	} else if elc == elcOptions[1] {
-		mode = "default"
-	} else {
+	mode := elcMode(elc)
+	if mode == "" {
 		fmt.Fprintf(os.Stderr, "invalid elc mode: %s\n", elc)
 		slog.Error("invalid elc mode", slog.String("elc", elc))
 		return
 	}
-	setScript := script.ScriptDefinition{
-		Name:          "set elc",
-		Script:        fmt.Sprintf("bhs-power-mode.sh --%s", mode),
-		Superuser:     true,
-		Architectures: []string{"x86_64"},
-		Families:      []string{"6"},          // Intel only
-		Models:        []string{"173", "175"}, // GNR and SRF only
-		Depends:       []string{"bhs-power-mode.sh"},
-	}
-	_, err := runScript(myTarget, setScript, localTempDir)
+	_, err := runScript(myTarget, setElcScript(mode), localTempDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: failed to set ELC mode: %v\n", err)
 	}
 }
 
+// msrWriteScript returns a script that writes the value to the MSR on all CPUs and records the change, with the MSR's
+// distinct values before and after the change, in the audit log. The value is a decimal number, or, in a dry run's
+// plan, a description of the value.
+func msrWriteScript(msr string, value string) string {
+	return fmt.Sprintf("before=$(%s)\nwrmsr -a %s %s || exit 1\n", msrValues(msr), msr, value) +
+		script.AuditChange("write msr", msr, "$before", "$("+msrValues(msr)+")", false)
+}
+
+// msrValues returns a command that prints the MSR's distinct values on all CPUs
+func msrValues(msr string) string {
+	return fmt.Sprintf("rdmsr -a %s | sort -u | paste -sd, -", msr)
+}
+
+// tpmiWriteScript returns a script that writes the value to the bits of the uncore frequency TPMI register on all dies
+// and records the change, with the bits' distinct values before and after the change, in the audit log
+func tpmiWriteScript(bits string, value uint64) string {
+	return fmt.Sprintf("before=$(%s)\npcm-tpmi 2 0x18 -d -b %s -w %d || exit 1\n", tpmiValues(bits), bits, value) +
+		script.AuditChange("write tpmi", tpmiUncoreItem(bits), "$before", "$("+tpmiValues(bits)+")", false)
+}
+
+// tpmiValues returns a command that prints the distinct values of the bits, or of the whole register if bits is
+// empty, of the uncore frequency TPMI register on all dies
+func tpmiValues(bits string) string {
+	args := "-d"
+	if bits != "" {
+		args += " -b " + bits
+	}
+	return fmt.Sprintf("pcm-tpmi 2 0x18 %s | grep -oP 'value \\K[0-9]+' | sort -u | paste -sd, -", args)
+}
+
+// tpmiUncoreItem is the audit log item of the uncore frequency TPMI register's bits, or of the whole register
+func tpmiUncoreItem(bits string) string {
+	if bits == "" {
+		return "tpmi 2 0x18"
+	}
+	return "tpmi 2 0x18 bits " + bits
+}
+
+// onlineValues is a command that prints the online CPUs, e.g., 0-3,6
+const onlineValues = "cat /sys/devices/system/cpu/online"
+
+// governorValues is a command that prints the CPUs' distinct scaling governors
+const governorValues = "cat /sys/devices/system/cpu/cpu*/cpufreq/scaling_governor | sort -u | paste -sd, -"
+
 func runScript(myTarget target.Target, myScript script.ScriptDefinition, localTempDir string) (string, error) {
 	output, err := script.RunScript(myTarget, myScript, localTempDir)
 	if err != nil {
@@ -832,3 +656,318 @@ func runScript(myTarget target.Target, myScript script.ScriptDefinition, localTe
 	}
 	return output.Stdout, err
 }
+
+// configurationScripts returns the scripts that collect the Configuration table, it is printed before and after the
+// changes
+func configurationScripts() (scripts []script.ScriptDefinition) {
+	for _, scriptName := range report.GetScriptNamesForTable(report.ConfigurationTableName) {
+		scripts = append(scripts, script.GetScriptByName(scriptName))
+	}
+	return
+}
+
+// planTarget returns what the requested changes would do on the target, see common.DryRunPlans. The values that are
+// computed from what is read on the target are described.
+func planTarget(cmd *cobra.Command, myTarget target.Target) (plan script.Plan, err error) {
+	if plan, err = script.PlanScripts(myTarget, configurationScripts()); err != nil {
+		return
+	}
+	if cmd.Flags().Lookup(flagCoresName).Changed {
+		plan.AddChange(script.PlannedChange{Setting: "/sys/devices/system/cpu/cpu*/online", Read: onlineValues}, setCoreCountScript(flagCores))
+	}
+	if cmd.Flags().Lookup(flagLlcSizeName).Changed {
+		plan.AddChange(script.PlannedChange{Setting: "msr 0xC90", Read: msrValues("0xC90")},
+			append(llcSizeReadScripts(), setLlcSizeScript(fmt.Sprintf("<cache ways for %.2f MB, from the LLC size and cache ways that are read>", flagLlcSize)))...)
+	}
+	if cmd.Flags().Lookup(flagAllCoreMaxFrequencyName).Changed {
+		plan.AddChange(script.PlannedChange{Setting: "msr 0x1AD", Read: msrValues("0x1AD")}, setCoreFrequencyScript(flagAllCoreMaxFrequency))
+	}
+	for _, uncore := range []struct {
+		maxFreq   bool
+		flagName  string
+		frequency float64
+	}{
+		{true, flagUncoreMaxFrequencyName, flagUncoreMaxFrequency},
+		{false, flagUncoreMinFrequencyName, flagUncoreMinFrequency},
+	} {
+		if !cmd.Flags().Lookup(uncore.flagName).Changed || plan.Family != "6" { // Intel only
+			continue
+		}
+		if uncoreFrequencyFromTPMI(plan.Family, plan.Model) {
+			bits := uncoreFrequencyTPMIBits(uncore.maxFreq)
+			plan.AddChange(script.PlannedChange{Setting: tpmiUncoreItem(bits), Read: tpmiValues(bits)},
+				append(uncoreFrequencyReadScripts(), setUncoreFrequencyTPMIScript(uncore.maxFreq, uncore.frequency))...)
+		} else {
+			bits := "0:5"
+			if !uncore.maxFreq {
+				bits = "8:14"
+			}
+			plan.AddChange(script.PlannedChange{Setting: "msr 0x620", Read: msrValues("0x620")},
+				append(uncoreFrequencyReadScripts(), setUncoreFrequencyMSRScript(fmt.Sprintf("<0x620 that is read, with bits %s set to %d>", bits, uint64((uncore.frequency*1000)/100))))...)
+		}
+	}
+	if cmd.Flags().Lookup(flagPowerName).Changed {
+		plan.AddChange(script.PlannedChange{Setting: "msr 0x610", Read: msrValues("0x610")},
+			msrReadScript("get power MSR", "0x610"), msrSetScript("set tdp", "0x610", fmt.Sprintf("<0x610 that is read, with bits 0:13 set to %d>", flagPower*8)))
+	}
+	if cmd.Flags().Lookup(flagEpbName).Changed {
+		plan.AddChange(script.PlannedChange{Setting: "msr 0x1B0", Read: msrValues("0x1B0")}, msrSetScript("set epb", "0x1B0", strconv.Itoa(flagEpb)))
+	}
+	if cmd.Flags().Lookup(flagEppName).Changed {
+		plan.AddChange(script.PlannedChange{Setting: "msr 0x774", Read: msrValues("0x774")},
+			msrReadScript("get epp msr", "0x774"), msrSetScript("set epp valid", "0x774", "<0x774 that is read, with bit 60 cleared>"))
+		plan.AddChange(script.PlannedChange{Setting: "msr 0x772", Read: msrValues("0x772")},
+			msrReadScript("get epp pkg msr", "0x772"), msrSetScript("set epp", "0x772", fmt.Sprintf("<0x772 that is read, with bits 24:31 set to %d>", flagEpp)))
+	}
+	if cmd.Flags().Lookup(flagGovernorName).Changed {
+		plan.AddChange(script.PlannedChange{Setting: "/sys/devices/system/cpu/cpu*/cpufreq/scaling_governor", Read: governorValues}, setGovernorScript(flagGovernor))
+	}
+	if cmd.Flags().Lookup(flagElcName).Changed {
+		plan.AddChange(script.PlannedChange{Setting: tpmiUncoreItem(""), Read: tpmiValues("")}, setElcScript(elcMode(flagElc)))
+	}
+	return
+}
+
+// setCoreCountScript returns the script that takes CPUs off-line to leave the number of cores per processor
+func setCoreCountScript(cores int) script.ScriptDefinition {
+	return script.ScriptDefinition{
+		Name: "set core count",
+		Script: fmt.Sprintf(`
+desired_core_count_per_socket=%[1]d
+num_cpus=$(ls /sys/devices/system/cpu/ | grep -E "^cpu[0-9]+$" | wc -l)
+num_threads=$(lscpu | grep 'Thread(s) per core' | awk '{print $NF}')
+num_sockets=$(lscpu | grep 'Socket(s)' | awk '{print $NF}')
+num_cores_per_socket=$((num_cpus / num_sockets / num_threads))
+
+# if desired core count is greater than current core count, exit
+if [[ $desired_core_count_per_socket -gt $num_cores_per_socket ]]; then
+	echo "requested core count ($desired_core_count_per_socket) is greater than physical cores ($num_cores_per_socket)"
+	exit 1
+fi
+
+# record the online CPUs before and after the change in the audit log
+before=$(%[2]s)
+audit_online() {
+    %[3]s}
+
+# enable all logical CPUs
+trap audit_online EXIT
+echo 1 | tee /sys/devices/system/cpu/cpu*/online > /dev/null
+
+# if no cores to disable, exit
+num_cores_to_disable_per_socket=$((num_cores_per_socket - desired_core_count_per_socket))
+if [[ $num_cores_to_disable_per_socket -eq 0 ]]; then
+    echo "no cpus to off-line"
+    exit 0
+fi
+
+# get lines from cpuinfo that match the fields we need
+proc_cpuinfo_filtered=$(grep -E '(processor|core id|physical id)' /proc/cpuinfo)
+
+# loop through each line of text in proc_cpuinfo_filtered, creating a new record for each logical CPU
+while IFS= read -r line; do
+    # if line contains 'processor', start a new record
+    if [[ $line =~ "processor" ]]; then
+        # if record isn't empty (is empty first time through loop), put the record in the list of cpuinfo records
+        if [[ -n "$record" ]]; then
+            cpuinfo+=("$record")
+        fi
+        record="$line"$'\n'
+    else
+        record+="$line"$'\n'
+    fi
+done <<< "$proc_cpuinfo_filtered"
+# add the last record
+if [[ -n "$record" ]]; then
+    cpuinfo+=("$record")
+fi
+
+# build a unique list of core ids from the records
+core_ids=()
+for record in "${cpuinfo[@]}"; do
+    core_id=$(echo "$record" | grep 'core id' | awk '{print $NF}')
+    found=0
+    for id in "${core_ids[@]}"; do
+        if [[ "$id" == "$core_id" ]]; then
+            found=1
+            break
+        fi
+    done
+    if [[ $found -eq 0 ]]; then
+        core_ids+=("$core_id")
+    fi
+done
+
+# disable logical CPUs to reach the desired core count per socket
+for ((socket=0; socket<num_sockets; socket++)); do
+    offlined_cores=0
+    # loop through core_ids in reverse order to off-line the highest numbered cores first
+    for ((i=${#core_ids[@]}-1; i>=0; i--)); do
+        core=${core_ids[i]}
+        if [[ $offlined_cores -eq $num_cores_to_disable_per_socket ]]; then
+            break
+        fi
+        offlined_cores=$((offlined_cores+1))
+        # find record that matches socket and core and off-line the logical CPU
+        for record in "${cpuinfo[@]}"; do
+            processor=$(echo "$record" | grep 'processor' | awk '{print $NF}')
+            core_id=$(echo "$record" | grep 'core id' | awk '{print $NF}')
+            physical_id=$(echo "$record" | grep 'physical id' | awk '{print $NF}')
+            if [[ $physical_id -eq $socket && $core_id -eq $core ]]; then
+                echo "Off-lining processor $processor (socket $physical_id, core $core_id)"
+                echo 0 | tee /sys/devices/system/cpu/cpu"$processor"/online > /dev/null
+                num_disabled_cores=$((num_disabled_cores+1))
+            fi
+        done
+    done
+done
+`, cores, onlineValues, script.AuditChange("write sysfs", "/sys/devices/system/cpu/cpu*/online", "$before", "$("+onlineValues+")", false)),
+		Superuser: true,
+		Requires:  []string{script.RequireBash},
+	}
+}
+
+// llcSizeReadScripts returns the scripts that read the LLC size and cache ways that the LLC size is set from
+func llcSizeReadScripts() []script.ScriptDefinition {
+	return []script.ScriptDefinition{
+		script.GetScriptByName(script.LscpuScriptName),
+		script.GetScriptByName(script.LspciBitsScriptName),
+		script.GetScriptByName(script.LspciDevicesScriptName),
+		script.GetScriptByName(script.L3WaySizeName),
+	}
+}
+
+// setLlcSizeScript returns the script that writes the cache ways to the LLC MSR
+func setLlcSizeScript(ways string) script.ScriptDefinition {
+	return script.ScriptDefinition{
+		Name:          "set LLC size",
+		Script:        msrWriteScript("0xC90", ways),
+		Superuser:     true,
+		Architectures: []string{"x86_64"},
+		Families:      []string{"6"}, // Intel only
+		Depends:       []string{"rdmsr", "wrmsr"},
+		Lkms:          []string{"msr"},
+	}
+}
+
+// setCoreFrequencyScript returns the script that sets all the frequency bins to the frequency
+func setCoreFrequencyScript(coreFrequency float64) script.ScriptDefinition {
+	freqInt := uint64(coreFrequency * 10)
+	var msr uint64
+	for i := 0; i < 8; i++ {
+		msr = msr | freqInt<<uint(i*8)
+	}
+	return script.ScriptDefinition{
+		Name:          "set frequency bins",
+		Script:        msrWriteScript("0x1AD", strconv.FormatUint(msr, 10)),
+		Superuser:     true,
+		Architectures: []string{"x86_64"},
+		Families:      []string{"6"}, // Intel only
+		Depends:       []string{"rdmsr", "wrmsr"},
+	}
+}
+
+// uncoreFrequencyReadScripts returns the scripts that read the uncore frequencies before they are set
+func uncoreFrequencyReadScripts() []script.ScriptDefinition {
+	return []script.ScriptDefinition{
+		script.GetScriptByName(script.LscpuScriptName),
+		script.GetScriptByName(script.LspciBitsScriptName),
+		script.GetScriptByName(script.LspciDevicesScriptName),
+		script.GetScriptByName(script.UncoreMaxFromMSRScriptName),
+		script.GetScriptByName(script.UncoreMinFromMSRScriptName),
+		script.GetScriptByName(script.UncoreMaxFromTPMIScriptName),
+		script.GetScriptByName(script.UncoreMinFromTPMIScriptName),
+		msrReadScript("get uncore frequency MSR", "0x620"),
+	}
+}
+
+// uncoreFrequencyFromTPMI returns true if the uncore frequency is set with TPMI, rather than the MSR, on the processor
+func uncoreFrequencyFromTPMI(family string, model string) bool {
+	return family == "6" && (model == "173" || model == "175") // Intel, GNR and SRF only
+}
+
+// uncoreFrequencyTPMIBits returns the bits of the uncore frequency TPMI register that hold the max, or min, frequency
+func uncoreFrequencyTPMIBits(maxFreq bool) string {
+	if maxFreq {
+		return "8:14" // bits 8:14 are the max frequency
+	}
+	return "15:21" // bits 15:21 are the min frequency
+}
+
+// setUncoreFrequencyTPMIScript returns the script that writes the max, or min, uncore frequency to TPMI
+func setUncoreFrequencyTPMIScript(maxFreq bool, uncoreFrequency float64) script.ScriptDefinition {
+	return script.ScriptDefinition{
+		Name:          "write max and min uncore frequency TPMI",
+		Script:        tpmiWriteScript(uncoreFrequencyTPMIBits(maxFreq), uint64(uncoreFrequency*10)),
+		Architectures: []string{"x86_64"},
+		Families:      []string{"6"}, // Intel only
+		Depends:       []string{"pcm-tpmi"},
+		Superuser:     true,
+	}
+}
+
+// setUncoreFrequencyMSRScript returns the script that writes the value to the uncore frequency MSR
+func setUncoreFrequencyMSRScript(value string) script.ScriptDefinition {
+	return msrSetScript("set uncore frequency MSR", "0x620", value)
+}
+
+// msrReadScript returns the script that reads the MSR
+func msrReadScript(name string, msr string) script.ScriptDefinition {
+	return script.ScriptDefinition{
+		Name:          name,
+		Script:        "rdmsr " + msr,
+		Superuser:     true,
+		Architectures: []string{"x86_64"},
+		Families:      []string{"6"}, // Intel only
+		Lkms:          []string{"msr"},
+		Depends:       []string{"rdmsr"},
+	}
+}
+
+// msrSetScript returns the script that writes the value to the MSR, see msrWriteScript
+func msrSetScript(name string, msr string, value string) script.ScriptDefinition {
+	return script.ScriptDefinition{
+		Name:          name,
+		Script:        msrWriteScript(msr, value),
+		Superuser:     true,
+		Architectures: []string{"x86_64"},
+		Families:      []string{"6"}, // Intel only
+		Lkms:          []string{"msr"},
+		Depends:       []string{"rdmsr", "wrmsr"},
+	}
+}
+
+// setGovernorScript returns the script that sets the scaling governor of all CPUs
+func setGovernorScript(governor string) script.ScriptDefinition {
+	return script.ScriptDefinition{
+		Name: "set governor",
+		Script: fmt.Sprintf("before=$(%[1]s)\necho %[2]s | tee /sys/devices/system/cpu/cpu*/cpufreq/scaling_governor || exit 1\n", governorValues, governor) +
+			script.AuditChange("write sysfs", "/sys/devices/system/cpu/cpu*/cpufreq/scaling_governor", "$before", "$("+governorValues+")", false),
+		Superuser: true,
+	}
+}
+
+// elcMode returns the bhs-power-mode.sh mode of the ELC option, or an empty string if the option isn't valid
+func elcMode(elc string) string {
+	switch elc {
+	case elcOptions[0]:
+		return "latency-optimized-mode"
+	case elcOptions[1]:
+		return "default"
+	}
+	return ""
+}
+
+// setElcScript returns the script that sets the efficiency latency control mode
+func setElcScript(mode string) script.ScriptDefinition {
+	return script.ScriptDefinition{
+		Name: "set elc",
+		Script: fmt.Sprintf("before=$(%[1]s)\nbhs-power-mode.sh --%[2]s || exit 1\n", tpmiValues(""), mode) +
+			script.AuditChange("write tpmi", tpmiUncoreItem(""), "$before", "$("+tpmiValues("")+")", false),
+		Superuser:     true,
+		Architectures: []string{"x86_64"},
+		Families:      []string{"6"},          // Intel only
+		Models:        []string{"173", "175"}, // GNR and SRF only
+		Depends:       []string{"bhs-power-mode.sh", "pcm-tpmi"},
+	}
+}
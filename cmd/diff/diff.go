@@ -0,0 +1,394 @@
+// Package diff is a subcommand of the root command. It compares the reports of two or more systems or runs.
+package diff
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"perfspect/internal/common"
+	"perfspect/internal/report"
+	"perfspect/internal/util"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const cmdName = "diff"
+
+var examples = []string{
+	fmt.Sprintf("  Compare two systems:                  $ %s %s hostA.raw hostB.raw", common.AppName, cmdName),
+	fmt.Sprintf("  Compare runs before and after update: $ %s %s before/host.raw after/host.raw --format txt", common.AppName, cmdName),
+}
+
+var Cmd = &cobra.Command{
+	Use:   cmdName + " <report.raw> <report.raw> [...]",
+	Short: "Compare the reports of two or more systems or runs",
+	Long: `Compares the data in two or more ".raw" files, e.g., from two systems, or from one system before and after a BIOS update, and reports only the fields that differ, per table.
+
+The first report is the baseline. The insights that aren't in all the reports are listed with whether they appeared or disappeared relative to the baseline.`,
+	Example:       strings.Join(examples, "\n"),
+	RunE:          runCmd,
+	PreRunE:       validateFlags,
+	GroupID:       "primary",
+	Args:          cobra.MinimumNArgs(2),
+	SilenceErrors: true,
+}
+
+var (
+	flagFormat []string
+)
+
+const (
+	flagFormatName = "format"
+)
+
+// the values in the insights table, relative to the baseline
+const (
+	insightPresent     = "present"
+	insightAppeared    = "appeared"
+	insightDisappeared = "disappeared"
+)
+
+// diffFileName is the name of the diff report files, without the extension
+const diffFileName = "diff"
+
+func init() {
+	Cmd.Flags().StringSliceVar(&flagFormat, flagFormatName, []string{report.FormatAll}, "")
+
+	Cmd.SetUsageFunc(usageFunc)
+}
+
+func usageFunc(cmd *cobra.Command) error {
+	cmd.Printf("Usage: %s [flags] <report.raw> <report.raw> [...]\n\n", cmd.CommandPath())
+	cmd.Printf("Examples:\n%s\n\n", cmd.Example)
+	cmd.Println("Flags:")
+	for _, group := range getFlagGroups() {
+		cmd.Printf("  %s:\n", group.GroupName)
+		for _, flag := range group.Flags {
+			flagDefault := ""
+			if cmd.Flags().Lookup(flag.Name).DefValue != "" {
+				flagDefault = fmt.Sprintf(" (default: %s)", cmd.Flags().Lookup(flag.Name).DefValue)
+			}
+			cmd.Printf("    --%-20s %s%s\n", flag.Name, flag.Help, flagDefault)
+		}
+	}
+	cmd.Println("\nGlobal Flags:")
+	cmd.Parent().PersistentFlags().VisitAll(func(pf *pflag.Flag) {
+		flagDefault := ""
+		if cmd.Parent().PersistentFlags().Lookup(pf.Name).DefValue != "" {
+			flagDefault = fmt.Sprintf(" (default: %s)", cmd.Flags().Lookup(pf.Name).DefValue)
+		}
+		cmd.Printf("  --%-20s %s%s\n", pf.Name, pf.Usage, flagDefault)
+	})
+	return nil
+}
+
+func getFlagGroups() []common.FlagGroup {
+	var groups []common.FlagGroup
+	flags := []common.Flag{
+		{
+			Name: flagFormatName,
+			Help: fmt.Sprintf("choose output format(s) from: %s", strings.Join(append([]string{report.FormatAll}, report.FormatOptions...), ", ")),
+		},
+	}
+	groups = append(groups, common.FlagGroup{
+		GroupName: "Options",
+		Flags:     flags,
+	})
+	return groups
+}
+
+func validateFlags(cmd *cobra.Command, args []string) error {
+	formatOptions := append([]string{report.FormatAll}, report.FormatOptions...)
+	for _, format := range flagFormat {
+		if !util.StringInList(format, formatOptions) {
+			err := fmt.Errorf("format options are: %s", strings.Join(formatOptions, ", "))
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			slog.Error(err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+func runCmd(cmd *cobra.Command, args []string) error {
+	// appContext is the application context that holds common data and resources.
+	appContext := cmd.Context().Value(common.AppContext{}).(common.AppContext)
+	reportFilePaths, err := diff(args, appContext.OutputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		slog.Error(err.Error())
+		cmd.SilenceUsage = true
+		return err
+	}
+	if len(reportFilePaths) > 0 {
+		fmt.Println("Report files:")
+	}
+	for _, reportFilePath := range reportFilePaths {
+		fmt.Printf("  %s\n", reportFilePath)
+	}
+	return nil
+}
+
+// diff compares the raw reports in the paths and writes the differences to the output directory, in the requested
+// formats. It returns the paths of the report files.
+func diff(paths []string, outputDir string) (reportFilePaths []string, err error) {
+	var rawReports []report.RawReport
+	for _, path := range paths {
+		var pathReports []report.RawReport
+		pathReports, err = report.ReadRawReports(path)
+		if err != nil {
+			err = fmt.Errorf("failed to read raw file(s): %v", err)
+			return
+		}
+		rawReports = append(rawReports, pathReports...)
+	}
+	if len(rawReports) < 2 {
+		err = fmt.Errorf("at least two raw reports are required, found %d", len(rawReports))
+		return
+	}
+	// the tables that are in any of the reports are compared
+	var tableNames []string
+	for _, rawReport := range rawReports {
+		// add the plugin tables that were stored in the raw file, unless they were loaded from plugins
+		var pluginTables []report.PluginTableDefinition
+		for _, pluginTable := range rawReport.PluginTables {
+			if !report.HasTable(pluginTable.Name) {
+				pluginTables = append(pluginTables, pluginTable)
+			}
+		}
+		if err = report.AddPluginTables(pluginTables); err != nil {
+			err = fmt.Errorf("failed to add plugin tables from raw file: %v", err)
+			return
+		}
+		for _, tableName := range rawReport.TableNames {
+			// skip the tables that are added after processing, e.g., insights
+			if report.HasTable(tableName) {
+				tableNames = util.UniqueAppend(tableNames, tableName)
+			}
+		}
+	}
+	var allReportsTableValues [][]report.TableValues
+	var reportNames []string
+	for _, rawReport := range rawReports {
+		var allTableValues []report.TableValues
+		allTableValues, err = report.Process(tableNames, rawReport.ScriptOutputs)
+		if err != nil {
+			err = fmt.Errorf("failed to process collected data: %v", err)
+			return
+		}
+		allTableValues = append(allTableValues, common.DefaultInsightsFunc(allTableValues, rawReport.ScriptOutputs))
+		allReportsTableValues = append(allReportsTableValues, allTableValues)
+		reportNames = append(reportNames, uniqueName(rawReport.TargetName, reportNames))
+	}
+	diffTableValues := diffTables(allReportsTableValues, reportNames)
+	if len(diffTableValues) == 0 {
+		fmt.Printf("No differences found between: %s\n", strings.Join(reportNames, ", "))
+		return
+	}
+	if err = common.CreateOutputDir(outputDir); err != nil {
+		err = fmt.Errorf("failed to create output directory: %v", err)
+		return
+	}
+	formats := flagFormat
+	if util.StringInList(report.FormatAll, formats) {
+		formats = report.FormatOptions
+	}
+	for _, format := range formats {
+		var reportBytes []byte
+		reportBytes, err = report.Create(format, diffTableValues, nil, strings.Join(reportNames, " vs. "))
+		if err != nil {
+			err = fmt.Errorf("failed to create report: %v", err)
+			return
+		}
+		if len(formats) == 1 && format == report.FormatTxt {
+			fmt.Printf("%s:\n", strings.Join(reportNames, " vs. "))
+			fmt.Print(string(reportBytes))
+		}
+		reportPath := filepath.Join(outputDir, diffFileName+"."+format)
+		if err = report.WriteReport(reportBytes, reportPath); err != nil {
+			err = fmt.Errorf("failed to write report: %v", err)
+			return
+		}
+		reportFilePaths = append(reportFilePaths, reportPath)
+	}
+	return
+}
+
+// uniqueName returns the name, with a suffix if it is already in the names, e.g., a system's before and after reports
+func uniqueName(name string, names []string) string {
+	unique := name
+	for i := 2; slices.Contains(names, unique); i++ {
+		unique = fmt.Sprintf("%s (%d)", name, i)
+	}
+	return unique
+}
+
+// diffTables returns a table for each of the tables whose values differ between the reports. A table has a column
+// for each report and a row for each field that differs. The fields of tables that have rows, e.g., the DIMMs, are
+// compared row by row. The insights table lists the insights that aren't in all the reports.
+func diffTables(allReportsTableValues [][]report.TableValues, reportNames []string) (diffTableValues []report.TableValues) {
+	// the tables, in the order they first appear in the reports
+	var tableNames []string
+	for _, allTableValues := range allReportsTableValues {
+		for _, tableValues := range allTableValues {
+			tableNames = util.UniqueAppend(tableNames, tableValues.Name)
+		}
+	}
+	for _, tableName := range tableNames {
+		var tables []*report.TableValues
+		for _, allTableValues := range allReportsTableValues {
+			tables = append(tables, findTable(allTableValues, tableName))
+		}
+		var tableValues report.TableValues
+		if tableName == common.TableNameInsights {
+			tableValues = diffInsights(tables, reportNames)
+		} else {
+			tableValues = diffFields(tables, reportNames)
+		}
+		if len(tableValues.Fields[0].Values) > 0 {
+			tableValues.Name = tableName
+			tableValues.MenuLabel = tableName
+			diffTableValues = append(diffTableValues, tableValues)
+		}
+	}
+	return
+}
+
+// findTable returns the table with the name, or nil if it isn't in the report
+func findTable(allTableValues []report.TableValues, name string) *report.TableValues {
+	for i := range allTableValues {
+		if allTableValues[i].Name == name {
+			return &allTableValues[i]
+		}
+	}
+	return nil
+}
+
+// newDiffTable returns a table with a column for the first field, e.g., the field name, and a column for each report
+func newDiffTable(firstFieldName string, reportNames []string) report.TableValues {
+	tableValues := report.TableValues{
+		TableDefinition: report.TableDefinition{HasRows: true},
+		Fields:          []report.Field{{Name: firstFieldName, Values: []string{}}},
+	}
+	for _, reportName := range reportNames {
+		tableValues.Fields = append(tableValues.Fields, report.Field{Name: reportName, Values: []string{}})
+	}
+	return tableValues
+}
+
+// diffFields returns the fields whose values differ between the tables, a nil table has no values
+func diffFields(tables []*report.TableValues, reportNames []string) report.TableValues {
+	diffTable := newDiffTable("Field", reportNames)
+	// the fields, in the order they first appear in the tables, and the most rows in any of the tables
+	var fieldNames []string
+	hasRows := false
+	numRows := 0
+	for _, table := range tables {
+		if table == nil {
+			continue
+		}
+		hasRows = hasRows || table.HasRows
+		for _, field := range table.Fields {
+			fieldNames = util.UniqueAppend(fieldNames, field.Name)
+			numRows = max(numRows, len(field.Values))
+		}
+	}
+	for row := 0; row < numRows; row++ {
+		for _, fieldName := range fieldNames {
+			var values []string
+			for _, table := range tables {
+				values = append(values, fieldValue(table, fieldName, row))
+			}
+			if !differ(values) {
+				continue
+			}
+			label := fieldName
+			if hasRows {
+				label = fmt.Sprintf("%s (row %d)", fieldName, row+1)
+			}
+			diffTable.Fields[0].Values = append(diffTable.Fields[0].Values, label)
+			for i, value := range values {
+				diffTable.Fields[i+1].Values = append(diffTable.Fields[i+1].Values, value)
+			}
+		}
+	}
+	return diffTable
+}
+
+// fieldValue returns the field's value in the row, or an empty string if the table doesn't have it
+func fieldValue(table *report.TableValues, fieldName string, row int) string {
+	if table == nil {
+		return ""
+	}
+	for _, field := range table.Fields {
+		if field.Name == fieldName && row < len(field.Values) {
+			return field.Values[row]
+		}
+	}
+	return ""
+}
+
+// differ returns true if the values aren't all the same
+func differ(values []string) bool {
+	for _, value := range values[1:] {
+		if value != values[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// diffInsights returns the insights, i.e., their recommendations, that aren't in all the tables. They are marked as
+// present, or as appeared or disappeared relative to the first table, the baseline.
+func diffInsights(tables []*report.TableValues, reportNames []string) report.TableValues {
+	diffTable := newDiffTable("Recommendation", reportNames)
+	var recommendations []string
+	for _, table := range tables {
+		for _, recommendation := range insightRecommendations(table) {
+			recommendations = util.UniqueAppend(recommendations, recommendation)
+		}
+	}
+	for _, recommendation := range recommendations {
+		var present []bool
+		for _, table := range tables {
+			present = append(present, slices.Contains(insightRecommendations(table), recommendation))
+		}
+		if !slices.Contains(present, false) {
+			continue
+		}
+		diffTable.Fields[0].Values = append(diffTable.Fields[0].Values, recommendation)
+		for i := range tables {
+			var value string
+			switch {
+			case present[i] && (i == 0 || present[0]):
+				value = insightPresent
+			case present[i]:
+				value = insightAppeared
+			case present[0]:
+				value = insightDisappeared
+			}
+			diffTable.Fields[i+1].Values = append(diffTable.Fields[i+1].Values, value)
+		}
+	}
+	return diffTable
+}
+
+// insightRecommendations returns the recommendations in the insights table, a nil table has none
+func insightRecommendations(table *report.TableValues) []string {
+	if table == nil {
+		return nil
+	}
+	for _, field := range table.Fields {
+		if field.Name == "Recommendation" {
+			return field.Values
+		}
+	}
+	return nil
+}
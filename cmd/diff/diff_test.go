@@ -0,0 +1,65 @@
+package diff
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"perfspect/internal/common"
+	"perfspect/internal/report"
+	"reflect"
+	"testing"
+)
+
+func TestDiffTables(t *testing.T) {
+	table := func(name string, hasRows bool, fields ...report.Field) report.TableValues {
+		return report.TableValues{TableDefinition: report.TableDefinition{Name: name, HasRows: hasRows}, Fields: fields}
+	}
+	insights := func(recommendations ...string) report.TableValues {
+		return table(common.TableNameInsights, true, report.Field{Name: "Recommendation", Values: recommendations}, report.Field{Name: "Justification", Values: make([]string, len(recommendations))})
+	}
+	baseline := []report.TableValues{
+		table("BIOS", false, report.Field{Name: "Vendor", Values: []string{"Intel"}}, report.Field{Name: "Version", Values: []string{"1.0"}}),
+		table("DIMM", true, report.Field{Name: "Slot", Values: []string{"A1", "B1"}}, report.Field{Name: "Size", Values: []string{"16GB", "16GB"}}),
+		table("Same", false, report.Field{Name: "Value", Values: []string{"1"}}),
+		insights("keep", "fix governor"),
+	}
+	after := []report.TableValues{
+		table("BIOS", false, report.Field{Name: "Vendor", Values: []string{"Intel"}}, report.Field{Name: "Version", Values: []string{"2.0"}}),
+		table("DIMM", true, report.Field{Name: "Slot", Values: []string{"A1", "B1", "C1"}}, report.Field{Name: "Size", Values: []string{"16GB", "32GB", "32GB"}}),
+		table("Same", false, report.Field{Name: "Value", Values: []string{"1"}}),
+		table("New", false, report.Field{Name: "Value", Values: []string{"1"}}),
+		insights("keep", "update microcode"),
+	}
+	diffTableValues := diffTables([][]report.TableValues{baseline, after}, []string{"before", "after"})
+	want := map[string][][]string{
+		"BIOS":                   {{"Version"}, {"1.0"}, {"2.0"}},
+		"DIMM":                   {{"Size (row 2)", "Slot (row 3)", "Size (row 3)"}, {"16GB", "", ""}, {"32GB", "C1", "32GB"}},
+		common.TableNameInsights: {{"fix governor", "update microcode"}, {insightPresent, ""}, {insightDisappeared, insightAppeared}},
+		"New":                    {{"Value"}, {""}, {"1"}},
+	}
+	if len(diffTableValues) != len(want) {
+		t.Fatalf("unexpected tables: %+v", diffTableValues)
+	}
+	for _, tableValues := range diffTableValues {
+		var got [][]string
+		for _, field := range tableValues.Fields {
+			got = append(got, field.Values)
+		}
+		if !reflect.DeepEqual(got, want[tableValues.Name]) {
+			t.Errorf("unexpected %s table: got %v, want %v", tableValues.Name, got, want[tableValues.Name])
+		}
+		if tableValues.Fields[1].Name != "before" || tableValues.Fields[2].Name != "after" {
+			t.Errorf("unexpected %s columns: %+v", tableValues.Name, tableValues.Fields)
+		}
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	names := []string{"host", "host (2)"}
+	if name := uniqueName("host", names); name != "host (3)" {
+		t.Errorf("unexpected name: %s", name)
+	}
+	if name := uniqueName("other", names); name != "other" {
+		t.Errorf("unexpected name: %s", name)
+	}
+}
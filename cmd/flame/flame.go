@@ -48,6 +48,8 @@ const (
 
 func init() {
 	Cmd.Flags().StringVar(&common.FlagInput, common.FlagInputName, "", "")
+	Cmd.Flags().BoolVar(&common.FlagDiagnostics, common.FlagDiagnosticsName, false, "")
+	Cmd.Flags().BoolVar(&common.FlagDryRun, common.FlagDryRunName, false, "")
 	Cmd.Flags().StringSliceVar(&common.FlagFormat, common.FlagFormatName, []string{report.FormatHtml}, "")
 	Cmd.Flags().IntVar(&flagDuration, flagDurationName, 30, "")
 	Cmd.Flags().IntVar(&flagFrequency, flagFrequencyName, 11, "")
@@ -108,6 +110,14 @@ func getFlagGroups() []common.FlagGroup {
 			Name: common.FlagInputName,
 			Help: "\".raw\" file, or directory containing \".raw\" files. Will skip data collection and use raw data for reports.",
 		},
+		{
+			Name: common.FlagDiagnosticsName,
+			Help: "include a table of how each script ran, e.g., its exit code, duration, and tool version, to help find why data is missing",
+		},
+		{
+			Name: common.FlagDryRunName,
+			Help: common.DryRunHelp,
+		},
 	}
 	groups = append(groups, common.FlagGroup{
 		GroupName: "Advanced Options",
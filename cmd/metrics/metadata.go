@@ -19,6 +19,7 @@ import (
 	"perfspect/internal/cpudb"
 	"perfspect/internal/script"
 	"perfspect/internal/target"
+	"perfspect/internal/util"
 )
 
 // Metadata is the representation of the platform's state and capabilities
@@ -45,8 +46,46 @@ type Metadata struct {
 }
 
 // LoadMetadata - populates and returns a Metadata structure containing state of the
-// system.
+// system. The metadata is kept in the target cache, if it is enabled.
 func LoadMetadata(myTarget target.Target, noRoot bool, perfPath string, localTempDir string) (metadata Metadata, err error) {
+	cacheKey, ok := metadataCacheKey(myTarget, noRoot, perfPath)
+	if ok && target.CacheGet(myTarget, cacheKey, &metadata) {
+		return
+	}
+	if metadata, err = loadMetadata(myTarget, noRoot, perfPath, localTempDir); err != nil {
+		return
+	}
+	if ok {
+		target.CachePut(myTarget, cacheKey, metadata)
+	}
+	return
+}
+
+// metadataCacheKey returns the key of the metadata in the target cache. The events that perf supports depend on the
+// perf binary, and whether they can be used depends on privileges and perf_event_paranoid, which can change without
+// a reboot, so they are part of the key. It returns false if they can't be read, and the metadata isn't cached.
+func metadataCacheKey(myTarget target.Target, noRoot bool, perfPath string) (key string, ok bool) {
+	if !target.CacheEnabled() {
+		return
+	}
+	stdout, _, _, err := myTarget.RunCommand(exec.Command("sh", "-c", fmt.Sprintf("sha256sum %s | cut -d' ' -f1 && cat /proc/sys/kernel/perf_event_paranoid", perfPath)), 0)
+	if err != nil {
+		slog.Warn("failed to read perf checksum and perf_event_paranoid, metrics metadata isn't cached", slog.String("target", myTarget.GetName()), slog.String("error", err.Error()))
+		return
+	}
+	fields := strings.Fields(stdout)
+	if len(fields) != 2 {
+		slog.Warn("unexpected perf checksum and perf_event_paranoid, metrics metadata isn't cached", slog.String("target", myTarget.GetName()), slog.String("output", stdout))
+		return
+	}
+	key = fmt.Sprintf("metrics metadata perf %s paranoid %s", fields[0], fields[1])
+	if noRoot {
+		key += " noroot"
+	}
+	return key, true
+}
+
+func loadMetadata(myTarget target.Target, noRoot bool, perfPath string, localTempDir string) (metadata Metadata, err error) {
 	// CPU Info
 	var cpuInfo []map[string]string
 	cpuInfo, err = getCPUInfo(myTarget)
@@ -255,6 +294,7 @@ func (md Metadata) WriteJSONToFile(path string) (err error) {
 		return
 	}
 	defer rawFile.Close()
+	util.RecordFile(rawFile.Name())
 	var out []byte
 	mdCopy := md
 	mdCopy.PerfSupportedEvents = ""
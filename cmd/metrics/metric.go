@@ -13,6 +13,8 @@ import (
 	"strings"
 	"sync"
 
+	"perfspect/internal/util"
+
 	"github.com/Knetic/govaluate"
 	mapset "github.com/deckarep/golang-set/v2"
 )
@@ -237,6 +239,7 @@ func writeEventsToFile(path string, events [][]byte) (err error) {
 		return
 	}
 	defer rawFile.Close()
+	util.RecordFile(rawFile.Name())
 	for _, rawEvent := range events {
 		rawEvent = append(rawEvent, []byte("\n")...)
 		if _, err = rawFile.Write(rawEvent); err != nil {
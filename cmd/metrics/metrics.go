@@ -5,13 +5,16 @@ package metrics
 // SPDX-License-Identifier: BSD-3-Clause
 
 import (
+	"context"
 	"embed"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -182,6 +185,7 @@ func init() {
 	Cmd.Flags().IntVar(&flagPerfMuxInterval, flagPerfMuxIntervalName, 125, "")
 	Cmd.Flags().BoolVar(&flagNoRoot, flagNoRootName, false, "")
 	Cmd.Flags().BoolVar(&flagWriteEventsToFile, flagWriteEventsToFileName, false, "")
+	Cmd.Flags().BoolVar(&common.FlagDryRun, common.FlagDryRunName, false, "")
 
 	common.AddTargetFlags(Cmd)
 
@@ -309,6 +313,10 @@ func getFlagGroups() []common.FlagGroup {
 			Name: flagWriteEventsToFileName,
 			Help: "write raw perf events to file",
 		},
+		{
+			Name: common.FlagDryRunName,
+			Help: common.DryRunHelp + " The perf events aren't in the plan, they are selected from the events that the target's PMU supports.",
+		},
 	}
 	groups = append(groups, common.FlagGroup{
 		GroupName: "Advanced Options",
@@ -480,6 +488,7 @@ type targetContext struct {
 	nmiDisabled         bool
 	perfMuxIntervalsSet bool
 	perfMuxIntervals    map[string]int
+	restoreFailed       bool
 	groupDefinitions    []GroupDefinition
 	metricDefinitions   []MetricDefinition
 	printedFiles        []string
@@ -496,16 +505,17 @@ func runCmd(cmd *cobra.Command, args []string) error {
 	localTempDir := appContext.TempDir
 	localOutputDir := appContext.OutputDir
 	// handle signals
-	// child processes will exit when the signals are received which will
-	// allow this app to exit normally
+	// the commands running on the targets, e.g., perf, are interrupted when the signals are
+	// received which will allow this app to exit normally
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
 	sigChannel := make(chan os.Signal, 1)
 	signal.Notify(sigChannel, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		sig := <-sigChannel
 		setSignalReceived()
 		slog.Info("received signal", slog.String("signal", sig.String()))
-		// propogate signal to children
-		util.SignalChildren(sig)
+		cancel()
 	}()
 	// round up to next perfPrintInterval second (the collection interval used by perf stat)
 	if flagDuration != 0 {
@@ -515,14 +525,24 @@ func runCmd(cmd *cobra.Command, args []string) error {
 			flagDuration = (qi + 1) * flagPerfPrintInterval
 		}
 	}
-	// get the targets
-	myTargets, targetErrs, err := common.GetTargets(cmd, !flagNoRoot, !flagNoRoot, localTempDir)
+	// get the targets, a dry run reports the privileges that are needed instead of checking for them
+	myTargets, targetErrs, err := common.GetTargets(cmd, !flagNoRoot && !common.FlagDryRun, !flagNoRoot && !common.FlagDryRun, localTempDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		slog.Error(err.Error())
 		cmd.SilenceUsage = true
 		return err
 	}
+	// print what would be done on the targets, instead of doing it
+	if common.FlagDryRun {
+		if err := common.DryRunPlans(myTargets, targetErrs, planTarget, localOutputDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			slog.Error(err.Error())
+			cmd.SilenceUsage = true
+			return err
+		}
+		return nil
+	}
 	// check for live mode with multiple targets
 	if flagLive && len(myTargets) > 1 {
 		err := fmt.Errorf("live mode is only supported for a single target")
@@ -542,16 +562,22 @@ func runCmd(cmd *cobra.Command, args []string) error {
 			return err
 		}
 	}
+	// the runner bounds the number of targets worked on at the same time and the time spent on each target
+	runner := common.NewTargetRunner(cmd, multiSpinner.Status)
+	defer runner.PrintSummary(os.Stdout)
 	multiSpinner.Start()
 	defer multiSpinner.Finish()
 	// check for errors in target creation
+	var reachableTargets []target.Target
 	for i := range targetErrs {
 		if targetErrs[i] != nil {
 			_ = multiSpinner.Status(myTargets[i].GetName(), fmt.Sprintf("Error: %v", targetErrs[i]))
-			// remove target from targets list
-			myTargets = append(myTargets[:i], myTargets[i+1:]...)
+			runner.Fail(myTargets[i].GetName(), targetErrs[i])
+			continue
 		}
+		reachableTargets = append(reachableTargets, myTargets[i])
 	}
+	myTargets = reachableTargets
 	// check if any targets remain
 	if len(myTargets) == 0 {
 		err := fmt.Errorf("no targets specified")
@@ -560,24 +586,35 @@ func runCmd(cmd *cobra.Command, args []string) error {
 		cmd.SilenceUsage = true
 		return err
 	}
-	// check if all targets have the same architecture
-	for _, target := range myTargets {
-		tArch, err := target.GetArchitecture()
-		if err != nil {
+	// get the targets' architectures
+	runner.Run(ctx, myTargets, func(ctx context.Context, i int) error {
+		_ = runner.Status(myTargets[i].GetName(), "checking architecture")
+		if _, err := myTargets[i].GetArchitecture(); err != nil {
 			err = fmt.Errorf("failed to get architecture: %w", err)
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			slog.Error(err.Error())
-			cmd.SilenceUsage = true
+			_ = runner.Status(myTargets[i].GetName(), fmt.Sprintf("Error: %v", err))
 			return err
 		}
-		tArch0, err := myTargets[0].GetArchitecture()
-		if err != nil {
-			err = fmt.Errorf("failed to get architecture: %w", err)
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			slog.Error(err.Error())
-			cmd.SilenceUsage = true
-			return err
+		return nil
+	})
+	// from here on, only work with the targets that responded
+	reachableTargets = nil
+	for _, myTarget := range myTargets {
+		if runner.Succeeded(myTarget.GetName()) {
+			reachableTargets = append(reachableTargets, myTarget)
 		}
+	}
+	myTargets = reachableTargets
+	if len(myTargets) == 0 {
+		err := fmt.Errorf("failed to get architecture of any target")
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		slog.Error(err.Error())
+		cmd.SilenceUsage = true
+		return err
+	}
+	// check if all targets have the same architecture
+	for _, target := range myTargets {
+		tArch, _ := target.GetArchitecture()
+		tArch0, _ := myTargets[0].GetArchitecture()
 		if tArch != tArch0 {
 			err := fmt.Errorf("all targets must have the same architecture")
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -595,29 +632,27 @@ func runCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	// prepare the targets
-	channelTargetError := make(chan targetError)
 	var targetContexts []targetContext
 	for _, myTarget := range myTargets {
 		targetContexts = append(targetContexts, targetContext{target: myTarget})
 	}
 	targetTempRoot, _ := cmd.Flags().GetString(common.FlagTargetTempDirName)
-	for i := range targetContexts {
-		go prepareTarget(&targetContexts[i], targetTempRoot, localTempDir, localPerfPath, channelTargetError, multiSpinner.Status)
-	}
-	// wait for all targets to be prepared
-	numPreparedTargets := 0
-	for range targetContexts {
-		targetError := <-channelTargetError
-		if targetError.err != nil {
-			slog.Error("failed to prepare target", slog.String("target", targetError.target.GetName()), slog.String("error", targetError.err.Error()))
-		} else {
-			numPreparedTargets++
+	numPreparedTargets := runOnTargets(ctx, runner, targetContexts, "failed to prepare target", func(ctx context.Context, targetContext *targetContext, channelTargetError chan targetError) {
+		prepareTarget(targetContext, targetTempRoot, localTempDir, localPerfPath, channelTargetError, runner.Status)
+	})
+	// abandoned targets may still be in use so they are not restored or cleaned up
+	for _, targetContext := range targetContexts {
+		if runner.Abandoned(targetContext.target.GetName()) {
+			slog.Warn("target abandoned, it will not be restored or cleaned up", slog.String("target", targetContext.target.GetName()))
 		}
 	}
 	// schedule temporary directory cleanup
 	if cmd.Parent().PersistentFlags().Lookup("debug").Value.String() != "true" { // don't remove the directory if we're debugging
 		defer func() {
 			for _, targetContext := range targetContexts {
+				if runner.Abandoned(targetContext.target.GetName()) {
+					continue
+				}
 				if targetContext.tempDir != "" {
 					err := targetContext.target.RemoveDirectory(targetContext.tempDir)
 					if err != nil {
@@ -627,24 +662,50 @@ func runCmd(cmd *cobra.Command, args []string) error {
 			}
 		}()
 	}
-	// schedule NMI watchdog reset
+	// schedule restore journal removal, the journal is kept if a setting wasn't restored, so that it is replayed
 	defer func() {
 		for _, targetContext := range targetContexts {
+			if runner.Abandoned(targetContext.target.GetName()) || targetContext.restoreFailed {
+				continue
+			}
+			if targetContext.nmiDisabled || targetContext.perfMuxIntervalsSet {
+				err := script.ClearRestoreJournal(targetContext.target, localTempDir)
+				if err != nil {
+					slog.Error("failed to remove restore journal", slog.String("target", targetContext.target.GetName()), slog.String("error", err.Error()))
+				}
+			}
+		}
+	}()
+	// schedule NMI watchdog reset
+	defer func() {
+		for i, targetContext := range targetContexts {
+			if runner.Abandoned(targetContext.target.GetName()) {
+				continue
+			}
 			if targetContext.nmiDisabled {
 				err := EnableNMIWatchdog(targetContext.target, targetContext.tempDir)
 				if err != nil {
 					slog.Error("failed to re-enable NMI watchdog", slog.String("target", targetContext.target.GetName()), slog.String("error", err.Error()))
+					targetContexts[i].restoreFailed = true
 				}
+				script.Restored(targetContext.target, nmiWatchdogAuditItem, "1", err)
 			}
 		}
 	}()
 	// schedule mux interval reset
 	defer func() {
-		for _, targetContext := range targetContexts {
+		for i, targetContext := range targetContexts {
+			if runner.Abandoned(targetContext.target.GetName()) {
+				continue
+			}
 			if targetContext.perfMuxIntervalsSet {
 				err := SetMuxIntervals(targetContext.target, targetContext.perfMuxIntervals, localTempDir)
 				if err != nil {
 					slog.Error("failed to reset perf mux intervals", slog.String("target", targetContext.target.GetName()), slog.String("error", err.Error()))
+					targetContexts[i].restoreFailed = true
+				}
+				for device, interval := range targetContext.perfMuxIntervals {
+					script.Restored(targetContext.target, device, strconv.Itoa(interval), err)
 				}
 			}
 		}
@@ -657,19 +718,9 @@ func runCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	// prepare the metrics for each target
-	for i := range targetContexts {
-		go prepareMetrics(&targetContexts[i], localTempDir, channelTargetError, multiSpinner.Status)
-	}
-	// wait for all metrics to be prepared
-	numTargetsWithPreparedMetrics := 0
-	for range targetContexts {
-		targetError := <-channelTargetError
-		if targetError.err != nil {
-			slog.Error("failed to prepare metrics", slog.String("target", targetError.target.GetName()), slog.String("error", targetError.err.Error()))
-		} else {
-			numTargetsWithPreparedMetrics++
-		}
-	}
+	numTargetsWithPreparedMetrics := runOnTargets(ctx, runner, targetContexts, "failed to prepare metrics", func(ctx context.Context, targetContext *targetContext, channelTargetError chan targetError) {
+		prepareMetrics(targetContext, localTempDir, channelTargetError, runner.Status)
+	})
 	if numTargetsWithPreparedMetrics == 0 {
 		err := fmt.Errorf("no targets had metrics prepared")
 		slog.Error(err.Error())
@@ -681,6 +732,9 @@ func runCmd(cmd *cobra.Command, args []string) error {
 		// stop the multiSpinner
 		multiSpinner.Finish()
 		for _, targetContext := range targetContexts {
+			if !runner.Succeeded(targetContext.target.GetName()) {
+				continue
+			}
 			fmt.Printf("\nMetrics available on %s:\n", targetContext.target.GetName())
 			for _, metric := range targetContext.metricDefinitions {
 				fmt.Printf("\"%s\"\n", metric.Name)
@@ -701,6 +755,9 @@ func runCmd(cmd *cobra.Command, args []string) error {
 	// write metadata to file
 	if flagWriteEventsToFile {
 		for _, targetContext := range targetContexts {
+			if !runner.Succeeded(targetContext.target.GetName()) {
+				continue
+			}
 			if err = targetContext.metadata.WriteJSONToFile(localOutputDir + "/" + targetContext.target.GetName() + "_" + "metadata.json"); err != nil {
 				err = fmt.Errorf("failed to write metadata to file: %w", err)
 				fmt.Fprintf(os.Stderr, "Error: %+v\n", err)
@@ -710,30 +767,22 @@ func runCmd(cmd *cobra.Command, args []string) error {
 		}
 	}
 	// start the metric collection
-	for i := range targetContexts {
-		if targetContexts[i].err == nil {
-			finalMessage := "collecting metrics"
-			if flagDuration == 0 {
-				finalMessage += ", press Ctrl+C to stop"
-			} else {
-				finalMessage += fmt.Sprintf(" for %d seconds", flagDuration)
-			}
-			_ = multiSpinner.Status(targetContexts[i].target.GetName(), finalMessage)
-		}
-		go collectOnTarget(&targetContexts[i], localTempDir, localOutputDir, channelTargetError, multiSpinner.Status)
-	}
 	if flagLive {
 		multiSpinner.Finish()
 	}
-	for range targetContexts {
-		targetError := <-channelTargetError
-		if targetError.err != nil {
-			slog.Error("failed to collect on target", slog.String("target", targetError.target.GetName()), slog.String("error", targetError.err.Error()))
+	runOnTargets(ctx, runner, targetContexts, "failed to collect on target", func(ctx context.Context, targetContext *targetContext, channelTargetError chan targetError) {
+		finalMessage := "collecting metrics"
+		if flagDuration == 0 {
+			finalMessage += ", press Ctrl+C to stop"
+		} else {
+			finalMessage += fmt.Sprintf(" for %d seconds", flagDuration)
 		}
-	}
+		_ = runner.Status(targetContext.target.GetName(), finalMessage)
+		collectOnTarget(ctx, targetContext, localTempDir, localOutputDir, channelTargetError, runner.Status)
+	})
 	// finalize and stop the spinner
 	for _, targetContext := range targetContexts {
-		if targetContext.err == nil {
+		if runner.Succeeded(targetContext.target.GetName()) {
 			_ = multiSpinner.Status(targetContext.target.GetName(), "collection complete")
 		}
 	}
@@ -741,7 +790,7 @@ func runCmd(cmd *cobra.Command, args []string) error {
 	if !flagLive {
 		multiSpinner.Finish()
 		for i := range targetContexts {
-			if targetContexts[i].err != nil {
+			if !runner.Succeeded(targetContexts[i].target.GetName()) {
 				continue
 			}
 			myTarget := targetContexts[i].target
@@ -754,14 +803,16 @@ func runCmd(cmd *cobra.Command, args []string) error {
 				cmd.SilenceUsage = true
 				return err
 			}
-			if err = os.WriteFile(localOutputDir+"/"+myTarget.GetName()+"_"+"metrics_summary.csv", []byte(out), 0644); err != nil {
+			summaryPath := localOutputDir + "/" + myTarget.GetName() + "_" + "metrics_summary.csv"
+			if err = os.WriteFile(summaryPath, []byte(out), 0644); err != nil {
 				err = fmt.Errorf("failed to write summary to file: %w", err)
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				slog.Error(err.Error())
 				cmd.SilenceUsage = true
 				return err
 			}
-			targetContexts[i].printedFiles = append(targetContexts[i].printedFiles, localOutputDir+"/"+myTarget.GetName()+"_"+"metrics_summary.csv")
+			util.RecordFile(summaryPath)
+			targetContexts[i].printedFiles = append(targetContexts[i].printedFiles, summaryPath)
 			// html summary
 			htmlSummary := (flagScope == scopeSystem || flagScope == scopeProcess) && flagGranularity == granularitySystem
 			if htmlSummary {
@@ -773,20 +824,26 @@ func runCmd(cmd *cobra.Command, args []string) error {
 					cmd.SilenceUsage = true
 					return err
 				}
-				if err = os.WriteFile(localOutputDir+"/"+myTarget.GetName()+"_"+"metrics_summary.html", []byte(out), 0644); err != nil {
+				htmlSummaryPath := localOutputDir + "/" + myTarget.GetName() + "_" + "metrics_summary.html"
+				if err = os.WriteFile(htmlSummaryPath, []byte(out), 0644); err != nil {
 					err = fmt.Errorf("failed to write HTML summary to file: %w", err)
 					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 					slog.Error(err.Error())
 					cmd.SilenceUsage = true
 					return err
 				}
-				targetContexts[i].printedFiles = append(targetContexts[i].printedFiles, localOutputDir+"/"+myTarget.GetName()+"_"+"metrics_summary.html")
+				util.RecordFile(htmlSummaryPath)
+				targetContexts[i].printedFiles = append(targetContexts[i].printedFiles, htmlSummaryPath)
 			}
 		}
 		// print the names of the files that were created
 		fmt.Println()
 		fmt.Println("Metric files:")
 		for i := range targetContexts {
+			// abandoned collection may still be adding files
+			if runner.TimedOut(targetContexts[i].target.GetName()) || runner.Abandoned(targetContexts[i].target.GetName()) {
+				continue
+			}
 			for _, file := range targetContexts[i].printedFiles {
 				fmt.Printf("  %s\n", file)
 			}
@@ -795,6 +852,62 @@ func runCmd(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// runOnTargets runs one step of the metrics collection on the targets through the runner. The step
+// reports its outcome on the provided channel. Returns the number of targets on which all steps
+// have succeeded so far.
+func runOnTargets(ctx context.Context, runner *common.TargetRunner, targetContexts []targetContext, errorMessage string, step func(context.Context, *targetContext, chan targetError)) (numSucceeded int) {
+	var myTargets []target.Target
+	for _, targetContext := range targetContexts {
+		myTargets = append(myTargets, targetContext.target)
+	}
+	runner.Run(ctx, myTargets, func(ctx context.Context, i int) error {
+		channelTargetError := make(chan targetError, 1)
+		step(ctx, &targetContexts[i], channelTargetError)
+		targetError := <-channelTargetError
+		if targetError.err != nil {
+			slog.Error(errorMessage, slog.String("target", targetError.target.GetName()), slog.String("error", targetError.err.Error()))
+		}
+		return targetError.err
+	})
+	for _, myTarget := range myTargets {
+		if runner.Succeeded(myTarget.GetName()) {
+			numSucceeded++
+		}
+	}
+	return
+}
+
+// planTarget returns what prepareTarget would do on the target, see common.DryRunPlans
+func planTarget(myTarget target.Target) (plan script.Plan, err error) {
+	if plan, err = script.PlanScripts(myTarget, nil); err != nil {
+		return
+	}
+	// perf is copied to remote targets, the local target runs it from the local temporary directory
+	if _, ok := myTarget.(*target.LocalTarget); !ok {
+		if err = plan.AddBundledTool("perf"); err != nil {
+			err = fmt.Errorf("failed to find perf: %w", err)
+			return
+		}
+	}
+	if flagNoRoot {
+		return
+	}
+	plan.AddRestoreJournalReplay()
+	// sysctl is found on the path, or in /usr/sbin, on the target
+	plan.AddChange(script.PlannedChange{
+		Setting:   nmiWatchdogAuditItem,
+		Condition: "if it is enabled",
+		Read:      "sysctl kernel.nmi_watchdog",
+		Restore:   "sysctl kernel.nmi_watchdog=1",
+	}, nmiWatchdogScript("sysctl", "0"))
+	plan.AddChange(script.PlannedChange{
+		Setting: "perf_event_mux_interval_ms",
+		Read:    getMuxIntervalsScript().Script,
+		Restore: "echo <interval that was read> > <file that was read>, for each file",
+	}, setAllMuxIntervalsScript(flagPerfMuxInterval))
+	return
+}
+
 func prepareTarget(targetContext *targetContext, targetTempRoot string, localTempDir string, localPerfPath string, channelError chan targetError, statusUpdate progress.MultiSpinnerUpdateFunc) {
 	myTarget := targetContext.target
 	var err error
@@ -806,6 +919,20 @@ func prepareTarget(targetContext *targetContext, targetTempRoot string, localTem
 		channelError <- targetError{target: myTarget, err: err}
 		return
 	}
+	// restore the settings that a previous run changed but didn't restore, e.g., because it was killed, before they
+	// are read
+	if !flagNoRoot {
+		var journal string
+		if journal, err = script.ReplayRestoreJournal(myTarget, localTempDir); err != nil {
+			_ = statusUpdate(myTarget.GetName(), fmt.Sprintf("Error: %s", err.Error()))
+			targetContext.err = err
+			channelError <- targetError{target: myTarget, err: err}
+			return
+		}
+		if journal != "" {
+			_ = statusUpdate(myTarget.GetName(), "restored settings from a previous run")
+		}
+	}
 	// check if NMI watchdog is enabled and disable it if necessary
 	if !flagNoRoot {
 		var nmiWatchdogEnabled bool
@@ -817,6 +944,16 @@ func prepareTarget(targetContext *targetContext, targetTempRoot string, localTem
 			return
 		}
 		if nmiWatchdogEnabled {
+			var restoreCommand string
+			if restoreCommand, err = nmiWatchdogRestoreCommand(myTarget); err == nil {
+				err = script.JournalRestore(myTarget, "enable NMI watchdog", []string{restoreCommand}, localTempDir)
+			}
+			if err != nil {
+				_ = statusUpdate(myTarget.GetName(), fmt.Sprintf("Error: %s", err.Error()))
+				targetContext.err = err
+				channelError <- targetError{target: myTarget, err: err}
+				return
+			}
 			if err = DisableNMIWatchdog(myTarget, localTempDir); err != nil {
 				err = fmt.Errorf("failed to disable NMI watchdog: %w", err)
 				_ = statusUpdate(myTarget.GetName(), fmt.Sprintf("Error: %s", err.Error()))
@@ -825,6 +962,7 @@ func prepareTarget(targetContext *targetContext, targetTempRoot string, localTem
 				return
 			}
 			targetContext.nmiDisabled = true
+			script.Audit(myTarget, "write sysctl", nmiWatchdogAuditItem, "1", "0", true)
 		}
 	}
 	// set perf mux interval to desired value
@@ -836,6 +974,12 @@ func prepareTarget(targetContext *targetContext, targetTempRoot string, localTem
 			channelError <- targetError{target: myTarget, err: err}
 			return
 		}
+		if err = script.JournalRestore(myTarget, "reset perf mux intervals", muxIntervalsRestoreCommands(targetContext.perfMuxIntervals), localTempDir); err != nil {
+			_ = statusUpdate(myTarget.GetName(), fmt.Sprintf("Error: %s", err.Error()))
+			targetContext.err = err
+			channelError <- targetError{target: myTarget, err: err}
+			return
+		}
 		if err = SetAllMuxIntervals(myTarget, flagPerfMuxInterval, localTempDir); err != nil {
 			err = fmt.Errorf("failed to set all perf mux intervals: %w", err)
 			_ = statusUpdate(myTarget.GetName(), fmt.Sprintf("Error: %s", err.Error()))
@@ -844,6 +988,9 @@ func prepareTarget(targetContext *targetContext, targetTempRoot string, localTem
 			return
 		}
 		targetContext.perfMuxIntervalsSet = true
+		for _, device := range slices.Sorted(maps.Keys(targetContext.perfMuxIntervals)) {
+			script.Audit(myTarget, "write perf mux interval", device, strconv.Itoa(targetContext.perfMuxIntervals[device]), strconv.Itoa(flagPerfMuxInterval), true)
+		}
 	}
 	// get the full path to the perf binary
 	if targetContext.perfPath, err = getPerfPath(myTarget, localPerfPath); err != nil {
@@ -901,7 +1048,7 @@ func prepareMetrics(targetContext *targetContext, localTempDir string, channelEr
 	channelError <- targetError{target: myTarget, err: nil}
 }
 
-func collectOnTarget(targetContext *targetContext, localTempDir string, localOutputDir string, channelError chan targetError, statusUpdate progress.MultiSpinnerUpdateFunc) {
+func collectOnTarget(ctx context.Context, targetContext *targetContext, localTempDir string, localOutputDir string, channelError chan targetError, statusUpdate progress.MultiSpinnerUpdateFunc) {
 	myTarget := targetContext.target
 	if targetContext.err != nil {
 		channelError <- targetError{target: myTarget, err: nil}
@@ -928,7 +1075,7 @@ func collectOnTarget(targetContext *targetContext, localTempDir string, localOut
 			break
 		}
 		beginTimestamp := time.Now()
-		go runPerf(myTarget, flagNoRoot, processes, perfCommand, targetContext.groupDefinitions, targetContext.metricDefinitions, targetContext.metadata, localTempDir, localOutputDir, frameChannel, errorChannel)
+		go runPerf(ctx, myTarget, flagNoRoot, processes, perfCommand, targetContext.groupDefinitions, targetContext.metricDefinitions, targetContext.metadata, localTempDir, localOutputDir, frameChannel, errorChannel)
 		// wait for runPerf to finish
 		perfErr := <-errorChannel // capture and return all errors
 		if perfErr != nil {
@@ -1157,8 +1304,8 @@ func getPerfCommand(myTarget target.Target, perfPath string, eventGroups []Group
 // runPerf starts Linux perf using the provided command, then reads perf's output
 // until perf stops. When collecting for cgroups, perf will be manually terminated if/when the
 // run duration exceeds the collection time or the time when the cgroup list needs
-// to be refreshed.
-func runPerf(myTarget target.Target, noRoot bool, processes []Process, cmd *exec.Cmd, eventGroupDefinitions []GroupDefinition, metricDefinitions []MetricDefinition, metadata Metadata, localTempDir string, outputDir string, frameChannel chan []MetricFrame, errorChannel chan error) {
+// to be refreshed. Perf is interrupted when ctx is done.
+func runPerf(ctx context.Context, myTarget target.Target, noRoot bool, processes []Process, cmd *exec.Cmd, eventGroupDefinitions []GroupDefinition, metricDefinitions []MetricDefinition, metadata Metadata, localTempDir string, outputDir string, frameChannel chan []MetricFrame, errorChannel chan error) {
 	var err error
 	defer func() { errorChannel <- err }()
 	cpuCount := metadata.SocketCount * metadata.CoresPerSocket * metadata.ThreadsPerCore
@@ -1169,18 +1316,11 @@ func runPerf(myTarget target.Target, noRoot bool, processes []Process, cmd *exec
 	stderrChannel := make(chan string)
 	exitcodeChannel := make(chan int)
 	scriptErrorChannel := make(chan error)
-	cmdChannel := make(chan *exec.Cmd)
+	// perfCtx is canceled to stop perf before the collection is complete, e.g., to refresh the cgroups
+	perfCtx, stopPerf := context.WithCancel(ctx)
+	defer stopPerf()
 	slog.Debug("running perf stat", slog.String("command", perfCommand))
-	go script.RunScriptAsync(myTarget, script.ScriptDefinition{Name: "perf stat", Script: perfCommand, Superuser: !noRoot}, localTempDir, stdoutChannel, stderrChannel, exitcodeChannel, scriptErrorChannel, cmdChannel)
-	var localCommand *exec.Cmd
-	select {
-	case cmd := <-cmdChannel:
-		localCommand = cmd
-	case err := <-scriptErrorChannel:
-		if err != nil {
-			return
-		}
-	}
+	go script.RunScriptAsyncContext(perfCtx, myTarget, script.ScriptDefinition{Name: "perf stat", Script: perfCommand, Superuser: !noRoot}, localTempDir, stdoutChannel, stderrChannel, exitcodeChannel, scriptErrorChannel)
 	// must manually terminate perf in cgroup scope when a timeout is specified and/or need to refresh cgroups
 	startPerfTimestamp := time.Now()
 	var timeout int
@@ -1229,11 +1369,7 @@ func runPerf(myTarget target.Target, noRoot bool, processes []Process, cmd *exec
 				outputLines = [][]byte{} // empty it
 			}
 			if timeout != 0 && int(time.Since(startPerfTimestamp).Seconds()) > timeout {
-				err = localCommand.Process.Signal(os.Interrupt)
-				if err != nil {
-					err = fmt.Errorf("failed to terminate perf: %v", err)
-					slog.Error(err.Error())
-				}
+				stopPerf()
 			}
 		}
 	}()
@@ -1242,7 +1378,7 @@ func runPerf(myTarget target.Target, noRoot bool, processes []Process, cmd *exec
 	for !done {
 		select {
 		case err := <-scriptErrorChannel:
-			if err != nil {
+			if err != nil && perfCtx.Err() == nil {
 				slog.Error("error from perf", slog.String("error", err.Error()))
 			}
 			done = true
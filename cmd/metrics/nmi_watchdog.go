@@ -15,6 +15,9 @@ import (
 	"perfspect/internal/target"
 )
 
+// nmiWatchdogAuditItem is the item that is changed when the NMI watchdog is disabled, see script.Audit
+const nmiWatchdogAuditItem = "kernel.nmi_watchdog"
+
 // EnableNMIWatchdog - sets the kernel.nmi_watchdog value to "1"
 func EnableNMIWatchdog(myTarget target.Target, localTempDir string) (err error) {
 	slog.Info("enabling NMI watchdog")
@@ -29,6 +32,16 @@ func DisableNMIWatchdog(myTarget target.Target, localTempDir string) (err error)
 	return
 }
 
+// nmiWatchdogRestoreCommand - gets the command that enables the NMI watchdog, for the restore journal
+func nmiWatchdogRestoreCommand(myTarget target.Target) (command string, err error) {
+	var sysctl string
+	if sysctl, err = findSysctl(myTarget); err != nil {
+		return
+	}
+	command = fmt.Sprintf("%s kernel.nmi_watchdog=1", sysctl)
+	return
+}
+
 // NMIWatchdogEnabled - reads the kernel.nmi_watchdog value. If it is "1", returns true
 func NMIWatchdogEnabled(myTarget target.Target) (enabled bool, err error) {
 	var setting string
@@ -64,11 +77,7 @@ func setNMIWatchdog(myTarget target.Target, setting string, localTempDir string)
 	if sysctl, err = findSysctl(myTarget); err != nil {
 		return
 	}
-	_, err = script.RunScript(myTarget, script.ScriptDefinition{
-		Name:      "set NMI watchdog",
-		Script:    fmt.Sprintf("%s kernel.nmi_watchdog=%s", sysctl, setting),
-		Superuser: true},
-		localTempDir)
+	_, err = script.RunScript(myTarget, nmiWatchdogScript(sysctl, setting), localTempDir)
 	if err != nil {
 		err = fmt.Errorf("failed to set NMI watchdog to %s, %v", setting, err)
 		return
@@ -83,6 +92,15 @@ func setNMIWatchdog(myTarget target.Target, setting string, localTempDir string)
 	return
 }
 
+// nmiWatchdogScript - returns the script that sets the NMI watchdog with sysctl
+func nmiWatchdogScript(sysctl string, setting string) script.ScriptDefinition {
+	return script.ScriptDefinition{
+		Name:      "set NMI watchdog",
+		Script:    fmt.Sprintf("%s kernel.nmi_watchdog=%s", sysctl, setting),
+		Superuser: true,
+	}
+}
+
 // findSysctl - gets a useable path to sysctl or error
 func findSysctl(myTarget target.Target) (path string, err error) {
 	cmd := exec.Command("which", "sysctl")
@@ -7,6 +7,8 @@ package metrics
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -16,8 +18,7 @@ import (
 
 // GetMuxIntervals - get a map of sysfs device file names to current mux value for the associated device
 func GetMuxIntervals(myTarget target.Target, localTempDir string) (intervals map[string]int, err error) {
-	bash := "for file in $(find /sys/devices -type f -name perf_event_mux_interval_ms); do echo $file $(cat $file); done"
-	scriptOutput, err := script.RunScript(myTarget, script.ScriptDefinition{Name: "get mux intervals", Script: bash, Superuser: false}, localTempDir)
+	scriptOutput, err := script.RunScript(myTarget, getMuxIntervalsScript(), localTempDir)
 	if err != nil {
 		return
 	}
@@ -47,12 +48,31 @@ func SetMuxIntervals(myTarget target.Target, intervals map[string]int, localTemp
 	return
 }
 
+// muxIntervalsRestoreCommands - gets the commands that write the given intervals, for the restore journal
+func muxIntervalsRestoreCommands(intervals map[string]int) (commands []string) {
+	for _, device := range slices.Sorted(maps.Keys(intervals)) {
+		commands = append(commands, fmt.Sprintf("echo %d > %s", intervals[device], device))
+	}
+	return
+}
+
 // SetAllMuxIntervals - writes the given interval (ms) to all perf mux sysfs device files
 func SetAllMuxIntervals(myTarget target.Target, interval int, localTempDir string) (err error) {
-	bash := fmt.Sprintf("for file in $(find /sys/devices -type f -name perf_event_mux_interval_ms); do echo %d > $file; done", interval)
-	_, err = script.RunScript(myTarget, script.ScriptDefinition{Name: "set all mux intervals", Script: bash, Superuser: true}, localTempDir)
+	_, err = script.RunScript(myTarget, setAllMuxIntervalsScript(interval), localTempDir)
 	if err != nil {
 		return
 	}
 	return
 }
+
+// getMuxIntervalsScript - returns the script that prints each perf mux sysfs device file and its interval (ms)
+func getMuxIntervalsScript() script.ScriptDefinition {
+	bash := "for file in $(find /sys/devices -type f -name perf_event_mux_interval_ms); do echo $file $(cat $file); done"
+	return script.ScriptDefinition{Name: "get mux intervals", Script: bash, Superuser: false}
+}
+
+// setAllMuxIntervalsScript - returns the script that writes the given interval (ms) to all perf mux sysfs device files
+func setAllMuxIntervalsScript(interval int) script.ScriptDefinition {
+	bash := fmt.Sprintf("for file in $(find /sys/devices -type f -name perf_event_mux_interval_ms); do echo %d > $file; done", interval)
+	return script.ScriptDefinition{Name: "set all mux intervals", Script: bash, Superuser: true}
+}
@@ -11,6 +11,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"perfspect/internal/util"
 )
 
 func printMetricsJSON(metricFrames []MetricFrame, targetName string, printToStdout bool, printToFile bool, outputDir string) (filename string, err error) {
@@ -43,6 +45,7 @@ func printMetricsJSON(metricFrames []MetricFrame, targetName string, printToStdo
 				return
 			}
 			defer file.Close()
+			util.RecordFile(file.Name())
 			_, err = file.WriteString(string(jsonBytes) + "\n")
 			if err != nil {
 				return
@@ -66,6 +69,7 @@ func printMetricsCSV(metricFrames []MetricFrame, targetName string, printToStdou
 			return
 		}
 		defer file.Close()
+		util.RecordFile(file.Name())
 	}
 	for _, metricFrame := range metricFrames {
 		if metricFrame.FrameCount == 1 {
@@ -127,6 +131,7 @@ func printMetricsWide(metricFrames []MetricFrame, targetName string, printToStdo
 			return
 		}
 		defer file.Close()
+		util.RecordFile(file.Name())
 	}
 	for _, metricFrame := range metricFrames {
 		var names []string
@@ -274,6 +279,7 @@ func printMetricsTxt(metricFrames []MetricFrame, targetName string, printToStdou
 			return
 		}
 		defer file.Close()
+		util.RecordFile(file.Name())
 		_, err = file.WriteString(strings.Join(outputLines, "\n") + "\n")
 		if err != nil {
 			return
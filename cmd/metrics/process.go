@@ -153,6 +153,7 @@ for cgroup in "${!cgroup_cpu_usage[@]}"; do
 done | sort -nr | head -n %d
 `, filter, maxCgroups),
 		Superuser: true,
+		Requires:  []string{script.RequireBash},
 	}
 	output, err := script.RunScript(myTarget, hotCgroupsScript, localTempDir)
 	if err != nil {
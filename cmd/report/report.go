@@ -79,6 +79,10 @@ var (
 	flagSystemSummary  bool
 
 	flagBenchmark []string
+
+	flagPlugins string
+
+	flagBaseline string
 )
 
 // flag names
@@ -117,6 +121,10 @@ const (
 	flagSystemSummaryName  = "system-summary"
 
 	flagBenchmarkName = "benchmark"
+
+	flagPluginsName = "plugins"
+
+	flagBaselineName = "baseline"
 )
 
 var benchmarkOptions = []string{
@@ -182,9 +190,13 @@ func init() {
 	}
 	// set up other flags
 	Cmd.Flags().StringVar(&common.FlagInput, common.FlagInputName, "", "")
+	Cmd.Flags().BoolVar(&common.FlagDiagnostics, common.FlagDiagnosticsName, false, "")
+	Cmd.Flags().BoolVar(&common.FlagDryRun, common.FlagDryRunName, false, "")
 	Cmd.Flags().BoolVar(&flagAll, flagAllName, false, "")
 	Cmd.Flags().StringSliceVar(&common.FlagFormat, common.FlagFormatName, []string{report.FormatAll}, "")
 	Cmd.Flags().StringSliceVar(&flagBenchmark, flagBenchmarkName, []string{}, "")
+	Cmd.Flags().StringVar(&flagPlugins, flagPluginsName, "", "")
+	Cmd.Flags().StringVar(&flagBaseline, flagBaselineName, "", "")
 
 	common.AddTargetFlags(Cmd)
 
@@ -237,7 +249,7 @@ func getFlagGroups() []common.FlagGroup {
 	flags = []common.Flag{
 		{
 			Name: common.FlagFormatName,
-			Help: fmt.Sprintf("choose output format(s) from: %s", strings.Join(append([]string{report.FormatAll}, report.FormatOptions...), ", ")),
+			Help: fmt.Sprintf("choose output format(s) from: %s. %s writes a file per table, with a host column, and isn't included in %s", strings.Join(append(append([]string{report.FormatAll}, report.FormatOptions...), report.TableFormatOptions...), ", "), report.FormatCsv, report.FormatAll),
 		},
 		{
 			Name: flagBenchmarkName,
@@ -254,6 +266,22 @@ func getFlagGroups() []common.FlagGroup {
 			Name: common.FlagInputName,
 			Help: "\".raw\" file, or directory containing \".raw\" files. Will skip data collection and use raw data for reports.",
 		},
+		{
+			Name: common.FlagDiagnosticsName,
+			Help: "include a table of how each script ran, e.g., its exit code, duration, and tool version, to help find why data is missing",
+		},
+		{
+			Name: common.FlagDryRunName,
+			Help: common.DryRunHelp,
+		},
+		{
+			Name: flagPluginsName,
+			Help: "directory containing \".yaml\" plugin files that define additional scripts and tables to include in the report",
+		},
+		{
+			Name: flagBaselineName,
+			Help: "\".yaml\" file of expected values and ranges, e.g., a golden configuration, to check the targets against. Adds a Compliance table to the report and exits with an error if any check fails.",
+		},
 	}
 	groups = append(groups, common.FlagGroup{
 		GroupName: "Advanced Options",
@@ -278,7 +306,7 @@ func validateFlags(cmd *cobra.Command, args []string) error {
 	}
 	// validate format options
 	for _, format := range common.FlagFormat {
-		formatOptions := append([]string{report.FormatAll}, report.FormatOptions...)
+		formatOptions := append(append([]string{report.FormatAll}, report.FormatOptions...), report.TableFormatOptions...)
 		if !util.StringInList(format, formatOptions) {
 			err := fmt.Errorf("format options are: %s", strings.Join(formatOptions, ", "))
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -316,6 +344,34 @@ func runCmd(cmd *cobra.Command, args []string) error {
 			tableNames = util.UniqueAppend(tableNames, tableName)
 		}
 	}
+	// add plugin tables
+	if flagPlugins != "" {
+		pluginTableNames, err := common.LoadPlugins(flagPlugins)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			slog.Error(err.Error())
+			cmd.SilenceUsage = true
+			return err
+		}
+		for _, tableName := range pluginTableNames {
+			tableNames = util.UniqueAppend(tableNames, tableName)
+		}
+	}
+	// add the tables that the baseline checks
+	var baseline *common.Baseline
+	if flagBaseline != "" {
+		var err error
+		baseline, err = common.LoadBaseline(flagBaseline)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			slog.Error(err.Error())
+			cmd.SilenceUsage = true
+			return err
+		}
+		for _, tableName := range baseline.TableNames() {
+			tableNames = util.UniqueAppend(tableNames, tableName)
+		}
+	}
 	// include benchmark summary table if all benchmark options are selected
 	var summaryFunc common.SummaryFunc
 	if len(flagBenchmark) == len(benchmarkOptions) {
@@ -332,6 +388,7 @@ func runCmd(cmd *cobra.Command, args []string) error {
 		SummaryFunc:      summaryFunc,
 		SummaryTableName: benchmarkSummaryTableName,
 		InsightsFunc:     insightsFunc,
+		Baseline:         baseline,
 	}
 	return reportingCommand.Run()
 }
@@ -0,0 +1,124 @@
+// Package restore is a subcommand of the root command. It restores the settings that a run changed on the target(s) but didn't restore.
+package restore
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"perfspect/internal/common"
+	"perfspect/internal/script"
+	"perfspect/internal/target"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const cmdName = "restore"
+
+var examples = []string{
+	fmt.Sprintf("  Restore settings on local host:     $ %s %s", common.AppName, cmdName),
+	fmt.Sprintf("  Restore settings on remote target:  $ %s %s --target 192.168.1.1 --user fred --key fred_key", common.AppName, cmdName),
+	fmt.Sprintf("  Restore settings on remote targets: $ %s %s --targets targets.yaml", common.AppName, cmdName),
+}
+
+var Cmd = &cobra.Command{
+	Use:   cmdName,
+	Short: "Restore settings that were changed on the target(s) by an interrupted metrics run",
+	Long: `Restores the settings, e.g., the NMI watchdog and the perf multiplexing intervals, that the metrics command changed on the target(s) but didn't restore because it was killed or lost its connection to the target(s).
+
+Before changing a setting, the original value is written to a restore journal, ~/.cache/perfspect/restore.sh, on the target. The journal is removed when the setting is restored. This command replays the journals that are left on the target(s). The metrics command also replays a target's journal before it changes the target's settings.
+
+Only the metrics command's settings are journaled. The changes that the config command makes are requested, so they aren't journaled and this command doesn't restore them.`,
+	Example:       strings.Join(examples, "\n"),
+	RunE:          runCmd,
+	GroupID:       "primary",
+	Args:          cobra.NoArgs,
+	SilenceErrors: true,
+}
+
+func init() {
+	common.AddTargetFlags(Cmd)
+
+	Cmd.SetUsageFunc(usageFunc)
+}
+
+func usageFunc(cmd *cobra.Command) error {
+	cmd.Printf("Usage: %s [flags]\n\n", cmd.CommandPath())
+	cmd.Printf("Examples:\n%s\n\n", cmd.Example)
+	cmd.Println("Flags:")
+	for _, group := range []common.FlagGroup{common.GetTargetFlagGroup()} {
+		cmd.Printf("  %s:\n", group.GroupName)
+		for _, flag := range group.Flags {
+			cmd.Printf("    --%-20s %s\n", flag.Name, flag.Help)
+		}
+	}
+	cmd.Println("\nGlobal Flags:")
+	cmd.Parent().PersistentFlags().VisitAll(func(pf *pflag.Flag) {
+		flagDefault := ""
+		if cmd.Parent().PersistentFlags().Lookup(pf.Name).DefValue != "" {
+			flagDefault = fmt.Sprintf(" (default: %s)", cmd.Flags().Lookup(pf.Name).DefValue)
+		}
+		cmd.Printf("  --%-20s %s%s\n", pf.Name, pf.Usage, flagDefault)
+	})
+	return nil
+}
+
+func runCmd(cmd *cobra.Command, args []string) error {
+	// appContext is the application context that holds common data and resources.
+	appContext := cmd.Context().Value(common.AppContext{}).(common.AppContext)
+	localTempDir := appContext.TempDir
+	// the journals are replayed with elevated privileges
+	myTargets, targetErrs, err := common.GetTargets(cmd, true, true, localTempDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		slog.Error(err.Error())
+		cmd.SilenceUsage = true
+		return err
+	}
+	targetTempRoot, _ := cmd.Flags().GetString(common.FlagTargetTempDirName)
+	var failed []string
+	for i, myTarget := range myTargets {
+		var journal string
+		err = targetErrs[i]
+		if err == nil {
+			journal, err = restoreTarget(myTarget, targetTempRoot, localTempDir)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", myTarget.GetName(), err)
+			slog.Error(err.Error(), slog.String("target", myTarget.GetName()))
+			failed = append(failed, myTarget.GetName())
+			continue
+		}
+		if journal == "" {
+			fmt.Printf("%s: nothing to restore\n", myTarget.GetName())
+			continue
+		}
+		fmt.Printf("%s: restored settings with:\n%s\n", myTarget.GetName(), strings.TrimSpace(journal))
+	}
+	if len(failed) > 0 {
+		err = fmt.Errorf("failed to restore settings on: %s", strings.Join(failed, ", "))
+		cmd.SilenceUsage = true
+		return err
+	}
+	return nil
+}
+
+// restoreTarget replays the target's restore journal, it returns the journal or an empty string if there is none
+func restoreTarget(myTarget target.Target, targetTempRoot string, localTempDir string) (journal string, err error) {
+	// the scripts that read and replay the journal are copied to, and run from, the target's temporary directory
+	targetTempDir, err := myTarget.CreateTempDirectory(targetTempRoot)
+	if err != nil {
+		err = fmt.Errorf("failed to create temporary directory: %v", err)
+		return
+	}
+	defer func() {
+		if err := myTarget.RemoveDirectory(targetTempDir); err != nil {
+			slog.Error("error removing target temporary directory", slog.String("error", err.Error()))
+		}
+	}()
+	return script.ReplayRestoreJournal(myTarget, localTempDir)
+}
@@ -21,17 +21,24 @@ import (
 	"time"
 
 	"perfspect/cmd/config"
+	"perfspect/cmd/diff"
 	"perfspect/cmd/flame"
 	"perfspect/cmd/metrics"
 	"perfspect/cmd/report"
+	"perfspect/cmd/restore"
+	"perfspect/cmd/schema"
 	"perfspect/cmd/telemetry"
+	"perfspect/cmd/tools"
 	"perfspect/internal/common"
+	"perfspect/internal/script"
 	"perfspect/internal/util"
 
 	"github.com/spf13/cobra"
 )
 
 var gLogFile *os.File
+var gManifest *common.Manifest
+var gOutputDir string
 var gVersion = "9.9.9" // overwritten by ldflags in Makefile, set to high number here to avoid update prompt while debugging
 
 const (
@@ -111,6 +118,10 @@ Additional help topics:{{range .Commands}}{{if .IsAdditionalHelpTopicCommand}}
 	rootCmd.AddCommand(telemetry.Cmd)
 	rootCmd.AddCommand(flame.Cmd)
 	rootCmd.AddCommand(config.Cmd)
+	rootCmd.AddCommand(tools.Cmd)
+	rootCmd.AddCommand(restore.Cmd)
+	rootCmd.AddCommand(diff.Cmd)
+	rootCmd.AddCommand(schema.Cmd)
 	if onIntelNetwork() {
 		rootCmd.AddGroup([]*cobra.Group{{ID: "other", Title: "Other Commands:"}}...)
 		rootCmd.AddCommand(updateCmd)
@@ -129,6 +140,12 @@ func Execute() {
 	cobra.EnableCommandSorting = false
 	cobra.EnableCaseInsensitive = true
 	err := rootCmd.Execute()
+	// the manifest is written whether or not the command succeeded
+	if gManifest != nil {
+		if err := gManifest.Write(gOutputDir, err); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
 	if err != nil {
 		os.Exit(1)
 	}
@@ -212,6 +229,13 @@ func initializeApplication(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Error: failed to create temp dir: %v\n", err)
 		os.Exit(1)
 	}
+	// record the run in a manifest, except for the update, tools, and schema commands which don't produce output
+	if cmd.Name() != "update" && cmd.Name() != "tools" && cmd.Name() != "schema" {
+		gManifest = common.NewManifest(cmd.Name(), os.Args, gVersion)
+		gOutputDir = outputDir
+		script.SetRecorder(gManifest.AddScript)
+		util.SetFileRecorder(gManifest.AddFile)
+	}
 	cmd.SetContext(
 		context.WithValue(
 			context.Background(),
@@ -219,7 +243,8 @@ func initializeApplication(cmd *cobra.Command, args []string) error {
 			common.AppContext{
 				OutputDir: outputDir,
 				TempDir:   applicationTempDir,
-				Version:   gVersion},
+				Version:   gVersion,
+				Manifest:  gManifest},
 		),
 	)
 	// check for updates unless the user has disabled this feature or is not on the Intel network or is running the update command
@@ -0,0 +1,68 @@
+// Package schema is a subcommand of the root command. It prints the JSON Schema of the JSON reports.
+package schema
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"perfspect/internal/common"
+	"perfspect/internal/report"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const cmdName = "schema"
+
+var examples = []string{
+	fmt.Sprintf("  Print the schema:           $ %s %s", common.AppName, cmdName),
+	fmt.Sprintf("  Save the schema to a file:  $ %s %s > perfspect-report.schema.json", common.AppName, cmdName),
+}
+
+var Cmd = &cobra.Command{
+	Use:   cmdName,
+	Short: "Print the JSON Schema of the JSON reports",
+	Long: fmt.Sprintf(`Prints the JSON Schema of the JSON reports, so that tools can validate the reports before they use them.
+
+The schema describes each table's fields, with their type and unit. Integer and number fields are objects with the value as collected and the number at its start. The reports include the version of the schema that they conform to, in the %[1]s field. The schema's version is %[2]s. The major version changes when a change can break consumers, e.g., a field is removed or its type changes, and the minor version when tables or fields are added.`, report.SchemaVersionKey, report.SchemaVersion),
+	Example:       strings.Join(examples, "\n"),
+	RunE:          runCmd,
+	GroupID:       "primary",
+	Args:          cobra.NoArgs,
+	SilenceErrors: true,
+}
+
+func init() {
+	Cmd.SetUsageFunc(usageFunc)
+}
+
+func usageFunc(cmd *cobra.Command) error {
+	cmd.Printf("Usage: %s [flags]\n\n", cmd.CommandPath())
+	cmd.Printf("Examples:\n%s\n\n", cmd.Example)
+	cmd.Println("Global Flags:")
+	cmd.Parent().PersistentFlags().VisitAll(func(pf *pflag.Flag) {
+		flagDefault := ""
+		if cmd.Parent().PersistentFlags().Lookup(pf.Name).DefValue != "" {
+			flagDefault = fmt.Sprintf(" (default: %s)", cmd.Flags().Lookup(pf.Name).DefValue)
+		}
+		cmd.Printf("  --%-20s %s%s\n", pf.Name, pf.Usage, flagDefault)
+	})
+	return nil
+}
+
+func runCmd(cmd *cobra.Command, args []string) error {
+	out, err := common.ReportJSONSchema()
+	if err != nil {
+		err = fmt.Errorf("failed to create schema: %v", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		slog.Error(err.Error())
+		cmd.SilenceUsage = true
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
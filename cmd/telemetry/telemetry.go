@@ -92,6 +92,8 @@ func init() {
 		Cmd.Flags().BoolVar(cat.FlagVar, cat.FlagName, cat.DefaultValue, cat.Help)
 	}
 	Cmd.Flags().StringVar(&common.FlagInput, common.FlagInputName, "", "")
+	Cmd.Flags().BoolVar(&common.FlagDiagnostics, common.FlagDiagnosticsName, false, "")
+	Cmd.Flags().BoolVar(&common.FlagDryRun, common.FlagDryRunName, false, "")
 	Cmd.Flags().BoolVar(&flagAll, flagAllName, false, "")
 	Cmd.Flags().StringSliceVar(&common.FlagFormat, common.FlagFormatName, []string{report.FormatAll}, "")
 	Cmd.Flags().IntVar(&flagDuration, flagDurationName, 30, "")
@@ -148,7 +150,7 @@ func getFlagGroups() []common.FlagGroup {
 	flags = []common.Flag{
 		{
 			Name: common.FlagFormatName,
-			Help: fmt.Sprintf("choose output format(s) from: %s", strings.Join(append([]string{report.FormatAll}, report.FormatOptions...), ", ")),
+			Help: fmt.Sprintf("choose output format(s) from: %s. %s writes a file per table, with a host column, and isn't included in %s", strings.Join(append(append([]string{report.FormatAll}, report.FormatOptions...), report.TableFormatOptions...), ", "), report.FormatCsv, report.FormatAll),
 		},
 		{
 			Name: flagDurationName,
@@ -169,6 +171,14 @@ func getFlagGroups() []common.FlagGroup {
 			Name: common.FlagInputName,
 			Help: "\".raw\" file, or directory containing \".raw\" files. Will skip data collection and use raw data for reports.",
 		},
+		{
+			Name: common.FlagDiagnosticsName,
+			Help: "include a table of how each script ran, e.g., its exit code, duration, and tool version, to help find why data is missing",
+		},
+		{
+			Name: common.FlagDryRunName,
+			Help: common.DryRunHelp,
+		},
 	}
 	groups = append(groups, common.FlagGroup{
 		GroupName: "Advanced Options",
@@ -195,6 +205,7 @@ func validateFlags(cmd *cobra.Command, args []string) error {
 	for _, format := range common.FlagFormat {
 		formatOptions := []string{report.FormatAll}
 		formatOptions = append(formatOptions, report.FormatOptions...)
+		formatOptions = append(formatOptions, report.TableFormatOptions...)
 		if !util.StringInList(format, formatOptions) {
 			err := fmt.Errorf("format options are: %s", strings.Join(formatOptions, ", "))
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
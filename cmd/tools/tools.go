@@ -0,0 +1,301 @@
+// Package tools is a subcommand of the root command. It lists, verifies, exports, and overrides the tools that are bundled for the scripts.
+package tools
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"perfspect/internal/common"
+	"perfspect/internal/script"
+	"perfspect/internal/target"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const cmdName = "tools"
+
+var examples = []string{
+	fmt.Sprintf("  List the bundled tools and overrides:     $ %s %s", common.AppName, cmdName),
+	fmt.Sprintf("  Verify the tools' checksums:              $ %s %s --verify", common.AppName, cmdName),
+	fmt.Sprintf("  Export the bundled tools to a directory:  $ %s %s --export ./bundle", common.AppName, cmdName),
+	fmt.Sprintf("  Override the bundled turbostat:           $ %s %s --override ./turbostat --arch x86_64", common.AppName, cmdName),
+	fmt.Sprintf("  Remove the tool cache from a target:      $ %s %s --clean-remote --target 192.168.1.1 --user fred --key fred_key", common.AppName, cmdName),
+}
+
+var Cmd = &cobra.Command{
+	Use:   cmdName,
+	Short: "Manage the tools that are used to collect data",
+	Long: fmt.Sprintf(`Lists, verifies, exports, and overrides the tools, e.g., turbostat and perf, that are copied to targets to collect data.
+
+The tools are bundled in %[1]s. A tool in the tools directory, %[2]s/<arch>/<tool>, is used in place of the bundled tool with the same name. Each architecture's directory may have a %[3]s file, in sha256sum's format, that lists the tools' checksums.
+
+Targets that are used with --%[4]s keep the tools in a cache directory, ~/.cache/perfspect/tools, that --clean-remote removes.`, common.AppName, script.ToolsDir(), script.ChecksumsFileName, "toolcache"),
+	Example:       strings.Join(examples, "\n"),
+	RunE:          runCmd,
+	PreRunE:       validateFlags,
+	GroupID:       "primary",
+	Args:          cobra.NoArgs,
+	SilenceErrors: true,
+}
+
+var (
+	flagVerify   bool
+	flagExport   string
+	flagOverride string
+	flagArch     string
+	flagClean    bool
+)
+
+const (
+	flagVerifyName   = "verify"
+	flagExportName   = "export"
+	flagOverrideName = "override"
+	flagArchName     = "arch"
+	flagCleanName    = "clean-remote"
+)
+
+func init() {
+	Cmd.Flags().BoolVar(&flagVerify, flagVerifyName, false, "")
+	Cmd.Flags().StringVar(&flagExport, flagExportName, "", "")
+	Cmd.Flags().StringVar(&flagOverride, flagOverrideName, "", "")
+	Cmd.Flags().StringVar(&flagArch, flagArchName, "", "")
+	Cmd.Flags().BoolVar(&flagClean, flagCleanName, false, "")
+
+	common.AddTargetFlags(Cmd)
+
+	Cmd.SetUsageFunc(usageFunc)
+}
+
+func usageFunc(cmd *cobra.Command) error {
+	cmd.Printf("Usage: %s [flags]\n\n", cmd.CommandPath())
+	cmd.Printf("Examples:\n%s\n\n", cmd.Example)
+	cmd.Println("Flags:")
+	for _, group := range getFlagGroups() {
+		cmd.Printf("  %s:\n", group.GroupName)
+		for _, flag := range group.Flags {
+			cmd.Printf("    --%-20s %s\n", flag.Name, flag.Help)
+		}
+	}
+	cmd.Println("\nGlobal Flags:")
+	cmd.Parent().PersistentFlags().VisitAll(func(pf *pflag.Flag) {
+		flagDefault := ""
+		if cmd.Parent().PersistentFlags().Lookup(pf.Name).DefValue != "" {
+			flagDefault = fmt.Sprintf(" (default: %s)", cmd.Flags().Lookup(pf.Name).DefValue)
+		}
+		cmd.Printf("  --%-20s %s%s\n", pf.Name, pf.Usage, flagDefault)
+	})
+	return nil
+}
+
+func getFlagGroups() []common.FlagGroup {
+	flags := []common.Flag{
+		{
+			Name: flagVerifyName,
+			Help: "verify the checksums of the tools that are used",
+		},
+		{
+			Name: flagExportName,
+			Help: "write the bundled tools, and their checksums, to the specified directory",
+		},
+		{
+			Name: flagOverrideName,
+			Help: "use the specified file, or directory, in place of the bundled tool with the same name",
+		},
+		{
+			Name: flagArchName,
+			Help: "the architecture of the tools, e.g., x86_64, default is all architectures, or x86_64 for --override",
+		},
+		{
+			Name: flagCleanName,
+			Help: "remove the tool cache from the target(s), see --toolcache",
+		},
+	}
+	return []common.FlagGroup{{GroupName: "Options", Flags: flags}, common.GetTargetFlagGroup()}
+}
+
+func validateFlags(cmd *cobra.Command, args []string) error {
+	actions := 0
+	for _, flagName := range []string{flagVerifyName, flagExportName, flagOverrideName, flagCleanName} {
+		if cmd.Flags().Lookup(flagName).Changed {
+			actions++
+		}
+	}
+	if actions > 1 {
+		err := fmt.Errorf("only one of --%s, --%s, --%s, and --%s can be specified", flagVerifyName, flagExportName, flagOverrideName, flagCleanName)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return err
+	}
+	if cmd.Flags().Lookup(flagExportName).Changed && flagExport == "" {
+		err := fmt.Errorf("--%s requires a directory", flagExportName)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return err
+	}
+	if cmd.Flags().Lookup(flagOverrideName).Changed && flagOverride == "" {
+		err := fmt.Errorf("--%s requires a file or directory", flagOverrideName)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+func runCmd(cmd *cobra.Command, args []string) error {
+	var err error
+	switch {
+	case flagVerify:
+		err = verifyTools()
+	case flagExport != "":
+		err = exportTools()
+	case flagOverride != "":
+		err = overrideTool()
+	case flagClean:
+		err = cleanRemote(cmd)
+	default:
+		err = listTools()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		slog.Error(err.Error())
+		cmd.SilenceUsage = true
+	}
+	return err
+}
+
+func listTools() error {
+	tools, err := script.ListTools(flagArch)
+	if err != nil {
+		return fmt.Errorf("failed to list tools: %v", err)
+	}
+	if len(tools) == 0 {
+		fmt.Println("No tools found.")
+		return nil
+	}
+	rows := [][]string{{"ARCH", "TOOL", "SOURCE", "SHA256"}}
+	for _, tool := range tools {
+		source := tool.Source
+		if tool.Source == script.ToolSourceOverride && tool.Bundled {
+			source += " (of bundled)"
+		}
+		rows = append(rows, []string{tool.Architecture, tool.Name, source, tool.SHA256})
+	}
+	printRows(rows)
+	return nil
+}
+
+func verifyTools() error {
+	checks, err := script.VerifyTools(flagArch)
+	if err != nil {
+		return fmt.Errorf("failed to verify tools: %v", err)
+	}
+	rows := [][]string{{"ARCH", "FILE", "SOURCE", "STATUS"}}
+	var mismatched []string
+	unverified := 0
+	for _, check := range checks {
+		rows = append(rows, []string{check.Tool.Architecture, check.File, check.Tool.Source, check.Status})
+		switch check.Status {
+		case script.ChecksumMismatch:
+			mismatched = append(mismatched, check.Tool.Architecture+"/"+check.File)
+		case script.ChecksumMissing:
+			unverified++
+		}
+	}
+	printRows(rows)
+	if unverified > 0 {
+		fmt.Printf("\n%d file(s) not listed in a %s file could not be verified.\n", unverified, script.ChecksumsFileName)
+	}
+	if len(mismatched) > 0 {
+		return fmt.Errorf("checksum mismatch: %s", strings.Join(mismatched, ", "))
+	}
+	return nil
+}
+
+func exportTools() error {
+	paths, err := script.ExportTools(flagArch, flagExport)
+	if err != nil {
+		return fmt.Errorf("failed to export tools: %v", err)
+	}
+	fmt.Println("Exported files:")
+	for _, path := range paths {
+		fmt.Printf("  %s\n", path)
+	}
+	return nil
+}
+
+func overrideTool() error {
+	arch := flagArch
+	if arch == "" {
+		arch = "x86_64"
+	}
+	tool, err := script.OverrideTool(arch, flagOverride)
+	if err != nil {
+		return fmt.Errorf("failed to override tool: %v", err)
+	}
+	fmt.Printf("%s will be used in place of the bundled %s for %s, SHA256: %s\n", tool.Path, tool.Name, tool.Architecture, tool.SHA256)
+	return nil
+}
+
+func cleanRemote(cmd *cobra.Command) error {
+	appContext := cmd.Context().Value(common.AppContext{}).(common.AppContext)
+	localTempDir := appContext.TempDir
+	myTargets, targetErrs, err := common.GetTargets(cmd, false, false, localTempDir)
+	if err != nil {
+		return err
+	}
+	targetTempRoot, _ := cmd.Flags().GetString(common.FlagTargetTempDirName)
+	var failed []string
+	for i, myTarget := range myTargets {
+		err = targetErrs[i]
+		if err == nil {
+			err = cleanTarget(cmd, myTarget, targetTempRoot, localTempDir)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", myTarget.GetName(), err)
+			slog.Error(err.Error(), slog.String("target", myTarget.GetName()))
+			failed = append(failed, myTarget.GetName())
+			continue
+		}
+		fmt.Printf("Removed the tool cache from %s\n", myTarget.GetName())
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to remove the tool cache from: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func cleanTarget(cmd *cobra.Command, myTarget target.Target, targetTempRoot string, localTempDir string) (err error) {
+	targetTempDir, err := myTarget.CreateTempDirectory(targetTempRoot)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer func() {
+		if err := myTarget.RemoveDirectory(targetTempDir); err != nil {
+			slog.Error("error removing target temporary directory", slog.String("error", err.Error()))
+		}
+	}()
+	return script.CleanToolCache(cmd.Context(), myTarget, localTempDir)
+}
+
+// printRows prints the rows in columns, the first row is the heading
+func printRows(rows [][]string) {
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, value := range row {
+			widths[i] = max(widths[i], len(value))
+		}
+	}
+	for _, row := range rows {
+		var sb strings.Builder
+		for i, value := range row {
+			if i < len(row)-1 {
+				sb.WriteString(fmt.Sprintf("%-*s  ", widths[i], value))
+			} else {
+				sb.WriteString(value)
+			}
+		}
+		fmt.Println(sb.String())
+	}
+}
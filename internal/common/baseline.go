@@ -0,0 +1,234 @@
+package common
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// baseline.go checks the values in the reports against a baseline, i.e., a golden configuration, of expected values
+// and ranges, e.g., the scaling governor and the uncore frequency
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"perfspect/internal/report"
+	"perfspect/internal/util"
+)
+
+const TableNameCompliance = "Compliance"
+
+// the results of the baseline rules
+const (
+	CompliancePass = "pass"
+	ComplianceFail = "fail"
+)
+
+// BaselineRule is the expected value, or range of values, of a field in a table. The field's value must equal Value,
+// if set, and be one of Values, if set. The first number in the field's value must be within Min and Max, if set. A
+// table with rows, e.g., NIC, must have the expected value in every row.
+type BaselineRule struct {
+	Table  string   `yaml:"table"`
+	Field  string   `yaml:"field"`
+	Value  string   `yaml:"value"`
+	Values []string `yaml:"values"`
+	Min    *float64 `yaml:"min"`
+	Max    *float64 `yaml:"max"`
+	// Microarchitectures limits the rule to the targets with these microarchitectures, e.g., SPR, EMR
+	Microarchitectures []string `yaml:"microarchitectures"`
+}
+
+// Baseline is the golden configuration that the targets are checked against
+type Baseline struct {
+	Rules []BaselineRule `yaml:"rules"`
+}
+
+// LoadBaseline reads and validates the baseline file. Plugin tables must be added first, if the rules refer to them.
+func LoadBaseline(path string) (baseline *Baseline, err error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		err = fmt.Errorf("failed to read baseline file: %v", err)
+		return
+	}
+	baseline = &Baseline{}
+	if err = yaml.UnmarshalStrict(contents, baseline); err != nil {
+		err = fmt.Errorf("failed to parse baseline file %s: %v", path, err)
+		return
+	}
+	if len(baseline.Rules) == 0 {
+		err = fmt.Errorf("baseline file %s has no rules", path)
+		return
+	}
+	for i, rule := range baseline.Rules {
+		if err = rule.validate(); err != nil {
+			err = fmt.Errorf("baseline file %s: rule %d: %v", path, i+1, err)
+			return
+		}
+	}
+	return
+}
+
+func (rule BaselineRule) validate() error {
+	if rule.Table == "" || rule.Field == "" {
+		return fmt.Errorf("table and field are required")
+	}
+	if !report.HasTable(rule.Table) {
+		return fmt.Errorf("table not found: %s", rule.Table)
+	}
+	// the fields of tables whose fields depend on the data, e.g., a column per device, can't be checked here
+	if fieldNames := report.GetFieldNames(rule.Table); len(fieldNames) > 0 && !slices.Contains(fieldNames, rule.Field) {
+		return fmt.Errorf("field not found in table %s: %s", rule.Table, rule.Field)
+	}
+	if rule.Value == "" && len(rule.Values) == 0 && rule.Min == nil && rule.Max == nil {
+		return fmt.Errorf("%s %s: one of value, values, min, or max is required", rule.Table, rule.Field)
+	}
+	if rule.Min != nil && rule.Max != nil && *rule.Min > *rule.Max {
+		return fmt.Errorf("%s %s: min is greater than max", rule.Table, rule.Field)
+	}
+	return nil
+}
+
+// TableNames returns the tables that the rules check, they must be in the reports
+func (baseline *Baseline) TableNames() (tableNames []string) {
+	for _, rule := range baseline.Rules {
+		tableNames = util.UniqueAppend(tableNames, rule.Table)
+		if len(rule.Microarchitectures) > 0 {
+			tableNames = util.UniqueAppend(tableNames, report.CPUTableName)
+		}
+	}
+	return
+}
+
+// Evaluate checks the table values against the rules. It returns the Compliance table, with the result of each rule
+// that applies to the target, and the number of rules that failed.
+func (baseline *Baseline) Evaluate(allTableValues []report.TableValues) (complianceTableValues report.TableValues, violations int) {
+	complianceTableValues = report.TableValues{
+		TableDefinition: report.TableDefinition{
+			Name:      TableNameCompliance,
+			HasRows:   true,
+			MenuLabel: TableNameCompliance,
+		},
+		Fields: []report.Field{
+			{Name: "Table", Values: []string{}},
+			{Name: "Field", Values: []string{}},
+			{Name: "Expected", Values: []string{}},
+			{Name: "Actual", Values: []string{}},
+			{Name: "Result", Values: []string{}},
+		},
+	}
+	uarch := baselineFieldValues(allTableValues, report.CPUTableName, "Microarchitecture")
+	for _, rule := range baseline.Rules {
+		if len(rule.Microarchitectures) > 0 && (len(uarch) == 0 || !slices.ContainsFunc(rule.Microarchitectures, func(u string) bool { return strings.EqualFold(u, uarch[0]) })) {
+			continue
+		}
+		values := baselineFieldValues(allTableValues, rule.Table, rule.Field)
+		result := CompliancePass
+		if len(values) == 0 {
+			result = ComplianceFail
+		}
+		var actual []string
+		for _, value := range values {
+			if !rule.check(value) {
+				result = ComplianceFail
+			}
+			actual = util.UniqueAppend(actual, value)
+		}
+		if result == ComplianceFail {
+			violations++
+		}
+		if len(values) == 0 {
+			actual = []string{"not found"}
+		}
+		for i, value := range []string{rule.Table, rule.Field, rule.expected(), strings.Join(actual, ", "), result} {
+			complianceTableValues.Fields[i].Values = append(complianceTableValues.Fields[i].Values, value)
+		}
+	}
+	return
+}
+
+// baselineFieldValues returns the field's values, or nil if the table or field isn't in the table values
+func baselineFieldValues(allTableValues []report.TableValues, tableName string, fieldName string) []string {
+	for _, tableValues := range allTableValues {
+		if tableValues.Name != tableName {
+			continue
+		}
+		for _, field := range tableValues.Fields {
+			if field.Name == fieldName {
+				return field.Values
+			}
+		}
+	}
+	return nil
+}
+
+// firstNumberRegex matches the first number in a value, e.g., 2.4 in 2.4GHz
+var firstNumberRegex = regexp.MustCompile(`[-+]?\d*\.?\d+`)
+
+// check returns true if the value is expected
+func (rule BaselineRule) check(value string) bool {
+	value = strings.TrimSpace(value)
+	if rule.Value != "" && !strings.EqualFold(value, rule.Value) {
+		return false
+	}
+	if len(rule.Values) > 0 && !slices.ContainsFunc(rule.Values, func(v string) bool { return strings.EqualFold(value, v) }) {
+		return false
+	}
+	if rule.Min != nil || rule.Max != nil {
+		number, err := strconv.ParseFloat(firstNumberRegex.FindString(value), 64)
+		if err != nil {
+			return false
+		}
+		if (rule.Min != nil && number < *rule.Min) || (rule.Max != nil && number > *rule.Max) {
+			return false
+		}
+	}
+	return true
+}
+
+// expected describes the expected value, e.g., "performance" or "between 1.8 and 2.5"
+func (rule BaselineRule) expected() string {
+	var expected []string
+	if rule.Value != "" {
+		expected = append(expected, rule.Value)
+	}
+	if len(rule.Values) > 0 {
+		expected = append(expected, "one of "+strings.Join(rule.Values, ", "))
+	}
+	switch {
+	case rule.Min != nil && rule.Max != nil:
+		expected = append(expected, fmt.Sprintf("between %g and %g", *rule.Min, *rule.Max))
+	case rule.Min != nil:
+		expected = append(expected, fmt.Sprintf("at least %g", *rule.Min))
+	case rule.Max != nil:
+		expected = append(expected, fmt.Sprintf("at most %g", *rule.Max))
+	}
+	return strings.Join(expected, "; ")
+}
+
+// baselineError returns the error that fails the run if any target violated the baseline rules, or wasn't checked
+// against them because collection didn't succeed on it, e.g., it couldn't be connected to, timed out, or was
+// abandoned. violatingTargets are the targets that violated rules, with the number of violations, and results are
+// the outcomes of the work on the targets.
+func baselineError(violatingTargets []string, results []TargetResult) error {
+	var problems []string
+	if len(violatingTargets) > 0 {
+		problems = append(problems, fmt.Sprintf("baseline violations, see the %s table: %s", TableNameCompliance, strings.Join(violatingTargets, ", ")))
+	}
+	var uncheckedTargets []string
+	for _, result := range results {
+		if result.Status != TargetStatusSucceeded {
+			uncheckedTargets = append(uncheckedTargets, fmt.Sprintf("%s (%s)", result.TargetName, result.Status))
+		}
+	}
+	if len(uncheckedTargets) > 0 {
+		problems = append(problems, fmt.Sprintf("targets not checked against the baseline: %s", strings.Join(uncheckedTargets, ", ")))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(problems, "; "))
+}
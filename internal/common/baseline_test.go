@@ -0,0 +1,139 @@
+package common
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"os"
+	"path/filepath"
+	"perfspect/internal/report"
+	"reflect"
+	"testing"
+)
+
+const testBaseline = `rules:
+  - table: Power
+    field: Scaling Governor
+    value: performance
+  - table: Memory
+    field: Transparent Huge Pages
+    values: [always, madvise]
+  - table: Uncore
+    field: Max Frequency
+    min: 2.0
+    max: 2.5
+  - table: NIC
+    field: Speed
+    min: 25000
+  - table: Memory
+    field: Automatic NUMA Balancing
+    value: Disabled
+  - table: Power
+    field: Energy Performance Preference
+    value: Performance (0)
+    microarchitectures: [SPR, EMR]
+  - table: Power
+    field: Energy Performance Bias
+    value: Performance (0)
+    microarchitectures: [GNR]
+`
+
+func TestLoadBaseline(t *testing.T) {
+	dir := t.TempDir()
+	for _, invalid := range []string{
+		"rules: []\n",
+		"rules:\n  - table: Unittest Missing\n    field: Value\n    value: 1\n",
+		"rules:\n  - table: Power\n    field: Scaling Governor\n",
+		"rules:\n  - table: Uncore\n    field: Max Frequency\n    min: 3\n    max: 2\n",
+		"rules:\n  - table: Power\n    field: Scaling Governor\n    expected: performance\n",
+		"rules:\n  - table: NIC\n    field: Unittest Missing\n    value: 1\n",
+	} {
+		path := filepath.Join(dir, "invalid.yaml")
+		if err := os.WriteFile(path, []byte(invalid), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadBaseline(path); err == nil {
+			t.Errorf("expected error for baseline:\n%s", invalid)
+		}
+	}
+	path := filepath.Join(dir, "golden.yaml")
+	if err := os.WriteFile(path, []byte(testBaseline), 0644); err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tableNames := baseline.TableNames(); !reflect.DeepEqual(tableNames, []string{report.PowerTableName, report.MemoryTableName, report.UncoreTableName, report.NICTableName, report.CPUTableName}) {
+		t.Errorf("unexpected table names: %v", tableNames)
+	}
+	table := func(name string, fields ...report.Field) report.TableValues {
+		return report.TableValues{TableDefinition: report.TableDefinition{Name: name}, Fields: fields}
+	}
+	allTableValues := []report.TableValues{
+		table(report.CPUTableName, report.Field{Name: "Microarchitecture", Values: []string{"SPR"}}),
+		table(report.PowerTableName, report.Field{Name: "Scaling Governor", Values: []string{"performance"}}, report.Field{Name: "Energy Performance Preference", Values: []string{"Balance Performance (128)"}}),
+		table(report.MemoryTableName, report.Field{Name: "Transparent Huge Pages", Values: []string{"never"}}),
+		table(report.UncoreTableName, report.Field{Name: "Max Frequency", Values: []string{"2.4GHz"}}),
+		table(report.NICTableName, report.Field{Name: "Speed", Values: []string{"25000Mb/s", "10000Mb/s"}}),
+	}
+	complianceTableValues, violations := baseline.Evaluate(allTableValues)
+	if violations != 4 {
+		t.Errorf("unexpected violations: %d", violations)
+	}
+	want := [][]string{
+		{"Power", "Memory", "Uncore", "NIC", "Memory", "Power"},
+		{"Scaling Governor", "Transparent Huge Pages", "Max Frequency", "Speed", "Automatic NUMA Balancing", "Energy Performance Preference"},
+		{"performance", "one of always, madvise", "between 2 and 2.5", "at least 25000", "Disabled", "Performance (0)"},
+		{"performance", "never", "2.4GHz", "25000Mb/s, 10000Mb/s", "not found", "Balance Performance (128)"},
+		{CompliancePass, ComplianceFail, CompliancePass, ComplianceFail, ComplianceFail, ComplianceFail},
+	}
+	for i, field := range complianceTableValues.Fields {
+		if !reflect.DeepEqual(field.Values, want[i]) {
+			t.Errorf("unexpected %s values: got %v, want %v", field.Name, field.Values, want[i])
+		}
+	}
+}
+
+func TestBaselineError(t *testing.T) {
+	tests := []struct {
+		name             string
+		violatingTargets []string
+		results          []TargetResult
+		want             string
+	}{
+		{
+			name:    "all checked",
+			results: []TargetResult{{TargetName: "a", Status: TargetStatusSucceeded}},
+		},
+		{
+			name:             "violations",
+			violatingTargets: []string{"a (2)"},
+			results:          []TargetResult{{TargetName: "a", Status: TargetStatusSucceeded}},
+			want:             "baseline violations, see the Compliance table: a (2)",
+		},
+		{
+			name:    "not checked",
+			results: []TargetResult{{TargetName: "a", Status: TargetStatusSucceeded}, {TargetName: "b", Status: TargetStatusFailed}, {TargetName: "c", Status: TargetStatusTimedOut}},
+			want:    "targets not checked against the baseline: b (failed), c (timed out)",
+		},
+		{
+			name:             "violations and not checked",
+			violatingTargets: []string{"a (1)"},
+			results:          []TargetResult{{TargetName: "a", Status: TargetStatusSucceeded}, {TargetName: "b", Status: TargetStatusFailed}},
+			want:             "baseline violations, see the Compliance table: a (1); targets not checked against the baseline: b (failed)",
+		},
+	}
+	for _, test := range tests {
+		err := baselineError(test.violatingTargets, test.results)
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%s: unexpected error: got %v, want %s", test.name, err, test.want)
+		}
+	}
+}
@@ -5,6 +5,7 @@ package common
 // SPDX-License-Identifier: BSD-3-Clause
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
@@ -15,6 +16,8 @@ import (
 	"perfspect/internal/script"
 	"perfspect/internal/target"
 	"perfspect/internal/util"
+	"slices"
+	"sync"
 	"syscall"
 
 	"github.com/spf13/cobra"
@@ -24,9 +27,10 @@ var AppName = filepath.Base(os.Args[0])
 
 // AppContext represents the application context that can be accessed from all commands.
 type AppContext struct {
-	OutputDir string // OutputDir is the directory where the application will write output files.
-	TempDir   string // TempDir is the local host's temp directory.
-	Version   string // Version is the version of the application.
+	OutputDir string    // OutputDir is the directory where the application will write output files.
+	TempDir   string    // TempDir is the local host's temp directory.
+	Version   string    // Version is the version of the application.
+	Manifest  *Manifest // Manifest records the run, it is written to the output directory when the command completes.
 }
 
 type Flag struct {
@@ -57,13 +61,17 @@ type Category struct {
 }
 
 var (
-	FlagInput  string
-	FlagFormat []string
+	FlagInput       string
+	FlagFormat      []string
+	FlagDiagnostics bool
+	FlagDryRun      bool
 )
 
 const (
-	FlagInputName  = "input"
-	FlagFormatName = "format"
+	FlagInputName       = "input"
+	FlagFormatName      = "format"
+	FlagDiagnosticsName = "diagnostics"
+	FlagDryRunName      = "dry-run"
 )
 
 func CreateOutputDir(outputDir string) error {
@@ -86,6 +94,7 @@ type ReportingCommand struct {
 	SummaryFunc      SummaryFunc
 	SummaryTableName string
 	InsightsFunc     InsightsFunc
+	Baseline         *Baseline // the reports are checked against the baseline, if set
 }
 
 func (rc *ReportingCommand) Run() error {
@@ -93,17 +102,30 @@ func (rc *ReportingCommand) Run() error {
 	appContext := rc.Cmd.Context().Value(AppContext{}).(AppContext)
 	localTempDir := appContext.TempDir
 	outputDir := appContext.OutputDir
+	// runner runs the data collection on the targets, it is nil when reports are created from raw files
+	var runner *TargetRunner
 	// handle signals
-	// child processes will exit when the signals are received which will
-	// allow this app to exit normally
+	// the commands running on the targets are interrupted when the signals are received
+	// which will allow this app to exit normally
+	ctx, cancel := context.WithCancel(rc.Cmd.Context())
+	defer cancel()
 	sigChannel := make(chan os.Signal, 1)
 	signal.Notify(sigChannel, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		sig := <-sigChannel
 		slog.Info("received signal", slog.String("signal", sig.String()))
+		cancel()
 	}()
 	// get the data we need to generate reports
 	var orderedTargetScriptOutputs []TargetScriptOutputs
+	var partialTargetScriptOutputs []TargetScriptOutputs // from targets where collection failed
+	if FlagInput != "" && FlagDryRun {
+		err := fmt.Errorf("--%s can't be used with --%s, nothing is run on the targets when reports are created from raw files", FlagDryRunName, FlagInputName)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		slog.Error(err.Error())
+		rc.Cmd.SilenceUsage = true
+		return err
+	}
 	if FlagInput != "" {
 		// read the raw file(s) as JSON
 		rawReports, err := report.ReadRawReports(FlagInput)
@@ -116,15 +138,35 @@ func (rc *ReportingCommand) Run() error {
 		}
 		rc.TableNames = []string{} // use the table names from the raw files
 		for _, rawReport := range rawReports {
+			// add the plugin tables that were stored in the raw file, unless they were loaded from plugins
+			var pluginTables []report.PluginTableDefinition
+			for _, pluginTable := range rawReport.PluginTables {
+				if !report.HasTable(pluginTable.Name) {
+					pluginTables = append(pluginTables, pluginTable)
+				}
+			}
+			if err := report.AddPluginTables(pluginTables); err != nil {
+				err = fmt.Errorf("failed to add plugin tables from raw file: %w", err)
+				fmt.Fprintf(os.Stderr, "Error: %+v\n", err)
+				slog.Error(err.Error())
+				rc.Cmd.SilenceUsage = true
+				return err
+			}
 			for _, tableName := range rawReport.TableNames { // just in case someone tries to use the raw files that were collected with a different set of categories
 				// filter out tables that we add after processing
-				if tableName == TableNameInsights || tableName == TableNamePerfspect || tableName == rc.SummaryTableName {
+				if tableName == TableNameInsights || tableName == TableNamePerfspect || tableName == report.SkippedDataTableName || tableName == report.DiagnosticsTableName || tableName == rc.SummaryTableName {
 					continue
 				}
 				rc.TableNames = util.UniqueAppend(rc.TableNames, tableName)
 			}
 			orderedTargetScriptOutputs = append(orderedTargetScriptOutputs, TargetScriptOutputs{targetName: rawReport.TargetName, scriptOutputs: rawReport.ScriptOutputs})
 		}
+		// the baseline's tables are checked even if they weren't collected, so that missing values are reported
+		if rc.Baseline != nil {
+			for _, tableName := range rc.Baseline.TableNames() {
+				rc.TableNames = util.UniqueAppend(rc.TableNames, tableName)
+			}
+		}
 	} else {
 		// get the list of unique scripts to run and tables we're interested in
 		var scriptNames []string
@@ -147,14 +189,24 @@ func (rc *ReportingCommand) Run() error {
 				break
 			}
 		}
-		// get the targets
-		myTargets, targetErrs, err := GetTargets(rc.Cmd, elevated, false, localTempDir)
+		// get the targets, a dry run reports the privileges the scripts need instead of checking for, or prompting for, them
+		myTargets, targetErrs, err := GetTargets(rc.Cmd, elevated && !FlagDryRun, false, localTempDir)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			slog.Error(err.Error())
 			rc.Cmd.SilenceUsage = true
 			return err
 		}
+		// print what would be run on the targets, instead of running it
+		if FlagDryRun {
+			if err := DryRun(myTargets, targetErrs, scriptsToRun, outputDir); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				slog.Error(err.Error())
+				rc.Cmd.SilenceUsage = true
+				return err
+			}
+			return nil
+		}
 		// setup and start the progress indicator
 		multiSpinner := progress.NewMultiSpinner()
 		for _, target := range myTargets {
@@ -167,16 +219,22 @@ func (rc *ReportingCommand) Run() error {
 			}
 		}
 		multiSpinner.Start()
+		runner = NewTargetRunner(rc.Cmd, multiSpinner.Status)
 		// check for errors in target creation
+		var reachableTargets []target.Target
 		for i := range targetErrs {
 			if targetErrs[i] != nil {
 				_ = multiSpinner.Status(myTargets[i].GetName(), fmt.Sprintf("Error: %v", targetErrs[i]))
-				// remove target from targets list
-				myTargets = append(myTargets[:i], myTargets[i+1:]...)
+				runner.Fail(myTargets[i].GetName(), targetErrs[i])
+				continue
 			}
+			reachableTargets = append(reachableTargets, myTargets[i])
 		}
+		myTargets = reachableTargets
 		// check if we have any targets to run the scripts on
 		if len(myTargets) == 0 {
+			multiSpinner.Finish()
+			runner.PrintSummary(os.Stderr)
 			err := fmt.Errorf("no targets specified")
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			slog.Error(err.Error())
@@ -184,31 +242,9 @@ func (rc *ReportingCommand) Run() error {
 			return err
 		}
 		// run the scripts on the targets
-		channelTargetScriptOutputs := make(chan TargetScriptOutputs)
-		channelError := make(chan error)
-		for _, target := range myTargets {
-			go collectOnTarget(rc.Cmd, rc.Duration, target, scriptsToRun, localTempDir, channelTargetScriptOutputs, channelError, multiSpinner.Status)
-		}
-		// wait for scripts to run on all targets
-		var allTargetScriptOutputs []TargetScriptOutputs
-		for range myTargets {
-			select {
-			case scriptOutputs := <-channelTargetScriptOutputs:
-				allTargetScriptOutputs = append(allTargetScriptOutputs, scriptOutputs)
-			case err := <-channelError:
-				slog.Error(err.Error())
-			}
-		}
-		// allTargetScriptOutputs is in the order of data collection completion
-		// reorder to match order of myTargets
-		for _, target := range myTargets {
-			for _, targetScriptOutputs := range allTargetScriptOutputs {
-				if targetScriptOutputs.targetName == target.GetName() {
-					orderedTargetScriptOutputs = append(orderedTargetScriptOutputs, targetScriptOutputs)
-					break
-				}
-			}
-		}
+		orderedTargetScriptOutputs, partialTargetScriptOutputs = collectOnTargets(ctx, runner, myTargets, func(ctx context.Context, myTarget target.Target) (TargetScriptOutputs, error) {
+			return collectOnTarget(ctx, rc.Cmd, rc.Duration, myTarget, scriptsToRun, localTempDir, runner.Status)
+		})
 		multiSpinner.Finish()
 		fmt.Println()
 	}
@@ -220,7 +256,8 @@ func (rc *ReportingCommand) Run() error {
 		return err
 	}
 	// create the raw report before processing the data, so that we can save the raw data even if there is an error while processing
-	for _, targetScriptOutputs := range orderedTargetScriptOutputs {
+	for i, targetScriptOutputs := range slices.Concat(orderedTargetScriptOutputs, partialTargetScriptOutputs) {
+		partial := i >= len(orderedTargetScriptOutputs)
 		reportBytes, err := report.CreateRawReport(rc.TableNames, targetScriptOutputs.scriptOutputs, targetScriptOutputs.targetName)
 		if err != nil {
 			err = fmt.Errorf("failed to create raw report: %w", err)
@@ -233,6 +270,10 @@ func (rc *ReportingCommand) Run() error {
 		if rc.ReportNamePost != "" {
 			post = "_" + rc.ReportNamePost
 		}
+		// the data from targets where collection failed is saved, so that it can be used with --input
+		if partial {
+			post += "_partial"
+		}
 		reportFilename := fmt.Sprintf("%s%s.%s", targetScriptOutputs.targetName, post, "raw")
 		reportPath := filepath.Join(appContext.OutputDir, reportFilename)
 		if err = report.WriteReport(reportBytes, reportPath); err != nil {
@@ -242,6 +283,9 @@ func (rc *ReportingCommand) Run() error {
 			rc.Cmd.SilenceUsage = true
 			return err
 		}
+		if partial {
+			fmt.Fprintf(os.Stderr, "Partial data collected from %s: %s\n", targetScriptOutputs.targetName, reportPath)
+		}
 	}
 	// check report formats
 	formats := FlagFormat
@@ -251,6 +295,7 @@ func (rc *ReportingCommand) Run() error {
 	// process the collected data and create the requested report(s)
 	allTargetsTableValues := make([][]report.TableValues, 0)
 	var reportFilePaths []string
+	var violatingTargets []string // the targets that failed baseline rules, with the number of failed rules
 	for _, targetScriptOutputs := range orderedTargetScriptOutputs {
 		scriptOutputs := targetScriptOutputs.scriptOutputs
 		// process the tables, i.e., get field values from script output
@@ -278,17 +323,41 @@ func (rc *ReportingCommand) Run() error {
 			insightsTableValues := rc.InsightsFunc(allTableValues, targetScriptOutputs.scriptOutputs)
 			allTableValues = append(allTableValues, insightsTableValues)
 		}
+		// special case - add tableValues for the baseline compliance checks
+		if rc.Baseline != nil {
+			complianceTableValues, violations := rc.Baseline.Evaluate(allTableValues)
+			allTableValues = append(allTableValues, complianceTableValues)
+			if violations > 0 {
+				violatingTargets = append(violatingTargets, fmt.Sprintf("%s (%d)", targetScriptOutputs.targetName, violations))
+			}
+		}
+		// special case - add tableValues for the data that wasn't collected
+		allTableValues = append(allTableValues, report.GetSkippedDataTableValues(rc.TableNames, scriptOutputs))
+		// special case - add tableValues for how the data was collected, if requested
+		if FlagDiagnostics {
+			allTableValues = append(allTableValues, report.GetCollectionDiagnosticsTableValues(rc.TableNames, scriptOutputs))
+		}
 		// special case - add tableValues for the application version
-		allTableValues = append(allTableValues, report.TableValues{
-			TableDefinition: report.TableDefinition{
-				Name: TableNamePerfspect,
-			},
-			Fields: []report.Field{
-				{Name: "Version", Values: []string{appContext.Version}},
-			},
-		})
+		allTableValues = append(allTableValues, PerfspectVersionTableValues(appContext.Version))
+		post := ""
+		if rc.ReportNamePost != "" {
+			post = "_" + rc.ReportNamePost
+		}
 		// create the report(s)
 		for _, format := range formats {
+			// the tables are exported to a directory, a file per table
+			if util.StringInList(format, report.TableFormatOptions) {
+				exportDir := filepath.Join(appContext.OutputDir, fmt.Sprintf("%s%s_%s", targetScriptOutputs.targetName, post, format))
+				if err := exportTables([][]report.TableValues{allTableValues}, []string{targetScriptOutputs.targetName}, exportDir); err != nil {
+					err = fmt.Errorf("failed to export tables: %w", err)
+					fmt.Fprintf(os.Stderr, "Error: %+v\n", err)
+					slog.Error(err.Error())
+					rc.Cmd.SilenceUsage = true
+					return err
+				}
+				reportFilePaths = append(reportFilePaths, exportDir)
+				continue
+			}
 			reportBytes, err := report.Create(format, allTableValues, scriptOutputs, targetScriptOutputs.targetName)
 			if err != nil {
 				err = fmt.Errorf("failed to create report: %w", err)
@@ -301,10 +370,6 @@ func (rc *ReportingCommand) Run() error {
 				fmt.Printf("%s:\n", targetScriptOutputs.targetName)
 				fmt.Print(string(reportBytes))
 			}
-			post := ""
-			if rc.ReportNamePost != "" {
-				post = "_" + rc.ReportNamePost
-			}
 			reportFilename := fmt.Sprintf("%s%s.%s", targetScriptOutputs.targetName, post, format)
 			reportPath := filepath.Join(appContext.OutputDir, reportFilename)
 			if err = report.WriteReport(reportBytes, reportPath); err != nil {
@@ -326,7 +391,7 @@ func (rc *ReportingCommand) Run() error {
 		for _, targetScriptOutputs := range orderedTargetScriptOutputs {
 			targetNames = append(targetNames, targetScriptOutputs.targetName)
 		}
-		multiTargetFormats := []string{report.FormatHtml, report.FormatXlsx}
+		multiTargetFormats := []string{report.FormatHtml, report.FormatXlsx, report.FormatMd, report.FormatAdoc}
 		for _, format := range multiTargetFormats {
 			if !util.StringInList(format, formats) {
 				continue
@@ -350,6 +415,21 @@ func (rc *ReportingCommand) Run() error {
 			}
 			reportFilePaths = append(reportFilePaths, reportPath)
 		}
+		// the tables of all the targets are exported to a directory, a file per table
+		for _, format := range report.TableFormatOptions {
+			if !util.StringInList(format, formats) {
+				continue
+			}
+			exportDir := filepath.Join(appContext.OutputDir, fmt.Sprintf("%s_%s", "all_hosts", format))
+			if err := exportTables(allTargetsTableValues, targetNames, exportDir); err != nil {
+				err = fmt.Errorf("failed to export multi-target tables: %w", err)
+				fmt.Fprintf(os.Stderr, "Error: %+v\n", err)
+				slog.Error(err.Error())
+				rc.Cmd.SilenceUsage = true
+				return err
+			}
+			reportFilePaths = append(reportFilePaths, exportDir)
+		}
 	}
 	if len(reportFilePaths) > 0 {
 		fmt.Println("Report files:")
@@ -357,10 +437,37 @@ func (rc *ReportingCommand) Run() error {
 	for _, reportFilePath := range reportFilePaths {
 		fmt.Printf("  %s\n", reportFilePath)
 	}
+	if runner != nil {
+		runner.PrintSummary(os.Stdout)
+	}
+	if rc.Baseline != nil {
+		var results []TargetResult
+		if runner != nil {
+			results = runner.Results()
+		}
+		if err := baselineError(violatingTargets, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			slog.Error(err.Error())
+			rc.Cmd.SilenceUsage = true
+			return err
+		}
+	}
 	return nil
 
 }
 
+// PerfspectVersionTableValues returns the table of the application's version, it is added to the reports
+func PerfspectVersionTableValues(version string) report.TableValues {
+	return report.TableValues{
+		TableDefinition: report.TableDefinition{
+			Name: TableNamePerfspect,
+		},
+		Fields: []report.Field{
+			{Name: "Version", Values: []string{version}},
+		},
+	}
+}
+
 func DefaultInsightsFunc(allTableValues []report.TableValues, scriptOutputs map[string]script.ScriptOutput) report.TableValues {
 	insightsTableValues := report.TableValues{
 		TableDefinition: report.TableDefinition{
@@ -382,16 +489,47 @@ func DefaultInsightsFunc(allTableValues []report.TableValues, scriptOutputs map[
 	return insightsTableValues
 }
 
-func collectOnTarget(cmd *cobra.Command, duration int, myTarget target.Target, scriptsToRun []script.ScriptDefinition, localTempDir string, channelTargetScriptOutputs chan TargetScriptOutputs, channelError chan error, statusUpdate progress.MultiSpinnerUpdateFunc) {
+// collectOnTargets runs collect on the targets through the runner. It returns the outputs of the targets where
+// collection succeeded and the partial outputs of the targets where it failed, in the order of myTargets. The
+// outputs of abandoned targets are dropped, because their collection may still be running.
+func collectOnTargets(ctx context.Context, runner *TargetRunner, myTargets []target.Target, collect func(context.Context, target.Target) (TargetScriptOutputs, error)) (orderedTargetScriptOutputs []TargetScriptOutputs, partialTargetScriptOutputs []TargetScriptOutputs) {
+	// the outputs are guarded because abandoned collection can still store its output after Run returns
+	var mutex sync.Mutex
+	allTargetScriptOutputs := make([]TargetScriptOutputs, len(myTargets))
+	runner.Run(ctx, myTargets, func(ctx context.Context, i int) error {
+		scriptOutputs, err := collect(ctx, myTargets[i])
+		mutex.Lock()
+		allTargetScriptOutputs[i] = scriptOutputs
+		mutex.Unlock()
+		if err != nil {
+			slog.Error(err.Error())
+			return err
+		}
+		return nil
+	})
+	mutex.Lock()
+	defer mutex.Unlock()
+	for i, myTarget := range myTargets {
+		if runner.Abandoned(myTarget.GetName()) {
+			continue
+		}
+		if runner.Succeeded(myTarget.GetName()) {
+			orderedTargetScriptOutputs = append(orderedTargetScriptOutputs, allTargetScriptOutputs[i])
+		} else if len(allTargetScriptOutputs[i].scriptOutputs) > 0 {
+			partialTargetScriptOutputs = append(partialTargetScriptOutputs, allTargetScriptOutputs[i])
+		}
+	}
+	return
+}
+
+func collectOnTarget(ctx context.Context, cmd *cobra.Command, duration int, myTarget target.Target, scriptsToRun []script.ScriptDefinition, localTempDir string, statusUpdate progress.MultiSpinnerUpdateFunc) (targetScriptOutputs TargetScriptOutputs, err error) {
 	// create a temporary directory on the target
 	var targetTempDir string
-	var err error
 	_ = statusUpdate(myTarget.GetName(), "creating temporary directory")
 	targetTempRoot, _ := cmd.Flags().GetString(FlagTargetTempDirName)
 	if targetTempDir, err = myTarget.CreateTempDirectory(targetTempRoot); err != nil {
 		_ = statusUpdate(myTarget.GetName(), fmt.Sprintf("error creating temporary directory: %v", err))
 		err = fmt.Errorf("error creating temporary directory on %s: %v", myTarget.GetName(), err)
-		channelError <- err
 		return
 	}
 	// don't remove the directory if we're debugging
@@ -409,13 +547,16 @@ func collectOnTarget(cmd *cobra.Command, duration int, myTarget target.Target, s
 		status = fmt.Sprintf("%s, duration=%ds", status, duration)
 	}
 	_ = statusUpdate(myTarget.GetName(), status)
-	scriptOutputs, err := script.RunScripts(myTarget, scriptsToRun, true, localTempDir)
+	scriptOutputs, err := script.RunScriptsWithProgress(ctx, myTarget, scriptsToRun, true, localTempDir, func(finished int, total int) {
+		_ = statusUpdate(myTarget.GetName(), fmt.Sprintf("%s, %d/%d scripts complete", status, finished, total))
+	})
+	// the outputs of the scripts that finished are kept when there's an error
+	targetScriptOutputs = TargetScriptOutputs{targetName: myTarget.GetName(), scriptOutputs: scriptOutputs}
 	if err != nil {
 		_ = statusUpdate(myTarget.GetName(), fmt.Sprintf("error collecting data: %v", err))
 		err = fmt.Errorf("error running data collection scripts on %s: %v", myTarget.GetName(), err)
-		channelError <- err
 		return
 	}
 	_ = statusUpdate(myTarget.GetName(), "collection complete")
-	channelTargetScriptOutputs <- TargetScriptOutputs{targetName: myTarget.GetName(), scriptOutputs: scriptOutputs}
+	return
 }
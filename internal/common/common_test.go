@@ -0,0 +1,48 @@
+package common
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"context"
+	"fmt"
+	"perfspect/internal/script"
+	"perfspect/internal/target"
+	"testing"
+	"time"
+)
+
+func TestCollectOnTargets(t *testing.T) {
+	targets := newTestTargets(4)
+	status := &testStatus{statuses: make(map[string]string)}
+	runner := newTargetRunner(0, 100*time.Millisecond, status.update)
+	runner.abandonDelay = 100 * time.Millisecond
+	stored := make(chan struct{})
+	ordered, partial := collectOnTargets(context.Background(), runner, targets, func(ctx context.Context, myTarget target.Target) (TargetScriptOutputs, error) {
+		outputs := TargetScriptOutputs{targetName: myTarget.GetName(), scriptOutputs: map[string]script.ScriptOutput{}}
+		switch myTarget.GetName() {
+		case "target0":
+			// hangs, ignoring ctx, and returns its output after it is abandoned
+			defer close(stored)
+			time.Sleep(500 * time.Millisecond)
+			outputs.scriptOutputs["late"] = script.ScriptOutput{}
+			return outputs, nil
+		case "target1":
+			outputs.scriptOutputs["partial"] = script.ScriptOutput{}
+			return outputs, fmt.Errorf("broken")
+		case "target2":
+			return outputs, fmt.Errorf("broken")
+		}
+		return outputs, nil
+	})
+	// the abandoned collection stores its output after collectOnTargets returns, the race detector reports
+	// it if the output is read
+	<-stored
+	if len(ordered) != 1 || ordered[0].targetName != "target3" {
+		t.Errorf("unexpected outputs: %v", ordered)
+	}
+	// the failed target without output isn't included
+	if len(partial) != 1 || partial[0].targetName != "target1" {
+		t.Errorf("unexpected partial outputs: %v", partial)
+	}
+}
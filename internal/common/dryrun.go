@@ -0,0 +1,61 @@
+package common
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"perfspect/internal/script"
+	"perfspect/internal/target"
+	"perfspect/internal/util"
+)
+
+// DryRunFileName is the name of the file, in the output directory, that the dry run's plans are written to
+const DryRunFileName = "dryrun.json"
+
+// DryRunHelp is the help for the --dry-run flag of the commands that support it
+const DryRunHelp = "print what would be done on each target, without changing anything on the targets: the settings that would be changed, with the commands that read their values first, " +
+	"the scripts and the master scripts that run them at the same time, the kernel modules, the privileges, and the files that would be copied, including to the tool cache. " +
+	"Only these read-only commands are run on the targets: \"exit 0\", to check the connection, \"uname -m\" and lscpu, to read the processor's architecture, family, and model, " +
+	"and, with --" + flagCacheName + ", cat of the boot ID, host name, and process status, to identify the target's cache entries. The plans are also written to " + DryRunFileName + " in the output directory."
+
+// DryRun prints the plans for running the scripts on the targets and writes them, as JSON, to the output directory.
+// Targets that couldn't be reached have the error in place of a plan.
+func DryRun(myTargets []target.Target, targetErrs []error, scripts []script.ScriptDefinition, outputDir string) error {
+	return DryRunPlans(myTargets, targetErrs, func(myTarget target.Target) (script.Plan, error) {
+		return script.PlanScripts(myTarget, scripts)
+	}, outputDir)
+}
+
+// DryRunPlans is DryRun for the commands that plan more than running scripts, e.g., changing settings. plan returns
+// the plan for a target, it must not change anything on the target.
+func DryRunPlans(myTargets []target.Target, targetErrs []error, plan func(target.Target) (script.Plan, error), outputDir string) error {
+	plans := make([]script.Plan, len(myTargets))
+	for i, myTarget := range myTargets {
+		err := targetErrs[i]
+		if err == nil {
+			plans[i], err = plan(myTarget)
+		}
+		if err != nil {
+			plans[i] = script.Plan{Target: myTarget.GetName(), Error: err.Error()}
+		}
+		fmt.Println(plans[i].String())
+	}
+	if err := CreateOutputDir(outputDir); err != nil {
+		return err
+	}
+	plansBytes, err := json.MarshalIndent(plans, "", " ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal dry run: %v", err)
+	}
+	dryRunPath := filepath.Join(outputDir, DryRunFileName)
+	if err = os.WriteFile(dryRunPath, plansBytes, 0644); err != nil {
+		return fmt.Errorf("failed to write dry run: %v", err)
+	}
+	util.RecordFile(dryRunPath)
+	fmt.Printf("Dry run written to %s\n", dryRunPath)
+	return nil
+}
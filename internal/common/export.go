@@ -0,0 +1,125 @@
+package common
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// export.go writes the tables of the reports as flat files, a CSV file per table, for analytics pipelines,
+// e.g., to query the configuration and telemetry of a fleet with SQL
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"perfspect/internal/report"
+	"perfspect/internal/util"
+)
+
+// HostColumnName is the name of the column with the target's name in the exported tables
+const HostColumnName = "Host"
+
+// tableFileNameRegex matches the characters that are replaced in the tables' file names
+var tableFileNameRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// tableFileName returns the file name of the table, without the extension, e.g., core_turbo_frequency
+func tableFileName(tableName string) string {
+	return strings.Trim(tableFileNameRegex.ReplaceAllString(strings.ToLower(tableName), "_"), "_")
+}
+
+// exportTables writes a CSV file per table to dir. The tables of the targets are combined,
+// each row has the target's name in the Host column. A table with rows, e.g., a telemetry table, has a row for each
+// of the table's rows, and other tables have one row per target.
+func exportTables(allTargetsTableValues [][]report.TableValues, targetNames []string, dir string) (err error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		err = fmt.Errorf("failed to create directory: %v", err)
+		return
+	}
+	// the tables, in the order they first appear in the targets' reports
+	var tableNames []string
+	for _, allTableValues := range allTargetsTableValues {
+		for _, tableValues := range allTableValues {
+			tableNames = util.UniqueAppend(tableNames, tableValues.Name)
+		}
+	}
+	for _, tableName := range tableNames {
+		columnNames, columns := exportColumns(tableName, allTargetsTableValues, targetNames)
+		if len(columnNames) == 1 { // no fields, only the host
+			continue
+		}
+		var out bytes.Buffer
+		if err = writeCsv(&out, columnNames, columns); err != nil {
+			err = fmt.Errorf("failed to export table %s: %v", tableName, err)
+			return
+		}
+		if err = report.WriteReport(out.Bytes(), filepath.Join(dir, tableFileName(tableName)+"."+report.FormatCsv)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// exportColumns returns the columns of the table, the Host column and the fields of the targets' tables
+func exportColumns(tableName string, allTargetsTableValues [][]report.TableValues, targetNames []string) (columnNames []string, columns [][]string) {
+	columnNames = []string{HostColumnName}
+	var tables []report.TableValues
+	var hosts []string
+	for targetIdx, allTableValues := range allTargetsTableValues {
+		for _, tableValues := range allTableValues {
+			if tableValues.Name != tableName {
+				continue
+			}
+			tables = append(tables, tableValues)
+			hosts = append(hosts, targetNames[targetIdx])
+			for _, field := range tableValues.Fields {
+				columnNames = util.UniqueAppend(columnNames, field.Name)
+			}
+		}
+	}
+	columns = make([][]string, len(columnNames))
+	for tableIdx, tableValues := range tables {
+		if len(tableValues.Fields) == 0 {
+			continue
+		}
+		numRows := len(tableValues.Fields[0].Values)
+		if !tableValues.HasRows {
+			numRows = min(numRows, 1)
+		}
+		for row := 0; row < numRows; row++ {
+			columns[0] = append(columns[0], hosts[tableIdx])
+			for columnIdx, columnName := range columnNames[1:] {
+				var value string
+				for _, field := range tableValues.Fields {
+					if field.Name == columnName && row < len(field.Values) {
+						value = field.Values[row]
+						break
+					}
+				}
+				columns[columnIdx+1] = append(columns[columnIdx+1], value)
+			}
+		}
+	}
+	return
+}
+
+// writeCsv writes the columns as CSV, with a header row of the column names
+func writeCsv(out *bytes.Buffer, columnNames []string, columns [][]string) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(columnNames); err != nil {
+		return err
+	}
+	for row := 0; row < len(columns[0]); row++ {
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = column[row]
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
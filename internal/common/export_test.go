@@ -0,0 +1,64 @@
+package common
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"os"
+	"path/filepath"
+	"perfspect/internal/report"
+	"testing"
+)
+
+func TestExportTables(t *testing.T) {
+	table := func(name string, hasRows bool, fields ...report.Field) report.TableValues {
+		return report.TableValues{TableDefinition: report.TableDefinition{Name: name, HasRows: hasRows}, Fields: fields}
+	}
+	allTargetsTableValues := [][]report.TableValues{
+		{
+			table(report.CPUTableName, false, report.Field{Name: "CPU Model", Values: []string{"Xeon, \"Gold\""}}, report.Field{Name: "Sockets", Values: []string{"2"}}),
+			table(report.CPUUtilizationTableName, true, report.Field{Name: "Time", Values: []string{"10:00:00", "10:00:01"}}, report.Field{Name: "%usr", Values: []string{"1.5", "2.5"}}),
+			table(report.SkippedDataTableName, true),
+		},
+		{
+			table(report.CPUTableName, false, report.Field{Name: "CPU Model", Values: []string{"Xeon Platinum"}}, report.Field{Name: "Microcode", Values: []string{"0x1"}}),
+			table(report.CPUUtilizationTableName, true, report.Field{Name: "Time"}, report.Field{Name: "%usr"}),
+		},
+	}
+	dir := filepath.Join(t.TempDir(), "all_hosts_csv")
+	if err := exportTables(allTargetsTableValues, []string{"a", "b"}, dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"cpu.csv", "Host,CPU Model,Sockets,Microcode\na,\"Xeon, \"\"Gold\"\"\",2,\nb,Xeon Platinum,,0x1\n"},
+		{"cpu_utilization.csv", "Host,Time,%usr\na,10:00:00,1.5\na,10:00:01,2.5\n"},
+	}
+	for _, test := range tests {
+		got, err := os.ReadFile(filepath.Join(dir, test.file))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(got) != test.want {
+			t.Errorf("unexpected %s:\n%s\nwant:\n%s", test.file, got, test.want)
+		}
+	}
+	// tables without fields aren't exported
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("unexpected files: %v", entries)
+	}
+}
+
+func TestTableFileName(t *testing.T) {
+	for name, want := range map[string]string{
+		report.CoreTurboFrequencyTableName: "core_turbo_frequency",
+		report.CstateTableName:             "c_states",
+		"PCIe Slots (x16)":                 "pcie_slots_x16",
+	} {
+		if got := tableFileName(name); got != want {
+			t.Errorf("unexpected file name for %s: %s", name, got)
+		}
+	}
+}
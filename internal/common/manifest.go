@@ -0,0 +1,264 @@
+package common
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"perfspect/internal/script"
+	"perfspect/internal/target"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// ManifestFileName is the name of the file the run manifest is written to in the output directory
+const ManifestFileName = "manifest.json"
+
+// AuditLogFileName is the name of the file the audit log, the privileged actions that changed the state of the
+// targets, is written to in the output directory, see script.AuditLog
+const AuditLogFileName = "audit.json"
+
+// run status values
+const (
+	RunStatusSucceeded = "succeeded"
+	RunStatusFailed    = "failed"
+)
+
+// Manifest is a machine-readable record of a run: the command, the targets and what happened on
+// them, the scripts that were run, and the files that were generated. It is written to the output
+// directory at the end of the run, whether or not the run succeeded.
+type Manifest struct {
+	Command     string           `json:"command"`
+	CommandLine []string         `json:"command_line"`
+	Version     string           `json:"version"`
+	StartTime   time.Time        `json:"start_time"`
+	EndTime     time.Time        `json:"end_time"`
+	Status      string           `json:"status"`
+	Error       string           `json:"error,omitempty"`
+	Targets     []ManifestTarget `json:"targets"`
+	Files       []ManifestFile   `json:"files"`
+	mutex       sync.Mutex
+	runner      *TargetRunner
+	files       []string // the paths of the files that the run wrote, in the order they were first written
+}
+
+// ManifestTarget records what happened on a target. The status, error, and duration are those
+// reported by the TargetRunner, they are empty for commands that don't use one. The platform is
+// empty if no scripts were run on the target.
+type ManifestTarget struct {
+	Name      string           `json:"name"`
+	Connected bool             `json:"connected"`
+	Status    string           `json:"status,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Duration  float64          `json:"duration_seconds"`
+	Platform  *script.Platform `json:"platform,omitempty"`
+	Scripts   []ManifestScript `json:"scripts"`
+	Tools     []script.Tool    `json:"tools,omitempty"` // the tools that were copied to the target to run scripts
+}
+
+// ManifestScript records a script that was run on a target.
+type ManifestScript struct {
+	Name     string  `json:"name"`
+	ExitCode int     `json:"exit_code"`
+	Duration float64 `json:"duration_seconds"`
+	Variant  string  `json:"variant,omitempty"`  // the fallback that produced the output, if the script didn't
+	Attempts int     `json:"attempts,omitempty"` // the number of times the script and its fallbacks were run
+}
+
+// ManifestFile records a file that was generated in the output directory.
+type ManifestFile struct {
+	Path   string `json:"path"` // relative to the output directory
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// NewManifest creates a Manifest for a run of the command that starts now.
+func NewManifest(command string, commandLine []string, version string) *Manifest {
+	return &Manifest{
+		Command:     command,
+		CommandLine: commandLine,
+		Version:     version,
+		StartTime:   time.Now(),
+		Targets:     []ManifestTarget{},
+		Files:       []ManifestFile{},
+	}
+}
+
+// AddTargets records the targets of the run. A target is connected if it has no error.
+func (m *Manifest) AddTargets(targets []target.Target, targetErrs []error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for i, myTarget := range targets {
+		t := m.getTargetLocked(myTarget.GetName())
+		t.Connected = targetErrs[i] == nil
+		if targetErrs[i] != nil {
+			t.Error = targetErrs[i].Error()
+		}
+	}
+}
+
+// AddScript records a script that was run on a target. It is a script.Recorder.
+func (m *Manifest) AddScript(targetName string, scriptOutput script.ScriptOutput) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	t := m.getTargetLocked(targetName)
+	t.Scripts = append(t.Scripts, ManifestScript{
+		Name:     scriptOutput.Name,
+		ExitCode: scriptOutput.Exitcode,
+		Duration: scriptOutput.Duration.Seconds(),
+		Variant:  scriptOutput.Variant,
+		Attempts: scriptOutput.Attempts,
+	})
+}
+
+// AddFile records a file that the run wrote to the output directory. It is a util.FileRecorder.
+func (m *Manifest) AddFile(path string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.addFileLocked(path)
+}
+
+func (m *Manifest) addFileLocked(path string) {
+	if !slices.Contains(m.files, path) {
+		m.files = append(m.files, path)
+	}
+}
+
+// setRunner sets the runner that reports the status of the targets
+func (m *Manifest) setRunner(runner *TargetRunner) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.runner = runner
+}
+
+// Write completes the manifest with the outcome of the run, i.e., runErr, and the files that the run
+// wrote to the output directory, then writes it to the output directory. The output directory is
+// created if it doesn't exist.
+func (m *Manifest) Write(outputDir string, runErr error) (err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.EndTime = time.Now()
+	m.Status = RunStatusSucceeded
+	if runErr != nil {
+		m.Status = RunStatusFailed
+		m.Error = runErr.Error()
+	}
+	if m.runner != nil {
+		for _, result := range m.runner.Results() {
+			t := m.getTargetLocked(result.TargetName)
+			t.Status = result.Status
+			t.Error = ""
+			if result.Err != nil {
+				t.Error = result.Err.Error()
+			}
+			t.Duration = result.Duration.Seconds()
+		}
+	}
+	for i := range m.Targets {
+		if platform, ok := script.CachedPlatform(m.Targets[i].Name); ok {
+			m.Targets[i].Platform = &platform
+		}
+		m.Targets[i].Tools = script.UsedTools(m.Targets[i].Name)
+	}
+	if err = CreateOutputDir(outputDir); err != nil {
+		return
+	}
+	// the audit log is written before the generated files are listed, so that it is included
+	if auditLog := script.AuditLog(); len(auditLog) > 0 {
+		var auditBytes []byte
+		if auditBytes, err = json.MarshalIndent(auditLog, "", " "); err != nil {
+			err = fmt.Errorf("failed to marshal audit log: %v", err)
+			return
+		}
+		auditPath := filepath.Join(outputDir, AuditLogFileName)
+		if err = os.WriteFile(auditPath, auditBytes, 0644); err != nil {
+			err = fmt.Errorf("failed to write audit log: %v", err)
+			return
+		}
+		m.addFileLocked(auditPath)
+	}
+	if m.Files, err = describeFiles(outputDir, m.files); err != nil {
+		return
+	}
+	manifestBytes, err := json.MarshalIndent(m, "", " ")
+	if err != nil {
+		err = fmt.Errorf("failed to marshal manifest: %v", err)
+		return
+	}
+	if err = os.WriteFile(filepath.Join(outputDir, ManifestFileName), manifestBytes, 0644); err != nil {
+		err = fmt.Errorf("failed to write manifest: %v", err)
+	}
+	return
+}
+
+func (m *Manifest) getTargetLocked(name string) *ManifestTarget {
+	for i := range m.Targets {
+		if m.Targets[i].Name == name {
+			return &m.Targets[i]
+		}
+	}
+	m.Targets = append(m.Targets, ManifestTarget{Name: name, Scripts: []ManifestScript{}})
+	return &m.Targets[len(m.Targets)-1]
+}
+
+// getManifest returns the command's manifest, or nil if it doesn't have one
+func getManifest(cmd *cobra.Command) *Manifest {
+	if cmd.Context() == nil {
+		return nil
+	}
+	appContext, ok := cmd.Context().Value(AppContext{}).(AppContext)
+	if !ok {
+		return nil
+	}
+	return appContext.Manifest
+}
+
+// describeFiles returns the size and checksum of each of the files, by their paths relative to the output
+// directory. Files that are outside the output directory or no longer exist, e.g., because they were
+// moved, are skipped.
+func describeFiles(outputDir string, paths []string) (files []ManifestFile, err error) {
+	files = []ManifestFile{}
+	for _, path := range paths {
+		relPath, relErr := filepath.Rel(outputDir, path)
+		if relErr != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+			slog.Debug("skipping file outside of output directory", slog.String("path", path))
+			continue
+		}
+		info, statErr := os.Stat(path)
+		if statErr != nil || !info.Mode().IsRegular() {
+			slog.Debug("skipping file that is no longer in output directory", slog.String("path", path))
+			continue
+		}
+		var checksum string
+		if checksum, err = sha256File(path); err != nil {
+			err = fmt.Errorf("failed to describe generated file: %v", err)
+			return
+		}
+		files = append(files, ManifestFile{Path: filepath.ToSlash(relPath), Size: info.Size(), SHA256: checksum})
+	}
+	return
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,97 @@
+package common
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"perfspect/internal/script"
+	"testing"
+	"time"
+)
+
+func TestManifestWrite(t *testing.T) {
+	outputDir := t.TempDir()
+	manifest := NewManifest("report", []string{"perfspect", "report"}, "1.2.3")
+	// only the files that the run recorded are listed, not the files from an earlier run or others that
+	// are added to the output directory, whatever their modification times
+	if err := os.WriteFile(filepath.Join(outputDir, "old.txt"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	targets := newTestTargets(3)
+	manifest.AddTargets(targets, []error{nil, nil, fmt.Errorf("unreachable")})
+	status := &testStatus{statuses: make(map[string]string)}
+	runner := newTargetRunner(0, 0, status.update)
+	manifest.setRunner(runner)
+	runner.Fail("target2", fmt.Errorf("unreachable"))
+	runner.Run(context.Background(), targets[:2], func(ctx context.Context, i int) error {
+		manifest.AddScript(targets[i].GetName(), script.ScriptOutput{ScriptDefinition: script.ScriptDefinition{Name: "lscpu"}, Exitcode: i, Duration: time.Second})
+		if i == 1 {
+			return fmt.Errorf("broken")
+		}
+		return nil
+	})
+	if err := os.WriteFile(filepath.Join(outputDir, "target0.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest.AddFile(filepath.Join(outputDir, "target0.json"))
+	manifest.AddFile(filepath.Join(outputDir, "target0.json"))
+	// files outside the output directory and files that were removed are skipped
+	elsewhere := filepath.Join(t.TempDir(), "elsewhere.json")
+	if err := os.WriteFile(elsewhere, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest.AddFile(elsewhere)
+	manifest.AddFile(filepath.Join(outputDir, "removed.json"))
+	if err := manifest.Write(outputDir, nil); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	contents, err := os.ReadFile(filepath.Join(outputDir, ManifestFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written Manifest
+	if err := json.Unmarshal(contents, &written); err != nil {
+		t.Fatalf("failed to parse manifest: %v", err)
+	}
+	if written.Status != RunStatusSucceeded || written.Version != "1.2.3" || written.EndTime.Before(written.StartTime) {
+		t.Errorf("unexpected run fields: status=%s version=%s", written.Status, written.Version)
+	}
+	if len(written.Targets) != 3 {
+		t.Fatalf("expected 3 targets, got %d", len(written.Targets))
+	}
+	expected := []struct {
+		connected bool
+		status    string
+		error     string
+		scripts   int
+	}{
+		{true, TargetStatusSucceeded, "", 1},
+		{true, TargetStatusFailed, "broken", 1},
+		{false, TargetStatusFailed, "unreachable", 0},
+	}
+	for i, target := range written.Targets {
+		if target.Connected != expected[i].connected || target.Status != expected[i].status || target.Error != expected[i].error || len(target.Scripts) != expected[i].scripts {
+			t.Errorf("unexpected target: %+v", target)
+		}
+	}
+	if script := written.Targets[1].Scripts[0]; script.Name != "lscpu" || script.ExitCode != 1 || script.Duration != 1 {
+		t.Errorf("unexpected script: %+v", script)
+	}
+	// sha256 of "{}"
+	if len(written.Files) != 1 || written.Files[0].Path != "target0.json" || written.Files[0].SHA256 != "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a" {
+		t.Errorf("unexpected files: %+v", written.Files)
+	}
+	// a failed run is recorded
+	if err := manifest.Write(outputDir, fmt.Errorf("no targets")); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	contents, _ = os.ReadFile(filepath.Join(outputDir, ManifestFileName))
+	if err := json.Unmarshal(contents, &written); err != nil || written.Status != RunStatusFailed || written.Error != "no targets" {
+		t.Errorf("failed run not recorded: status=%s error=%s, %v", written.Status, written.Error, err)
+	}
+}
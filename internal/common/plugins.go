@@ -0,0 +1,125 @@
+package common
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// plugins.go loads user-defined scripts and tables from YAML plugin files
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v2"
+
+	"perfspect/internal/report"
+	"perfspect/internal/script"
+)
+
+type pluginScriptFromYAML struct {
+	Name          string   `yaml:"name"`
+	Script        string   `yaml:"script"`
+	Architectures []string `yaml:"architectures"`
+	Superuser     bool     `yaml:"superuser"`
+	Depends       []string `yaml:"depends"`
+	Timeout       int      `yaml:"timeout"`
+	Resources     []string `yaml:"resources"`
+	After         []string `yaml:"after"`
+	Retries       int      `yaml:"retries"`
+	RetryBackoff  int      `yaml:"backoff"`
+	// fallbacks are run in place of the script, in order, if it fails or can't run on the target
+	Fallbacks []pluginScriptFromYAML `yaml:"fallbacks"`
+}
+
+// definition returns the script definition of the plugin script
+func (s pluginScriptFromYAML) definition() script.ScriptDefinition {
+	var fallbacks []script.ScriptDefinition
+	for _, fallback := range s.Fallbacks {
+		fallbacks = append(fallbacks, fallback.definition())
+	}
+	return script.ScriptDefinition{
+		Name:          s.Name,
+		Script:        s.Script,
+		Architectures: s.Architectures,
+		Superuser:     s.Superuser,
+		Depends:       s.Depends,
+		Timeout:       s.Timeout,
+		Resources:     s.Resources,
+		After:         s.After,
+		Retries:       s.Retries,
+		RetryBackoff:  s.RetryBackoff,
+		Fallbacks:     fallbacks,
+		Requires:      []string{script.RequireBash}, // plugin scripts are bash scripts
+	}
+}
+
+type pluginFile struct {
+	Scripts []pluginScriptFromYAML         `yaml:"scripts"`
+	Tables  []report.PluginTableDefinition `yaml:"tables"`
+}
+
+// LoadPlugins loads the plugin files, i.e., the .yaml and .yml files, in dir. It adds their scripts
+// and tables to the ones that are built in, and returns the names of the tables.
+func LoadPlugins(dir string) (tableNames []string, err error) {
+	var paths []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		var matches []string
+		if matches, err = filepath.Glob(filepath.Join(dir, pattern)); err != nil {
+			return
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		if _, err = os.Stat(dir); err != nil {
+			err = fmt.Errorf("failed to read plugin directory: %v", err)
+			return
+		}
+		slog.Warn("no plugin files found", slog.String("dir", dir))
+		return
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		var names []string
+		if names, err = loadPluginFile(path); err != nil {
+			err = fmt.Errorf("failed to load plugin file %s: %v", path, err)
+			return
+		}
+		tableNames = append(tableNames, names...)
+	}
+	return
+}
+
+// loadPluginFile adds the scripts and tables in the plugin file and returns the names of the tables
+func loadPluginFile(path string) (tableNames []string, err error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var plugin pluginFile
+	if err = yaml.UnmarshalStrict(contents, &plugin); err != nil {
+		return
+	}
+	var scripts []script.ScriptDefinition
+	for _, s := range plugin.Scripts {
+		scripts = append(scripts, s.definition())
+	}
+	if err = script.AddScripts(scripts); err != nil {
+		return
+	}
+	for _, table := range plugin.Tables {
+		for _, field := range table.Fields {
+			if field.Script != "" && !script.HasScript(field.Script) {
+				err = fmt.Errorf("table %s: field %s: script not found: %s", table.Name, field.Name, field.Script)
+				return
+			}
+		}
+		tableNames = append(tableNames, table.Name)
+	}
+	if err = report.AddPluginTables(plugin.Tables); err != nil {
+		return
+	}
+	slog.Info("loaded plugin", slog.String("path", path), slog.Int("scripts", len(scripts)), slog.Int("tables", len(tableNames)))
+	return
+}
@@ -0,0 +1,105 @@
+package common
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"perfspect/internal/report"
+	"perfspect/internal/script"
+	"slices"
+	"testing"
+)
+
+const testPlugin = `scripts:
+  - name: unittest plugin widgets
+    script: |
+      echo '{"widgets": [{"name": "a", "speed": 10}, {"name": "b", "speed": 20.5}]}'
+    superuser: true
+    timeout: 10
+  - name: unittest plugin firmware
+    script: |
+      echo "Firmware Version: 1.2.3"
+      echo "Firmware Version: 4.5.6"
+    architectures: [x86_64]
+tables:
+  - name: Unittest Widgets
+    rows: true
+    fields:
+      - name: Name
+        script: unittest plugin widgets
+        jq: .widgets[].name
+      - name: Speed
+        script: unittest plugin widgets
+        jq: .widgets[].speed
+  - name: Unittest Firmware
+    menu: Firmware
+    fields:
+      - name: Version
+        script: unittest plugin firmware
+        regex: '^Firmware Version:\s*(\S+)'
+      - name: Widget Count
+        script: unittest plugin widgets
+        jq: .widgets[-1].name
+`
+
+func TestLoadPlugins(t *testing.T) {
+	// a table's fields must come from scripts that exist
+	invalidDir := t.TempDir()
+	invalid := "tables:\n  - name: Unittest Invalid\n    fields:\n      - name: Value\n        script: unittest plugin missing\n        regex: .*\n"
+	if err := os.WriteFile(filepath.Join(invalidDir, "invalid.yml"), []byte(invalid), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPlugins(invalidDir); err == nil {
+		t.Error("expected error for missing script")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "test.yaml"), []byte(testPlugin), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tableNames, err := LoadPlugins(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(tableNames, []string{"Unittest Widgets", "Unittest Firmware"}) {
+		t.Fatalf("unexpected table names: %v", tableNames)
+	}
+	widgets := script.GetTimedScriptByName("unittest plugin widgets", 0, 0, 0)
+	if !widgets.Superuser || widgets.Timeout != 10 || !slices.Contains(widgets.Requires, script.RequireBash) {
+		t.Errorf("unexpected script definition: %+v", widgets)
+	}
+	// loading the same plugins again fails, names must be unique
+	if _, err := LoadPlugins(dir); err == nil {
+		t.Error("expected error for duplicate script")
+	}
+	outputs := map[string]script.ScriptOutput{
+		"unittest plugin widgets":  {Stdout: `{"widgets": [{"name": "a", "speed": 10}, {"name": "b", "speed": 20.5}]}`},
+		"unittest plugin firmware": {Stdout: "Firmware Version: 1.2.3\nFirmware Version: 4.5.6\n"},
+	}
+	allTableValues, err := report.Process(tableNames, outputs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rows := allTableValues[0]
+	if !rows.HasRows || !slices.Equal(rows.Fields[0].Values, []string{"a", "b"}) || !slices.Equal(rows.Fields[1].Values, []string{"10", "20.5"}) {
+		t.Errorf("unexpected values: %+v", rows)
+	}
+	firmware := allTableValues[1]
+	if firmware.HasRows || firmware.MenuLabel != "Firmware" || !slices.Equal(firmware.Fields[0].Values, []string{"1.2.3"}) || !slices.Equal(firmware.Fields[1].Values, []string{"b"}) {
+		t.Errorf("unexpected values: %+v", firmware)
+	}
+	// the table definitions are stored in raw reports
+	out, err := report.CreateRawReport(tableNames, outputs, "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var rawReport report.RawReport
+	if err := json.Unmarshal(out, &rawReport); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rawReport.PluginTables) != 2 || rawReport.PluginTables[1].Fields[0].Regex != `^Firmware Version:\s*(\S+)` {
+		t.Errorf("unexpected plugin tables in raw report: %+v", rawReport.PluginTables)
+	}
+}
@@ -0,0 +1,288 @@
+package common
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"perfspect/internal/progress"
+	"perfspect/internal/target"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// target run status values
+const (
+	TargetStatusSucceeded = "succeeded"
+	TargetStatusFailed    = "failed"
+	TargetStatusTimedOut  = "timed out"
+)
+
+// TargetResult is the outcome of the work done on a target.
+type TargetResult struct {
+	TargetName string
+	Status     string
+	Err        error
+	Duration   time.Duration
+}
+
+// TargetRunner runs work on multiple targets concurrently. No more than a fixed number of
+// targets are worked on at the same time, and each target has a time limit for all work done
+// on it, i.e., over all calls to Run. Time spent waiting for other targets doesn't count. When
+// a target exceeds its time limit, the context passed to its work is canceled and the target is
+// reported as timed out. Work that doesn't return shortly after its context is canceled is
+// abandoned, i.e., it is left to finish in the background and the run continues without it.
+type TargetRunner struct {
+	parallel     int
+	timeout      time.Duration
+	abandonDelay time.Duration
+	statusUpdate progress.MultiSpinnerUpdateFunc
+	mutex        sync.Mutex
+	elapsed      map[string]time.Duration
+	results      map[string]*TargetResult
+	abandoned    map[string]bool
+	order        []string
+}
+
+// targetAbandonDelay is the time work is given to return after its context is canceled
+const targetAbandonDelay = 10 * time.Second
+
+// NewTargetRunner creates a TargetRunner configured by the command's target flags.
+// Progress is reported through statusUpdate, e.g., a MultiSpinner's Status function.
+func NewTargetRunner(cmd *cobra.Command, statusUpdate progress.MultiSpinnerUpdateFunc) *TargetRunner {
+	parallel, _ := cmd.Flags().GetInt(flagParallelName)
+	timeout, _ := cmd.Flags().GetInt(flagTargetTimeoutName)
+	runner := newTargetRunner(parallel, time.Duration(timeout)*time.Second, statusUpdate)
+	// the targets' outcomes are recorded in the run manifest
+	if manifest := getManifest(cmd); manifest != nil {
+		manifest.setRunner(runner)
+	}
+	return runner
+}
+
+func newTargetRunner(parallel int, timeout time.Duration, statusUpdate progress.MultiSpinnerUpdateFunc) *TargetRunner {
+	return &TargetRunner{
+		parallel:     parallel,
+		timeout:      timeout,
+		abandonDelay: targetAbandonDelay,
+		statusUpdate: statusUpdate,
+		elapsed:      make(map[string]time.Duration),
+		results:      make(map[string]*TargetResult),
+		abandoned:    make(map[string]bool),
+	}
+}
+
+// Run calls work for each of the targets, where i is the index of the target in targets, and
+// waits until the work is complete or abandoned. The context passed to work is canceled when ctx
+// is canceled or the target's time limit is exceeded. Targets that failed or timed out in a
+// previous call to Run are skipped. The work function should report its progress through the
+// runner's Status function. The targets' results are kept by name, so the targets must have distinct names, see
+// validateTargets.
+func (r *TargetRunner) Run(ctx context.Context, targets []target.Target, work func(ctx context.Context, i int) error) {
+	names := make(map[string]bool)
+	for _, myTarget := range targets {
+		if names[myTarget.GetName()] {
+			panic(fmt.Sprintf("expected distinct target names, got %s more than once", myTarget.GetName()))
+		}
+		names[myTarget.GetName()] = true
+	}
+	parallel := r.parallel
+	if parallel <= 0 || parallel > len(targets) {
+		parallel = len(targets)
+	}
+	slots := make(chan struct{}, parallel)
+	type result struct {
+		name      string
+		err       error
+		elapsed   time.Duration
+		timedOut  bool
+		abandoned bool
+	}
+	results := make(chan result, len(targets))
+	pending := 0
+	for i, myTarget := range targets {
+		name := myTarget.GetName()
+		if !r.register(name) {
+			continue
+		}
+		pending++
+		go func(i int, name string) {
+			select {
+			case slots <- struct{}{}:
+			default:
+				_ = r.Status(name, "waiting")
+				slots <- struct{}{}
+			}
+			defer func() { <-slots }()
+			targetCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			if r.timeout > 0 {
+				targetCtx, cancel = context.WithTimeout(targetCtx, r.timeout-r.getElapsed(name))
+				defer cancel()
+			}
+			begin := time.Now()
+			done := make(chan error, 1) // buffered so that abandoned work doesn't block
+			go func() {
+				done <- work(targetCtx, i)
+			}()
+			res := result{name: name}
+			select {
+			case res.err = <-done:
+			case <-targetCtx.Done():
+				select {
+				case res.err = <-done:
+				case <-time.After(r.abandonDelay):
+					res.err = targetCtx.Err()
+					res.abandoned = true
+				}
+			}
+			res.elapsed = time.Since(begin)
+			res.timedOut = ctx.Err() == nil && errors.Is(targetCtx.Err(), context.DeadlineExceeded)
+			results <- res
+		}(i, name)
+	}
+	for range pending {
+		res := <-results
+		r.addElapsed(res.name, res.elapsed)
+		if res.abandoned {
+			r.setAbandoned(res.name)
+			slog.Error("abandoned work on target", slog.String("target", res.name))
+		}
+		if res.timedOut {
+			r.record(res.name, TargetStatusTimedOut, fmt.Errorf("did not complete within %s", r.timeout))
+			_ = r.statusUpdate(res.name, fmt.Sprintf("timed out after %s", r.timeout))
+			slog.Error("target timed out", slog.String("target", res.name), slog.String("timeout", r.timeout.String()))
+		} else if res.err != nil {
+			r.record(res.name, TargetStatusFailed, res.err)
+		} else {
+			r.record(res.name, TargetStatusSucceeded, nil)
+		}
+	}
+}
+
+// Fail records that the target failed before any work was run on it, e.g., it couldn't be connected to.
+func (r *TargetRunner) Fail(name string, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.recordLocked(name, TargetStatusFailed, err)
+}
+
+// Succeeded returns true if all work run on the target so far has succeeded.
+func (r *TargetRunner) Succeeded(name string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	result, ok := r.results[name]
+	return ok && result.Status == TargetStatusSucceeded
+}
+
+// TimedOut returns true if the target exceeded its time limit.
+func (r *TargetRunner) TimedOut(name string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	result, ok := r.results[name]
+	return ok && result.Status == TargetStatusTimedOut
+}
+
+// Abandoned returns true if work on the target didn't return after its context was canceled. The
+// work may still be in progress, so the target shouldn't be used, e.g., to restore its settings.
+func (r *TargetRunner) Abandoned(name string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.abandoned[name]
+}
+
+// Status updates the target's progress status. Updates from work on targets that have timed
+// out are dropped so that the "timed out" status remains visible.
+func (r *TargetRunner) Status(name string, status string) error {
+	if r.TimedOut(name) {
+		return nil
+	}
+	return r.statusUpdate(name, status)
+}
+
+// Results returns the results for all targets, in the order they were first run or failed.
+func (r *TargetRunner) Results() (results []TargetResult) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, name := range r.order {
+		results = append(results, *r.results[name])
+	}
+	return
+}
+
+// PrintSummary writes a summary of the run to w. Nothing is written for a single target that succeeded.
+func (r *TargetRunner) PrintSummary(w io.Writer) {
+	results := r.Results()
+	succeeded := 0
+	for _, result := range results {
+		if result.Status == TargetStatusSucceeded {
+			succeeded++
+		}
+	}
+	if len(results) == 0 || (len(results) == 1 && succeeded == 1) {
+		return
+	}
+	fmt.Fprintf(w, "Run summary: %d of %d targets succeeded\n", succeeded, len(results))
+	for _, result := range results {
+		line := fmt.Sprintf("  %-20s  %-9s  %8s", result.TargetName, result.Status, result.Duration.Round(time.Second))
+		if result.Err != nil {
+			line += "  " + result.Err.Error()
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// register adds the target to the run, if it isn't already, and returns true if work should be run on it.
+func (r *TargetRunner) register(name string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if result, ok := r.results[name]; ok {
+		return result.Status == TargetStatusSucceeded
+	}
+	r.order = append(r.order, name)
+	r.results[name] = &TargetResult{TargetName: name, Status: TargetStatusSucceeded}
+	return true
+}
+
+// getElapsed returns the time spent working on the target so far.
+func (r *TargetRunner) getElapsed(name string) time.Duration {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.elapsed[name]
+}
+
+func (r *TargetRunner) setAbandoned(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.abandoned[name] = true
+}
+
+func (r *TargetRunner) addElapsed(name string, elapsed time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.elapsed[name] += elapsed
+}
+
+func (r *TargetRunner) record(name string, status string, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.recordLocked(name, status, err)
+}
+
+func (r *TargetRunner) recordLocked(name string, status string, err error) {
+	result, ok := r.results[name]
+	if !ok {
+		r.order = append(r.order, name)
+		result = &TargetResult{TargetName: name}
+		r.results[name] = result
+	}
+	result.Status = status
+	result.Err = err
+	result.Duration = r.elapsed[name]
+}
@@ -0,0 +1,157 @@
+package common
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"perfspect/internal/target"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testStatus records the last status of each target.
+type testStatus struct {
+	mutex    sync.Mutex
+	statuses map[string]string
+}
+
+func (s *testStatus) update(name string, status string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.statuses[name] = status
+	return nil
+}
+
+func (s *testStatus) get(name string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.statuses[name]
+}
+
+func newTestTargets(n int) (targets []target.Target) {
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("target%d", i)
+		targets = append(targets, target.NewRemoteTarget(name, name, "", "", ""))
+	}
+	return
+}
+
+func TestTargetRunnerParallel(t *testing.T) {
+	targets := newTestTargets(6)
+	status := &testStatus{statuses: make(map[string]string)}
+	runner := newTargetRunner(2, 0, status.update)
+	var running, maxRunning int32
+	runner.Run(context.Background(), targets, func(ctx context.Context, i int) error {
+		now := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&maxRunning)
+			if now <= old || atomic.CompareAndSwapInt32(&maxRunning, old, now) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	})
+	if maxRunning != 2 {
+		t.Errorf("expected at most 2 targets at a time, got %d", maxRunning)
+	}
+	for _, myTarget := range targets {
+		if !runner.Succeeded(myTarget.GetName()) {
+			t.Errorf("%s did not succeed", myTarget.GetName())
+		}
+	}
+}
+
+func TestTargetRunnerTimeout(t *testing.T) {
+	targets := newTestTargets(3)
+	status := &testStatus{statuses: make(map[string]string)}
+	runner := newTargetRunner(0, 100*time.Millisecond, status.update)
+	runner.abandonDelay = 100 * time.Millisecond
+	release := make(chan struct{})
+	defer close(release)
+	start := time.Now()
+	runner.Run(context.Background(), targets, func(ctx context.Context, i int) error {
+		switch i {
+		case 0:
+			<-release // hangs, ignoring ctx
+			_ = runner.Status(targets[i].GetName(), "late update")
+		case 1:
+			return fmt.Errorf("broken")
+		}
+		return nil
+	})
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("runner waited for the hung target")
+	}
+	if !runner.TimedOut("target0") || !strings.HasPrefix(status.get("target0"), "timed out") {
+		t.Errorf("target0 not timed out: status=%q", status.get("target0"))
+	}
+	if !runner.Abandoned("target0") {
+		t.Error("target0 not abandoned")
+	}
+	if runner.Succeeded("target1") || runner.TimedOut("target1") {
+		t.Error("target1 should have failed")
+	}
+	if !runner.Succeeded("target2") {
+		t.Error("target2 should have succeeded")
+	}
+	// targets that failed or timed out are skipped in the next step
+	var ran []int
+	runner.Run(context.Background(), targets, func(ctx context.Context, i int) error {
+		ran = append(ran, i)
+		return nil
+	})
+	if len(ran) != 1 || ran[0] != 2 {
+		t.Errorf("expected only target2 to run, ran %v", ran)
+	}
+	// the summary lists all targets
+	var summary strings.Builder
+	runner.PrintSummary(&summary)
+	for _, expected := range []string{"1 of 3 targets succeeded", "target0", "timed out", "target1", "failed", "broken"} {
+		if !strings.Contains(summary.String(), expected) {
+			t.Errorf("expected %q in summary:\n%s", expected, summary.String())
+		}
+	}
+}
+
+func TestTargetRunnerCancel(t *testing.T) {
+	targets := newTestTargets(2)
+	status := &testStatus{statuses: make(map[string]string)}
+	runner := newTargetRunner(0, 10*time.Second, status.update)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	runner.Run(ctx, targets, func(ctx context.Context, i int) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	for _, myTarget := range targets {
+		name := myTarget.GetName()
+		if runner.Succeeded(name) || runner.TimedOut(name) || runner.Abandoned(name) {
+			t.Errorf("%s should have failed", name)
+		}
+	}
+	for _, result := range runner.Results() {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("unexpected error for %s: %v", result.TargetName, result.Err)
+		}
+	}
+}
+
+func TestTargetRunnerDuplicateNames(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for duplicate target names")
+		}
+	}()
+	status := &testStatus{statuses: make(map[string]string)}
+	runner := newTargetRunner(0, 0, status.update)
+	targets := append(newTestTargets(2), newTestTargets(1)...)
+	runner.Run(context.Background(), targets, func(ctx context.Context, i int) error { return nil })
+}
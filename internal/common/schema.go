@@ -0,0 +1,21 @@
+package common
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"perfspect/internal/report"
+)
+
+// ReportJSONSchema returns the JSON Schema of the JSON reports, including the tables that are added to the reports
+// after processing, e.g., Insights
+func ReportJSONSchema() ([]byte, error) {
+	complianceTableValues, _ := (&Baseline{}).Evaluate(nil)
+	return report.JSONSchema([]report.TableValues{
+		DefaultInsightsFunc(nil, nil),
+		complianceTableValues,
+		report.GetSkippedDataTableValues(nil, nil),
+		report.GetCollectionDiagnosticsTableValues(nil, nil),
+		PerfspectVersionTableValues(""),
+	})
+}
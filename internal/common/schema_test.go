@@ -0,0 +1,135 @@
+package common
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"bytes"
+	"encoding/json"
+	"perfspect/internal/report"
+	"perfspect/internal/script"
+	"reflect"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+func TestReportJSONSchema(t *testing.T) {
+	out, err := ReportJSONSchema()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var schema struct {
+		Required   []string `json:"required"`
+		Properties map[string]struct {
+			Const string `json:"const"`
+			Items struct {
+				Properties map[string]struct {
+					Type       any    `json:"type"`
+					Unit       string `json:"unit"`
+					Properties map[string]struct {
+						Type any `json:"type"`
+					} `json:"properties"`
+				} `json:"properties"`
+			} `json:"items"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(out, &schema); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(schema.Required, []string{report.SchemaVersionKey}) || schema.Properties[report.SchemaVersionKey].Const != report.SchemaVersion {
+		t.Errorf("unexpected schema version: %v, %+v", schema.Required, schema.Properties[report.SchemaVersionKey])
+	}
+	for _, tableName := range []string{report.CPUTableName, report.CPUUtilizationTableName, TableNameInsights, TableNameCompliance, TableNamePerfspect} {
+		if len(schema.Properties[tableName].Items.Properties) == 0 {
+			t.Errorf("no fields in table %s", tableName)
+		}
+	}
+	memTotal := schema.Properties[report.MemoryTableName].Items.Properties["MemTotal"]
+	if memTotal.Type != "object" || !reflect.DeepEqual(memTotal.Properties["number"].Type, []any{report.FieldTypeInteger, "null"}) || memTotal.Unit != "kB" {
+		t.Errorf("unexpected MemTotal: %+v", memTotal)
+	}
+	if osType := schema.Properties[report.OperatingSystemTableName].Items.Properties["OS"].Type; osType != report.FieldTypeString {
+		t.Errorf("unexpected OS type: %v", osType)
+	}
+}
+
+func TestJSONReport(t *testing.T) {
+	allTableValues := []report.TableValues{
+		{
+			TableDefinition: report.TableDefinition{Name: report.MemoryTableName},
+			Fields: []report.Field{
+				{Name: "MemTotal", Values: []string{"6158152 kB"}},
+				{Name: "MemFree", Values: []string{""}},
+				{Name: "Transparent Huge Pages", Values: []string{"madvise"}},
+			},
+		},
+		{
+			TableDefinition: report.TableDefinition{Name: report.PowerStatsTableName, HasRows: true},
+			Fields:          []report.Field{{Name: "Time", Values: []string{"10:00:00"}}, {Name: "Package", Values: []string{"101.25"}}},
+		},
+		{TableDefinition: report.TableDefinition{Name: "Unittest Empty"}},
+	}
+	out, err := report.Create(report.FormatJson, allTableValues, nil, "unittest")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]any{
+		report.SchemaVersionKey: report.SchemaVersion,
+		report.MemoryTableName: []any{map[string]any{
+			"MemTotal":               map[string]any{"value": "6158152 kB", "number": float64(6158152)},
+			"MemFree":                map[string]any{"value": "", "number": nil},
+			"Transparent Huge Pages": "madvise",
+		}},
+		report.PowerStatsTableName: []any{map[string]any{"Time": "10:00:00", "Package": map[string]any{"value": "101.25", "number": 101.25}}},
+		"Unittest Empty":           []any{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected report:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestJSONReportValidates(t *testing.T) {
+	schemaBytes, err := ReportJSONSchema()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("schema.json", bytes.NewReader(schemaBytes)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	schema, err := compiler.Compile("schema.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the tables of a report, processed from the outputs of the scripts as the report command does
+	outputs := map[string]script.ScriptOutput{
+		script.LscpuScriptName:   {Stdout: "Architecture:  x86_64\nCPU family:  6\nModel:  143\nStepping:  8\nCPU(s):  8\nSocket(s):  1\nCore(s) per socket:  4\n"},
+		script.MeminfoScriptName: {Stdout: "MemTotal:        6158152 kB\nMemFree:         1234 kB\nHugePages_Total:       0\n"},
+	}
+	allTableValues, err := report.Process([]string{report.CPUTableName, report.MemoryTableName, report.PowerStatsTableName}, outputs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	allTableValues = append(allTableValues, DefaultInsightsFunc(allTableValues, outputs), PerfspectVersionTableValues("1.0.0"))
+	out, err := report.Create(report.FormatJson, allTableValues, outputs, "unittest")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got any
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := schema.Validate(got); err != nil {
+		t.Errorf("report doesn't validate: %v", err)
+	}
+	// a number that isn't a typed value doesn't validate
+	got.(map[string]any)[report.MemoryTableName].([]any)[0].(map[string]any)["MemTotal"] = 6158152
+	if err := schema.Validate(got); err == nil {
+		t.Error("expected a validation error")
+	}
+}
@@ -6,13 +6,16 @@ package common
 import (
 	"fmt"
 	"log/slog"
+	"net"
 	"os"
 	"os/user"
 	"path"
+	"path/filepath"
 	"perfspect/internal/script"
 	"perfspect/internal/target"
 	"perfspect/internal/util"
 	"runtime"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
@@ -27,6 +30,17 @@ var (
 	flagTargetKeyFile string
 	flagTargetsFile   string
 	flagTargetTempDir string
+	flagNativeSSH     bool
+	flagJumpHosts     []string
+	flagJumpKeyFiles  []string
+	flagContainer     string
+	flagPod           string
+	flagNamespace     string
+	flagSelect        string
+	flagParallel      int
+	flagTargetTimeout int
+	flagCache         bool
+	flagToolCache     bool
 )
 
 // target flag names
@@ -37,6 +51,17 @@ const (
 	flagTargetUserName    = "user"
 	flagTargetKeyName     = "key"
 	FlagTargetTempDirName = "targettemp"
+	flagNativeSSHName     = "nativessh"
+	flagJumpHostsName     = "jump"
+	flagJumpKeysName      = "jumpkey"
+	flagContainerName     = "container"
+	flagPodName           = "pod"
+	flagNamespaceName     = "namespace"
+	flagSelectName        = "select"
+	flagParallelName      = "parallel"
+	flagTargetTimeoutName = "targettimeout"
+	flagCacheName         = "cache"
+	flagToolCacheName     = "toolcache"
 )
 
 var targetFlags = []Flag{
@@ -46,6 +71,17 @@ var targetFlags = []Flag{
 	{Name: flagTargetKeyName, Help: "private key file for SSH to remote target"},
 	{Name: flagTargetsFileName, Help: "file with remote target(s) connection details. See targets.yaml for format."},
 	{Name: FlagTargetTempDirName, Help: "directory to use on remote target for temporary files"},
+	{Name: flagNativeSSHName, Help: "use the built-in SSH client instead of the system's ssh, scp, and sshpass"},
+	{Name: flagJumpHostsName, Help: "comma-separated list of jump hosts, [user@]host[:port], through which the remote target(s) are reached, in connection order"},
+	{Name: flagJumpKeysName, Help: "comma-separated list of private key files for SSH to the jump hosts, in the same order as the jump hosts"},
+	{Name: flagContainerName, Help: "name or ID of docker container target, or name of container in Kubernetes pod target"},
+	{Name: flagPodName, Help: "name of Kubernetes pod target, accessed with kubectl"},
+	{Name: flagNamespaceName, Help: "namespace of Kubernetes pod target"},
+	{Name: flagSelectName, Help: "comma-separated list of label=value pairs. Only targets in the targets file with all of the labels are used, e.g., role=db,cpu=spr"},
+	{Name: flagParallelName, Help: "maximum number of targets to work on at the same time, 0 for no limit"},
+	{Name: flagTargetTimeoutName, Help: "maximum number of seconds to spend on each target, 0 for no limit. Targets that take longer are reported as timed out."},
+	{Name: flagCacheName, Help: "cache what is discovered about the target(s), e.g., CPU model, in the user's cache directory and reuse it until the target reboots"},
+	{Name: flagToolCacheName, Help: "keep the tools that are copied to the target(s) in ~/.cache/perfspect/tools on the target(s), so that they are only copied again when they change. Remove with 'tools --clean-remote'."},
 }
 
 func AddTargetFlags(cmd *cobra.Command) {
@@ -55,8 +91,23 @@ func AddTargetFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVar(&flagTargetKeyFile, flagTargetKeyName, "", targetFlags[3].Help)
 	cmd.Flags().StringVar(&flagTargetsFile, flagTargetsFileName, "", targetFlags[4].Help)
 	cmd.Flags().StringVar(&flagTargetTempDir, FlagTargetTempDirName, "", targetFlags[5].Help)
+	cmd.Flags().BoolVar(&flagNativeSSH, flagNativeSSHName, false, targetFlags[6].Help)
+	cmd.Flags().StringSliceVar(&flagJumpHosts, flagJumpHostsName, []string{}, targetFlags[7].Help)
+	cmd.Flags().StringSliceVar(&flagJumpKeyFiles, flagJumpKeysName, []string{}, targetFlags[8].Help)
+	cmd.Flags().StringVar(&flagContainer, flagContainerName, "", targetFlags[9].Help)
+	cmd.Flags().StringVar(&flagPod, flagPodName, "", targetFlags[10].Help)
+	cmd.Flags().StringVar(&flagNamespace, flagNamespaceName, "", targetFlags[11].Help)
+	cmd.Flags().StringVar(&flagSelect, flagSelectName, "", targetFlags[12].Help)
+	cmd.Flags().IntVar(&flagParallel, flagParallelName, 0, targetFlags[13].Help)
+	cmd.Flags().IntVar(&flagTargetTimeout, flagTargetTimeoutName, 0, targetFlags[14].Help)
+	cmd.Flags().BoolVar(&flagCache, flagCacheName, false, targetFlags[15].Help)
+	cmd.Flags().BoolVar(&flagToolCache, flagToolCacheName, false, targetFlags[16].Help)
 
 	cmd.MarkFlagsMutuallyExclusive(flagTargetHostName, flagTargetsFileName)
+	cmd.MarkFlagsMutuallyExclusive(flagTargetHostName, flagContainerName)
+	cmd.MarkFlagsMutuallyExclusive(flagTargetHostName, flagPodName)
+	cmd.MarkFlagsMutuallyExclusive(flagTargetsFileName, flagContainerName)
+	cmd.MarkFlagsMutuallyExclusive(flagTargetsFileName, flagPodName)
 }
 
 func GetTargetFlagGroup() FlagGroup {
@@ -70,10 +121,52 @@ func GetTargetFlagGroup() FlagGroup {
 // If a targets file is specified, it reads the targets from the file.
 // Otherwise, it retrieves a single target using the getTarget function.
 // The function returns a slice of target.Target and an error if any.
+// The targets are recorded in the run manifest.
 func GetTargets(cmd *cobra.Command, needsElevatedPrivileges bool, failIfCantElevate bool, localTempDir string) ([]target.Target, []error, error) {
+	if useCache, _ := cmd.Flags().GetBool(flagCacheName); useCache {
+		enableTargetCache(cmd)
+	}
+	if useToolCache, _ := cmd.Flags().GetBool(flagToolCacheName); useToolCache {
+		script.EnableToolCache()
+	}
+	myTargets, targetErrs, err := getTargets(cmd, needsElevatedPrivileges, failIfCantElevate, localTempDir)
+	if err == nil {
+		if manifest := getManifest(cmd); manifest != nil {
+			manifest.AddTargets(myTargets, targetErrs)
+		}
+	}
+	return myTargets, targetErrs, err
+}
+
+// enableTargetCache enables the target cache in the user's cache directory
+func enableTargetCache(cmd *cobra.Command) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		slog.Warn("target cache disabled, user's cache directory not found", slog.String("error", err.Error()))
+		return
+	}
+	var version string
+	if cmd.Context() != nil {
+		if appContext, ok := cmd.Context().Value(AppContext{}).(AppContext); ok {
+			version = appContext.Version
+		}
+	}
+	target.EnableCache(filepath.Join(cacheDir, "perfspect", "targets"), version)
+}
+
+func getTargets(cmd *cobra.Command, needsElevatedPrivileges bool, failIfCantElevate bool, localTempDir string) ([]target.Target, []error, error) {
 	flagTargetsFile, _ := cmd.Flags().GetString(flagTargetsFileName)
+	selector, _ := cmd.Flags().GetString(flagSelectName)
 	if flagTargetsFile != "" {
-		return getTargetsFromFile(flagTargetsFile, localTempDir)
+		nativeSSH, _ := cmd.Flags().GetBool(flagNativeSSHName)
+		jumpHosts, err := getJumpHostsFromFlags(cmd)
+		if err != nil {
+			return nil, nil, err
+		}
+		return getTargetsFromFile(flagTargetsFile, selector, nativeSSH, jumpHosts, localTempDir)
+	}
+	if selector != "" {
+		return nil, nil, fmt.Errorf("--%s requires --%s", flagSelectName, flagTargetsFileName)
 	}
 	myTarget, targetErr, err := getTarget(cmd, needsElevatedPrivileges, failIfCantElevate, localTempDir)
 	return []target.Target{myTarget}, []error{targetErr}, err
@@ -94,8 +187,39 @@ func getTarget(cmd *cobra.Command, needsElevatedPrivileges bool, failIfCantEleva
 	targetPort, _ := cmd.Flags().GetString(flagTargetPortName)
 	targetUser, _ := cmd.Flags().GetString(flagTargetUserName)
 	targetKey, _ := cmd.Flags().GetString(flagTargetKeyName)
+	nativeSSH, _ := cmd.Flags().GetBool(flagNativeSSHName)
+	containerName, _ := cmd.Flags().GetString(flagContainerName)
+	podName, _ := cmd.Flags().GetString(flagPodName)
+	if containerName != "" || podName != "" {
+		namespace, _ := cmd.Flags().GetString(flagNamespaceName)
+		var myTarget *target.ContainerTarget
+		if podName != "" {
+			myTarget = target.NewPodTarget(podName, podName, namespace, containerName)
+		} else {
+			myTarget = target.NewContainerTarget(containerName, containerName)
+		}
+		if !myTarget.CanConnect() {
+			err := fmt.Errorf("failed to connect to container target (%s)", myTarget.GetName())
+			return myTarget, nil, err
+		}
+		if needsElevatedPrivileges && !myTarget.CanElevatePrivileges() {
+			if failIfCantElevate {
+				err := fmt.Errorf("failed to elevate privileges on container target")
+				return myTarget, err, nil
+			} else {
+				slog.Warn("failed to elevate privileges on container target, continuing without elevated privileges", slog.String("target", myTarget.GetName()))
+			}
+		}
+		return myTarget, nil, nil
+	}
 	if targetHost != "" {
+		jumpHosts, err := getJumpHostsFromFlags(cmd)
+		if err != nil {
+			return nil, nil, err
+		}
 		myTarget := target.NewRemoteTarget(targetHost, targetHost, targetPort, targetUser, targetKey)
+		myTarget.SetNativeSSH(nativeSSH)
+		myTarget.SetJumpHosts(jumpHosts)
 		if !myTarget.CanConnect() {
 			if targetKey == "" && targetUser != "" {
 				if !term.IsTerminal(int(os.Stdin.Fd())) {
@@ -108,16 +232,14 @@ func getTarget(cmd *cobra.Command, needsElevatedPrivileges bool, failIfCantEleva
 					if err != nil {
 						return myTarget, nil, err
 					}
-					var hostArchitecture string
-					hostArchitecture, err = getHostArchitecture()
-					if err != nil {
-						return myTarget, nil, err
-					}
-					sshPassPath, err := util.ExtractResource(script.Resources, path.Join("resources", hostArchitecture, "sshpass"), localTempDir)
-					if err != nil {
-						return myTarget, nil, err
+					// the built-in SSH client doesn't need sshpass
+					if !nativeSSH {
+						sshPassPath, err := extractSshPass(localTempDir)
+						if err != nil {
+							return myTarget, nil, err
+						}
+						myTarget.SetSshPassPath(sshPassPath)
 					}
-					myTarget.SetSshPassPath(sshPassPath)
 					myTarget.SetSshPass(sshPwd)
 					// if still can't connect, return target error
 					if !myTarget.CanConnect() {
@@ -182,7 +304,38 @@ func getTarget(cmd *cobra.Command, needsElevatedPrivileges bool, failIfCantEleva
 }
 
 type targetFromYAML struct {
-	Name string `yaml:"name"`
+	Name   string            `yaml:"name"`
+	Host   string            `yaml:"host"`
+	Port   string            `yaml:"port"`
+	User   string            `yaml:"user"`
+	Key    string            `yaml:"key"`
+	Pwd    string            `yaml:"pwd"`
+	Jump   []jumpFromYAML    `yaml:"jump"`
+	Labels map[string]string `yaml:"labels"`
+	// container targets
+	Container string `yaml:"container"`
+	Pod       string `yaml:"pod"`
+	Namespace string `yaml:"namespace"`
+}
+
+// defaultsFromYAML holds connection details that are inherited by the targets that don't specify their own.
+type defaultsFromYAML struct {
+	Port string         `yaml:"port"`
+	User string         `yaml:"user"`
+	Key  string         `yaml:"key"`
+	Pwd  string         `yaml:"pwd"`
+	Jump []jumpFromYAML `yaml:"jump"`
+}
+
+// groupFromYAML is a named group of targets. The group's labels and defaults are inherited by its targets.
+type groupFromYAML struct {
+	Name     string            `yaml:"name"`
+	Labels   map[string]string `yaml:"labels"`
+	Defaults defaultsFromYAML  `yaml:"defaults"`
+	Targets  []targetFromYAML  `yaml:"targets"`
+}
+
+type jumpFromYAML struct {
 	Host string `yaml:"host"`
 	Port string `yaml:"port"`
 	User string `yaml:"user"`
@@ -191,12 +344,140 @@ type targetFromYAML struct {
 }
 
 type targetsFile struct {
-	Targets []targetFromYAML `yaml:"targets"`
+	Defaults defaultsFromYAML `yaml:"defaults"`
+	Groups   []groupFromYAML  `yaml:"groups"`
+	Targets  []targetFromYAML `yaml:"targets"`
+}
+
+// groupLabel is the label that is automatically given to the targets in a group. Its value is the group's name.
+const groupLabel = "group"
+
+// resolveTargets flattens the groups in the targets file into a single list of targets, applies the
+// group and file defaults to the targets, and validates the result. Values specified on a target take
+// precedence over its group's defaults, which take precedence over the file's defaults. Labels specified
+// on a target take precedence over its group's labels.
+func resolveTargets(targetsFile targetsFile) (targets []targetFromYAML, err error) {
+	for _, t := range targetsFile.Targets {
+		targets = append(targets, applyDefaults(t, targetsFile.Defaults))
+	}
+	for i, group := range targetsFile.Groups {
+		if group.Name == "" {
+			err = fmt.Errorf("group %d in targets file has no name", i+1)
+			return
+		}
+		for _, t := range group.Targets {
+			labels := map[string]string{groupLabel: group.Name}
+			for k, v := range group.Labels {
+				labels[k] = v
+			}
+			for k, v := range t.Labels {
+				labels[k] = v
+			}
+			t.Labels = labels
+			targets = append(targets, applyDefaults(applyDefaults(t, group.Defaults), targetsFile.Defaults))
+		}
+	}
+	err = validateTargets(targets)
+	return
+}
+
+// applyDefaults returns the target with its empty connection details set from the defaults.
+func applyDefaults(t targetFromYAML, defaults defaultsFromYAML) targetFromYAML {
+	if t.Container != "" || t.Pod != "" {
+		return t // connection details don't apply to container targets
+	}
+	if t.Port == "" {
+		t.Port = defaults.Port
+	}
+	if t.User == "" {
+		t.User = defaults.User
+	}
+	if t.Key == "" && t.Pwd == "" {
+		t.Key = defaults.Key
+		t.Pwd = defaults.Pwd
+	}
+	if len(t.Jump) == 0 {
+		t.Jump = defaults.Jump
+	}
+	return t
+}
+
+// validateTargets checks the resolved targets for missing and conflicting values.
+func validateTargets(targets []targetFromYAML) error {
+	names := make(map[string]bool)
+	for i, t := range targets {
+		name := getTargetNameFromYAML(t)
+		if t.Host == "" && t.Container == "" && t.Pod == "" {
+			return fmt.Errorf("target %d (%s) in targets file has no host, container, or pod", i+1, name)
+		}
+		if t.Host != "" && (t.Container != "" || t.Pod != "") {
+			return fmt.Errorf("target (%s) in targets file has a host and a container or pod", name)
+		}
+		// output files are named after the targets
+		if names[name] {
+			return fmt.Errorf("target name (%s) is used more than once in targets file", name)
+		}
+		names[name] = true
+		for _, j := range t.Jump {
+			if j.Host == "" {
+				return fmt.Errorf("target (%s) in targets file has a jump host with no host", name)
+			}
+		}
+	}
+	return nil
+}
+
+// getTargetNameFromYAML returns the name that the target will be given, i.e., its name, if
+// specified, otherwise its host, pod, or container.
+func getTargetNameFromYAML(t targetFromYAML) string {
+	for _, name := range []string{t.Name, t.Host, t.Pod, t.Container} {
+		if name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// parseSelector parses a comma-separated list of label=value pairs into a map.
+func parseSelector(selector string) (labels map[string]string, err error) {
+	labels = make(map[string]string)
+	for _, pair := range strings.Split(selector, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		label, value, found := strings.Cut(pair, "=")
+		label = strings.TrimSpace(label)
+		if !found || label == "" {
+			err = fmt.Errorf("invalid selector (%s), expected label=value", pair)
+			return
+		}
+		labels[label] = strings.TrimSpace(value)
+	}
+	return
+}
+
+// selectTargets returns the targets that have all of the selector's labels.
+func selectTargets(targets []targetFromYAML, selector map[string]string) (selected []targetFromYAML) {
+	for _, t := range targets {
+		matches := true
+		for label, value := range selector {
+			if targetValue, ok := t.Labels[label]; !ok || targetValue != value {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			selected = append(selected, t)
+		}
+	}
+	return
 }
 
 // getTargetsFromFile reads a targets file and returns a list of target objects.
-// It takes the path to the targets file and the local temporary directory as input.
-func getTargetsFromFile(targetsFilePath string, localTempDir string) (targets []target.Target, targetErrs []error, err error) {
+// It takes the path to the targets file, the label selector, whether to use the built-in SSH client, the jump
+// hosts to use for targets that don't specify their own, and the local temporary directory as input.
+func getTargetsFromFile(targetsFilePath string, selector string, nativeSSH bool, defaultJumpHosts []target.JumpHost, localTempDir string) (targets []target.Target, targetErrs []error, err error) {
 	var targetsFile targetsFile
 	// read the file into a byte array
 	yamlFile, err := os.ReadFile(targetsFilePath)
@@ -208,33 +489,70 @@ func getTargetsFromFile(targetsFilePath string, localTempDir string) (targets []
 	if err != nil {
 		return
 	}
+	// resolve groups and defaults, and select the targets
+	resolvedTargets, err := resolveTargets(targetsFile)
+	if err != nil {
+		err = fmt.Errorf("invalid targets file (%s): %v", targetsFilePath, err)
+		return
+	}
+	if selector != "" {
+		var selectorLabels map[string]string
+		if selectorLabels, err = parseSelector(selector); err != nil {
+			return
+		}
+		resolvedTargets = selectTargets(resolvedTargets, selectorLabels)
+		slog.Info("selected targets", slog.String("selector", selector), slog.Int("count", len(resolvedTargets)))
+	}
+	if len(resolvedTargets) == 0 {
+		err = fmt.Errorf("no targets found in targets file (%s)", targetsFilePath)
+		if selector != "" {
+			err = fmt.Errorf("no targets in targets file (%s) match selector (%s)", targetsFilePath, selector)
+		}
+		return
+	}
 
 	// if any of the targets require a password, extract sshpass from resources
+	// (the built-in SSH client doesn't need sshpass)
 	needsSshPass := false
-	for _, t := range targetsFile.Targets {
-		if t.Pwd != "" {
+	for _, t := range resolvedTargets {
+		if nativeSSH {
+			break
+		}
+		if t.Pwd != "" && t.Container == "" && t.Pod == "" {
 			needsSshPass = true
 			break
 		}
 	}
 	var sshPassPath string
 	if needsSshPass {
-		var hostArchitecture string
-		hostArchitecture, err = getHostArchitecture()
-		if err != nil {
-			return
-		}
-		sshPassPath, err = util.ExtractResource(script.Resources, path.Join("resources", hostArchitecture, "sshpass"), localTempDir)
+		sshPassPath, err = extractSshPass(localTempDir)
 		if err != nil {
 			return
 		}
 	}
 	// create target objects from the targetFromYAML structs
-	for _, t := range targetsFile.Targets {
+	for _, t := range resolvedTargets {
+		if t.Container != "" || t.Pod != "" {
+			containerTarget, targetErr := getContainerTargetFromYAML(t)
+			targets = append(targets, containerTarget)
+			targetErrs = append(targetErrs, targetErr)
+			continue
+		}
 		newTarget := target.NewRemoteTarget(t.Name, t.Host, t.Port, t.User, t.Key)
 		newTarget.SetSshPassPath(sshPassPath)
 		newTarget.SetSshPass(t.Pwd)
-		if !newTarget.CanConnect() {
+		newTarget.SetNativeSSH(nativeSSH)
+		jumpHosts := defaultJumpHosts
+		if len(t.Jump) > 0 {
+			jumpHosts = nil
+			for _, j := range t.Jump {
+				jumpHosts = append(jumpHosts, target.JumpHost{Host: j.Host, Port: j.Port, User: j.User, Key: j.Key, Pwd: j.Pwd})
+			}
+		}
+		newTarget.SetJumpHosts(jumpHosts)
+		if jumpErr := validateJumpHosts(jumpHosts, nativeSSH); jumpErr != nil {
+			targetErrs = append(targetErrs, fmt.Errorf("target host (%s): %v", newTarget.GetName(), jumpErr))
+		} else if !newTarget.CanConnect() {
 			targetErrs = append(targetErrs, fmt.Errorf("failed to connect to target host (%s)", newTarget.GetName()))
 		} else {
 			targetErrs = append(targetErrs, nil)
@@ -244,6 +562,77 @@ func getTargetsFromFile(targetsFilePath string, localTempDir string) (targets []
 	return
 }
 
+// getContainerTargetFromYAML creates a container target from a targets file entry that specifies a
+// container or pod. It returns the target and a target error if the target can't be connected to.
+func getContainerTargetFromYAML(t targetFromYAML) (*target.ContainerTarget, error) {
+	var containerTarget *target.ContainerTarget
+	if t.Pod != "" {
+		containerTarget = target.NewPodTarget(t.Name, t.Pod, t.Namespace, t.Container)
+	} else {
+		containerTarget = target.NewContainerTarget(t.Name, t.Container)
+	}
+	if !containerTarget.CanConnect() {
+		return containerTarget, fmt.Errorf("failed to connect to container target (%s)", containerTarget.GetName())
+	}
+	return containerTarget, nil
+}
+
+// getJumpHostsFromFlags parses the jump host flags into a list of jump hosts.
+func getJumpHostsFromFlags(cmd *cobra.Command) (jumpHosts []target.JumpHost, err error) {
+	jumpHostFlags, _ := cmd.Flags().GetStringSlice(flagJumpHostsName)
+	jumpKeyFlags, _ := cmd.Flags().GetStringSlice(flagJumpKeysName)
+	if len(jumpKeyFlags) > len(jumpHostFlags) {
+		err = fmt.Errorf("more jump host keys (%d) than jump hosts (%d)", len(jumpKeyFlags), len(jumpHostFlags))
+		return
+	}
+	for i, jumpHostFlag := range jumpHostFlags {
+		var jumpHost target.JumpHost
+		if jumpHost, err = parseJumpHost(jumpHostFlag); err != nil {
+			return
+		}
+		if i < len(jumpKeyFlags) {
+			jumpHost.Key = jumpKeyFlags[i]
+		}
+		jumpHosts = append(jumpHosts, jumpHost)
+	}
+	return
+}
+
+// parseJumpHost parses a jump host in [user@]host[:port] format. IPv6 addresses
+// with a port must be enclosed in square brackets, e.g., [fe80::1]:2222.
+func parseJumpHost(s string) (jumpHost target.JumpHost, err error) {
+	hostPort := s
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		jumpHost.User = s[:i]
+		hostPort = s[i+1:]
+	}
+	jumpHost.Host = hostPort
+	if strings.HasPrefix(hostPort, "[") || strings.Count(hostPort, ":") == 1 {
+		if jumpHost.Host, jumpHost.Port, err = net.SplitHostPort(hostPort); err != nil {
+			err = fmt.Errorf("invalid jump host (%s): %v", s, err)
+			return
+		}
+	}
+	if jumpHost.Host == "" {
+		err = fmt.Errorf("invalid jump host (%s): missing host", s)
+	}
+	return
+}
+
+// validateJumpHosts checks that the jump hosts can be used with the selected SSH client.
+func validateJumpHosts(jumpHosts []target.JumpHost, nativeSSH bool) error {
+	for _, jumpHost := range jumpHosts {
+		if jumpHost.Host == "" {
+			return fmt.Errorf("jump host is missing host")
+		}
+		// sshpass can only answer the password prompt of the target, not those of the jump hosts
+		if jumpHost.Pwd != "" && jumpHost.Key == "" && !nativeSSH {
+			return fmt.Errorf("password authentication to jump host (%s) requires --%s", jumpHost.Host, flagNativeSSHName)
+		}
+	}
+	return nil
+}
+
 // getPassword prompts the user for a password and returns it as a string.
 // It takes a prompt string as input and displays the prompt to the user.
 // The user's input is hidden as they type, and the entered password is returned as a string.
@@ -258,6 +647,16 @@ func getPassword(prompt string) (string, error) {
 	return string(pwd), nil
 }
 
+// extractSshPass extracts the sshpass binary for the local host's architecture from resources
+// and returns its path.
+func extractSshPass(localTempDir string) (string, error) {
+	hostArchitecture, err := getHostArchitecture()
+	if err != nil {
+		return "", err
+	}
+	return util.ExtractResource(script.Resources, path.Join("resources", hostArchitecture, "sshpass"), localTempDir)
+}
+
 func getHostArchitecture() (string, error) {
 	if runtime.GOARCH == "amd64" {
 		return "x86_64", nil
@@ -0,0 +1,161 @@
+package common
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const testFleet = `
+defaults:
+  user: admin
+  key: /keys/admin
+  port: 22
+groups:
+  - name: databases
+    labels:
+      role: db
+    defaults:
+      user: dba
+      port: 2222
+      jump:
+        - host: bastion
+    targets:
+      - host: db1
+        labels:
+          cpu: spr
+      - host: db2
+        user: root
+        labels:
+          cpu: icx
+  - name: web
+    labels:
+      role: web
+      cpu: spr
+    targets:
+      - host: web1
+        pwd: secret
+      - name: web-pod
+        pod: web-5d4f8b7c9-x2x7q
+        labels:
+          cpu: emr
+targets:
+  - host: standalone
+    labels:
+      cpu: spr
+`
+
+func resolveTestFleet(t *testing.T, fleet string) ([]targetFromYAML, error) {
+	t.Helper()
+	var file targetsFile
+	if err := yaml.Unmarshal([]byte(fleet), &file); err != nil {
+		t.Fatalf("failed to parse targets file: %v", err)
+	}
+	return resolveTargets(file)
+}
+
+func TestResolveTargets(t *testing.T) {
+	targets, err := resolveTestFleet(t, testFleet)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resolved := make(map[string]targetFromYAML)
+	for _, target := range targets {
+		resolved[getTargetNameFromYAML(target)] = target
+	}
+	if len(resolved) != 5 {
+		t.Fatalf("expected 5 targets, got %d", len(resolved))
+	}
+	tests := []struct {
+		name string
+		user string
+		port string
+		key  string
+		pwd  string
+		jump int
+	}{
+		{"standalone", "admin", "22", "/keys/admin", "", 0},
+		{"db1", "dba", "2222", "/keys/admin", "", 1},
+		{"db2", "root", "2222", "/keys/admin", "", 1},
+		{"web1", "admin", "22", "", "secret", 0},
+		{"web-pod", "", "", "", "", 0},
+	}
+	for _, test := range tests {
+		target, ok := resolved[test.name]
+		if !ok {
+			t.Errorf("target %s not found", test.name)
+			continue
+		}
+		if target.User != test.user || target.Port != test.port || target.Key != test.key || target.Pwd != test.pwd || len(target.Jump) != test.jump {
+			t.Errorf("unexpected values for %s: %+v", test.name, target)
+		}
+	}
+	if labels := resolved["db1"].Labels; labels["group"] != "databases" || labels["role"] != "db" || labels["cpu"] != "spr" {
+		t.Errorf("unexpected labels for db1: %v", labels)
+	}
+	if labels := resolved["web-pod"].Labels; labels["cpu"] != "emr" || labels["role"] != "web" {
+		t.Errorf("target labels do not take precedence over group labels: %v", labels)
+	}
+}
+
+func TestResolveTargetsInvalid(t *testing.T) {
+	tests := map[string]string{
+		"missing host":        "targets:\n  - name: nohost\n",
+		"duplicate name":      "targets:\n  - host: a\n  - name: a\n    host: b\n",
+		"duplicate in groups": "groups:\n  - name: g1\n    targets:\n      - host: a\n  - name: g2\n    targets:\n      - host: a\n",
+		"duplicate pod":       "targets:\n  - pod: a\n    container: b\n  - pod: a\n    container: c\n",
+		"host and pod":        "targets:\n  - host: a\n    pod: b\n",
+		"unnamed group":       "groups:\n  - targets:\n      - host: a\n",
+		"empty jump":          "targets:\n  - host: a\n    jump:\n      - user: b\n",
+	}
+	for name, fleet := range tests {
+		if _, err := resolveTestFleet(t, fleet); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestSelectTargets(t *testing.T) {
+	targets, err := resolveTestFleet(t, testFleet)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := map[string][]string{
+		"role=db":            {"db1", "db2"},
+		"role=db,cpu=spr":    {"db1"},
+		" cpu = spr ":        {"standalone", "db1", "web1"},
+		"group=web":          {"web1", "web-pod"},
+		"role=db,cpu=emr":    nil,
+		"":                   {"standalone", "db1", "db2", "web1", "web-pod"},
+		"role=web,group=web": {"web1", "web-pod"},
+	}
+	for selector, expected := range tests {
+		labels, err := parseSelector(selector)
+		if err != nil {
+			t.Errorf("failed to parse selector %q: %v", selector, err)
+			continue
+		}
+		var names []string
+		for _, target := range selectTargets(targets, labels) {
+			names = append(names, getTargetNameFromYAML(target))
+		}
+		if len(names) != len(expected) {
+			t.Errorf("selector %q: expected %v, got %v", selector, expected, names)
+			continue
+		}
+		for i := range names {
+			if names[i] != expected[i] {
+				t.Errorf("selector %q: expected %v, got %v", selector, expected, names)
+				break
+			}
+		}
+	}
+	for _, selector := range []string{"role", "=db", "role=db,cpu"} {
+		if _, err := parseSelector(selector); err == nil {
+			t.Errorf("expected error for selector %q", selector)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package report
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// asciidoc.go renders reports as AsciiDoc. Tables with rows are rendered as tables, other tables as labeled lists of
+// their fields and values.
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// asciidocEscaper escapes the characters that would break an AsciiDoc table cell or list item, and keeps the line
+// breaks in values
+var asciidocEscaper = strings.NewReplacer("|", "\\|", "\r\n", " +\n", "\n", " +\n")
+
+// asciidocValue returns the escaped value, or the {empty} attribute if the value is empty
+func asciidocValue(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return "{empty}"
+	}
+	return asciidocEscaper.Replace(value)
+}
+
+func createAsciidocReport(allTableValues []TableValues, targetName string) (out []byte, err error) {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("= %s\n\n", targetName))
+	for _, tableValues := range allTableValues {
+		sb.WriteString(fmt.Sprintf("== %s\n\n", tableValues.Name))
+		if noData(tableValues) {
+			sb.WriteString(noDataFound + "\n\n")
+			continue
+		}
+		if tableValues.HasRows {
+			writeAsciidocTable(&sb, tableValues)
+		} else {
+			for _, field := range tableValues.Fields {
+				var value string
+				if len(field.Values) > 0 {
+					value = field.Values[0]
+				}
+				sb.WriteString(fmt.Sprintf("%s:: %s\n", strings.ReplaceAll(field.Name, "::", ":{empty}:"), asciidocValue(value)))
+			}
+			sb.WriteString("\n")
+		}
+		writeAsciidocNotes(&sb, tableValues.Notes)
+	}
+	out = []byte(sb.String())
+	return
+}
+
+func createAsciidocReportMultiTarget(allTargetsTableValues [][]TableValues, targetNames []string) (out []byte, err error) {
+	var sb strings.Builder
+	sb.WriteString("= " + strings.Join(targetNames, ", ") + "\n\n")
+	for _, tableName := range multiTargetTableNames(allTargetsTableValues) {
+		targetsTableValues, fieldNames := multiTargetTable(allTargetsTableValues, tableName)
+		sb.WriteString(fmt.Sprintf("== %s\n\n", tableName))
+		if hasRows(targetsTableValues) {
+			// a table for each target
+			for targetIdx, targetName := range targetNames {
+				targetTableValues := targetsTableValues[targetIdx]
+				sb.WriteString(fmt.Sprintf("=== %s\n\n", targetName))
+				if noData(targetTableValues) {
+					sb.WriteString(noDataFound + "\n\n")
+					continue
+				}
+				writeAsciidocTable(&sb, targetTableValues)
+				writeAsciidocNotes(&sb, targetTableValues.Notes)
+			}
+			continue
+		}
+		if !slices.ContainsFunc(targetsTableValues, func(tableValues TableValues) bool { return !noData(tableValues) }) {
+			sb.WriteString(noDataFound + "\n\n")
+			continue
+		}
+		// the targets' values side by side
+		sb.WriteString(fmt.Sprintf("[%%header,cols=\"h,%d*\"]\n|===\n|Field", len(targetNames)))
+		for _, targetName := range targetNames {
+			sb.WriteString(" |" + asciidocValue(targetName))
+		}
+		sb.WriteString("\n")
+		for _, fieldName := range fieldNames {
+			sb.WriteString("\n|" + asciidocValue(fieldName) + "\n")
+			for _, targetTableValues := range targetsTableValues {
+				sb.WriteString("|" + asciidocValue(firstFieldValue(targetTableValues, fieldName)) + "\n")
+			}
+		}
+		sb.WriteString("|===\n\n")
+	}
+	out = []byte(sb.String())
+	return
+}
+
+// writeAsciidocTable writes the table with a column for each field and a row for each of their values
+func writeAsciidocTable(sb *strings.Builder, tableValues TableValues) {
+	sb.WriteString(fmt.Sprintf("[%%header,cols=\"%d*\"]\n|===\n", len(tableValues.Fields)))
+	for i, field := range tableValues.Fields {
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+		sb.WriteString("|" + asciidocValue(field.Name))
+	}
+	sb.WriteString("\n")
+	for row := 0; row < len(tableValues.Fields[0].Values); row++ {
+		sb.WriteString("\n")
+		for _, field := range tableValues.Fields {
+			sb.WriteString("|" + asciidocValue(field.Values[row]) + "\n")
+		}
+	}
+	sb.WriteString("|===\n\n")
+}
+
+func writeAsciidocNotes(sb *strings.Builder, notes []string) {
+	for _, note := range notes {
+		sb.WriteString("NOTE: " + asciidocValue(note) + "\n\n")
+	}
+}
@@ -0,0 +1,116 @@
+package report
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import "testing"
+
+func TestAsciidocReport(t *testing.T) {
+	tests := []struct {
+		name     string
+		table    TableValues
+		expected string
+	}{
+		{
+			name: "fields",
+			table: TableValues{
+				TableDefinition: TableDefinition{Name: "Host"},
+				Fields:          []Field{{Name: "Name", Values: []string{"web1"}}, {Name: "Empty", Values: []string{" "}}, {Name: "a::b", Values: []string{"c"}}},
+			},
+			expected: "= target\n\n== Host\n\nName:: web1\nEmpty:: {empty}\na:{empty}:b:: c\n\n",
+		},
+		{
+			name: "pipes and newlines",
+			table: TableValues{
+				TableDefinition: TableDefinition{Name: "Kernel"},
+				Fields:          []Field{{Name: "Cmdline", Values: []string{"one|two\nthree\r\nfour"}}},
+			},
+			expected: "= target\n\n== Kernel\n\nCmdline:: one\\|two +\nthree +\nfour\n\n",
+		},
+		{
+			name: "rows",
+			table: TableValues{
+				TableDefinition: TableDefinition{Name: "NIC", HasRows: true},
+				Fields:          []Field{{Name: "Name", Values: []string{"eth0", "eth1"}}, {Name: "Speed", Values: []string{"10|Gb", ""}}},
+			},
+			expected: "= target\n\n== NIC\n\n[%header,cols=\"2*\"]\n|===\n|Name |Speed\n\n|eth0\n|10\\|Gb\n\n|eth1\n|{empty}\n|===\n\n",
+		},
+		{
+			name: "notes",
+			table: TableValues{
+				TableDefinition: TableDefinition{Name: "Host"},
+				Fields:          []Field{{Name: "Name", Values: []string{"web1"}}},
+				Notes:           []string{"a note"},
+			},
+			expected: "= target\n\n== Host\n\nName:: web1\n\nNOTE: a note\n\n",
+		},
+		{
+			name:     "no data",
+			table:    TableValues{TableDefinition: TableDefinition{Name: "NIC", HasRows: true}, Fields: []Field{{Name: "Name"}}},
+			expected: "= target\n\n== NIC\n\nNo data found.\n\n",
+		},
+	}
+	for _, test := range tests {
+		out, err := createAsciidocReport([]TableValues{test.table}, "target")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if string(out) != test.expected {
+			t.Errorf("%s: unexpected report:\n%q\nwant:\n%q", test.name, out, test.expected)
+		}
+	}
+}
+
+func TestAsciidocReportMultiTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		tables   [][]TableValues
+		expected string
+	}{
+		{
+			name: "side by side",
+			tables: [][]TableValues{
+				{{TableDefinition: TableDefinition{Name: "Host"}, Fields: []Field{{Name: "Name", Values: []string{"web1"}}, {Name: "OS", Values: []string{"a|b"}}}}},
+				{{TableDefinition: TableDefinition{Name: "Host"}, Fields: []Field{{Name: "Name", Values: []string{"web2"}}, {Name: "OS", Values: []string{"c\nd"}}}}},
+			},
+			expected: "= web1, web2\n\n== Host\n\n[%header,cols=\"h,2*\"]\n|===\n|Field |web1 |web2\n\n|Name\n|web1\n|web2\n\n|OS\n|a\\|b\n|c +\nd\n|===\n\n",
+		},
+		{
+			name: "mismatched fields",
+			tables: [][]TableValues{
+				{{TableDefinition: TableDefinition{Name: "Host"}, Fields: []Field{{Name: "Name", Values: []string{"web1"}}}}},
+				{{TableDefinition: TableDefinition{Name: "Host"}, Fields: []Field{{Name: "OS", Values: []string{"linux"}}, {Name: "Name", Values: []string{"web2"}}}}},
+			},
+			expected: "= web1, web2\n\n== Host\n\n[%header,cols=\"h,2*\"]\n|===\n|Field |web1 |web2\n\n|Name\n|web1\n|web2\n\n|OS\n|{empty}\n|linux\n|===\n\n",
+		},
+		{
+			name: "mismatched tables",
+			tables: [][]TableValues{
+				{{TableDefinition: TableDefinition{Name: "Host"}, Fields: []Field{{Name: "Name", Values: []string{"web1"}}}}},
+				{
+					{TableDefinition: TableDefinition{Name: "NIC", HasRows: true}, Fields: []Field{{Name: "Name", Values: []string{"eth0"}}}},
+					{TableDefinition: TableDefinition{Name: "Host"}, Fields: []Field{{Name: "Name", Values: []string{"web2"}}}},
+				},
+			},
+			expected: "= web1, web2\n\n== Host\n\n[%header,cols=\"h,2*\"]\n|===\n|Field |web1 |web2\n\n|Name\n|web1\n|web2\n|===\n\n" +
+				"== NIC\n\n=== web1\n\nNo data found.\n\n=== web2\n\n[%header,cols=\"1*\"]\n|===\n|Name\n\n|eth0\n|===\n\n",
+		},
+		{
+			name: "no data",
+			tables: [][]TableValues{
+				{{TableDefinition: TableDefinition{Name: "Host"}}},
+				{{TableDefinition: TableDefinition{Name: "Host"}, Fields: []Field{{Name: "Name"}}}},
+			},
+			expected: "= web1, web2\n\n== Host\n\nNo data found.\n\n",
+		},
+	}
+	for _, test := range tests {
+		out, err := createAsciidocReportMultiTarget(test.tables, []string{"web1", "web2"})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if string(out) != test.expected {
+			t.Errorf("%s: unexpected report:\n%q\nwant:\n%q", test.name, out, test.expected)
+		}
+	}
+}
@@ -209,6 +209,9 @@ func createHtmlReport(allTableValues []TableValues, targetName string) (out []by
 		} else {
 			sb.WriteString(DefaultHTMLTableRendererFunc(tableValues))
 		}
+		for _, note := range tableValues.Notes {
+			sb.WriteString("<p><i>Note: " + html.EscapeString(note) + "</i></p>\n")
+		}
 	}
 	sb.WriteString("</div>\n") // end of myTables
 	sb.WriteString("</main>\n")
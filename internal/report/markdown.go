@@ -0,0 +1,109 @@
+package report
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// markdown.go renders reports as GitHub-flavored Markdown, e.g., to paste into wikis and pull requests. Tables with
+// rows are rendered as tables, other tables as lists of their fields and values.
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// markdownEscaper escapes the characters that would break a Markdown table cell or list item
+var markdownEscaper = strings.NewReplacer("\\", "\\\\", "|", "\\|", "*", "\\*", "_", "\\_", "`", "\\`", "<", "&lt;", ">", "&gt;", "\r\n", "<br>", "\n", "<br>")
+
+func createMarkdownReport(allTableValues []TableValues, targetName string) (out []byte, err error) {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s\n\n", markdownEscaper.Replace(targetName)))
+	for _, tableValues := range allTableValues {
+		sb.WriteString(fmt.Sprintf("## %s\n\n", markdownEscaper.Replace(tableValues.Name)))
+		if noData(tableValues) {
+			sb.WriteString(noDataFound + "\n\n")
+			continue
+		}
+		if tableValues.HasRows {
+			writeMarkdownTable(&sb, tableValues)
+		} else {
+			for _, field := range tableValues.Fields {
+				var value string
+				if len(field.Values) > 0 {
+					value = field.Values[0]
+				}
+				sb.WriteString(fmt.Sprintf("- **%s:** %s\n", markdownEscaper.Replace(field.Name), markdownEscaper.Replace(value)))
+			}
+			sb.WriteString("\n")
+		}
+		writeMarkdownNotes(&sb, tableValues.Notes)
+	}
+	out = []byte(sb.String())
+	return
+}
+
+func createMarkdownReportMultiTarget(allTargetsTableValues [][]TableValues, targetNames []string) (out []byte, err error) {
+	var sb strings.Builder
+	sb.WriteString("# " + markdownEscaper.Replace(strings.Join(targetNames, ", ")) + "\n\n")
+	for _, tableName := range multiTargetTableNames(allTargetsTableValues) {
+		targetsTableValues, fieldNames := multiTargetTable(allTargetsTableValues, tableName)
+		sb.WriteString(fmt.Sprintf("## %s\n\n", markdownEscaper.Replace(tableName)))
+		if hasRows(targetsTableValues) {
+			// a table for each target
+			for targetIdx, targetName := range targetNames {
+				targetTableValues := targetsTableValues[targetIdx]
+				sb.WriteString(fmt.Sprintf("### %s\n\n", markdownEscaper.Replace(targetName)))
+				if noData(targetTableValues) {
+					sb.WriteString(noDataFound + "\n\n")
+					continue
+				}
+				writeMarkdownTable(&sb, targetTableValues)
+				writeMarkdownNotes(&sb, targetTableValues.Notes)
+			}
+			continue
+		}
+		if !slices.ContainsFunc(targetsTableValues, func(tableValues TableValues) bool { return !noData(tableValues) }) {
+			sb.WriteString(noDataFound + "\n\n")
+			continue
+		}
+		// the targets' values side by side
+		sb.WriteString("| Field |")
+		for _, targetName := range targetNames {
+			sb.WriteString(" " + markdownEscaper.Replace(targetName) + " |")
+		}
+		sb.WriteString("\n|" + strings.Repeat(" --- |", len(targetNames)+1) + "\n")
+		for _, fieldName := range fieldNames {
+			sb.WriteString("| **" + markdownEscaper.Replace(fieldName) + "** |")
+			for _, targetTableValues := range targetsTableValues {
+				sb.WriteString(" " + markdownEscaper.Replace(firstFieldValue(targetTableValues, fieldName)) + " |")
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+	out = []byte(sb.String())
+	return
+}
+
+// writeMarkdownTable writes the table with a column for each field and a row for each of their values
+func writeMarkdownTable(sb *strings.Builder, tableValues TableValues) {
+	sb.WriteString("|")
+	for _, field := range tableValues.Fields {
+		sb.WriteString(" " + markdownEscaper.Replace(field.Name) + " |")
+	}
+	sb.WriteString("\n|" + strings.Repeat(" --- |", len(tableValues.Fields)) + "\n")
+	for row := 0; row < len(tableValues.Fields[0].Values); row++ {
+		sb.WriteString("|")
+		for _, field := range tableValues.Fields {
+			sb.WriteString(" " + markdownEscaper.Replace(field.Values[row]) + " |")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+}
+
+func writeMarkdownNotes(sb *strings.Builder, notes []string) {
+	for _, note := range notes {
+		sb.WriteString(fmt.Sprintf("> **Note:** %s\n\n", markdownEscaper.Replace(note)))
+	}
+}
@@ -0,0 +1,116 @@
+package report
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import "testing"
+
+func TestMarkdownReport(t *testing.T) {
+	tests := []struct {
+		name     string
+		table    TableValues
+		expected string
+	}{
+		{
+			name: "fields",
+			table: TableValues{
+				TableDefinition: TableDefinition{Name: "Host"},
+				Fields:          []Field{{Name: "Name", Values: []string{"web1"}}, {Name: "Empty", Values: []string{""}}},
+			},
+			expected: "# target\n\n## Host\n\n- **Name:** web1\n- **Empty:** \n\n",
+		},
+		{
+			name: "pipes and newlines",
+			table: TableValues{
+				TableDefinition: TableDefinition{Name: "Kernel"},
+				Fields:          []Field{{Name: "a|b", Values: []string{"one|two\nthree\r\nfour"}}},
+			},
+			expected: "# target\n\n## Kernel\n\n- **a\\|b:** one\\|two<br>three<br>four\n\n",
+		},
+		{
+			name: "rows",
+			table: TableValues{
+				TableDefinition: TableDefinition{Name: "NIC", HasRows: true},
+				Fields:          []Field{{Name: "Name", Values: []string{"eth0", "eth1"}}, {Name: "Speed", Values: []string{"10|Gb", "25Gb"}}},
+			},
+			expected: "# target\n\n## NIC\n\n| Name | Speed |\n| --- | --- |\n| eth0 | 10\\|Gb |\n| eth1 | 25Gb |\n\n",
+		},
+		{
+			name: "notes",
+			table: TableValues{
+				TableDefinition: TableDefinition{Name: "Host"},
+				Fields:          []Field{{Name: "Name", Values: []string{"web1"}}},
+				Notes:           []string{"a_note"},
+			},
+			expected: "# target\n\n## Host\n\n- **Name:** web1\n\n> **Note:** a\\_note\n\n",
+		},
+		{
+			name:     "no data",
+			table:    TableValues{TableDefinition: TableDefinition{Name: "NIC", HasRows: true}, Fields: []Field{{Name: "Name"}}},
+			expected: "# target\n\n## NIC\n\nNo data found.\n\n",
+		},
+	}
+	for _, test := range tests {
+		out, err := createMarkdownReport([]TableValues{test.table}, "target")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if string(out) != test.expected {
+			t.Errorf("%s: unexpected report:\n%q\nwant:\n%q", test.name, out, test.expected)
+		}
+	}
+}
+
+func TestMarkdownReportMultiTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		tables   [][]TableValues
+		expected string
+	}{
+		{
+			name: "side by side",
+			tables: [][]TableValues{
+				{{TableDefinition: TableDefinition{Name: "Host"}, Fields: []Field{{Name: "Name", Values: []string{"web1"}}, {Name: "OS", Values: []string{"a|b"}}}}},
+				{{TableDefinition: TableDefinition{Name: "Host"}, Fields: []Field{{Name: "Name", Values: []string{"web2"}}, {Name: "OS", Values: []string{"c\nd"}}}}},
+			},
+			expected: "# web1, web2\n\n## Host\n\n| Field | web1 | web2 |\n| --- | --- | --- |\n| **Name** | web1 | web2 |\n| **OS** | a\\|b | c<br>d |\n\n",
+		},
+		{
+			name: "mismatched fields",
+			tables: [][]TableValues{
+				{{TableDefinition: TableDefinition{Name: "Host"}, Fields: []Field{{Name: "Name", Values: []string{"web1"}}}}},
+				{{TableDefinition: TableDefinition{Name: "Host"}, Fields: []Field{{Name: "OS", Values: []string{"linux"}}, {Name: "Name", Values: []string{"web2"}}}}},
+			},
+			expected: "# web1, web2\n\n## Host\n\n| Field | web1 | web2 |\n| --- | --- | --- |\n| **Name** | web1 | web2 |\n| **OS** |  | linux |\n\n",
+		},
+		{
+			name: "mismatched tables",
+			tables: [][]TableValues{
+				{{TableDefinition: TableDefinition{Name: "Host"}, Fields: []Field{{Name: "Name", Values: []string{"web1"}}}}},
+				{
+					{TableDefinition: TableDefinition{Name: "NIC", HasRows: true}, Fields: []Field{{Name: "Name", Values: []string{"eth0"}}}},
+					{TableDefinition: TableDefinition{Name: "Host"}, Fields: []Field{{Name: "Name", Values: []string{"web2"}}}},
+				},
+			},
+			expected: "# web1, web2\n\n## Host\n\n| Field | web1 | web2 |\n| --- | --- | --- |\n| **Name** | web1 | web2 |\n\n" +
+				"## NIC\n\n### web1\n\nNo data found.\n\n### web2\n\n| Name |\n| --- |\n| eth0 |\n\n",
+		},
+		{
+			name: "no data",
+			tables: [][]TableValues{
+				{{TableDefinition: TableDefinition{Name: "Host"}}},
+				{{TableDefinition: TableDefinition{Name: "Host"}, Fields: []Field{{Name: "Name"}}}},
+			},
+			expected: "# web1, web2\n\n## Host\n\nNo data found.\n\n",
+		},
+	}
+	for _, test := range tests {
+		out, err := createMarkdownReportMultiTarget(test.tables, []string{"web1", "web2"})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if string(out) != test.expected {
+			t.Errorf("%s: unexpected report:\n%q\nwant:\n%q", test.name, out, test.expected)
+		}
+	}
+}
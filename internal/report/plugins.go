@@ -0,0 +1,289 @@
+package report
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// plugins.go defines tables that are loaded at run time, e.g., from plugin files, rather than
+// compiled in. Their fields are extracted from script output with regular expressions or jq-style paths.
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"perfspect/internal/script"
+)
+
+// PluginTableDefinition defines a table whose fields are extracted from script output. It is
+// serializable so that it can be stored in raw reports along with the script output.
+type PluginTableDefinition struct {
+	Name      string        `yaml:"name" json:"name"`
+	MenuLabel string        `yaml:"menu" json:"menu,omitempty"` // defaults to the table name
+	HasRows   bool          `yaml:"rows" json:"rows,omitempty"` // a field has a value for each match, rather than the first
+	Fields    []PluginField `yaml:"fields" json:"fields"`
+}
+
+// PluginField defines a field of a PluginTableDefinition. Its values are extracted from the script's
+// standard output with either Regex or JQ.
+type PluginField struct {
+	Name   string `yaml:"name" json:"name"`
+	Script string `yaml:"script" json:"script"`
+	// Regex is matched against each line of the output, the value is the first capture group, or the whole match
+	Regex string `yaml:"regex" json:"regex,omitempty"`
+	// JQ is a jq-style path into the output, parsed as JSON, e.g., .devices[].name or .info."fw version"
+	JQ string `yaml:"jq" json:"jq,omitempty"`
+}
+
+// pluginTableDefinitions are the definitions of the plugin tables that have been added, by name
+var pluginTableDefinitions = make(map[string]PluginTableDefinition)
+
+// AddPluginTables validates the plugin table definitions and adds them to the tables that can be
+// used in reports.
+func AddPluginTables(definitions []PluginTableDefinition) error {
+	for _, definition := range definitions {
+		if _, ok := tableDefinitions[definition.Name]; ok {
+			return fmt.Errorf("table %s: a table with the same name already exists", definition.Name)
+		}
+		table, err := definition.tableDefinition()
+		if err != nil {
+			return fmt.Errorf("table %s: %v", definition.Name, err)
+		}
+		tableDefinitions[definition.Name] = table
+		pluginTableDefinitions[definition.Name] = definition
+	}
+	return nil
+}
+
+// HasTable returns true if a table with the given name is defined.
+func HasTable(name string) bool {
+	_, ok := tableDefinitions[name]
+	return ok
+}
+
+// GetFieldNames returns the names of the fields that the named table's definition returns when there is no data. It
+// returns nil for tables whose fields depend on the data, e.g., a column per device.
+func GetFieldNames(tableName string) (fieldNames []string) {
+	for _, field := range tableDefinitions[tableName].FieldsFunc(map[string]script.ScriptOutput{}) {
+		fieldNames = append(fieldNames, field.Name)
+	}
+	return
+}
+
+// getPluginTableDefinitions returns the definitions of the plugin tables among the named tables
+func getPluginTableDefinitions(tableNames []string) (definitions []PluginTableDefinition) {
+	for _, tableName := range tableNames {
+		if definition, ok := pluginTableDefinitions[tableName]; ok {
+			definitions = append(definitions, definition)
+		}
+	}
+	return
+}
+
+// tableDefinition validates the plugin table definition and forms the table definition
+func (d PluginTableDefinition) tableDefinition() (table TableDefinition, err error) {
+	if d.Name == "" {
+		err = fmt.Errorf("name is required")
+		return
+	}
+	if len(d.Fields) == 0 {
+		err = fmt.Errorf("at least one field is required")
+		return
+	}
+	extractors := make([]func(string) []string, len(d.Fields))
+	for i, field := range d.Fields {
+		if field.Name == "" || field.Script == "" {
+			err = fmt.Errorf("field %d: name and script are required", i)
+			return
+		}
+		if (field.Regex == "") == (field.JQ == "") {
+			err = fmt.Errorf("field %s: one of regex or jq is required", field.Name)
+			return
+		}
+		if field.Regex != "" {
+			var re *regexp.Regexp
+			if re, err = regexp.Compile(field.Regex); err != nil {
+				err = fmt.Errorf("field %s: invalid regex: %v", field.Name, err)
+				return
+			}
+			extractors[i] = func(output string) []string { return regexValues(re, output) }
+		} else {
+			var path []jqStep
+			if path, err = parseJQPath(field.JQ); err != nil {
+				err = fmt.Errorf("field %s: invalid jq path: %v", field.Name, err)
+				return
+			}
+			extractors[i] = func(output string) []string { return jqValues(path, output) }
+		}
+		if !slices.Contains(table.ScriptNames, field.Script) {
+			table.ScriptNames = append(table.ScriptNames, field.Script)
+		}
+	}
+	table.Name = d.Name
+	table.HasRows = d.HasRows
+	table.MenuLabel = d.MenuLabel
+	if table.MenuLabel == "" {
+		table.MenuLabel = d.Name
+	}
+	table.FieldsFunc = func(outputs map[string]script.ScriptOutput) []Field {
+		fields := make([]Field, len(d.Fields))
+		numValues := 1
+		for i, field := range d.Fields {
+			fields[i].Name = field.Name
+			fields[i].Values = extractors[i](outputs[field.Script].Stdout)
+			if !d.HasRows && len(fields[i].Values) > 1 {
+				fields[i].Values = fields[i].Values[:1]
+			}
+			if d.HasRows {
+				numValues = max(numValues, len(fields[i].Values))
+			}
+		}
+		// all fields must have the same number of values
+		for i := range fields {
+			for len(fields[i].Values) < numValues {
+				fields[i].Values = append(fields[i].Values, "")
+			}
+		}
+		return fields
+	}
+	return
+}
+
+// regexValues returns the first capture group, or the whole match if there are no groups, of
+// each line of the output that matches the regular expression
+func regexValues(re *regexp.Regexp, output string) (values []string) {
+	for _, line := range strings.Split(output, "\n") {
+		match := re.FindStringSubmatch(strings.TrimSpace(line))
+		if len(match) > 1 {
+			values = append(values, match[1])
+		} else if len(match) == 1 {
+			values = append(values, match[0])
+		}
+	}
+	return
+}
+
+// jqStep is a step in a jq-style path: an object key, an array index, or, if all is true, all
+// elements of an array or values of an object
+type jqStep struct {
+	key   string
+	index *int
+	all   bool
+}
+
+var jqKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*`)
+
+// parseJQPath parses a jq-style path, a subset of jq's syntax: ., .key, ."quoted key", [n], and []
+func parseJQPath(path string) (steps []jqStep, err error) {
+	rest := strings.TrimSpace(path)
+	if !strings.HasPrefix(rest, ".") {
+		return nil, fmt.Errorf("path must start with '.': %s", path)
+	}
+	if rest == "." {
+		return
+	}
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "[]"):
+			steps = append(steps, jqStep{all: true})
+			rest = rest[2:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']' in %s", path)
+			}
+			index, convErr := strconv.Atoi(strings.TrimSpace(rest[1:end]))
+			if convErr != nil {
+				return nil, fmt.Errorf("invalid index in %s", path)
+			}
+			steps = append(steps, jqStep{index: &index})
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, `."`):
+			end := strings.Index(rest[2:], `"`)
+			if end < 0 {
+				return nil, fmt.Errorf("missing '\"' in %s", path)
+			}
+			steps = append(steps, jqStep{key: rest[2 : 2+end]})
+			rest = rest[2+end+1:]
+		case strings.HasPrefix(rest, "."):
+			key := jqKeyRegex.FindString(rest[1:])
+			if key == "" {
+				// a '.' before '[' is allowed, e.g., .[0]
+				if strings.HasPrefix(rest[1:], "[") {
+					rest = rest[1:]
+					continue
+				}
+				return nil, fmt.Errorf("invalid key in %s", path)
+			}
+			steps = append(steps, jqStep{key: key})
+			rest = rest[1+len(key):]
+		default:
+			return nil, fmt.Errorf("unexpected %q in %s", rest, path)
+		}
+	}
+	return
+}
+
+// jqValues returns the values at the path in the output, parsed as JSON. Values that aren't strings,
+// numbers, or booleans are returned as JSON.
+func jqValues(path []jqStep, output string) (values []string) {
+	decoder := json.NewDecoder(strings.NewReader(output))
+	decoder.UseNumber()
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return
+	}
+	current := []any{document}
+	for _, step := range path {
+		var next []any
+		for _, value := range current {
+			switch v := value.(type) {
+			case map[string]any:
+				if step.all {
+					// Go maps are unordered, so values are in key order
+					keys := make([]string, 0, len(v))
+					for key := range v {
+						keys = append(keys, key)
+					}
+					slices.Sort(keys)
+					for _, key := range keys {
+						next = append(next, v[key])
+					}
+				} else if element, ok := v[step.key]; ok && step.index == nil {
+					next = append(next, element)
+				}
+			case []any:
+				if step.all {
+					next = append(next, v...)
+				} else if step.index != nil {
+					index := *step.index
+					if index < 0 {
+						index += len(v)
+					}
+					if index >= 0 && index < len(v) {
+						next = append(next, v[index])
+					}
+				}
+			}
+		}
+		current = next
+	}
+	for _, value := range current {
+		switch v := value.(type) {
+		case nil:
+			values = append(values, "")
+		case string:
+			values = append(values, v)
+		case json.Number:
+			values = append(values, v.String())
+		case bool:
+			values = append(values, strconv.FormatBool(v))
+		default:
+			encoded, _ := json.Marshal(v)
+			values = append(values, string(encoded))
+		}
+	}
+	return
+}
@@ -11,10 +11,12 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 
 	"perfspect/internal/script"
+	"perfspect/internal/util"
 
 	"github.com/xuri/excelize/v2"
 )
@@ -24,13 +26,21 @@ const (
 	FormatXlsx = "xlsx"
 	FormatJson = "json"
 	FormatTxt  = "txt"
+	FormatMd   = "md"
+	FormatAdoc = "adoc"
 	FormatRaw  = "raw"
 	FormatAll  = "all"
+
+	// the tables are exported as files, a file per table, rather than as a report, see common.exportTables
+	FormatCsv = "csv"
 )
 
 const noDataFound = "No data found."
 
-var FormatOptions = []string{FormatHtml, FormatXlsx, FormatJson, FormatTxt}
+var FormatOptions = []string{FormatHtml, FormatXlsx, FormatJson, FormatTxt, FormatMd, FormatAdoc}
+
+// TableFormatOptions are the formats that write a file per table, they aren't included in FormatAll
+var TableFormatOptions = []string{FormatCsv}
 
 // Process processes the given tables and script outputs to generate table values.
 // It collects values for each field in the tables and returns a slice of TableValues.
@@ -45,11 +55,11 @@ func Process(tableNames []string, scriptOutputs map[string]script.ScriptOutput)
 
 // Create generates a report in the specified format based on the provided tables, table values, and script outputs.
 // The function ensures that all fields have the same number of values before generating the report.
-// It supports formats such as txt, json, html, xlsx.
+// It supports formats such as txt, json, html, xlsx, md, adoc.
 // If the format is not supported, the function panics with an error message.
 //
 // Parameters:
-// - format: The desired format of the report (txt, json, html, xlsx, raw).
+// - format: The desired format of the report (txt, json, html, xlsx, md, adoc).
 // - tableValues: The values for each field in each table.
 // - scriptOutputs: The outputs of any scripts used in the report.
 // - targetName: The name of the target for which the report is being generated.
@@ -81,6 +91,10 @@ func Create(format string, allTableValues []TableValues, scriptOutputs map[strin
 		return createHtmlReport(allTableValues, targetName)
 	case FormatXlsx:
 		return createXlsxReport(allTableValues)
+	case FormatMd:
+		return createMarkdownReport(allTableValues, targetName)
+	case FormatAdoc:
+		return createAsciidocReport(allTableValues, targetName)
 	}
 	panic(fmt.Sprintf("expected one of %s, got %s", strings.Join(FormatOptions, ", "), format))
 }
@@ -91,8 +105,62 @@ func CreateMultiTarget(format string, allTargetsTableValues [][]TableValues, tar
 		return createHtmlReportMultiTarget(allTargetsTableValues, targetNames)
 	case "xlsx":
 		return createXlsxReportMultiTarget(allTargetsTableValues, targetNames)
+	case FormatMd:
+		return createMarkdownReportMultiTarget(allTargetsTableValues, targetNames)
+	case FormatAdoc:
+		return createAsciidocReportMultiTarget(allTargetsTableValues, targetNames)
+	}
+	panic("only HTML, XLSX, Markdown, and AsciiDoc multi-target report supported currently")
+}
+
+// multiTargetTableNames returns the names of the targets' tables, in the order they first appear, so that a table
+// that some targets don't have is still reported
+func multiTargetTableNames(allTargetsTableValues [][]TableValues) (tableNames []string) {
+	for _, allTableValues := range allTargetsTableValues {
+		for _, tableValues := range allTableValues {
+			tableNames = util.UniqueAppend(tableNames, tableValues.Name)
+		}
+	}
+	return
+}
+
+// multiTargetTable returns each target's values for the named table, with only the name for a target that doesn't
+// have the table, and the names of the fields of the targets' tables, in the order they first appear
+func multiTargetTable(allTargetsTableValues [][]TableValues, tableName string) (targetsTableValues []TableValues, fieldNames []string) {
+	for _, allTableValues := range allTargetsTableValues {
+		tableValues := TableValues{TableDefinition: TableDefinition{Name: tableName}}
+		for _, targetTableValues := range allTableValues {
+			if targetTableValues.Name == tableName {
+				tableValues = targetTableValues
+				break
+			}
+		}
+		for _, field := range tableValues.Fields {
+			fieldNames = util.UniqueAppend(fieldNames, field.Name)
+		}
+		targetsTableValues = append(targetsTableValues, tableValues)
 	}
-	panic("only HTML and XLSX multi-target report supported currently")
+	return
+}
+
+// firstFieldValue returns the first value of the named field, or an empty string if the table doesn't have it
+func firstFieldValue(tableValues TableValues, fieldName string) string {
+	for _, field := range tableValues.Fields {
+		if field.Name == fieldName && len(field.Values) > 0 {
+			return field.Values[0]
+		}
+	}
+	return ""
+}
+
+// noData returns true if the table has no values
+func noData(tableValues TableValues) bool {
+	return len(tableValues.Fields) == 0 || len(tableValues.Fields[0].Values) == 0
+}
+
+// hasRows returns true if any of the targets' tables has rows
+func hasRows(targetsTableValues []TableValues) bool {
+	return slices.ContainsFunc(targetsTableValues, func(tableValues TableValues) bool { return tableValues.HasRows })
 }
 
 func createTextReport(allTableValues []TableValues) (out []byte, err error) {
@@ -113,6 +181,9 @@ func createTextReport(allTableValues []TableValues) (out []byte, err error) {
 		} else {
 			sb.WriteString(DefaultTextTableRendererFunc(tableValues))
 		}
+		for _, note := range tableValues.Notes {
+			sb.WriteString(fmt.Sprintf("Note: %s\n", note))
+		}
 		sb.WriteString("\n")
 	}
 	out = []byte(sb.String())
@@ -181,13 +252,15 @@ func DefaultTextTableRendererFunc(tableValues TableValues) string {
 	return sb.String()
 }
 
+// createJsonReport creates the JSON report, see JSONSchema for its schema
 func createJsonReport(allTableValues []TableValues) (out []byte, err error) {
-	type outRecord map[string]string
+	type outRecord map[string]any
 	type outTable []outRecord
-	type outReport map[string]outTable
+	type outReport map[string]any
 	oReport := make(outReport)
+	oReport[SchemaVersionKey] = SchemaVersion
 	for _, tableValues := range allTableValues {
-		var oTable outTable
+		oTable := outTable{} // an empty array, rather than null, if the table has no fields
 		if len(tableValues.Fields) == 0 {
 			oReport[tableValues.Name] = oTable
 			continue
@@ -197,7 +270,7 @@ func createJsonReport(allTableValues []TableValues) (out []byte, err error) {
 			for recordIdx := 0; recordIdx < numRecords; recordIdx++ {
 				oRecord := make(outRecord)
 				for _, field := range tableValues.Fields {
-					oRecord[field.Name] = field.Values[recordIdx]
+					oRecord[field.Name] = jsonValue(tableValues.Name, field.Name, field.Values[recordIdx])
 				}
 				oTable = append(oTable, oRecord)
 			}
@@ -205,7 +278,7 @@ func createJsonReport(allTableValues []TableValues) (out []byte, err error) {
 			// insert an empty record
 			oRecord := make(outRecord)
 			for _, field := range tableValues.Fields {
-				oRecord[field.Name] = ""
+				oRecord[field.Name] = jsonValue(tableValues.Name, field.Name, "")
 			}
 			oTable = append(oTable, oRecord)
 		}
@@ -247,6 +320,10 @@ func renderXlsxTable(tableValues TableValues, f *excelize.File, sheetName string
 	} else {
 		DefaultXlsxTableRendererFunc(tableValues, f, sheetName, row)
 	}
+	for _, note := range tableValues.Notes {
+		_ = f.SetCellValue(sheetName, cellName(col, *row), "Note: "+note)
+		*row++
+	}
 	*row++
 }
 
@@ -471,10 +548,12 @@ func getValueForCell(value string) (val interface{}) {
 }
 
 // RawReport represents a raw report containing the target name, table names, and script outputs.
+// The definitions of any plugin tables are included so that the report can be recreated without the plugins.
 type RawReport struct {
 	TargetName    string                         // json:"target_name"
 	TableNames    []string                       // json:"table_names"
 	ScriptOutputs map[string]script.ScriptOutput // json:"script_outputs"
+	PluginTables  []PluginTableDefinition        `json:",omitempty"`
 }
 
 func CreateRawReport(tableNames []string, scriptOutputs map[string]script.ScriptOutput, targetName string) (out []byte, err error) {
@@ -482,6 +561,7 @@ func CreateRawReport(tableNames []string, scriptOutputs map[string]script.Script
 		TargetName:    targetName,
 		TableNames:    tableNames,
 		ScriptOutputs: scriptOutputs,
+		PluginTables:  getPluginTableDefinitions(tableNames),
 	}
 	out, err = json.MarshalIndent(report, "", " ")
 	return
@@ -537,7 +617,7 @@ func readRawReport(rawReportPath string) (report RawReport, err error) {
 	return
 }
 
-// WriteReport writes the report bytes to the specified path.
+// WriteReport writes the report bytes to the specified path, and records the file, see util.RecordFile.
 func WriteReport(reportBytes []byte, reportPath string) error {
 	err := os.WriteFile(reportPath, reportBytes, 0644)
 	if err != nil {
@@ -546,5 +626,6 @@ func WriteReport(reportBytes []byte, reportPath string) error {
 		slog.Error(err.Error())
 		return err
 	}
+	util.RecordFile(reportPath)
 	return nil
 }
@@ -0,0 +1,258 @@
+package report
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// schema.go defines the schema of the JSON report, the type and unit of each field, so that consumers can validate
+// the reports and rely on their shape across releases
+
+import (
+	"encoding/json"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SchemaVersion is the version of the JSON report's schema, it is included in the reports. The major version is
+// incremented when a change can break consumers, e.g., a field is removed or renamed, or its type or unit changes. The
+// minor version is incremented when tables or fields are added, and the patch version for other changes, e.g., to
+// the descriptions.
+const SchemaVersion = "2.0.0"
+
+// SchemaVersionKey is the key of the schema version in the JSON report
+const SchemaVersionKey = "schemaVersion"
+
+// the types of the fields in the JSON report, integer and number fields are objects with the value as collected and
+// the number, see typedValue
+const (
+	FieldTypeString  = "string"
+	FieldTypeInteger = "integer"
+	FieldTypeNumber  = "number"
+)
+
+// FieldSchema is the type of a field's values in the JSON report, and their unit, if they have one
+type FieldSchema struct {
+	Type string
+	Unit string
+}
+
+var (
+	integerField = FieldSchema{Type: FieldTypeInteger}
+	ghzField     = FieldSchema{Type: FieldTypeNumber, Unit: "GHz"}
+	kbField      = FieldSchema{Type: FieldTypeInteger, Unit: "kB"}
+	percentField = FieldSchema{Type: FieldTypeNumber, Unit: "%"}
+	rateField    = FieldSchema{Type: FieldTypeNumber, Unit: "1/s"}
+	kbRateField  = FieldSchema{Type: FieldTypeNumber, Unit: "kB/s"}
+	wattsField   = FieldSchema{Type: FieldTypeNumber, Unit: "W"}
+)
+
+// fieldSchemas are the fields whose values are numbers, by table and field name. The other fields are strings.
+var fieldSchemas = map[string]map[string]FieldSchema{
+	CPUTableName: {
+		"Family":                     integerField,
+		"Model":                      integerField,
+		"Stepping":                   integerField,
+		"Base Frequency":             ghzField,
+		"Maximum Frequency":          ghzField,
+		"All-core Maximum Frequency": ghzField,
+		"CPUs":                       integerField,
+		"Cores per Socket":           integerField,
+		"Sockets":                    integerField,
+		"NUMA Nodes":                 integerField,
+		"Memory Channels":            integerField,
+	},
+	UncoreTableName: {
+		"Min Frequency": ghzField,
+		"Max Frequency": ghzField,
+		"CHA Count":     integerField,
+	},
+	MemoryTableName: {
+		"MemTotal":        kbField,
+		"MemFree":         kbField,
+		"MemAvailable":    kbField,
+		"Buffers":         kbField,
+		"Cached":          kbField,
+		"HugePages_Total": integerField,
+		"Hugepagesize":    kbField,
+	},
+	CPUUtilizationTableName: {
+		"CPU":     integerField,
+		"CORE":    integerField,
+		"SOCK":    integerField,
+		"NODE":    integerField,
+		"%usr":    percentField,
+		"%nice":   percentField,
+		"%sys":    percentField,
+		"%iowait": percentField,
+		"%irq":    percentField,
+		"%soft":   percentField,
+		"%steal":  percentField,
+		"%guest":  percentField,
+		"%gnice":  percentField,
+		"%idle":   percentField,
+	},
+	AverageCPUUtilizationTableName: {
+		"%usr":    percentField,
+		"%nice":   percentField,
+		"%sys":    percentField,
+		"%iowait": percentField,
+		"%irq":    percentField,
+		"%soft":   percentField,
+		"%steal":  percentField,
+		"%guest":  percentField,
+		"%gnice":  percentField,
+		"%idle":   percentField,
+	},
+	IRQRateTableName: {
+		"CPU":        integerField,
+		"HI/s":       rateField,
+		"TIMER/s":    rateField,
+		"NET_TX/s":   rateField,
+		"NET_RX/s":   rateField,
+		"BLOCK/s":    rateField,
+		"IRQ_POLL/s": rateField,
+		"TASKLET/s":  rateField,
+		"SCHED/s":    rateField,
+		"HRTIMER/s":  rateField,
+		"RCU/s":      rateField,
+	},
+	DriveStatsTableName: {
+		"tps":       rateField,
+		"kB_read/s": kbRateField,
+		"kB_wrtn/s": kbRateField,
+		"kB_dscd/s": kbRateField,
+	},
+	NetworkStatsTableName: {
+		"rxpck/s": rateField,
+		"txpck/s": rateField,
+		"rxkB/s":  kbRateField,
+		"txkB/s":  kbRateField,
+	},
+	MemoryStatsTableName: {
+		"free":     kbField,
+		"avail":    kbField,
+		"used":     kbField,
+		"buffers":  kbField,
+		"cache":    kbField,
+		"commit":   kbField,
+		"active":   kbField,
+		"inactive": kbField,
+		"dirty":    kbField,
+	},
+	PowerStatsTableName: {
+		"Package": wattsField,
+		"DRAM":    wattsField,
+	},
+}
+
+// GetFieldSchema returns the type and unit of the field's values in the JSON report
+func GetFieldSchema(tableName string, fieldName string) FieldSchema {
+	if schema, ok := fieldSchemas[tableName][fieldName]; ok {
+		return schema
+	}
+	return FieldSchema{Type: FieldTypeString}
+}
+
+// leadingNumberRegex matches the number at the start of a value, e.g., 2.1 in 2.1GHz or 6158152 in 6158152 kB
+var leadingNumberRegex = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)`)
+
+// typedValue is the value of an integer or number field in the JSON report. The value is kept as it was collected,
+// e.g., 2.1GHz, because the number doesn't always capture it, e.g., 2.1-3.5GHz.
+type typedValue struct {
+	Value  string `json:"value"`
+	Number any    `json:"number"` // the number at the start of the value, or nil, i.e., null, if there isn't one of the type
+}
+
+// jsonValue returns the value as the field's type in the JSON report, the value itself for string fields and a
+// typedValue for integer and number fields
+func jsonValue(tableName string, fieldName string, value string) any {
+	schema := GetFieldSchema(tableName, fieldName)
+	if schema.Type == FieldTypeString {
+		return value
+	}
+	typed := typedValue{Value: value}
+	number := leadingNumberRegex.FindString(strings.TrimSpace(value))
+	if schema.Type == FieldTypeInteger {
+		if i, err := strconv.ParseInt(number, 10, 64); err == nil {
+			typed.Number = i
+		}
+	} else if f, err := strconv.ParseFloat(number, 64); err == nil {
+		typed.Number = f
+	}
+	return typed
+}
+
+// JSONSchema returns the JSON Schema of the JSON report. It describes the defined tables, and the extra tables,
+// e.g., the tables that the commands add to the reports after processing, such as Insights. The fields of a table
+// are the fields that its definition returns when there is no data, and the fields with a type other than string.
+// Fields that depend on the data, e.g., a column per device, are allowed as strings.
+func JSONSchema(extraTableValues []TableValues) (out []byte, err error) {
+	tableSchemas := make(map[string]any)
+	for _, name := range slices.Sorted(maps.Keys(tableDefinitions)) {
+		tableSchemas[name] = tableJSONSchema(name, GetFieldNames(name))
+	}
+	for _, tableValues := range extraTableValues {
+		var fieldNames []string
+		for _, field := range tableValues.Fields {
+			fieldNames = append(fieldNames, field.Name)
+		}
+		tableSchemas[tableValues.Name] = tableJSONSchema(tableValues.Name, fieldNames)
+	}
+	tableSchemas[SchemaVersionKey] = map[string]any{
+		"description": "the version of the schema that the report conforms to",
+		"const":       SchemaVersion,
+	}
+	schema := map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         "urn:perfspect:report:" + SchemaVersion,
+		"title":       "PerfSpect JSON report",
+		"description": "The tables of the report, by name. Each table is an array of records, by field name. Tables that aren't listed, e.g., from plugins, have string fields.",
+		"type":        "object",
+		"required":    []string{SchemaVersionKey},
+		"properties":  tableSchemas,
+		"additionalProperties": map[string]any{
+			"type":  "array",
+			"items": map[string]any{"type": "object", "additionalProperties": map[string]any{"type": FieldTypeString}},
+		},
+	}
+	return json.MarshalIndent(schema, "", " ")
+}
+
+// tableJSONSchema returns the JSON Schema of the table's records
+func tableJSONSchema(tableName string, fieldNames []string) map[string]any {
+	for fieldName := range fieldSchemas[tableName] {
+		if !slices.Contains(fieldNames, fieldName) {
+			fieldNames = append(fieldNames, fieldName)
+		}
+	}
+	fieldJSONSchemas := make(map[string]any)
+	for _, fieldName := range fieldNames {
+		fieldSchema := GetFieldSchema(tableName, fieldName)
+		fieldJSONSchema := map[string]any{"type": fieldSchema.Type}
+		if fieldSchema.Type != FieldTypeString {
+			fieldJSONSchema = map[string]any{
+				"type":     "object",
+				"required": []string{"value", "number"},
+				"properties": map[string]any{
+					"value":  map[string]any{"type": FieldTypeString, "description": "the value as collected"},
+					"number": map[string]any{"type": []string{fieldSchema.Type, "null"}, "description": "the number at the start of the value, null if there isn't one"},
+				},
+				"additionalProperties": false,
+			}
+		}
+		if fieldSchema.Unit != "" {
+			fieldJSONSchema["unit"] = fieldSchema.Unit
+		}
+		fieldJSONSchemas[fieldName] = fieldJSONSchema
+	}
+	return map[string]any{
+		"type": "array",
+		"items": map[string]any{
+			"type":                 "object",
+			"properties":           fieldJSONSchemas,
+			"additionalProperties": map[string]any{"type": FieldTypeString},
+		},
+	}
+}
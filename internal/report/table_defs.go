@@ -12,6 +12,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"perfspect/internal/cpudb"
 	"perfspect/internal/script"
@@ -58,6 +59,7 @@ type TableValues struct {
 	TableDefinition
 	Fields   []Field
 	Insights []Insight
+	Notes    []string // e.g., the data was collected with a fallback script
 }
 
 const (
@@ -95,6 +97,8 @@ const (
 	SystemEventLogTableName     = "System Event Log"
 	KernelLogTableName          = "Kernel Log"
 	SystemSummaryTableName      = "System Summary"
+	SkippedDataTableName        = "Skipped Data"
+	DiagnosticsTableName        = "Collection Diagnostics"
 	// benchmark table names
 	CPUSpeedTableName       = "CPU Speed"
 	CPUPowerTableName       = "CPU Power"
@@ -639,6 +643,107 @@ func GetValuesForTable(name string, outputs map[string]script.ScriptOutput) Tabl
 	if table.InsightsFunc != nil {
 		tableValues.Insights = table.InsightsFunc(outputs, tableValues)
 	}
+	// note the scripts whose output came from a fallback, the data may be less complete
+	for _, scriptName := range table.ScriptNames {
+		if output := outputs[scriptName]; output.Variant != "" && output.Exitcode == 0 {
+			tableValues.Notes = append(tableValues.Notes, fmt.Sprintf("%s: collected with the %s fallback", scriptName, output.Variant))
+		}
+	}
+	return tableValues
+}
+
+// GetSkippedDataTableValues returns the Skipped Data table, which lists the scripts that were not run
+// for each of the tables, and why, i.e., the data that is missing from the tables.
+func GetSkippedDataTableValues(tableNames []string, outputs map[string]script.ScriptOutput) TableValues {
+	tableValues := TableValues{
+		TableDefinition: TableDefinition{
+			Name:      SkippedDataTableName,
+			HasRows:   true,
+			MenuLabel: SkippedDataTableName,
+		},
+		Fields: []Field{
+			{Name: "Table", Values: []string{}},
+			{Name: "Script", Values: []string{}},
+			{Name: "Reason", Values: []string{}},
+		},
+	}
+	for _, tableName := range tableNames {
+		for _, scriptName := range GetScriptNamesForTable(tableName) {
+			if reason := outputs[scriptName].SkipReason; reason != "" {
+				tableValues.Fields[0].Values = append(tableValues.Fields[0].Values, tableName)
+				tableValues.Fields[1].Values = append(tableValues.Fields[1].Values, scriptName)
+				tableValues.Fields[2].Values = append(tableValues.Fields[2].Values, reason)
+			}
+		}
+	}
+	return tableValues
+}
+
+// GetCollectionDiagnosticsTableValues returns the Collection Diagnostics table, which lists how each of the scripts
+// for the tables ran, e.g., to find out why a table has no data.
+func GetCollectionDiagnosticsTableValues(tableNames []string, outputs map[string]script.ScriptOutput) TableValues {
+	tableValues := TableValues{
+		TableDefinition: TableDefinition{
+			Name:      DiagnosticsTableName,
+			HasRows:   true,
+			MenuLabel: DiagnosticsTableName,
+		},
+		Fields: []Field{
+			{Name: "Script", Values: []string{}},
+			{Name: "Tables", Values: []string{}},
+			{Name: "Status", Values: []string{}},
+			{Name: "Exit Code", Values: []string{}},
+			{Name: "Start", Values: []string{}},
+			{Name: "Duration", Values: []string{}},
+			{Name: "Elevated", Values: []string{}},
+			{Name: "Attempts", Values: []string{}},
+			{Name: "Fallback", Values: []string{}},
+			{Name: "Tool Version", Values: []string{}},
+			{Name: "Stderr", Values: []string{}},
+		},
+	}
+	var scriptNames []string
+	scriptTables := make(map[string][]string)
+	for _, tableName := range tableNames {
+		for _, scriptName := range GetScriptNamesForTable(tableName) {
+			if _, ok := scriptTables[scriptName]; !ok {
+				scriptNames = append(scriptNames, scriptName)
+			}
+			scriptTables[scriptName] = append(scriptTables[scriptName], tableName)
+		}
+	}
+	for _, scriptName := range scriptNames {
+		output, ok := outputs[scriptName]
+		var status, exitcode, start, duration, elevated, attempts string
+		switch {
+		case !ok:
+			status = "not run" // e.g., collection was interrupted
+		case output.SkipReason != "":
+			status = "skipped: " + output.SkipReason
+		default:
+			status = "succeeded"
+			if output.Exitcode != 0 {
+				status = "failed"
+			}
+			exitcode = strconv.Itoa(output.Exitcode)
+			if !output.Start.IsZero() {
+				start = output.Start.Format(time.RFC3339)
+			}
+			duration = output.Duration.Round(time.Millisecond).String()
+			elevated = strconv.FormatBool(output.Elevated)
+			if output.Attempts > 0 {
+				attempts = strconv.Itoa(output.Attempts)
+			}
+		}
+		// the first line of stderr is usually enough to tell why the script failed
+		stderr, _, _ := strings.Cut(strings.TrimSpace(output.Stderr), "\n")
+		if len(stderr) > 120 {
+			stderr = stderr[:120] + "..."
+		}
+		for i, value := range []string{scriptName, strings.Join(scriptTables[scriptName], ", "), status, exitcode, start, duration, elevated, attempts, output.Variant, output.ToolVersion, stderr} {
+			tableValues.Fields[i].Values = append(tableValues.Fields[i].Values, value)
+		}
+	}
 	return tableValues
 }
 
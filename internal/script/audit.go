@@ -0,0 +1,154 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// audit.go records the privileged actions that change the state of the targets, e.g., loading kernel modules and
+// writing MSRs, with the values before and after the change and whether the original value was restored, so that
+// it can be confirmed that the targets were returned to their original state
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"perfspect/internal/target"
+)
+
+// the restore statuses of the audit entries
+const (
+	RestoreRestored    = "restored"
+	RestoreFailed      = "restore failed"
+	RestoreNotRestored = "not restored" // the original value should have been restored but wasn't, e.g., the run was interrupted
+	RestoreNotRequired = "not required" // the change was requested, e.g., with the config command
+	restorePending     = "pending"      // the original value will be restored
+)
+
+// auditLinePrefix starts the lines that scripts print to stderr to record their changes, see AuditChange and AuditRestore
+const auditLinePrefix = "perfspect-audit"
+
+// AuditEntry is a privileged action that changed the state of a target
+type AuditEntry struct {
+	Target        string     `json:"target"`
+	Time          time.Time  `json:"time"`
+	Action        string     `json:"action"` // e.g., load kernel module, write msr
+	Item          string     `json:"item"`   // what was changed, e.g., msr, 0x1B0, /proc/sys/kernel/kptr_restrict
+	Before        string     `json:"before"`
+	After         string     `json:"after"`
+	Restore       string     `json:"restore"`
+	RestoreTime   *time.Time `json:"restore_time,omitempty"`
+	RestoredValue string     `json:"restored_value,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+var (
+	auditLog      []AuditEntry
+	auditLogMutex sync.Mutex
+)
+
+// Audit records a change to the target. If restore is true, the original value is expected to be restored before
+// the run ends, see Restored.
+func Audit(myTarget target.Target, action string, item string, before string, after string, restore bool) {
+	auditAt(myTarget.GetName(), time.Now(), action, item, before, after, restore)
+}
+
+func auditAt(targetName string, t time.Time, action string, item string, before string, after string, restore bool) {
+	entry := AuditEntry{Target: targetName, Time: t, Action: action, Item: item, Before: before, After: after, Restore: RestoreNotRequired}
+	if restore {
+		entry.Restore = restorePending
+	}
+	slog.Info("privileged action", slog.String("target", targetName), slog.String("action", action), slog.String("item", item), slog.String("before", before), slog.String("after", after))
+	auditLogMutex.Lock()
+	defer auditLogMutex.Unlock()
+	auditLog = append(auditLog, entry)
+}
+
+// Restored records that the original value of the item was restored on the target, or the error if it wasn't
+func Restored(myTarget target.Target, item string, value string, err error) {
+	restoredAt(myTarget.GetName(), time.Now(), item, value, err)
+}
+
+func restoredAt(targetName string, t time.Time, item string, value string, err error) {
+	auditLogMutex.Lock()
+	defer auditLogMutex.Unlock()
+	// the most recent change to the item is restored
+	for i := len(auditLog) - 1; i >= 0; i-- {
+		entry := &auditLog[i]
+		if entry.Target != targetName || entry.Item != item || entry.Restore != restorePending {
+			continue
+		}
+		entry.RestoreTime = &t
+		entry.RestoredValue = value
+		entry.Restore = RestoreRestored
+		if err != nil {
+			entry.Restore = RestoreFailed
+			entry.Error = err.Error()
+		} else if value != entry.Before {
+			entry.Restore = RestoreFailed
+			entry.Error = fmt.Sprintf("the value is %q, not %q", value, entry.Before)
+		}
+		if entry.Restore == RestoreFailed {
+			slog.Error("failed to restore", slog.String("target", targetName), slog.String("item", item), slog.String("error", entry.Error))
+		}
+		return
+	}
+	slog.Warn("restored item that wasn't changed", slog.String("target", targetName), slog.String("item", item))
+}
+
+// AuditLog returns the changes that were made to the targets, in the order they were made
+func AuditLog() []AuditEntry {
+	auditLogMutex.Lock()
+	defer auditLogMutex.Unlock()
+	entries := make([]AuditEntry, len(auditLog))
+	copy(entries, auditLog)
+	for i := range entries {
+		if entries[i].Restore == restorePending {
+			entries[i].Restore = RestoreNotRestored
+		}
+	}
+	return entries
+}
+
+// AuditChange returns a POSIX sh command that records a change that the script made, it is added to the script after
+// the change. The values may be sh expansions, e.g., $before, that are expanded when the script runs. If restore is
+// true, the script is expected to restore the original value and record it with AuditRestore.
+func AuditChange(action string, item string, before string, after string, restore bool) string {
+	kind := "set"
+	if restore {
+		kind = "change"
+	}
+	return fmt.Sprintf("echo \"%s|%s|%s|%s|%s|%s\" >&2\n", auditLinePrefix, kind, action, item, before, after)
+}
+
+// AuditRestore returns a POSIX sh command that records the value of the item after the script restored it
+func AuditRestore(item string, value string) string {
+	return fmt.Sprintf("echo \"%s|restore|%s|%s\" >&2\n", auditLinePrefix, item, value)
+}
+
+// auditScriptOutput records the changes that the script printed to stderr, see AuditChange and AuditRestore
+func auditScriptOutput(myTarget target.Target, scriptOutput ScriptOutput) {
+	for _, line := range strings.Split(scriptOutput.Stderr, "\n") {
+		if !strings.HasPrefix(line, auditLinePrefix+"|") {
+			continue
+		}
+		fields := strings.Split(strings.TrimSpace(line), "|")
+		switch {
+		case (fields[1] == "set" || fields[1] == "change") && len(fields) == 6:
+			auditAt(myTarget.GetName(), orNow(scriptOutput.Start), fields[2], fields[3], fields[4], fields[5], fields[1] == "change")
+		case fields[1] == "restore" && len(fields) == 4:
+			restoredAt(myTarget.GetName(), orNow(scriptOutput.End), fields[2], fields[3], nil)
+		default:
+			slog.Warn("invalid audit line", slog.String("target", myTarget.GetName()), slog.String("script", scriptOutput.Name), slog.String("line", line))
+		}
+	}
+}
+
+// orNow returns the time, or the current time if it is zero
+func orNow(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
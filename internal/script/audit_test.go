@@ -0,0 +1,64 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"perfspect/internal/target"
+)
+
+func TestAuditScript(t *testing.T) {
+	auditLog = nil
+	defer func() { auditLog = nil }()
+	tgt := target.NewLocalTarget()
+	targetTempDir, err := tgt.CreateTempDirectory("/tmp")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tgt.RemoveDirectory(targetTempDir)
+	tempDir := t.TempDir()
+	item := filepath.Join(tempDir, "setting")
+	if err := os.WriteFile(item, []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the script changes the setting, restores it, and changes another setting that it doesn't restore
+	script := "before=$(cat " + item + ")\necho 0 > " + item + "\n" +
+		AuditChange("write setting", item, "$before", "$(cat "+item+")", true) +
+		"echo \"$before\" > " + item + "\n" +
+		AuditRestore(item, "$(cat "+item+")") +
+		AuditChange("write other setting", "other", "", "2", true) +
+		AuditChange("write requested setting", "requested", "3", "4", false)
+	if _, err := RunScript(tgt, ScriptDefinition{Name: "unittest audit", Script: script}, tempDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	Audit(tgt, "load kernel module", "unittest", "not loaded", "loaded", true)
+	Restored(tgt, "unittest", "loaded", nil)
+	entries := AuditLog()
+	if len(entries) != 4 {
+		t.Fatalf("unexpected audit log: %+v", entries)
+	}
+	tests := []struct {
+		item    string
+		before  string
+		after   string
+		restore string
+	}{
+		{item, "1", "0", RestoreRestored},
+		{"other", "", "2", RestoreNotRestored},
+		{"requested", "3", "4", RestoreNotRequired},
+		{"unittest", "not loaded", "loaded", RestoreFailed},
+	}
+	for i, test := range tests {
+		entry := entries[i]
+		if entry.Target != tgt.GetName() || entry.Item != test.item || entry.Before != test.before || entry.After != test.after || entry.Restore != test.restore || entry.Time.IsZero() {
+			t.Errorf("unexpected entry: got %+v, want %+v", entry, test)
+		}
+	}
+	if entries[0].RestoredValue != "1" || entries[0].RestoreTime == nil || entries[3].Error == "" {
+		t.Errorf("unexpected restores: %+v, %+v", entries[0], entries[3])
+	}
+}
@@ -0,0 +1,223 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// capabilities.go probes targets for the privileges and system features that scripts need
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"perfspect/internal/target"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// capabilities that scripts may require, see ScriptDefinition.Capabilities
+const (
+	CapabilityMSR     = "msr"     // read model specific registers through /dev/cpu/*/msr
+	CapabilityPerf    = "perf"    // collect system-wide perf events
+	CapabilityDebugfs = "debugfs" // read the kernel's debug file system
+	CapabilityIPMI    = "ipmi"    // access the BMC through an IPMI device
+)
+
+// Linux capability bits, see capabilities(7)
+const (
+	capSysRawio = 17
+	capSysAdmin = 21
+	capPerfmon  = 38
+)
+
+// Capabilities describes what scripts can do on a target, with and without elevated privileges.
+type Capabilities struct {
+	Elevate           bool // the user is root or can use password-less sudo
+	Root              bool // the user is root
+	PerfEventParanoid int  // the value of kernel.perf_event_paranoid, 4 if unknown
+	CapSysRawio       bool // the user has CAP_SYS_RAWIO, needed to open MSR devices
+	CapSysAdmin       bool // the user has CAP_SYS_ADMIN
+	CapPerfmon        bool // the user has CAP_PERFMON
+	MSRDevice         bool // MSR devices exist
+	MSRModule         bool // the msr kernel module can be loaded
+	MSRReadable       bool // the user can read the MSR devices' files
+	DebugfsMounted    bool // the debug file system is mounted
+	DebugfsReadable   bool // the user can read the debug file system
+	IPMIDevice        bool // an IPMI device exists
+	IPMIModule        bool // the IPMI kernel modules can be loaded
+	IPMIAccessible    bool // the user can read and write the IPMI device
+}
+
+// capabilitiesScript prints the target's capabilities as key=value lines, it only uses POSIX sh features
+const capabilitiesScript = `echo uid=$(id -u)
+echo perf_event_paranoid=$(cat /proc/sys/kernel/perf_event_paranoid 2>/dev/null)
+echo cap_eff=$(grep '^CapEff:' /proc/self/status 2>/dev/null | cut -f2)
+has_module() {
+	modinfo "$1" >/dev/null 2>&1 || modprobe -n "$1" >/dev/null 2>&1 || [ -d /sys/module/"$1" ] || grep -qs "/$1.ko" /lib/modules/$(uname -r)/modules.builtin
+}
+[ -e /dev/cpu/0/msr ] && echo msr_device=1
+[ -r /dev/cpu/0/msr ] && echo msr_readable=1
+has_module msr && echo msr_module=1
+grep -qs ' /sys/kernel/debug debugfs ' /proc/mounts && echo debugfs_mounted=1
+ls /sys/kernel/debug/ >/dev/null 2>&1 && [ -n "$(ls /sys/kernel/debug/ 2>/dev/null)" ] && echo debugfs_readable=1
+for device in /dev/ipmi0 /dev/ipmi/0 /dev/ipmidev/0; do
+	if [ -e "$device" ]; then
+		echo ipmi_device=1
+		[ -r "$device" ] && [ -w "$device" ] && echo ipmi_accessible=1
+		break
+	fi
+done
+has_module ipmi_devintf && has_module ipmi_si && echo ipmi_module=1
+exit 0
+`
+
+var (
+	capabilitiesCache      = make(map[string]Capabilities)
+	capabilitiesCacheMutex sync.Mutex
+)
+
+// GetCapabilities probes the target's capabilities. The result is cached for the target's name for the rest of
+// the run. It isn't kept in the target cache, because the capabilities depend on settings that can change without
+// a reboot, e.g., perf_event_paranoid and the loaded kernel modules.
+func GetCapabilities(myTarget target.Target, localTempDir string) (Capabilities, error) {
+	return getCapabilities(context.Background(), myTarget, localTempDir)
+}
+
+func getCapabilities(ctx context.Context, myTarget target.Target, localTempDir string) (capabilities Capabilities, err error) {
+	capabilitiesCacheMutex.Lock()
+	capabilities, ok := capabilitiesCache[myTarget.GetName()]
+	capabilitiesCacheMutex.Unlock()
+	if ok {
+		return
+	}
+	if capabilities, err = probeCapabilities(ctx, myTarget, localTempDir); err != nil {
+		return
+	}
+	slog.Debug("target capabilities", slog.String("target", myTarget.GetName()), slog.String("capabilities", fmt.Sprintf("%+v", capabilities)))
+	capabilitiesCacheMutex.Lock()
+	capabilitiesCache[myTarget.GetName()] = capabilities
+	capabilitiesCacheMutex.Unlock()
+	return
+}
+
+func probeCapabilities(ctx context.Context, myTarget target.Target, localTempDir string) (capabilities Capabilities, err error) {
+	capabilities.Elevate = myTarget.CanElevatePrivileges()
+	// the probe script has no requirements, so running it doesn't probe again
+	scriptOutputs, err := RunScriptsContext(ctx, myTarget, []ScriptDefinition{{Name: "capabilities", Script: capabilitiesScript}}, false, localTempDir)
+	if err != nil {
+		err = fmt.Errorf("failed to probe capabilities: %v", err)
+		return
+	}
+	parseCapabilities(scriptOutputs["capabilities"].Stdout, &capabilities)
+	return
+}
+
+// parseCapabilities sets the capabilities from the output of capabilitiesScript
+func parseCapabilities(output string, capabilities *Capabilities) {
+	capabilities.PerfEventParanoid = 4 // more restrictive than any kernel's setting
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "uid":
+			capabilities.Root = value == "0"
+		case "perf_event_paranoid":
+			if paranoid, err := strconv.Atoi(value); err == nil {
+				capabilities.PerfEventParanoid = paranoid
+			}
+		case "cap_eff":
+			if capEff, err := strconv.ParseUint(value, 16, 64); err == nil {
+				capabilities.CapSysRawio = capEff&(1<<capSysRawio) != 0
+				capabilities.CapSysAdmin = capEff&(1<<capSysAdmin) != 0
+				capabilities.CapPerfmon = capEff&(1<<capPerfmon) != 0
+			}
+		case "msr_device":
+			capabilities.MSRDevice = true
+		case "msr_readable":
+			capabilities.MSRReadable = true
+		case "msr_module":
+			capabilities.MSRModule = true
+		case "debugfs_mounted":
+			capabilities.DebugfsMounted = true
+		case "debugfs_readable":
+			capabilities.DebugfsReadable = true
+		case "ipmi_device":
+			capabilities.IPMIDevice = true
+		case "ipmi_accessible":
+			capabilities.IPMIAccessible = true
+		case "ipmi_module":
+			capabilities.IPMIModule = true
+		}
+	}
+	if capabilities.Root {
+		capabilities.Elevate = true
+	}
+}
+
+// Check returns an empty string if the capability is available, with elevated privileges if elevated
+// is true, otherwise it returns the reason the capability isn't available.
+func (c Capabilities) Check(capability string, elevated bool) (reason string) {
+	switch capability {
+	case CapabilityMSR:
+		if elevated {
+			if !c.MSRDevice && !c.MSRModule {
+				return "MSR devices not found and msr kernel module not available"
+			}
+			return ""
+		}
+		if !c.MSRDevice {
+			return "MSR devices not found and loading the msr kernel module requires elevated privileges"
+		}
+		if !c.MSRReadable || !c.CapSysRawio {
+			return "reading MSRs requires elevated privileges or read access to /dev/cpu/*/msr and CAP_SYS_RAWIO"
+		}
+	case CapabilityPerf:
+		if elevated || c.PerfEventParanoid <= 0 || c.CapPerfmon || c.CapSysAdmin {
+			return ""
+		}
+		return fmt.Sprintf("perf_event_paranoid is %d, system-wide perf events require elevated privileges, CAP_PERFMON, or perf_event_paranoid <= 0", c.PerfEventParanoid)
+	case CapabilityDebugfs:
+		if !c.DebugfsMounted {
+			return "debugfs is not mounted"
+		}
+		if !elevated && !c.DebugfsReadable {
+			return "reading debugfs requires elevated privileges"
+		}
+	case CapabilityIPMI:
+		if elevated {
+			if !c.IPMIDevice && !c.IPMIModule {
+				return "IPMI device not found and IPMI kernel modules not available"
+			}
+			return ""
+		}
+		if !c.IPMIDevice {
+			return "IPMI device not found and loading the IPMI kernel modules requires elevated privileges"
+		}
+		if !c.IPMIAccessible {
+			return "accessing the IPMI device requires elevated privileges"
+		}
+	default:
+		return fmt.Sprintf("unknown capability: %s", capability)
+	}
+	return ""
+}
+
+// checkScript decides if and how the script can run given the target's capabilities. It returns
+// whether the script should run elevated, or, if the script can't run, the reason. Scripts that
+// declare capabilities run without elevated privileges when elevation isn't possible but all of
+// the capabilities are available without it.
+func (c Capabilities) checkScript(script ScriptDefinition) (elevated bool, reason string) {
+	elevated = script.Superuser && c.Elevate
+	if script.Superuser && !c.Elevate && len(script.Capabilities) == 0 {
+		return false, "requires elevated privileges"
+	}
+	var reasons []string
+	for _, capability := range script.Capabilities {
+		if reason := c.Check(capability, elevated); reason != "" {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", capability, reason))
+		}
+	}
+	return elevated, strings.Join(reasons, "; ")
+}
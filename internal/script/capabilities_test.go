@@ -0,0 +1,94 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"perfspect/internal/target"
+)
+
+func TestParseCapabilities(t *testing.T) {
+	var capabilities Capabilities
+	// CapEff with CAP_SYS_RAWIO and CAP_PERFMON, but not CAP_SYS_ADMIN
+	parseCapabilities("uid=1000\nperf_event_paranoid=2\ncap_eff=0000004000020000\nmsr_device=1\nipmi_module=1\n", &capabilities)
+	expected := Capabilities{PerfEventParanoid: 2, CapSysRawio: true, CapPerfmon: true, MSRDevice: true, IPMIModule: true}
+	if capabilities != expected {
+		t.Errorf("unexpected capabilities: got %+v, want %+v", capabilities, expected)
+	}
+	capabilities = Capabilities{}
+	parseCapabilities("uid=0\nperf_event_paranoid=\n", &capabilities)
+	if !capabilities.Root || !capabilities.Elevate {
+		t.Errorf("root should be able to elevate: %+v", capabilities)
+	}
+	if capabilities.PerfEventParanoid != 4 {
+		t.Errorf("unknown perf_event_paranoid should be restrictive, got %d", capabilities.PerfEventParanoid)
+	}
+}
+
+func TestCheckScript(t *testing.T) {
+	msrScript := ScriptDefinition{Name: "msr", Superuser: true, Lkms: []string{"msr"}, Capabilities: []string{CapabilityMSR}}
+	perfScript := ScriptDefinition{Name: "perf", Superuser: true, Capabilities: []string{CapabilityPerf}}
+	sudoScript := ScriptDefinition{Name: "sudo", Superuser: true}
+	userScript := ScriptDefinition{Name: "user"}
+	tests := []struct {
+		name         string
+		capabilities Capabilities
+		script       ScriptDefinition
+		elevated     bool
+		reason       string // substring of the expected reason, empty if the script can run
+	}{
+		{"elevated msr", Capabilities{Elevate: true, MSRModule: true}, msrScript, true, ""},
+		{"elevated no msr", Capabilities{Elevate: true}, msrScript, true, "msr kernel module not available"},
+		{"unelevated msr", Capabilities{MSRDevice: true, MSRReadable: true, CapSysRawio: true}, msrScript, false, ""},
+		{"unelevated msr no rawio", Capabilities{MSRDevice: true, MSRReadable: true}, msrScript, false, "CAP_SYS_RAWIO"},
+		{"unelevated perf paranoid", Capabilities{PerfEventParanoid: 2}, perfScript, false, "perf_event_paranoid is 2"},
+		{"unelevated perf perfmon", Capabilities{PerfEventParanoid: 2, CapPerfmon: true}, perfScript, false, ""},
+		{"unelevated sudo", Capabilities{}, sudoScript, false, "requires elevated privileges"},
+		{"elevated sudo", Capabilities{Elevate: true}, sudoScript, true, ""},
+		{"user", Capabilities{}, userScript, false, ""},
+	}
+	for _, test := range tests {
+		elevated, reason := test.capabilities.checkScript(test.script)
+		if elevated != test.elevated {
+			t.Errorf("%s: unexpected elevated: got %v, want %v", test.name, elevated, test.elevated)
+		}
+		if test.reason == "" && reason != "" || !strings.Contains(reason, test.reason) {
+			t.Errorf("%s: unexpected reason: got %q, want %q", test.name, reason, test.reason)
+		}
+	}
+}
+
+func TestRunScriptsSkipped(t *testing.T) {
+	tgt := target.NewLocalTarget()
+	targetTempDir, err := tgt.CreateTempDirectory("/tmp")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tgt.RemoveDirectory(targetTempDir)
+	tempDir, err := os.MkdirTemp(os.TempDir(), "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	scripts := []ScriptDefinition{
+		{Name: "unittest run", Script: "echo run"},
+		{Name: "unittest other arch", Script: "echo other", Architectures: []string{"unknown"}},
+		{Name: "unittest unknown capability", Script: "echo unknown", Capabilities: []string{"unknown"}},
+	}
+	scriptOutputs, err := RunScripts(tgt, scripts, false, tempDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if scriptOutputs["unittest run"].Stdout != "run\n" || scriptOutputs["unittest run"].SkipReason != "" {
+		t.Errorf("unexpected output: %+v", scriptOutputs["unittest run"])
+	}
+	for _, name := range []string{"unittest other arch", "unittest unknown capability"} {
+		if scriptOutputs[name].SkipReason == "" || scriptOutputs[name].Stdout != "" {
+			t.Errorf("%s should have been skipped: %+v", name, scriptOutputs[name])
+		}
+	}
+}
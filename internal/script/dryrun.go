@@ -0,0 +1,356 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// dryrun.go describes what running scripts on a target would do without running them, see PlanScripts
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"perfspect/internal/target"
+	"perfspect/internal/util"
+)
+
+// FileSourceScript is the source of the script files that are copied to the target, see PlannedFile
+const FileSourceScript = "script"
+
+// PlannedScript is a script that would be run on the target
+type PlannedScript struct {
+	Name         string          `json:"name"`
+	Superuser    bool            `json:"superuser"`
+	Capabilities []string        `json:"capabilities,omitempty"`
+	Lkms         []string        `json:"lkms,omitempty"`
+	Depends      []string        `json:"depends,omitempty"`
+	Timeout      int             `json:"timeout,omitempty"`
+	Retries      int             `json:"retries,omitempty"`
+	Fallbacks    []PlannedScript `json:"fallbacks,omitempty"`
+	SkipReason   string          `json:"skip_reason,omitempty"`
+	Script       string          `json:"script"`
+}
+
+// PlannedFile is a file, or directory, that would be copied to the target's temporary directory
+type PlannedFile struct {
+	Name   string `json:"name"`
+	Source string `json:"source"` // FileSourceScript, ToolSourceBundled, or ToolSourceOverride
+	SHA256 string `json:"sha256,omitempty"`
+	Cached bool   `json:"cached,omitempty"` // copied to the target's tool cache, unless it has it, and linked, see Plan.ToolCache
+}
+
+// PlannedChange is a setting that would be changed on the target. The value that is written may depend on the value
+// that is read, e.g., for a register whose other bits are kept, so the scripts may describe the value rather than
+// write it.
+type PlannedChange struct {
+	Setting   string          `json:"setting"`
+	Condition string          `json:"condition,omitempty"` // the change is only made if the condition holds
+	Read      string          `json:"read"`                // the command that reads the setting's value before it is changed
+	Scripts   []PlannedScript `json:"scripts"`             // the scripts that change the setting
+	Restore   string          `json:"restore,omitempty"`   // the command that restores the value, it is journaled first
+}
+
+// Plan is what running the scripts on the target would do
+type Plan struct {
+	Target       string          `json:"target"`
+	Architecture string          `json:"architecture,omitempty"`
+	Family       string          `json:"family,omitempty"`
+	Model        string          `json:"model,omitempty"`
+	Elevated     bool            `json:"elevated"` // some scripts require elevated privileges, i.e., root or sudo
+	Lkms         []string        `json:"lkms"`
+	Files        []PlannedFile   `json:"files"`
+	ToolCache    string          `json:"tool_cache,omitempty"` // the target's tool cache directory, if it is enabled
+	Changes      []PlannedChange `json:"changes,omitempty"`
+	Scripts      []PlannedScript `json:"scripts"`
+	// MasterScripts run the scripts of a wave at the same time, the scripts that the target skips, e.g., because it
+	// lacks a capability, are left out when it is run
+	MasterScripts []PlannedScript `json:"master_scripts,omitempty"`
+	Error         string          `json:"error,omitempty"`
+}
+
+// plannedTempDirectory stands for the target's temporary directory, which is created when the scripts are run
+const plannedTempDirectory = "<temporary directory>"
+
+// PlanScripts returns what RunScripts would do with the scripts on the target. Nothing is changed on the target, only
+// its processor's architecture, family, and model are read, see target.Target.GetArchitecture. The scripts that
+// probe the target, e.g., its platform and capabilities, are included in the plan. Scripts that would be skipped
+// because of the target's platform or capabilities can only be found by running the probes, so only the scripts
+// that aren't intended for the target's processor are marked as skipped.
+func PlanScripts(myTarget target.Target, scripts []ScriptDefinition) (plan Plan, err error) {
+	plan = Plan{Target: myTarget.GetName(), Lkms: []string{}, Files: []PlannedFile{}, Scripts: []PlannedScript{}}
+	if toolCacheEnabled {
+		plan.ToolCache = "~/" + toolCacheDir
+	}
+	if plan.Architecture, err = myTarget.GetArchitecture(); err != nil {
+		err = fmt.Errorf("error getting target architecture: %v", err)
+		return
+	}
+	if plan.Family, err = myTarget.GetFamily(); err != nil {
+		err = fmt.Errorf("error getting target family: %v", err)
+		return
+	}
+	if plan.Model, err = myTarget.GetModel(); err != nil {
+		err = fmt.Errorf("error getting target model: %v", err)
+		return
+	}
+	probes := []ScriptDefinition{{Name: "platform", Script: platformScript}}
+	if slices.ContainsFunc(scripts, func(s ScriptDefinition) bool {
+		return slices.ContainsFunc(append([]ScriptDefinition{s}, s.Fallbacks...), func(s ScriptDefinition) bool { return s.Superuser || len(s.Capabilities) > 0 })
+	}) {
+		probes = append(probes, ScriptDefinition{Name: "capabilities", Script: capabilitiesScript})
+	}
+	var tools []string
+	for _, script := range scripts {
+		if tool := scriptTool(script); tool != "" && !slices.Contains(tools, tool) {
+			tools = append(tools, tool)
+		}
+	}
+	var versions []ScriptDefinition
+	if len(tools) > 0 {
+		slices.Sort(tools)
+		versions = append(versions, ScriptDefinition{Name: "tool versions", Script: toolVersionsScript(tools)})
+	}
+	var dependencies []string
+	for _, script := range slices.Concat(probes, scripts, versions) {
+		planned := plan.planScript(script)
+		for _, fallback := range script.Fallbacks {
+			planned.Fallbacks = append(planned.Fallbacks, plan.planScript(fallback))
+		}
+		plan.Scripts = append(plan.Scripts, planned)
+		for _, s := range append([]ScriptDefinition{script}, script.Fallbacks...) {
+			dependencies = plan.addScriptFile(s, dependencies)
+		}
+	}
+	slices.Sort(dependencies)
+	plan.addTools(dependencies)
+	// the scripts, or their first fallback that is intended for the target's processor, are run in waves, and the
+	// scripts of a wave are run at the same time by a master script
+	var runnable []ScriptDefinition
+	for _, script := range scripts {
+		if i := slices.IndexFunc(append([]ScriptDefinition{script}, script.Fallbacks...), plan.supports); i == 0 {
+			runnable = append(runnable, script)
+		} else if i > 0 {
+			runnable = append(runnable, script.Fallbacks[i-1])
+		}
+	}
+	waves, err := scheduleScripts(runnable)
+	if err != nil {
+		return
+	}
+	for i, wave := range waves {
+		if len(wave) < 2 {
+			continue
+		}
+		if len(plan.MasterScripts) == 0 {
+			plan.Files = append(plan.Files, PlannedFile{Name: masterScriptName, Source: FileSourceScript})
+		}
+		// the shell is bash, if the target has it, see Platform.Shell
+		masterScript, elevated := formMasterScript(plannedTempDirectory, wave, ShellBash)
+		plan.MasterScripts = append(plan.MasterScripts, PlannedScript{Name: fmt.Sprintf("%s, wave %d", masterScriptName, i+1), Superuser: elevated, Script: masterScript})
+	}
+	return
+}
+
+// AddChange adds the change of a setting, that the scripts make, to the plan, with the files and privileges that the
+// scripts require. If the change is restored, the restore command is added to the restore journal first.
+func (plan *Plan) AddChange(change PlannedChange, scripts ...ScriptDefinition) {
+	if change.Restore != "" {
+		scripts = append([]ScriptDefinition{journalRestoreScript(change.Setting, []string{change.Restore})}, scripts...)
+	}
+	var dependencies []string
+	for _, script := range scripts {
+		change.Scripts = append(change.Scripts, plan.planScript(script))
+		dependencies = plan.addScriptFile(script, dependencies)
+	}
+	plan.addTools(dependencies)
+	plan.Changes = append(plan.Changes, change)
+}
+
+// AddRestoreJournalReplay adds the replay of the target's restore journal, see ReplayRestoreJournal, to the plan
+func (plan *Plan) AddRestoreJournalReplay() {
+	read := readRestoreJournalScript()
+	replay := ScriptDefinition{Name: "replay restore journal", Script: "# the commands in the journal", Superuser: true}
+	plan.Changes = append(plan.Changes, PlannedChange{
+		Setting:   "the settings in the restore journal, ~/" + restoreJournalPath,
+		Condition: "if a run that changed them didn't restore them, e.g., because it was killed",
+		Read:      read.Script,
+		Scripts:   []PlannedScript{plan.planScript(read), plan.planScript(replay), plan.planScript(clearRestoreJournalScript())},
+	})
+	for _, script := range []ScriptDefinition{read, replay, clearRestoreJournalScript()} {
+		plan.addScriptFile(script, nil)
+	}
+}
+
+// AddBundledTool adds the bundled tool that is copied to the target's temporary directory, rather than by the scripts
+// that depend on it, e.g., the metrics command's perf, to the plan
+func (plan *Plan) AddBundledTool(name string) (err error) {
+	files, err := fileChecksums(Resources, path.Join("resources", plan.Architecture), name)
+	if err != nil {
+		return
+	}
+	plan.Files = append(plan.Files, PlannedFile{Name: name, Source: ToolSourceBundled, SHA256: files[name]})
+	return
+}
+
+// addScriptFile adds the script's file to the plan, unless it is already copied, and returns the dependencies with
+// the script's dependencies on the target's architecture added
+func (plan *Plan) addScriptFile(script ScriptDefinition, dependencies []string) []string {
+	name := scriptNameToFilename(script.Name)
+	if !slices.ContainsFunc(plan.Files, func(f PlannedFile) bool { return f.Name == name }) {
+		plan.Files = append(plan.Files, PlannedFile{Name: name, Source: FileSourceScript})
+	}
+	if len(script.Architectures) == 0 || util.StringInList(plan.Architecture, script.Architectures) {
+		for _, dependency := range script.Depends {
+			dependencies = util.UniqueAppend(dependencies, dependency)
+		}
+	}
+	return dependencies
+}
+
+// addTools adds the tools to the plan's files, unless they are already copied
+func (plan *Plan) addTools(names []string) {
+	for _, name := range names {
+		if slices.ContainsFunc(plan.Files, func(f PlannedFile) bool { return f.Name == name }) {
+			continue
+		}
+		// a tool that isn't bundled for the architecture isn't copied
+		tool, err := getTool(plan.Architecture, name)
+		if err != nil {
+			continue
+		}
+		plan.Files = append(plan.Files, PlannedFile{Name: name, Source: tool.Source, SHA256: tool.SHA256, Cached: toolCacheEnabled})
+	}
+}
+
+// supports returns true if the script is intended for the target's processor
+func (plan Plan) supports(script ScriptDefinition) bool {
+	return !(len(script.Architectures) > 0 && !util.StringInList(plan.Architecture, script.Architectures) ||
+		len(script.Families) > 0 && !util.StringInList(plan.Family, script.Families) ||
+		len(script.Models) > 0 && !util.StringInList(plan.Model, script.Models))
+}
+
+// planScript returns the planned script and adds what it requires to the plan
+func (plan *Plan) planScript(script ScriptDefinition) PlannedScript {
+	planned := PlannedScript{
+		Name:         script.Name,
+		Superuser:    script.Superuser,
+		Capabilities: script.Capabilities,
+		Lkms:         script.Lkms,
+		Depends:      script.Depends,
+		Timeout:      script.Timeout,
+		Retries:      script.Retries,
+		Script:       script.Script,
+	}
+	if !plan.supports(script) {
+		planned.SkipReason = "not supported on the target's processor"
+		return planned
+	}
+	if script.Superuser {
+		plan.Elevated = true
+	}
+	for _, lkm := range script.Lkms {
+		plan.Lkms = util.UniqueAppend(plan.Lkms, lkm)
+	}
+	return planned
+}
+
+// String returns the plan in a human-readable form
+func (plan Plan) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Target: %s\n", plan.Target))
+	if plan.Error != "" {
+		sb.WriteString(fmt.Sprintf("  Error: %s\n", plan.Error))
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("  Processor: architecture %s, family %s, model %s\n", plan.Architecture, plan.Family, plan.Model))
+	if plan.Elevated {
+		sb.WriteString("  Privileges: elevated (root or sudo) for the scripts marked superuser, unless the target grants their capabilities\n")
+	} else {
+		sb.WriteString("  Privileges: none\n")
+	}
+	lkms := "none"
+	if len(plan.Lkms) > 0 {
+		lkms = strings.Join(plan.Lkms, ", ")
+	}
+	sb.WriteString(fmt.Sprintf("  Kernel modules to load: %s\n", lkms))
+	sb.WriteString(fmt.Sprintf("  Files to copy to the target's temporary directory, %s:\n", plannedTempDirectory))
+	for _, file := range plan.Files {
+		attributes := []string{file.Source}
+		if file.SHA256 != "" {
+			attributes = append(attributes, "sha256 "+file.SHA256)
+		}
+		if file.Cached {
+			attributes = append(attributes, "cached")
+		}
+		sb.WriteString(fmt.Sprintf("    %s (%s)\n", file.Name, strings.Join(attributes, ", ")))
+	}
+	if plan.ToolCache != "" {
+		sb.WriteString(fmt.Sprintf("  Tool cache: the cached files are copied to %s on the target, unless it has them, and linked into the temporary directory\n", plan.ToolCache))
+	}
+	if len(plan.Changes) > 0 {
+		sb.WriteString("  Settings to change:\n")
+		for _, change := range plan.Changes {
+			heading := change.Setting
+			if change.Condition != "" {
+				heading += ", " + change.Condition
+			}
+			sb.WriteString(fmt.Sprintf("    === %s ===\n", heading))
+			sb.WriteString(fmt.Sprintf("    read: %s\n", change.Read))
+			if change.Restore != "" {
+				sb.WriteString(fmt.Sprintf("    restore: %s\n", change.Restore))
+			}
+			for _, script := range change.Scripts {
+				writePlannedScript(&sb, script, "")
+			}
+		}
+	}
+	sb.WriteString("  Scripts to run:\n")
+	for _, script := range plan.Scripts {
+		writePlannedScript(&sb, script, "")
+	}
+	if len(plan.MasterScripts) > 0 {
+		sb.WriteString("  Master scripts that run the scripts of a wave at the same time:\n")
+		for _, script := range plan.MasterScripts {
+			writePlannedScript(&sb, script, "")
+		}
+	}
+	return sb.String()
+}
+
+// writePlannedScript writes the script's attributes and body, indented
+func writePlannedScript(sb *strings.Builder, script PlannedScript, kind string) {
+	var attributes []string
+	if script.SkipReason != "" {
+		attributes = append(attributes, "skipped: "+script.SkipReason)
+	}
+	if script.Superuser {
+		attributes = append(attributes, "superuser")
+	}
+	if len(script.Capabilities) > 0 {
+		attributes = append(attributes, "capabilities: "+strings.Join(script.Capabilities, ", "))
+	}
+	if len(script.Lkms) > 0 {
+		attributes = append(attributes, "lkms: "+strings.Join(script.Lkms, ", "))
+	}
+	if len(script.Depends) > 0 {
+		attributes = append(attributes, "depends: "+strings.Join(script.Depends, ", "))
+	}
+	if script.Timeout > 0 {
+		attributes = append(attributes, fmt.Sprintf("timeout: %ds", script.Timeout))
+	}
+	if script.Retries > 0 {
+		attributes = append(attributes, fmt.Sprintf("retries: %d", script.Retries))
+	}
+	heading := kind + script.Name
+	if len(attributes) > 0 {
+		heading += " [" + strings.Join(attributes, "; ") + "]"
+	}
+	sb.WriteString(fmt.Sprintf("    --- %s ---\n", heading))
+	for _, line := range strings.Split(strings.TrimRight(script.Script, "\n"), "\n") {
+		sb.WriteString(fmt.Sprintf("      %s\n", line))
+	}
+	for _, fallback := range script.Fallbacks {
+		writePlannedScript(sb, fallback, fmt.Sprintf("fallback for %s: ", script.Name))
+	}
+}
@@ -0,0 +1,95 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"perfspect/internal/target"
+)
+
+func TestPlanScripts(t *testing.T) {
+	tgt := target.NewLocalTarget()
+	scripts := []ScriptDefinition{
+		{Name: "unittest run", Script: "echo run"},
+		{Name: "unittest elevated", Script: "cat /dev/cpu/0/msr", Superuser: true, Lkms: []string{"msr"},
+			Fallbacks: []ScriptDefinition{{Name: "unittest elevated fallback", Script: "echo fallback"}}},
+		{Name: "unittest other arch", Script: "echo other", Architectures: []string{"unknown"}, Superuser: true, Lkms: []string{"unknown"}},
+	}
+	plan, err := PlanScripts(tgt, scripts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, script := range plan.Scripts {
+		names = append(names, script.Name)
+	}
+	// the probes run first
+	if !slices.Equal(names, []string{"platform", "capabilities", "unittest run", "unittest elevated", "unittest other arch"}) {
+		t.Errorf("unexpected scripts: %v", names)
+	}
+	if !plan.Elevated || !slices.Equal(plan.Lkms, []string{"msr"}) {
+		t.Errorf("unexpected privileges: elevated %v, lkms %v", plan.Elevated, plan.Lkms)
+	}
+	if plan.Scripts[4].SkipReason == "" || plan.Scripts[2].SkipReason != "" {
+		t.Errorf("unexpected skip reasons: %q, %q", plan.Scripts[4].SkipReason, plan.Scripts[2].SkipReason)
+	}
+	if len(plan.Scripts[3].Fallbacks) != 1 || plan.Scripts[3].Fallbacks[0].Script != "echo fallback" {
+		t.Errorf("unexpected fallbacks: %v", plan.Scripts[3].Fallbacks)
+	}
+	if !slices.ContainsFunc(plan.Files, func(f PlannedFile) bool { return f.Name == "unittest_elevated_fallback.sh" }) {
+		t.Errorf("fallback script not copied: %v", plan.Files)
+	}
+	text := plan.String()
+	for _, want := range []string{"Kernel modules to load: msr", "--- unittest elevated [superuser; lkms: msr] ---", "      cat /dev/cpu/0/msr"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("%q not in plan:\n%s", want, text)
+		}
+	}
+}
+
+func TestPlanScriptsMasterScriptsAndChanges(t *testing.T) {
+	tgt := target.NewLocalTarget()
+	toolCacheEnabled = true
+	defer func() { toolCacheEnabled = false }()
+	scripts := []ScriptDefinition{
+		{Name: "unittest first", Script: "echo first"},
+		{Name: "unittest second", Script: "echo second"},
+		{Name: "unittest after", Script: "echo after", After: []string{"unittest first"}},
+	}
+	plan, err := PlanScripts(tgt, scripts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the first two scripts run at the same time, the last one runs alone
+	if len(plan.MasterScripts) != 1 || !strings.Contains(plan.MasterScripts[0].Script, "unittest_second.sh") || strings.Contains(plan.MasterScripts[0].Script, "unittest_after.sh") {
+		t.Errorf("unexpected master scripts: %v", plan.MasterScripts)
+	}
+	if !slices.ContainsFunc(plan.Files, func(f PlannedFile) bool { return f.Name == masterScriptName }) {
+		t.Errorf("master script not copied: %v", plan.Files)
+	}
+	if plan.ToolCache == "" {
+		t.Errorf("tool cache not in plan")
+	}
+	plan.AddChange(PlannedChange{Setting: "unittest setting", Read: "cat unittest", Restore: "echo restore"},
+		ScriptDefinition{Name: "unittest write", Script: "echo write", Superuser: true})
+	if len(plan.Changes) != 1 || len(plan.Changes[0].Scripts) != 2 || plan.Changes[0].Scripts[1].Name != "unittest write" {
+		t.Fatalf("unexpected changes: %v", plan.Changes)
+	}
+	// the restore command is journaled before the setting is changed
+	if !strings.Contains(plan.Changes[0].Scripts[0].Script, "echo restore") {
+		t.Errorf("restore not journaled: %q", plan.Changes[0].Scripts[0].Script)
+	}
+	if !plan.Elevated {
+		t.Errorf("change's privileges not in plan")
+	}
+	text := plan.String()
+	for _, want := range []string{"=== unittest setting ===", "read: cat unittest", "restore: echo restore", "Master scripts", "Tool cache:"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("%q not in plan:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// platform.go probes targets for the shell, userland, distribution, and init system that scripts run on
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path"
+	"perfspect/internal/target"
+	"strings"
+	"sync"
+)
+
+// requirements that scripts may declare, see ScriptDefinition.Requires
+const (
+	RequireBash         = "bash"          // the script uses bash features, e.g., arrays, [[ ]], <<<
+	RequireGNUCoreutils = "gnu-coreutils" // the script uses GNU options, e.g., cut --output-delimiter
+	RequireSystemd      = "systemd"       // the script uses systemctl or journalctl
+)
+
+// shells that run scripts
+const (
+	ShellBash = "bash"
+	ShellSh   = "sh"
+)
+
+// Platform describes the software environment that scripts run in on a target.
+type Platform struct {
+	Shell         string `json:"shell"`          // the shell that runs scripts, bash if available, otherwise sh
+	BashVersion   string `json:"bash_version"`   // empty if bash isn't available
+	Coreutils     string `json:"coreutils"`      // gnu, busybox, toybox, or unknown
+	Distro        string `json:"distro"`         // ID from /etc/os-release, e.g., ubuntu, alpine
+	DistroVersion string `json:"distro_version"` // VERSION_ID from /etc/os-release
+	Init          string `json:"init"`           // systemd, openrc, busybox, sysvinit, or unknown
+}
+
+// platformScript prints the target's platform as key=value lines, it only uses POSIX sh features
+const platformScript = `if command -v bash >/dev/null 2>&1; then
+	echo shell=bash
+	echo bash_version=$(bash -c 'echo $BASH_VERSION')
+else
+	echo shell=sh
+fi
+case "$(ls --version 2>&1)" in
+	*"GNU coreutils"*) echo coreutils=gnu ;;
+	*BusyBox*) echo coreutils=busybox ;;
+	*toybox*) echo coreutils=toybox ;;
+esac
+if [ -r /etc/os-release ]; then
+	(. /etc/os-release; echo distro=$ID; echo distro_version=$VERSION_ID)
+fi
+if [ -d /run/systemd/system ]; then
+	echo init=systemd
+elif [ -d /run/openrc ] || command -v openrc >/dev/null 2>&1; then
+	echo init=openrc
+else
+	case "$(readlink -f /sbin/init 2>/dev/null)" in
+		*busybox*) echo init=busybox ;;
+		*/init) echo init=sysvinit ;;
+	esac
+fi
+exit 0
+`
+
+var (
+	platformCache      = make(map[string]Platform)
+	platformCacheMutex sync.Mutex
+)
+
+// CachedPlatform returns the target's platform if it has been probed.
+func CachedPlatform(targetName string) (platform Platform, ok bool) {
+	platformCacheMutex.Lock()
+	defer platformCacheMutex.Unlock()
+	platform, ok = platformCache[targetName]
+	return
+}
+
+// getPlatform probes the target's platform, localTempDirForTarget is the target's local temporary
+// directory. The result is cached for the target's name, and in the target cache, if it is enabled.
+// The probe runs with sh, because the shell that runs other scripts depends on its result.
+func getPlatform(ctx context.Context, myTarget target.Target, localTempDirForTarget string) (platform Platform, err error) {
+	platform, ok := CachedPlatform(myTarget.GetName())
+	if ok {
+		return
+	}
+	if !target.CacheGet(myTarget, "platform", &platform) {
+		if platform, err = probePlatform(ctx, myTarget, localTempDirForTarget); err != nil {
+			return
+		}
+		target.CachePut(myTarget, "platform", platform)
+	}
+	slog.Info("target platform", slog.String("target", myTarget.GetName()), slog.String("shell", platform.Shell), slog.String("coreutils", platform.Coreutils), slog.String("distro", platform.Distro), slog.String("distroVersion", platform.DistroVersion), slog.String("init", platform.Init))
+	platformCacheMutex.Lock()
+	platformCache[myTarget.GetName()] = platform
+	platformCacheMutex.Unlock()
+	return
+}
+
+func probePlatform(ctx context.Context, myTarget target.Target, localTempDirForTarget string) (platform Platform, err error) {
+	probe := ScriptDefinition{Name: "platform", Script: platformScript}
+	if _, err = prepareTargetToRunScripts(myTarget, []ScriptDefinition{probe}, localTempDirForTarget, false); err != nil {
+		err = fmt.Errorf("failed to probe platform: %v", err)
+		return
+	}
+	cmd := exec.Command(ShellSh, path.Join(myTarget.GetTempDirectory(), scriptNameToFilename(probe.Name)))
+	stdout, stderr, exitcode, err := myTarget.RunCommandContext(ctx, cmd)
+	if err != nil {
+		slog.Error("error running platform probe on target", slog.String("stdout", stdout), slog.String("stderr", stderr), slog.Int("exitcode", exitcode), slog.String("error", err.Error()))
+		err = fmt.Errorf("failed to probe platform: %v", err)
+		return
+	}
+	platform = parsePlatform(stdout)
+	return
+}
+
+// parsePlatform returns the platform from the output of platformScript
+func parsePlatform(output string) (platform Platform) {
+	platform = Platform{Shell: ShellSh, Coreutils: "unknown", Init: "unknown"}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found || value == "" {
+			continue
+		}
+		switch key {
+		case "shell":
+			platform.Shell = value
+		case "bash_version":
+			platform.BashVersion = value
+		case "coreutils":
+			platform.Coreutils = value
+		case "distro":
+			platform.Distro = strings.Trim(value, `"`)
+		case "distro_version":
+			platform.DistroVersion = strings.Trim(value, `"`)
+		case "init":
+			platform.Init = value
+		}
+	}
+	return
+}
+
+// Check returns an empty string if the platform meets the requirement, otherwise it returns the
+// reason it doesn't.
+func (p Platform) Check(requirement string) (reason string) {
+	switch requirement {
+	case RequireBash:
+		if p.Shell != ShellBash {
+			return "bash is not installed"
+		}
+	case RequireGNUCoreutils:
+		if p.Coreutils != "gnu" {
+			return fmt.Sprintf("the target's coreutils are %s, not GNU", p.Coreutils)
+		}
+	case RequireSystemd:
+		if p.Init != "systemd" {
+			return fmt.Sprintf("the target's init system is %s, not systemd", p.Init)
+		}
+	default:
+		return fmt.Sprintf("unknown requirement: %s", requirement)
+	}
+	return ""
+}
+
+// checkScript returns the reasons the platform doesn't meet the script's requirements, or an empty
+// string if it meets all of them.
+func (p Platform) checkScript(script ScriptDefinition) (reason string) {
+	var reasons []string
+	for _, requirement := range script.Requires {
+		if reason := p.Check(requirement); reason != "" {
+			reasons = append(reasons, fmt.Sprintf("requires %s: %s", requirement, reason))
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
@@ -0,0 +1,100 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"perfspect/internal/target"
+)
+
+func TestParsePlatform(t *testing.T) {
+	platform := parsePlatform("shell=bash\nbash_version=5.2.21(1)-release\ncoreutils=gnu\ndistro=ubuntu\ndistro_version=\"24.04\"\ninit=systemd\n")
+	expected := Platform{Shell: ShellBash, BashVersion: "5.2.21(1)-release", Coreutils: "gnu", Distro: "ubuntu", DistroVersion: "24.04", Init: "systemd"}
+	if platform != expected {
+		t.Errorf("unexpected platform: got %+v, want %+v", platform, expected)
+	}
+	platform = parsePlatform("shell=sh\ncoreutils=busybox\ndistro=alpine\ndistro_version=3.20.3\n")
+	expected = Platform{Shell: ShellSh, Coreutils: "busybox", Distro: "alpine", DistroVersion: "3.20.3", Init: "unknown"}
+	if platform != expected {
+		t.Errorf("unexpected platform: got %+v, want %+v", platform, expected)
+	}
+	for _, requirement := range []string{RequireBash, RequireGNUCoreutils, RequireSystemd} {
+		if reason := platform.Check(requirement); reason == "" {
+			t.Errorf("busybox platform should not meet %s", requirement)
+		}
+	}
+	if reason := platform.checkScript(ScriptDefinition{Name: "posix"}); reason != "" {
+		t.Errorf("unexpected reason for script without requirements: %s", reason)
+	}
+}
+
+func TestGetPlatform(t *testing.T) {
+	tgt := target.NewLocalTarget()
+	targetTempDir, err := tgt.CreateTempDirectory("/tmp")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tgt.RemoveDirectory(targetTempDir)
+	tempDir, err := os.MkdirTemp(os.TempDir(), "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	platform, err := getPlatform(context.Background(), tgt, tempDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if platform.Shell != ShellBash && platform.Shell != ShellSh {
+		t.Errorf("unexpected shell: %s", platform.Shell)
+	}
+	if cached, ok := CachedPlatform(tgt.GetName()); !ok || cached != platform {
+		t.Errorf("platform not cached: %+v", cached)
+	}
+}
+
+// TestRunScriptsWithSh runs scripts on a target that doesn't have bash
+func TestRunScriptsWithSh(t *testing.T) {
+	tgt := target.NewLocalTarget()
+	targetTempDir, err := tgt.CreateTempDirectory("/tmp")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tgt.RemoveDirectory(targetTempDir)
+	tempDir, err := os.MkdirTemp(os.TempDir(), "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	platformCacheMutex.Lock()
+	saved, cached := platformCache[tgt.GetName()]
+	platformCache[tgt.GetName()] = Platform{Shell: ShellSh, Coreutils: "busybox", Init: "unknown"}
+	platformCacheMutex.Unlock()
+	defer func() {
+		platformCacheMutex.Lock()
+		defer platformCacheMutex.Unlock()
+		delete(platformCache, tgt.GetName())
+		if cached {
+			platformCache[tgt.GetName()] = saved
+		}
+	}()
+	scripts := []ScriptDefinition{
+		{Name: "unittest posix 1", Script: "echo one"},
+		{Name: "unittest posix 2", Script: "echo two"},
+		{Name: "unittest bash", Script: "declare -A things; echo bash", Requires: []string{RequireBash}},
+	}
+	scriptOutputs, err := RunScripts(tgt, scripts, false, tempDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if scriptOutputs["unittest posix 1"].Stdout != "one\n" || scriptOutputs["unittest posix 2"].Stdout != "two\n" {
+		t.Errorf("unexpected outputs: %+v", scriptOutputs)
+	}
+	if !strings.Contains(scriptOutputs["unittest bash"].SkipReason, "requires bash") {
+		t.Errorf("bash script should have been skipped: %+v", scriptOutputs["unittest bash"])
+	}
+}
@@ -0,0 +1,86 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// restore.go keeps a restore journal on the targets, the commands that restore the settings that are changed while
+// collecting data, e.g., the NMI watchdog. The commands are added to the journal before the settings are changed and
+// the journal is removed after the settings are restored, so a journal that is found on a target is from a run that
+// was killed or lost its connection to the target, and is replayed to restore the settings.
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"perfspect/internal/target"
+)
+
+// restoreJournalPath is the restore journal on the targets, relative to the user's home directory. It is a POSIX sh
+// script that is run with elevated privileges.
+const restoreJournalPath = ".cache/perfspect/restore.sh"
+
+// restoreJournalEnd ends the here-document that adds commands to the journal
+const restoreJournalEnd = "PERFSPECT_RESTORE_JOURNAL"
+
+// JournalRestore adds the commands that restore the settings to the target's restore journal, it is called before
+// the settings are changed
+func JournalRestore(myTarget target.Target, description string, commands []string, localTempDir string) (err error) {
+	_, err = RunScript(myTarget, journalRestoreScript(description, commands), localTempDir)
+	if err != nil {
+		err = fmt.Errorf("failed to add to restore journal: %v", err)
+	}
+	return
+}
+
+// ClearRestoreJournal removes the target's restore journal, it is called after the settings are restored
+func ClearRestoreJournal(myTarget target.Target, localTempDir string) (err error) {
+	_, err = RunScript(myTarget, clearRestoreJournalScript(), localTempDir)
+	if err != nil {
+		err = fmt.Errorf("failed to remove restore journal: %v", err)
+	}
+	return
+}
+
+// ReplayRestoreJournal runs the commands in the target's restore journal, if it has one, and removes the journal. It
+// returns the journal that was replayed, or an empty string if the target has no journal.
+func ReplayRestoreJournal(myTarget target.Target, localTempDir string) (journal string, err error) {
+	output, err := RunScript(myTarget, readRestoreJournalScript(), localTempDir)
+	if err != nil {
+		err = fmt.Errorf("failed to read restore journal: %v", err)
+		return
+	}
+	if strings.TrimSpace(output.Stdout) == "" {
+		return
+	}
+	journal = output.Stdout
+	slog.Warn("replaying restore journal", slog.String("target", myTarget.GetName()), slog.String("journal", journal))
+	if _, err = RunScript(myTarget, ScriptDefinition{Name: "replay restore journal", Script: journal, Superuser: true}, localTempDir); err != nil {
+		err = fmt.Errorf("failed to replay restore journal: %v", err)
+		return
+	}
+	Audit(myTarget, "replay restore journal", "~/"+restoreJournalPath, "", "", false)
+	err = ClearRestoreJournal(myTarget, localTempDir)
+	return
+}
+
+// journalRestoreScript returns the script that adds the commands to the restore journal
+func journalRestoreScript(description string, commands []string) ScriptDefinition {
+	journal := fmt.Sprintf("# %s, %s\n%s\n", description, time.Now().Format(time.RFC3339), strings.Join(commands, "\n"))
+	return ScriptDefinition{
+		Name:   "journal restore",
+		Script: fmt.Sprintf("mkdir -p \"$(dirname \"$HOME/%[1]s\")\" && cat >> \"$HOME/%[1]s\" <<'%[2]s'\n%[3]s%[2]s\n", restoreJournalPath, restoreJournalEnd, journal),
+	}
+}
+
+// readRestoreJournalScript returns the script that prints the restore journal, if the target has one. The journal is
+// read without elevated privileges, because the user's home directory may differ with them.
+func readRestoreJournalScript() ScriptDefinition {
+	return ScriptDefinition{Name: "read restore journal", Script: fmt.Sprintf("cat \"$HOME/%s\" 2>/dev/null || true", restoreJournalPath)}
+}
+
+// clearRestoreJournalScript returns the script that removes the restore journal
+func clearRestoreJournalScript() ScriptDefinition {
+	return ScriptDefinition{Name: "clear restore journal", Script: fmt.Sprintf("rm -f \"$HOME/%s\"", restoreJournalPath)}
+}
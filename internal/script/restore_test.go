@@ -0,0 +1,60 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"perfspect/internal/target"
+)
+
+func TestRestoreJournal(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	tgt := target.NewLocalTarget()
+	targetTempDir, err := tgt.CreateTempDirectory("/tmp")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tgt.RemoveDirectory(targetTempDir)
+	tempDir := t.TempDir()
+	setting := filepath.Join(tempDir, "setting")
+	// the commands are added to the journal, in order
+	if err := JournalRestore(tgt, "unittest first", []string{"echo 1 > " + setting}, tempDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := JournalRestore(tgt, "unittest second", []string{"echo 2 >> " + setting, "echo '$HOME' >> " + setting}, tempDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	journal, err := os.ReadFile(filepath.Join(home, restoreJournalPath))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(journal), "# unittest first") || !strings.HasSuffix(string(journal), "echo 2 >> "+setting+"\necho '$HOME' >> "+setting+"\n") {
+		t.Errorf("unexpected journal:\n%s", journal)
+	}
+	// replaying the journal requires elevated privileges, with sudo
+	if _, err := exec.LookPath("sudo"); err == nil && tgt.CanElevatePrivileges() {
+		replayed, err := ReplayRestoreJournal(tgt, tempDir)
+		if err != nil || replayed != string(journal) {
+			t.Fatalf("unexpected replay: %q, error: %v", replayed, err)
+		}
+		if data, err := os.ReadFile(setting); err != nil || string(data) != "1\n2\n$HOME\n" {
+			t.Errorf("unexpected setting: %q, error: %v", data, err)
+		}
+		if replayed, err = ReplayRestoreJournal(tgt, tempDir); err != nil || replayed != "" {
+			t.Errorf("unexpected replay of removed journal: %q, error: %v", replayed, err)
+		}
+	}
+	if err := ClearRestoreJournal(tgt, tempDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, restoreJournalPath)); !os.IsNotExist(err) {
+		t.Errorf("journal not removed, error: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// retry.go tracks the attempts to run a script, see ScriptDefinition.Retries and ScriptDefinition.Fallbacks
+
+import (
+	"log/slog"
+	"time"
+)
+
+// timeoutExitcode is the exit code of a script that didn't finish before its timeout, it matches the timeout command's
+const timeoutExitcode = 124
+
+// scriptRun tracks the attempts to run a script on a target
+type scriptRun struct {
+	variants     []ScriptDefinition // the script and the fallbacks that can run on the target, named after the script
+	variantNames []string           // the names of the variants, empty for the script
+	variant      int                // the index of the variant that is run next
+	retries      int                // the number of times the variant has been retried
+	attempts     int                // the number of times the script and its fallbacks have been run
+}
+
+// newScriptRun returns the run of the script and the fallbacks that can run on the target. If none of them can,
+// it returns nil and the reason the script can't. check returns the variant as it is run on the target, or the
+// reason it can't be run.
+func newScriptRun(script ScriptDefinition, check func(ScriptDefinition) (ScriptDefinition, string)) (run *scriptRun, reason string) {
+	run = &scriptRun{}
+	for i, variant := range append([]ScriptDefinition{script}, script.Fallbacks...) {
+		variantName := ""
+		if i > 0 {
+			// the fallback is run in place of the script
+			variantName = variant.Name
+			variant.Name = script.Name
+			variant.Resources = script.Resources
+			variant.After = script.After
+		}
+		checked, variantReason := check(variant)
+		if variantReason != "" {
+			if i == 0 {
+				reason = variantReason
+			} else {
+				slog.Debug("fallback can't be run", slog.String("script", script.Name), slog.String("fallback", variantName), slog.String("reason", variantReason))
+			}
+			continue
+		}
+		run.variants = append(run.variants, checked)
+		run.variantNames = append(run.variantNames, variantName)
+	}
+	if len(run.variants) == 0 {
+		return nil, reason
+	}
+	if run.variantNames[0] != "" {
+		slog.Info("running fallback in place of script", slog.String("script", script.Name), slog.String("fallback", run.variantNames[0]), slog.String("reason", reason))
+	}
+	return run, ""
+}
+
+// current returns the variant that is run next
+func (r *scriptRun) current() ScriptDefinition {
+	return r.variants[r.variant]
+}
+
+// retry moves to the next attempt after the current variant failed: the variant is retried, if it has retries
+// left, otherwise the next fallback is run. It returns false if there are no attempts left, otherwise the time
+// to wait before the attempt, which doubles with each retry of the variant.
+func (r *scriptRun) retry() (delay time.Duration, ok bool) {
+	variant := r.current()
+	if r.retries < variant.Retries {
+		delay = time.Duration(variant.RetryBackoff) * time.Second << r.retries
+		r.retries++
+		return delay, true
+	}
+	if r.variant < len(r.variants)-1 {
+		r.variant++
+		r.retries = 0
+		return 0, true
+	}
+	return 0, false
+}
+
+// output completes the output of an attempt with the variant that produced it and the number of attempts
+func (r *scriptRun) output(scriptOutput ScriptOutput) ScriptOutput {
+	scriptOutput.ScriptDefinition = r.current()
+	scriptOutput.Variant = r.variantNames[r.variant]
+	scriptOutput.Attempts = r.attempts
+	return scriptOutput
+}
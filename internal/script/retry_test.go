@@ -0,0 +1,106 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"perfspect/internal/target"
+)
+
+func TestScriptRun(t *testing.T) {
+	script := ScriptDefinition{
+		Name:         "unittest primary",
+		Retries:      2,
+		RetryBackoff: 1,
+		Resources:    []string{ResourceMSR},
+		Fallbacks: []ScriptDefinition{
+			{Name: "unittest unsupported", Architectures: []string{"unknown"}},
+			{Name: "unittest fallback"},
+		},
+	}
+	check := func(s ScriptDefinition) (ScriptDefinition, string) {
+		if len(s.Architectures) > 0 {
+			return s, "not supported"
+		}
+		return s, ""
+	}
+	run, reason := newScriptRun(script, check)
+	if run == nil || reason != "" {
+		t.Fatalf("unexpected reason: %s", reason)
+	}
+	// the primary is retried with backoff, then the fallback that can run is run once
+	var delays []time.Duration
+	for {
+		delay, ok := run.retry()
+		if !ok {
+			break
+		}
+		delays = append(delays, delay)
+	}
+	if fmt.Sprint(delays) != "[1s 2s 0s]" {
+		t.Errorf("unexpected delays: %v", delays)
+	}
+	output := run.output(ScriptOutput{})
+	if output.Variant != "unittest fallback" || output.Name != script.Name || output.Resources[0] != ResourceMSR {
+		t.Errorf("unexpected output: %+v", output)
+	}
+	// no variant can run
+	script.Architectures = []string{"unknown"}
+	script.Fallbacks = script.Fallbacks[:1]
+	if run, reason := newScriptRun(script, check); run != nil || reason != "not supported" {
+		t.Errorf("unexpected run: %v, reason: %s", run, reason)
+	}
+}
+
+func TestRunScriptsRetry(t *testing.T) {
+	tgt := target.NewLocalTarget()
+	targetTempDir, err := tgt.CreateTempDirectory("/tmp")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tgt.RemoveDirectory(targetTempDir)
+	// the script fails the first time it runs
+	marker := filepath.Join(t.TempDir(), "marker")
+	scripts := []ScriptDefinition{
+		{Name: "unittest flaky", Script: fmt.Sprintf("if [ ! -f %[1]s ]; then touch %[1]s; exit 1; fi; echo flaky", marker), Retries: 1},
+		{Name: "unittest failing", Script: "exit 2", Fallbacks: []ScriptDefinition{{Name: "unittest failing fallback", Script: "echo fallback"}}},
+		{Name: "unittest unsupported", Script: "echo primary", Architectures: []string{"unknown"}, Fallbacks: []ScriptDefinition{{Name: "unittest unsupported fallback", Script: "echo fallback"}}},
+		{Name: "unittest timeout", Script: "sleep 10", Timeout: 1},
+	}
+	for _, parallel := range []bool{true, false} {
+		os.Remove(marker)
+		scriptOutputs := make(map[string]ScriptOutput)
+		if parallel {
+			if scriptOutputs, err = RunScripts(tgt, scripts, true, t.TempDir()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		} else {
+			// one script at a time
+			for _, script := range scripts {
+				outputs, err := RunScripts(tgt, []ScriptDefinition{script}, true, t.TempDir())
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				scriptOutputs[script.Name] = outputs[script.Name]
+			}
+		}
+		if output := scriptOutputs["unittest flaky"]; output.Stdout != "flaky\n" || output.Attempts != 2 || output.Variant != "" {
+			t.Errorf("parallel %t: unexpected output: %+v", parallel, output)
+		}
+		if output := scriptOutputs["unittest failing"]; output.Stdout != "fallback\n" || output.Attempts != 2 || output.Variant != "unittest failing fallback" {
+			t.Errorf("parallel %t: unexpected output: %+v", parallel, output)
+		}
+		if output := scriptOutputs["unittest unsupported"]; output.Stdout != "fallback\n" || output.Attempts != 1 || output.Variant != "unittest unsupported fallback" {
+			t.Errorf("parallel %t: unexpected output: %+v", parallel, output)
+		}
+		if output := scriptOutputs["unittest timeout"]; output.Exitcode != timeoutExitcode || output.Duration > 5*time.Second {
+			t.Errorf("parallel %t: unexpected output: %+v", parallel, output)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// scheduler.go orders scripts into waves of scripts that can run at the same time
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// resource classes that scripts may declare, see ScriptDefinition.Resources
+const (
+	ResourceAllCores = "all-cores" // the script loads all cores, e.g., a benchmark
+	ResourceMSR      = "msr"       // the script writes MSRs, or samples them over time, e.g., to check if PMU counters are in use
+	ResourceSystem   = "system"    // the script measures the whole system, e.g., idle power, so no other script runs at the same time
+)
+
+// conflicts returns true if the scripts can't run at the same time
+func conflicts(a ScriptDefinition, b ScriptDefinition) bool {
+	if slices.Contains(a.Resources, ResourceSystem) || slices.Contains(b.Resources, ResourceSystem) {
+		return true
+	}
+	for _, resource := range a.Resources {
+		if slices.Contains(b.Resources, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduleScripts divides the scripts into waves. The scripts in a wave don't conflict with each other,
+// and the scripts they run after, see ScriptDefinition.After, are in earlier waves. Dependencies on
+// scripts that aren't in the list are ignored. Scripts are added to the first wave they can run in, in
+// the order they are listed.
+// Waves, rather than starting each script as soon as it can run, allow each wave to run with one
+// command, which keeps the number of concurrent connections to remote targets low.
+func scheduleScripts(scripts []ScriptDefinition) (waves [][]ScriptDefinition, err error) {
+	names := make(map[string]bool)
+	for _, script := range scripts {
+		names[script.Name] = true
+	}
+	done := make(map[string]bool)
+	pending := scripts
+	for len(pending) > 0 {
+		var wave []ScriptDefinition
+		var deferred []ScriptDefinition
+		for _, script := range pending {
+			ready := true
+			for _, dependency := range script.After {
+				if names[dependency] && !done[dependency] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				for _, scheduled := range wave {
+					if conflicts(script, scheduled) {
+						ready = false
+						break
+					}
+				}
+			}
+			if ready {
+				wave = append(wave, script)
+			} else {
+				deferred = append(deferred, script)
+			}
+		}
+		if len(wave) == 0 {
+			var cycle []string
+			for _, script := range deferred {
+				cycle = append(cycle, script.Name)
+			}
+			err = fmt.Errorf("scripts have circular dependencies: %s", strings.Join(cycle, ", "))
+			return
+		}
+		for _, script := range wave {
+			done[script.Name] = true
+		}
+		waves = append(waves, wave)
+		pending = deferred
+	}
+	return
+}
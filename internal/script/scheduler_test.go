@@ -0,0 +1,51 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestScheduleScripts(t *testing.T) {
+	scripts := []ScriptDefinition{
+		{Name: "lscpu"},
+		{Name: "idle power", Resources: []string{ResourceSystem}},
+		{Name: "cpu speed", Resources: []string{ResourceSystem}},
+		{Name: "pmu busy", Resources: []string{ResourceMSR}},
+		{Name: "turbo power", Resources: []string{ResourceSystem}, After: []string{"idle power"}},
+		{Name: "load a", Resources: []string{ResourceAllCores}},
+		{Name: "load b", Resources: []string{ResourceAllCores}},
+		{Name: "dmidecode", After: []string{"not run"}},
+	}
+	waves, err := scheduleScripts(scripts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names [][]string
+	for _, wave := range waves {
+		var waveNames []string
+		for _, script := range wave {
+			waveNames = append(waveNames, script.Name)
+		}
+		names = append(names, waveNames)
+	}
+	expected := [][]string{
+		{"lscpu", "pmu busy", "load a", "dmidecode"},
+		{"idle power"},
+		{"cpu speed"},
+		{"turbo power"},
+		{"load b"},
+	}
+	if !slices.EqualFunc(names, expected, slices.Equal) {
+		t.Errorf("unexpected waves: got %v, want %v", names, expected)
+	}
+	_, err = scheduleScripts([]ScriptDefinition{
+		{Name: "a", After: []string{"b"}},
+		{Name: "b", After: []string{"a"}},
+	})
+	if err == nil {
+		t.Error("expected error for circular dependencies")
+	}
+}
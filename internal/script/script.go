@@ -5,14 +5,17 @@ package script
 // SPDX-License-Identifier: BSD-3-Clause
 
 import (
+	"context"
 	"embed"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/exec"
 	"path"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"perfspect/internal/target"
 	"perfspect/internal/util"
@@ -30,19 +33,60 @@ type ScriptDefinition struct {
 	Lkms          []string // loadable kernel modules
 	Depends       []string // binary dependencies that must be available for the script to run
 	Superuser     bool     // requires sudo or root
-	Sequential    bool     // run script sequentially (not at the same time as others)
+	Capabilities  []string // capabilities, e.g., msr, perf. If all are available without sudo or root, a Superuser script runs without them.
+	Requires      []string // platform requirements, e.g., bash, gnu-coreutils. The script isn't run on targets that don't meet them.
+	Resources     []string // resource classes, e.g., all-cores, msr. Scripts that use the same resource class don't run at the same time.
+	After         []string // names of scripts that must finish before the script starts, if they are run
 	Timeout       int      // seconds
+	Retries       int      // times the script is run again if it fails, i.e., exits with a non-zero code or times out
+	RetryBackoff  int      // seconds before the first retry, doubled for each retry after it
+	// Fallbacks are run, in order, in place of the script if it can't run on the target or fails after its retries.
+	// Their output is parsed like the script's, e.g., a fallback for dmidecode prints the DMI tables it reads from sysfs.
+	Fallbacks []ScriptDefinition
 }
 
 type ScriptOutput struct {
 	ScriptDefinition
-	Stdout   string
-	Stderr   string
-	Exitcode int
+	Stdout     string
+	Stderr     string
+	Exitcode   int
+	Duration   time.Duration // time the script ran, zero if unknown
+	SkipReason string        // why the script was not run, empty if it was run
+	Variant    string        // the name of the fallback that produced the output, empty if the script did
+	Attempts   int           // the number of times the script and its fallbacks were run
+	// Start and End are the times, on the local clock, that the script started and finished, zero if unknown
+	Start       time.Time
+	End         time.Time
+	Elevated    bool   // the script ran with elevated privileges, i.e., as root or with sudo
+	ToolVersion string // the version of the tool that produced the output, e.g., dmidecode, empty if unknown
+}
+
+// Recorder is called with the output of each script that is run on a target, e.g., to record the
+// scripts in the run manifest. It may be called concurrently for different targets.
+type Recorder func(targetName string, scriptOutput ScriptOutput)
+
+var recorder Recorder
+
+// SetRecorder sets the function that is called with the output of each script that is run by
+// RunScripts and RunScript.
+func SetRecorder(r Recorder) {
+	recorder = r
+}
+
+func record(myTarget target.Target, scriptOutput ScriptOutput) {
+	if recorder != nil {
+		recorder(myTarget.GetName(), scriptOutput)
+	}
 }
 
 // RunScript runs a script on the specified target and returns the output.
 func RunScript(myTarget target.Target, script ScriptDefinition, localTempDir string) (scriptOutput ScriptOutput, err error) {
+	return RunScriptContext(context.Background(), myTarget, script, localTempDir)
+}
+
+// RunScriptContext runs a script on the specified target and returns the output. If ctx is canceled
+// or its deadline passes, the script is interrupted.
+func RunScriptContext(ctx context.Context, myTarget target.Target, script ScriptDefinition, localTempDir string) (scriptOutput ScriptOutput, err error) {
 	targetArchitecture, err := myTarget.GetArchitecture()
 	if err != nil {
 		err = fmt.Errorf("error getting target architecture: %v", err)
@@ -64,13 +108,30 @@ func RunScript(myTarget target.Target, script ScriptDefinition, localTempDir str
 		err = fmt.Errorf("\"%s\" script is not intended for the target processor", script.Name)
 		return
 	}
-	scriptOutputs, err := RunScripts(myTarget, []ScriptDefinition{script}, false, localTempDir)
+	scriptOutputs, err := RunScriptsContext(ctx, myTarget, []ScriptDefinition{script}, false, localTempDir)
 	scriptOutput = scriptOutputs[script.Name]
 	return
 }
 
 // RunScripts runs a list of scripts on a target and returns the outputs of each script as a map with the script name as the key.
 func RunScripts(myTarget target.Target, scripts []ScriptDefinition, ignoreScriptErrors bool, localTempDir string) (map[string]ScriptOutput, error) {
+	return RunScriptsContext(context.Background(), myTarget, scripts, ignoreScriptErrors, localTempDir)
+}
+
+// RunScriptsContext runs a list of scripts on a target and returns the outputs of each script as a map with the script name
+// as the key. If ctx is canceled or its deadline passes, the running scripts are interrupted, the remaining scripts are not
+// run, and the returned error wraps ctx.Err(), even if script errors are ignored. The outputs of the scripts that finished
+// are returned along with the error.
+func RunScriptsContext(ctx context.Context, myTarget target.Target, scripts []ScriptDefinition, ignoreScriptErrors bool, localTempDir string) (map[string]ScriptOutput, error) {
+	return RunScriptsWithProgress(ctx, myTarget, scripts, ignoreScriptErrors, localTempDir, nil)
+}
+
+// Progress is called each time a script finishes with the number of scripts that have finished and the number of
+// scripts that are run, i.e., not including the scripts that are skipped.
+type Progress func(finished int, total int)
+
+// RunScriptsWithProgress is RunScriptsContext that reports its progress to progress, if it isn't nil.
+func RunScriptsWithProgress(ctx context.Context, myTarget target.Target, scripts []ScriptDefinition, ignoreScriptErrors bool, localTempDir string, progress Progress) (map[string]ScriptOutput, error) {
 	// need a unique temp directory for each target to avoid race conditions
 	localTempDirForTarget := path.Join(localTempDir, myTarget.GetName())
 	// if the directory doesn't exist, create it
@@ -80,6 +141,10 @@ func RunScripts(myTarget target.Target, scripts []ScriptDefinition, ignoreScript
 			return nil, err
 		}
 	}
+	platform, err := getPlatform(ctx, myTarget, localTempDirForTarget)
+	if err != nil {
+		return nil, err
+	}
 	targetArchitecture, err := myTarget.GetArchitecture()
 	if err != nil {
 		err = fmt.Errorf("error getting target architecture: %v", err)
@@ -95,119 +160,250 @@ func RunScripts(myTarget target.Target, scripts []ScriptDefinition, ignoreScript
 		err = fmt.Errorf("error getting target model: %v", err)
 		return nil, err
 	}
-	// drop scripts that should not be run and separate scripts that must run sequentially from those that can be run in parallel
-	canElevate := myTarget.CanElevatePrivileges()
-	var sequentialScripts []ScriptDefinition
-	var parallelScripts []ScriptDefinition
+	// the target's capabilities are only needed if scripts require privileges or capabilities
+	var capabilities Capabilities
 	for _, script := range scripts {
+		if slices.ContainsFunc(append([]ScriptDefinition{script}, script.Fallbacks...), func(s ScriptDefinition) bool { return s.Superuser || len(s.Capabilities) > 0 }) {
+			if capabilities, err = getCapabilities(ctx, myTarget, localTempDir); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	// checkScript returns the script as it is run on the target, or the reason it can't be run on the target
+	checkScript := func(script ScriptDefinition) (ScriptDefinition, string) {
 		if len(script.Architectures) > 0 && !util.StringInList(targetArchitecture, script.Architectures) ||
 			len(script.Families) > 0 && !util.StringInList(targetFamily, script.Families) ||
 			len(script.Models) > 0 && !util.StringInList(targetModel, script.Models) {
-			slog.Info("skipping script because it is not intended to run on the target processor", slog.String("target", myTarget.GetName()), slog.String("script", script.Name), slog.String("targetArchitecture", targetArchitecture), slog.String("targetFamily", targetFamily), slog.String("targetModel", targetModel))
-			continue
+			slog.Info("script is not intended to run on the target processor", slog.String("target", myTarget.GetName()), slog.String("script", script.Name), slog.String("targetArchitecture", targetArchitecture), slog.String("targetFamily", targetFamily), slog.String("targetModel", targetModel))
+			return script, "not supported on the target's processor"
+		}
+		if reason := platform.checkScript(script); reason != "" {
+			slog.Info("the target doesn't meet the script's requirements", slog.String("target", myTarget.GetName()), slog.String("script", script.Name), slog.String("reason", reason))
+			return script, reason
+		}
+		elevated, reason := capabilities.checkScript(script)
+		if reason != "" {
+			slog.Info("the target lacks the script's required privileges or capabilities", slog.String("target", myTarget.GetName()), slog.String("script", script.Name), slog.String("reason", reason))
+			return script, reason
 		}
-		if script.Superuser && !canElevate {
-			slog.Info("skipping script because it requires superuser privileges and the target cannot elevate privileges", slog.String("script", script.Name))
+		if script.Superuser && !elevated {
+			slog.Info("running script without elevated privileges, the required capabilities are available", slog.String("target", myTarget.GetName()), slog.String("script", script.Name))
+			script.Superuser = false
+			script.Lkms = nil // installing kernel modules requires elevated privileges
+		}
+		return script, ""
+	}
+	// drop scripts that can't be run, unless one of their fallbacks can, they are included in the outputs with the
+	// reason they were not run
+	scriptOutputs := make(map[string]ScriptOutput)
+	runs := make(map[string]*scriptRun)
+	var scriptsToRun []ScriptDefinition
+	for _, script := range scripts {
+		run, reason := newScriptRun(script, checkScript)
+		if run == nil {
+			slog.Info("skipping script", slog.String("target", myTarget.GetName()), slog.String("script", script.Name), slog.String("reason", reason))
+			scriptOutputs[script.Name] = ScriptOutput{ScriptDefinition: script, SkipReason: reason}
 			continue
 		}
-		if script.Sequential {
-			sequentialScripts = append(sequentialScripts, script)
-		} else {
-			parallelScripts = append(parallelScripts, script)
+		runs[script.Name] = run
+		scriptsToRun = append(scriptsToRun, run.current())
+	}
+	// scripts that don't conflict run at the same time
+	waves, err := scheduleScripts(scriptsToRun)
+	if err != nil {
+		return nil, err
+	}
+	numFinished := 0
+	finished := func() {
+		numFinished++
+		if progress != nil {
+			progress(numFinished, len(scriptsToRun))
 		}
 	}
 
 	// prepare target to run scripts by copying scripts and dependencies to target and installing LKMs
-	installedLkms, err := prepareTargetToRunScripts(myTarget, append(sequentialScripts, parallelScripts...), localTempDirForTarget, false)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	installedLkms, err := prepareTargetToRunScripts(myTarget, scriptsToRun, localTempDirForTarget, false)
 	if err != nil {
 		err = fmt.Errorf("error while preparing target to run scripts: %v", err)
 		return nil, err
 	}
-	if len(installedLkms) > 0 {
-		defer func() {
-			err := myTarget.UninstallLkms(installedLkms)
-			if err != nil {
-				slog.Error("error uninstalling LKMs", slog.String("lkms", strings.Join(installedLkms, ", ")), slog.String("error", err.Error()))
-			}
-		}()
-	}
+	defer func() {
+		uninstallLkms(myTarget, installedLkms)
+	}()
 
-	// if there's only 1 parallel script, run it sequentially
-	if len(parallelScripts) == 1 {
-		slog.Debug("running single parallel script sequentially", slog.String("script", parallelScripts[0].Name))
-		sequentialScripts = append(sequentialScripts, parallelScripts...)
-		parallelScripts = nil
-	}
-	scriptOutputs := make(map[string]ScriptOutput)
-	// run parallel scripts
-	if len(parallelScripts) > 0 {
-		// form one master script that calls all the parallel scripts in the background
-		masterScriptName := "parallel_master.sh"
-		masterScript, needsElevatedPrivileges := formMasterScript(myTarget, parallelScripts)
-		// write master script to local file
-		masterScriptPath := path.Join(localTempDirForTarget, masterScriptName)
-		err = os.WriteFile(masterScriptPath, []byte(masterScript), 0644)
-		if err != nil {
-			err = fmt.Errorf("error writing master script to local file: %v", err)
-			return nil, err
-		}
-		// copy master script to target
-		err = myTarget.PushFile(masterScriptPath, myTarget.GetTempDirectory())
-		if err != nil {
-			err = fmt.Errorf("error copying script to target: %v", err)
-			return nil, err
-		}
-		// run master script on target
-		// if the master script requires elevated privileges, we run it with sudo
-		// Note: adding 'sudo' to the individual scripts inside the master script
-		// instigates a known bug in the terminal that corrupts the tty settings:
-		// https://bugs.debian.org/cgi-bin/bugreport.cgi?bug=1043320
-		var cmd *exec.Cmd
-		if needsElevatedPrivileges {
-			// run master script with sudo, "-S" to read password from stdin
-			cmd = exec.Command("sudo", "-S", "bash", path.Join(myTarget.GetTempDirectory(), masterScriptName))
-		} else {
-			cmd = exec.Command("bash", path.Join(myTarget.GetTempDirectory(), masterScriptName))
-		}
-		stdout, stderr, exitcode, err := myTarget.RunCommand(cmd, 0)
-		if err != nil {
-			slog.Error("error running master script on target", slog.String("stdout", stdout), slog.String("stderr", stderr), slog.Int("exitcode", exitcode), slog.String("error", err.Error()))
-			return nil, err
-		}
-		// parse output of master script
-		parallelScriptOutputs := parseMasterScriptOutput(stdout)
-		for _, scriptOutput := range parallelScriptOutputs {
-			// find associated parallel script
-			scriptIdx := -1
-			for i, script := range parallelScripts {
-				if script.Name == scriptOutput.Name {
-					scriptIdx = i
-					break
+	for i, wave := range waves {
+		slog.Debug("running scripts", slog.String("target", myTarget.GetName()), slog.Int("wave", i+1), slog.Int("waves", len(waves)), slog.Int("scripts", len(wave)))
+		// the scripts in the wave that fail are run again, or their fallbacks are, until they have no attempts left
+		for len(wave) > 0 {
+			if err := ctx.Err(); err != nil {
+				return scriptOutputs, err
+			}
+			var retryWave []ScriptDefinition
+			var fallbacks []ScriptDefinition
+			var retryDelay time.Duration
+			// handle completes the script's output, unless the script will be run again, and returns true if it did
+			handle := func(scriptOutput ScriptOutput) bool {
+				run := runs[scriptOutput.Name]
+				scriptOutput = run.output(scriptOutput)
+				scriptOutput.Elevated = scriptOutput.Superuser || capabilities.Root
+				auditScriptOutput(myTarget, scriptOutput)
+				if scriptOutput.Exitcode != 0 && ctx.Err() != nil {
+					// the script was interrupted, its output is incomplete
+					return false
+				}
+				if scriptOutput.Exitcode != 0 {
+					variant := run.variant
+					if delay, ok := run.retry(); ok {
+						slog.Warn("script failed, running it again", slog.String("target", myTarget.GetName()), slog.String("script", scriptOutput.Name), slog.String("variant", scriptOutput.Variant), slog.Int("exitcode", scriptOutput.Exitcode), slog.String("stderr", scriptOutput.Stderr), slog.Duration("delay", delay))
+						retryWave = append(retryWave, run.current())
+						if run.variant != variant {
+							fallbacks = append(fallbacks, run.current())
+						}
+						retryDelay = max(retryDelay, delay)
+						return false
+					}
 				}
+				scriptOutputs[scriptOutput.Name] = scriptOutput
+				record(myTarget, scriptOutput)
+				finished()
+				return true
 			}
-			scriptOutput.Script = parallelScripts[scriptIdx].Script
-			scriptOutputs[scriptOutput.Name] = scriptOutput
-		}
-	}
-	// run sequential scripts
-	for _, script := range sequentialScripts {
-		cmd := prepareCommand(script, myTarget.GetTempDirectory())
-		stdout, stderr, exitcode, err := myTarget.RunCommand(cmd, 0)
-		if err != nil {
-			slog.Error("error running script on target", slog.String("script", script.Script), slog.String("stdout", stdout), slog.String("stderr", stderr), slog.Int("exitcode", exitcode), slog.String("error", err.Error()))
-		}
-		scriptOutputs[script.Name] = ScriptOutput{ScriptDefinition: script, Stdout: stdout, Stderr: stderr, Exitcode: exitcode}
-		if !ignoreScriptErrors {
-			return scriptOutputs, err
+			for _, script := range wave {
+				runs[script.Name].attempts++
+			}
+			if len(wave) == 1 {
+				// run a single script directly
+				scriptOutput, err := runSingleScript(ctx, myTarget, wave[0], platform.Shell)
+				// the error is ignored if the script will be run again
+				if !handle(scriptOutput) && ctx.Err() == nil {
+					err = nil
+				}
+				if err != nil && (!ignoreScriptErrors || ctx.Err() != nil) {
+					return scriptOutputs, err
+				}
+			} else if err := runParallelScripts(ctx, myTarget, wave, platform.Shell, localTempDirForTarget, handle); err != nil {
+				return scriptOutputs, err
+			}
+			if len(retryWave) > 0 {
+				select {
+				case <-ctx.Done():
+					return scriptOutputs, ctx.Err()
+				case <-time.After(retryDelay):
+				}
+				// the fallbacks replace the scripts on the target
+				if len(fallbacks) > 0 {
+					fallbackLkms, err := prepareTargetToRunScripts(myTarget, fallbacks, localTempDirForTarget, false)
+					installedLkms = append(installedLkms, fallbackLkms...)
+					if err != nil {
+						err = fmt.Errorf("error while preparing target to run scripts: %v", err)
+						return scriptOutputs, err
+					}
+				}
+			}
+			wave = retryWave
 		}
-		err = nil
 	}
+	setToolVersions(ctx, myTarget, scriptOutputs, localTempDir)
 	return scriptOutputs, nil
 }
 
+// runSingleScript runs the script on the target. If the script times out, the error says so and the exit code is
+// timeoutExitcode.
+func runSingleScript(ctx context.Context, myTarget target.Target, script ScriptDefinition, shell string) (scriptOutput ScriptOutput, err error) {
+	cmd := prepareCommand(script, myTarget.GetTempDirectory(), shell)
+	scriptCtx := ctx
+	if script.Timeout > 0 {
+		var cancel context.CancelFunc
+		scriptCtx, cancel = context.WithTimeout(ctx, time.Duration(script.Timeout)*time.Second)
+		defer cancel()
+	}
+	start := time.Now()
+	stdout, stderr, exitcode, err := myTarget.RunCommandContext(scriptCtx, cmd)
+	end := time.Now()
+	if err != nil && ctx.Err() == nil && scriptCtx.Err() != nil {
+		err = fmt.Errorf("script timed out after %d seconds", script.Timeout)
+		exitcode = timeoutExitcode
+	}
+	if err != nil {
+		slog.Error("error running script on target", slog.String("script", script.Script), slog.String("stdout", stdout), slog.String("stderr", stderr), slog.Int("exitcode", exitcode), slog.String("error", err.Error()))
+	}
+	scriptOutput = ScriptOutput{ScriptDefinition: script, Stdout: stdout, Stderr: stderr, Exitcode: exitcode, Duration: end.Sub(start), Start: start, End: end}
+	return
+}
+
+// masterScriptName is the name of the script that runs the scripts of a wave at the same time, see formMasterScript
+const masterScriptName = "parallel_master.sh"
+
+// runParallelScripts runs the scripts on the target at the same time with a master script, and calls handle with the
+// output of each script as soon as it finishes.
+func runParallelScripts(ctx context.Context, myTarget target.Target, scripts []ScriptDefinition, shell string, localTempDirForTarget string, handle func(ScriptOutput) bool) (err error) {
+	// form one master script that calls all the scripts in the background
+	masterScript, needsElevatedPrivileges := formMasterScript(myTarget.GetTempDirectory(), scripts, shell)
+	// write master script to local file
+	masterScriptPath := path.Join(localTempDirForTarget, masterScriptName)
+	err = os.WriteFile(masterScriptPath, []byte(masterScript), 0644)
+	if err != nil {
+		err = fmt.Errorf("error writing master script to local file: %v", err)
+		return
+	}
+	// copy master script to target
+	err = myTarget.PushFile(masterScriptPath, myTarget.GetTempDirectory())
+	if err != nil {
+		err = fmt.Errorf("error copying script to target: %v", err)
+		return
+	}
+	// run master script on target
+	// if the master script requires elevated privileges, we run it with sudo
+	// Note: adding 'sudo' to the individual scripts inside the master script
+	// instigates a known bug in the terminal that corrupts the tty settings:
+	// https://bugs.debian.org/cgi-bin/bugreport.cgi?bug=1043320
+	var cmd *exec.Cmd
+	if needsElevatedPrivileges {
+		// run master script with sudo, "-S" to read password from stdin
+		cmd = exec.Command("sudo", "-S", shell, path.Join(myTarget.GetTempDirectory(), masterScriptName))
+	} else {
+		cmd = exec.Command(shell, path.Join(myTarget.GetTempDirectory(), masterScriptName))
+	}
+	return runMasterScript(ctx, myTarget, cmd, func(scriptOutput ScriptOutput) {
+		// find associated script
+		scriptIdx := slices.IndexFunc(scripts, func(script ScriptDefinition) bool { return script.Name == scriptOutput.Name })
+		if scriptIdx < 0 {
+			slog.Warn("skipping output of unknown script", slog.String("script", scriptOutput.Name))
+			return
+		}
+		scriptOutput.ScriptDefinition = scripts[scriptIdx]
+		handle(scriptOutput)
+	})
+}
+
 // RunScriptAsync runs a script on the specified target and returns the output. It is meant to be called
 // in a go routine.
 func RunScriptAsync(myTarget target.Target, script ScriptDefinition, localTempDir string, stdoutChannel chan string, stderrChannel chan string, exitcodeChannel chan int, errorChannel chan error, cmdChannel chan *exec.Cmd) {
+	runScriptAsync(context.Background(), myTarget, script, localTempDir, errorChannel, func(cmd *exec.Cmd) error {
+		return myTarget.RunCommandAsync(cmd, stdoutChannel, stderrChannel, exitcodeChannel, script.Timeout, cmdChannel)
+	})
+}
+
+// RunScriptAsyncContext runs a script on the specified target and returns the output. It is meant to be called
+// in a go routine. The script is interrupted when ctx is canceled or its deadline passes, or when the script's
+// timeout expires.
+func RunScriptAsyncContext(ctx context.Context, myTarget target.Target, script ScriptDefinition, localTempDir string, stdoutChannel chan string, stderrChannel chan string, exitcodeChannel chan int, errorChannel chan error) {
+	if script.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(script.Timeout)*time.Second)
+		defer cancel()
+	}
+	runScriptAsync(ctx, myTarget, script, localTempDir, errorChannel, func(cmd *exec.Cmd) error {
+		return myTarget.RunCommandAsyncContext(ctx, cmd, stdoutChannel, stderrChannel, exitcodeChannel)
+	})
+}
+
+// runScriptAsync prepares the target to run the script, then runs the script's command with run.
+func runScriptAsync(ctx context.Context, myTarget target.Target, script ScriptDefinition, localTempDir string, errorChannel chan error, run func(cmd *exec.Cmd) error) {
 	// need a unique temp directory for each target to avoid race conditions when there are multiple targets
 	localTempDirForTarget := path.Join(localTempDir, myTarget.GetName())
 	// if the directory doesn't exist, create it
@@ -235,31 +431,41 @@ func RunScriptAsync(myTarget target.Target, script ScriptDefinition, localTempDi
 		errorChannel <- err
 		return
 	}
+	platform, err := getPlatform(ctx, myTarget, localTempDirForTarget)
+	if err != nil {
+		errorChannel <- err
+		return
+	}
+	if reason := platform.checkScript(script); reason != "" {
+		err = fmt.Errorf("cannot run script %s on %s: %s", script.Name, myTarget.GetName(), reason)
+		errorChannel <- err
+		return
+	}
 	installedLkms, err := prepareTargetToRunScripts(myTarget, []ScriptDefinition{script}, localTempDirForTarget, true)
 	if err != nil {
 		err = fmt.Errorf("error while preparing target to run script: %v", err)
 		errorChannel <- err
 		return
 	}
-	if len(installedLkms) != 0 {
-		defer func() {
-			err := myTarget.UninstallLkms(installedLkms)
-			if err != nil {
-				slog.Error("error uninstalling LKMs", slog.String("lkms", strings.Join(installedLkms, ", ")), slog.String("error", err.Error()))
-			}
-		}()
+	defer func() {
+		uninstallLkms(myTarget, installedLkms)
+	}()
+	if err = ctx.Err(); err != nil {
+		errorChannel <- err
+		return
 	}
-	cmd := prepareCommand(script, myTarget.GetTempDirectory())
-	err = myTarget.RunCommandAsync(cmd, stdoutChannel, stderrChannel, exitcodeChannel, script.Timeout, cmdChannel)
+	cmd := prepareCommand(script, myTarget.GetTempDirectory(), platform.Shell)
+	err = run(cmd)
 	errorChannel <- err
 }
 
-func prepareCommand(script ScriptDefinition, targetTempDirectory string) (cmd *exec.Cmd) {
+// prepareCommand forms the command that runs the script with the target's shell, see Platform.Shell
+func prepareCommand(script ScriptDefinition, targetTempDirectory string, shell string) (cmd *exec.Cmd) {
 	scriptPath := path.Join(targetTempDirectory, scriptNameToFilename(script.Name))
 	if script.Superuser {
-		cmd = exec.Command("sudo", "bash", scriptPath)
+		cmd = exec.Command("sudo", shell, scriptPath)
 	} else {
-		cmd = exec.Command("bash", scriptPath)
+		cmd = exec.Command(shell, scriptPath)
 	}
 	return
 }
@@ -274,50 +480,140 @@ func scriptNameToFilename(name string) string {
 	return sanitizeScriptName(name) + ".sh"
 }
 
-// formMasterScript forms a master script that runs all parallel scripts in the background, waits for them to finish, then prints the output of each script.
+// formMasterScript forms a master script that runs all parallel scripts in the background and prints the output of each script as soon as it finishes.
+// The master script and the scripts it calls are run with shell, so the master script only uses POSIX sh features.
 // Return values are the master script and a boolean indicating whether the master script requires elevated privileges.
-func formMasterScript(myTarget target.Target, parallelScripts []ScriptDefinition) (string, bool) {
-	// we write the stdout and stderr from each command to temporary files and save the PID of each command
-	// in a variable named after the script
+func formMasterScript(targetTempDirectory string, parallelScripts []ScriptDefinition, shell string) (string, bool) {
+	// we write the stdout and stderr from each command to temporary files, each command also writes its run time, in
+	// milliseconds, and, when it finishes, its exit code to files
 	var masterScript strings.Builder
-	targetTempDirectory := myTarget.GetTempDirectory()
 	masterScript.WriteString(fmt.Sprintf("script_dir=%s\n", targetTempDirectory))
 	// change working directory to target temporary directory in case any of the scripts write out temporary files
 	masterScript.WriteString(fmt.Sprintf("cd %s\n", targetTempDirectory))
 	// the master script will run all parallel scripts in the background
+	// scripts with a timeout are stopped by the timeout command, if the target has it, which exits with timeoutExitcode
+	masterScript.WriteString(fmt.Sprintf(`run_script() {
+	if [ "$1" -gt 0 ] && command -v timeout >/dev/null 2>&1; then
+		timeout "$1" %[1]s "$2"
+	else
+		%[1]s "$2"
+	fi
+}
+`, shell))
+	// date may not support %N, e.g., busybox, or not be available, so the run time is measured in seconds, or left
+	// empty, rather than letting the arithmetic fail, which would stop the script's subshell before it writes the exit code
+	masterScript.WriteString(`now_ms() {
+	now=$(date +%s%N 2>/dev/null)
+	case "$now" in
+	''|*[!0-9]*)
+		now=$(date +%s 2>/dev/null)
+		case "$now" in
+		''|*[!0-9]*) ;;
+		*) echo "${now}000" ;;
+		esac
+		;;
+	*) echo $((now / 1000000)) ;;
+	esac
+}
+elapsed_ms() {
+	end=$(now_ms)
+	if [ -n "$1" ] && [ -n "$end" ]; then echo $((end - $1)); fi
+}
+`)
 	masterScript.WriteString("\n# run all scripts in the background\n")
 	needsElevatedPrivileges := false
 	for _, script := range parallelScripts {
 		if script.Superuser {
 			needsElevatedPrivileges = true
 		}
+		outputPath := path.Join("$script_dir", sanitizeScriptName(script.Name))
+		// the exit code is written before the run time, which is measured in its own subshell, so that it can't be
+		// lost, and the exit code file is renamed into place, so that it is complete when it exists
 		masterScript.WriteString(
-			fmt.Sprintf("bash %s > %s 2>%s &\n",
+			fmt.Sprintf("rm -f \"%s.exitcode\" \"%s.printed\"\n(start=$(now_ms); run_script %d \"%s\" > \"%s.stdout\" 2>\"%s.stderr\"; echo $? > \"%s.exitcode.tmp\"; (elapsed_ms \"$start\") > \"%s.duration\"; mv \"%s.exitcode.tmp\" \"%s.exitcode\") &\n",
+				outputPath, outputPath,
+				script.Timeout,
 				path.Join("$script_dir", scriptNameToFilename(script.Name)),
-				path.Join("$script_dir", sanitizeScriptName(script.Name)+".stdout"),
-				path.Join("$script_dir", sanitizeScriptName(script.Name)+".stderr"),
+				outputPath, outputPath, outputPath, outputPath, outputPath, outputPath,
 			),
 		)
-		masterScript.WriteString(fmt.Sprintf("%s_pid=$!\n", sanitizeScriptName(script.Name)))
-	}
-	// the master script will wait for all parallel scripts to finish
-	masterScript.WriteString("\n# wait for all scripts to finish\n")
-	for _, script := range parallelScripts {
-		masterScript.WriteString(fmt.Sprintf("wait \"$%s_pid\"\n", sanitizeScriptName(script.Name)))
-		masterScript.WriteString(fmt.Sprintf("%s_exitcode=$?\n", sanitizeScriptName(script.Name)))
 	}
-	// the master script will print the output of each script
-	masterScript.WriteString("\n# print output of each script\n")
+	// the master script will print the output of each script when it finishes
+	masterScript.WriteString("\n# print output of each script when it finishes\n")
+	masterScript.WriteString(`print_output() {
+	echo "<---------------------->"
+	echo "SCRIPT NAME: $1"
+	echo STDOUT:
+	cat "$script_dir/$2.stdout"
+	echo STDERR:
+	cat "$script_dir/$2.stderr"
+	echo "DURATION MS: $(cat "$script_dir/$2.duration")"
+	echo "EXIT CODE: $(cat "$script_dir/$2.exitcode")"
+	mv "$script_dir/$2.exitcode" "$script_dir/$2.printed"
+}
+`)
+	masterScript.WriteString(fmt.Sprintf("remaining=%d\n", len(parallelScripts)))
+	masterScript.WriteString("while [ $remaining -gt 0 ]; do\n")
 	for _, script := range parallelScripts {
-		masterScript.WriteString("echo \"<---------------------->\"\n")
-		masterScript.WriteString(fmt.Sprintf("echo SCRIPT NAME: %s\n", script.Name))
-		masterScript.WriteString(fmt.Sprintf("echo STDOUT:\ncat %s\n", path.Join("$script_dir", sanitizeScriptName(script.Name)+".stdout")))
-		masterScript.WriteString(fmt.Sprintf("echo STDERR:\ncat %s\n", path.Join("$script_dir", sanitizeScriptName(script.Name)+".stderr")))
-		masterScript.WriteString(fmt.Sprintf("echo EXIT CODE: $%s_exitcode\n", sanitizeScriptName(script.Name)))
+		name := sanitizeScriptName(script.Name)
+		quotedName := "'" + strings.ReplaceAll(script.Name, "'", `'\''`) + "'"
+		masterScript.WriteString(fmt.Sprintf("\tif [ -f \"$script_dir/%s.exitcode\" ]; then print_output %s \"%s\"; remaining=$((remaining - 1)); fi\n", name, quotedName, name))
 	}
+	// fractional sleep isn't POSIX, but is supported by GNU coreutils and busybox
+	masterScript.WriteString("\tif [ $remaining -gt 0 ]; then sleep 0.1 2>/dev/null || sleep 1; fi\n")
+	masterScript.WriteString("done\n")
+	masterScript.WriteString("wait\n")
 	return masterScript.String(), needsElevatedPrivileges
 }
 
+// runMasterScript runs the master script on the target and calls handle with the output of each script as soon as the
+// script finishes, so that the spinner shows progress and the outputs of the scripts that finished survive a failure.
+func runMasterScript(ctx context.Context, myTarget target.Target, cmd *exec.Cmd, handle func(ScriptOutput)) (err error) {
+	stdoutChannel := make(chan string)
+	stderrChannel := make(chan string)
+	exitcodeChannel := make(chan int)
+	errorChannel := make(chan error)
+	go func() {
+		errorChannel <- myTarget.RunCommandAsyncContext(ctx, cmd, stdoutChannel, stderrChannel, exitcodeChannel)
+	}()
+	var frame []string
+	var stderrLines []string
+	for {
+		select {
+		case line := <-stdoutChannel:
+			frame = append(frame, line)
+			if strings.HasPrefix(line, "EXIT CODE:") {
+				for _, scriptOutput := range parseMasterScriptOutput(strings.Join(frame, "\n") + "\n") {
+					// the output is received as soon as the script finishes
+					scriptOutput.End = time.Now()
+					scriptOutput.Start = scriptOutput.End.Add(-scriptOutput.Duration)
+					handle(scriptOutput)
+				}
+				frame = nil
+			}
+		case line := <-stderrChannel:
+			stderrLines = append(stderrLines, line)
+		case exitcode := <-exitcodeChannel:
+			// the output has been sent before the exit code
+			if err = <-errorChannel; err == nil {
+				if ctx.Err() != nil {
+					err = ctx.Err()
+				} else if exitcode != 0 {
+					err = fmt.Errorf("master script exited with code %d: %s", exitcode, strings.Join(stderrLines, "\n"))
+				}
+			}
+			if err != nil {
+				slog.Error("error running master script on target", slog.String("stdout", strings.Join(frame, "\n")), slog.String("stderr", strings.Join(stderrLines, "\n")), slog.Int("exitcode", exitcode), slog.String("error", err.Error()))
+			}
+			return
+		case err = <-errorChannel:
+			// the command didn't start
+			slog.Error("error running master script on target", slog.String("error", err.Error()))
+			return
+		}
+	}
+}
+
 // parseMasterScriptOutput parses the output of the master script that runs all parallel scripts in the background.
 // It returns a list of ScriptOutput objects, one for each script that was run.
 func parseMasterScriptOutput(masterScriptOutput string) (scriptOutputs []ScriptOutput) {
@@ -336,6 +632,7 @@ func parseMasterScriptOutput(masterScriptOutput string) (scriptOutputs []ScriptO
 		var stdout string
 		var stderr string
 		var exitcode string
+		var durationMs string
 		var stdoutLines []string
 		var stderrLines []string
 		stdoutStarted := false
@@ -351,6 +648,12 @@ func parseMasterScriptOutput(masterScriptOutput string) (scriptOutputs []ScriptO
 				stdoutStarted = false
 				continue
 			}
+			if strings.HasPrefix(line, "DURATION MS:") {
+				durationMs = strings.TrimSpace(strings.TrimPrefix(line, "DURATION MS:"))
+				stdoutStarted = false
+				stderrStarted = false
+				continue
+			}
 			if strings.HasPrefix(line, "EXIT CODE:") {
 				exitcode = strings.TrimSpace(strings.TrimPrefix(line, "EXIT CODE:"))
 				stdoutStarted = false
@@ -376,11 +679,16 @@ func parseMasterScriptOutput(masterScriptOutput string) (scriptOutputs []ScriptO
 			slog.Error("error converting exit code to integer, setting to -100", slog.String("exitcode", exitcode), slog.String("error", err.Error()))
 			exitCodeInt = -100
 		}
+		var duration time.Duration
+		if ms, err := strconv.Atoi(durationMs); err == nil { // empty if the target's date couldn't measure it
+			duration = time.Duration(ms) * time.Millisecond
+		}
 		scriptOutputs = append(scriptOutputs, ScriptOutput{
 			ScriptDefinition: ScriptDefinition{Name: scriptName},
 			Stdout:           stdout,
 			Stderr:           stderr,
 			Exitcode:         exitCodeInt,
+			Duration:         duration,
 		})
 	}
 	return
@@ -439,6 +747,7 @@ func prepareTargetToRunScripts(myTarget target.Target, scripts []ScriptDefinitio
 		}
 	}
 	// copy dependencies to target
+	var cachedTools []cachedTool
 	for dependency := range dependenciesToCopy {
 		var localDependencyPath string
 		// first look for the dependency in the "tools" directory
@@ -457,12 +766,39 @@ func prepareTargetToRunScripts(myTarget target.Target, scripts []ScriptDefinitio
 				continue
 			}
 		}
+		// dependencies in the target's tool cache are copied after the loop
+		if toolCacheEnabled {
+			var tool Tool
+			if tool, err = getTool(targetArchitecture, path.Base(dependency)); err == nil {
+				cachedTools = append(cachedTools, cachedTool{name: tool.Name, localPath: localDependencyPath, checksum: tool.SHA256})
+				continue
+			}
+			slog.Warn("failed to get dependency checksum", slog.String("dependency", dependency), slog.String("error", err.Error()))
+			err = nil
+		}
 		// copy dependency to target
 		err = myTarget.PushFile(localDependencyPath, targetTempDirectory)
 		if err != nil {
 			err = fmt.Errorf("error copying dependency to target: %v", err)
 			return
 		}
+		recordUsedTool(myTarget.GetName(), targetArchitecture, path.Base(dependency))
+	}
+	if len(cachedTools) > 0 {
+		if err = pushCachedTools(myTarget, cachedTools, localTempDir); err != nil {
+			// the dependencies can still be copied to the temporary directory
+			slog.Warn("failed to use the target's tool cache", slog.String("target", myTarget.GetName()), slog.String("error", err.Error()))
+			for _, tool := range cachedTools {
+				err = myTarget.PushFile(tool.localPath, targetTempDirectory)
+				if err != nil {
+					err = fmt.Errorf("error copying dependency to target: %v", err)
+					return
+				}
+			}
+		}
+		for _, tool := range cachedTools {
+			recordUsedTool(myTarget.GetName(), targetArchitecture, tool.name)
+		}
 	}
 	// install lkms on target
 	var lkms []string
@@ -475,6 +811,22 @@ func prepareTargetToRunScripts(myTarget target.Target, scripts []ScriptDefinitio
 			err = fmt.Errorf("error installing LKMs: %v", err)
 			return
 		}
+		for _, lkm := range installedLkms {
+			Audit(myTarget, "load kernel module", lkm, "not loaded", "loaded", true)
+		}
 	}
 	return
 }
+
+// uninstallLkms uninstalls the LKMs that were installed on the target, one at a time so that each is audited
+func uninstallLkms(myTarget target.Target, lkms []string) {
+	for _, lkm := range lkms {
+		err := myTarget.UninstallLkms([]string{lkm})
+		if err != nil {
+			slog.Error("error uninstalling LKMs", slog.String("lkms", lkm), slog.String("error", err.Error()))
+			Restored(myTarget, lkm, "loaded", err)
+			continue
+		}
+		Restored(myTarget, lkm, "not loaded", nil)
+	}
+}
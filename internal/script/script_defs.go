@@ -103,7 +103,7 @@ func GetScriptByName(name string) ScriptDefinition {
 
 // GetTimedScriptByName returns the script definition with the given name. It will panic if the script is not found.
 func GetTimedScriptByName(name string, duration int, interval int, frequency int) ScriptDefinition {
-	for _, script := range getCollectionScripts(duration, interval, frequency) {
+	for _, script := range append(getCollectionScripts(duration, interval, frequency), addedScripts...) {
 		if script.Name == name {
 			return script
 		}
@@ -111,6 +111,44 @@ func GetTimedScriptByName(name string, duration int, interval int, frequency int
 	panic(fmt.Sprintf("script not found: %s", name))
 }
 
+// addedScripts are the script definitions added at run time, e.g., from plugins
+var addedScripts []ScriptDefinition
+
+// AddScripts validates the script definitions and adds them to the scripts that can be retrieved by name.
+func AddScripts(scripts []ScriptDefinition) error {
+	for _, s := range scripts {
+		if s.Name == "" {
+			return fmt.Errorf("script name cannot be empty")
+		}
+		if s.Script == "" {
+			return fmt.Errorf("script %s: script cannot be empty", s.Name)
+		}
+		if strings.ContainsAny(s.Name, "/") {
+			return fmt.Errorf("script %s: name cannot contain /", s.Name)
+		}
+		if HasScript(s.Name) {
+			return fmt.Errorf("script %s: a script with the same name already exists", s.Name)
+		}
+		for _, fallback := range s.Fallbacks {
+			if fallback.Name == "" || fallback.Script == "" {
+				return fmt.Errorf("script %s: fallback name and script cannot be empty", s.Name)
+			}
+		}
+		addedScripts = append(addedScripts, s)
+	}
+	return nil
+}
+
+// HasScript returns true if a script with the given name is defined.
+func HasScript(name string) bool {
+	for _, script := range append(getCollectionScripts(0, 0, 0), addedScripts...) {
+		if script.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // getCollectionScripts returns the script definitions that are used to collect information from the target system.
 func getCollectionScripts(duration, interval int, frequency int) (scripts []ScriptDefinition) {
 
@@ -130,6 +168,68 @@ func getCollectionScripts(duration, interval int, frequency int) (scripts []Scri
 			Script:    "dmidecode",
 			Superuser: true,
 			Depends:   []string{"dmidecode"},
+			// the BIOS, system, baseboard, and chassis information is also in sysfs, some of it (serial
+			// numbers, UUID) is only readable with elevated privileges
+			Fallbacks: []ScriptDefinition{
+				{
+					Name: "dmidecode sysfs",
+					Script: `dmi=/sys/class/dmi/id
+if [ ! -d $dmi ]; then
+	echo "$dmi not found" >&2
+	exit 1
+fi
+field() {
+	echo "	$1: $(cat $dmi/$2 2>/dev/null)"
+}
+echo "Handle 0x0000, DMI type 0, 26 bytes"
+echo "BIOS Information"
+field Vendor bios_vendor
+field Version bios_version
+field "Release Date" bios_date
+echo
+echo "Handle 0x0001, DMI type 1, 27 bytes"
+echo "System Information"
+field Manufacturer sys_vendor
+field "Product Name" product_name
+field Version product_version
+field "Serial Number" product_serial
+field UUID product_uuid
+field "SKU Number" product_sku
+field Family product_family
+echo
+echo "Handle 0x0002, DMI type 2, 15 bytes"
+echo "Base Board Information"
+field Manufacturer board_vendor
+field "Product Name" board_name
+field Version board_version
+field "Serial Number" board_serial
+echo
+case $(cat $dmi/chassis_type 2>/dev/null) in
+	1) chassis_type=Other ;;
+	3) chassis_type=Desktop ;;
+	4) chassis_type="Low Profile Desktop" ;;
+	6) chassis_type="Mini Tower" ;;
+	7) chassis_type=Tower ;;
+	9) chassis_type=Laptop ;;
+	10) chassis_type=Notebook ;;
+	17) chassis_type="Main Server Chassis" ;;
+	23) chassis_type="Rack Mount Chassis" ;;
+	24) chassis_type="Sealed-case PC" ;;
+	25) chassis_type="Multi-system" ;;
+	28) chassis_type=Blade ;;
+	29) chassis_type="Blade Enclosure" ;;
+	*) chassis_type=Unknown ;;
+esac
+echo "Handle 0x0003, DMI type 3, 22 bytes"
+echo "Chassis Information"
+field Manufacturer chassis_vendor
+echo "	Type: $chassis_type"
+field Version chassis_version
+field "Serial Number" chassis_serial
+echo
+`,
+				},
+			},
 		},
 		{
 			Name:   LscpuScriptName,
@@ -249,6 +349,7 @@ fi`,
 			Architectures: []string{x86_64},
 			Families:      []string{"6"}, // Intel
 			Lkms:          []string{"msr"},
+			Capabilities:  []string{CapabilityMSR},
 			Depends:       []string{"rdmsr"},
 			Superuser:     true,
 		},
@@ -258,6 +359,7 @@ fi`,
 			Architectures: []string{x86_64},
 			Families:      []string{"6"}, // Intel
 			Lkms:          []string{"msr"},
+			Capabilities:  []string{CapabilityMSR},
 			Depends:       []string{"rdmsr"},
 			Superuser:     true,
 		},
@@ -267,6 +369,7 @@ fi`,
 			Architectures: []string{x86_64},
 			Families:      []string{"6"}, // Intel
 			Lkms:          []string{"msr"},
+			Capabilities:  []string{CapabilityMSR},
 			Depends:       []string{"rdmsr"},
 			Superuser:     true,
 		},
@@ -276,6 +379,7 @@ fi`,
 			Architectures: []string{x86_64},
 			Families:      []string{"6"}, // Intel
 			Lkms:          []string{"msr"},
+			Capabilities:  []string{CapabilityMSR},
 			Depends:       []string{"rdmsr"},
 			Superuser:     true,
 		},
@@ -285,6 +389,7 @@ fi`,
 			Architectures: []string{x86_64},
 			Families:      []string{"6"}, // Intel
 			Lkms:          []string{"msr"},
+			Capabilities:  []string{CapabilityMSR},
 			Depends:       []string{"rdmsr"},
 			Superuser:     true,
 		},
@@ -294,6 +399,7 @@ fi`,
 			Architectures: []string{x86_64},
 			Families:      []string{"6"}, // Intel
 			Lkms:          []string{"msr"},
+			Capabilities:  []string{CapabilityMSR},
 			Depends:       []string{"rdmsr"},
 			Superuser:     true,
 		},
@@ -303,6 +409,7 @@ fi`,
 			Architectures: []string{x86_64},
 			Families:      []string{"6"}, // Intel
 			Lkms:          []string{"msr"},
+			Capabilities:  []string{CapabilityMSR},
 			Depends:       []string{"rdmsr"},
 			Superuser:     true,
 		},
@@ -312,6 +419,7 @@ fi`,
 			Architectures: []string{x86_64},
 			Families:      []string{"6"}, // Intel
 			Lkms:          []string{"msr"},
+			Capabilities:  []string{CapabilityMSR},
 			Depends:       []string{"rdmsr"},
 			Superuser:     true,
 		},
@@ -321,6 +429,7 @@ fi`,
 			Architectures: []string{x86_64},
 			Families:      []string{"6"}, // Intel
 			Lkms:          []string{"msr"},
+			Capabilities:  []string{CapabilityMSR},
 			Depends:       []string{"rdmsr"},
 			Superuser:     true,
 		},
@@ -330,6 +439,7 @@ fi`,
 			Architectures: []string{x86_64},
 			Families:      []string{"6"}, // Intel
 			Lkms:          []string{"msr"},
+			Capabilities:  []string{CapabilityMSR},
 			Depends:       []string{"rdmsr"},
 			Superuser:     true,
 		},
@@ -339,6 +449,7 @@ fi`,
 			Architectures: []string{x86_64},
 			Families:      []string{"6"}, // Intel
 			Lkms:          []string{"msr"},
+			Capabilities:  []string{CapabilityMSR},
 			Depends:       []string{"rdmsr"},
 			Superuser:     true,
 		},
@@ -348,6 +459,7 @@ fi`,
 			Architectures: []string{x86_64},
 			Families:      []string{"6"}, // Intel
 			Lkms:          []string{"msr"},
+			Capabilities:  []string{CapabilityMSR},
 			Depends:       []string{"rdmsr"},
 			Superuser:     true,
 		},
@@ -357,6 +469,7 @@ fi`,
 			Architectures: []string{x86_64},
 			Families:      []string{"6"}, // Intel
 			Lkms:          []string{"msr"},
+			Capabilities:  []string{CapabilityMSR},
 			Depends:       []string{"rdmsr"},
 			Superuser:     true,
 		},
@@ -448,6 +561,7 @@ done
 			Families:      []string{"6"}, // Intel
 			Depends:       []string{"pcm-tpmi"},
 			Superuser:     true,
+			Requires:      []string{RequireBash},
 		},
 		{
 			Name: ChaCountScriptName,
@@ -457,6 +571,7 @@ rdmsr 0x2FFE`, // uncore client cha count, uncore cha count, uncore cha count sp
 			Architectures: []string{x86_64},
 			Families:      []string{"6"}, // Intel
 			Lkms:          []string{"msr"},
+			Capabilities:  []string{CapabilityMSR},
 			Depends:       []string{"rdmsr"},
 			Superuser:     true,
 		},
@@ -469,10 +584,13 @@ rdmsr 0x2FFE`, // uncore client cha count, uncore cha count, uncore cha count sp
 			Script: "ls -1 /dev/dsa",
 		},
 		{
-			Name:      LshwScriptName,
-			Script:    "lshw -businfo -numeric",
-			Depends:   []string{"lshw"},
-			Superuser: true,
+			Name:         LshwScriptName,
+			Script:       "lshw -businfo -numeric",
+			Depends:      []string{"lshw"},
+			Superuser:    true,
+			Timeout:      120, // lshw can hang while probing some devices
+			Retries:      1,
+			RetryBackoff: 5,
 		},
 		{
 			Name:   MeminfoScriptName,
@@ -556,6 +674,7 @@ do
 	fi
 	echo "$name|$model|$size|$mountpoint|$fstype|$rqsize|$minio|$fw|$addr|$numa|$curlinkspeed|$curlinkwidth|$maxlinkspeed|$maxlinkwidth"
 done`,
+			Requires: []string{RequireBash, RequireGNUCoreutils},
 		},
 		{
 			Name: HdparmScriptName,
@@ -581,34 +700,41 @@ done`,
 			Depends:   []string{"spectre-meltdown-checker.sh", "rdmsr"},
 		},
 		{
-			Name:       ProcessListScriptName,
-			Script:     `ps -eo pid,ppid,%cpu,%mem,rss,command --sort=-%cpu,-pid | grep -v "]" | head -n 20`,
-			Sequential: true,
+			Name:      ProcessListScriptName,
+			Script:    `ps -eo pid,ppid,%cpu,%mem,rss,command --sort=-%cpu,-pid | grep -v "]" | head -n 20`,
+			Resources: []string{ResourceSystem}, // other scripts would be listed
 		},
 		{
-			Name:      IpmitoolSensorsScriptName,
-			Script:    "LC_ALL=C ipmitool sdr list full",
-			Superuser: true,
-			Depends:   []string{"ipmitool"},
+			Name:         IpmitoolSensorsScriptName,
+			Script:       "LC_ALL=C ipmitool sdr list full",
+			Superuser:    true,
+			Depends:      []string{"ipmitool"},
+			Capabilities: []string{CapabilityIPMI},
+			Timeout:      60, // the BMC can be slow to respond, or busy
+			Retries:      2,
+			RetryBackoff: 5,
 		},
 		{
-			Name:      IpmitoolChassisScriptName,
-			Script:    "LC_ALL=C ipmitool chassis status",
-			Superuser: true,
-			Depends:   []string{"ipmitool"},
+			Name:         IpmitoolChassisScriptName,
+			Script:       "LC_ALL=C ipmitool chassis status",
+			Superuser:    true,
+			Depends:      []string{"ipmitool"},
+			Capabilities: []string{CapabilityIPMI},
 		},
 		{
-			Name:      IpmitoolEventsScriptName,
-			Script:    `LC_ALL=C ipmitool sel elist | tail -n20 | cut -d'|' -f2-`,
-			Superuser: true,
-			Lkms:      []string{"ipmi_devintf", "ipmi_si"},
-			Depends:   []string{"ipmitool"},
+			Name:         IpmitoolEventsScriptName,
+			Script:       `LC_ALL=C ipmitool sel elist | tail -n20 | cut -d'|' -f2-`,
+			Superuser:    true,
+			Lkms:         []string{"ipmi_devintf", "ipmi_si"},
+			Depends:      []string{"ipmitool"},
+			Capabilities: []string{CapabilityIPMI},
 		},
 		{
-			Name:      IpmitoolEventTimeScriptName,
-			Script:    "LC_ALL=C ipmitool sel time get",
-			Superuser: true,
-			Depends:   []string{"ipmitool"},
+			Name:         IpmitoolEventTimeScriptName,
+			Script:       "LC_ALL=C ipmitool sel time get",
+			Superuser:    true,
+			Depends:      []string{"ipmitool"},
+			Capabilities: []string{CapabilityIPMI},
 		},
 		{
 			Name:      KernelLogScriptName,
@@ -648,7 +774,10 @@ done`,
 			Architectures: []string{x86_64},
 			Families:      []string{"6"}, // Intel
 			Lkms:          []string{"msr"},
+			Capabilities:  []string{CapabilityMSR},
 			Depends:       []string{"rdmsr"},
+			Requires:      []string{RequireBash},
+			Resources:     []string{ResourceMSR}, // other scripts that use the counters would make them appear busy
 		},
 		{
 			Name:          GaudiInfoScriptName,
@@ -687,7 +816,7 @@ echo $orig_num_huge_pages > /proc/sys/vm/nr_hugepages`,
 			Superuser:     true,
 			Lkms:          []string{"msr"},
 			Depends:       []string{"mlc"},
-			Sequential:    true,
+			Resources:     []string{ResourceSystem}, // changes huge pages and, while it runs, the prefetcher MSRs
 		},
 		{
 			Name: NumaBandwidthScriptName,
@@ -707,7 +836,7 @@ echo $orig_num_huge_pages > /proc/sys/vm/nr_hugepages`,
 			Superuser:     true,
 			Lkms:          []string{"msr"},
 			Depends:       []string{"mlc"},
-			Sequential:    true,
+			Resources:     []string{ResourceSystem}, // changes huge pages and, while it runs, the prefetcher MSRs
 		},
 		{
 			Name: CpuSpeedScriptName,
@@ -716,9 +845,9 @@ for method in $methods; do
 	printf "%s " "$method"
 	stress-ng --cpu 0 -t 1 --cpu-method "$method" --metrics-brief 2>&1 | tail -1 | awk '{print $9}'
 done`,
-			Superuser:  false,
-			Depends:    []string{"stress-ng"},
-			Sequential: true,
+			Superuser: false,
+			Depends:   []string{"stress-ng"},
+			Resources: []string{ResourceSystem}, // other scripts would interfere with the measurement
 		},
 		{
 			Name: TurboFrequenciesScriptName,
@@ -791,26 +920,31 @@ num_cores_per_socket=$( lscpu | grep 'Core(s) per socket:' | head -1 | awk '{pri
 
 # Run the avx-turbo benchmark
 avx-turbo --min-threads=1 --max-threads=$num_cores_per_socket --test scalar_iadd,avx128_fma,avx256_fma,avx512_fma --iters=100000 --cpuids=$interleaved_core_list`,
-			Superuser:  true,
-			Lkms:       []string{"msr"},
-			Depends:    []string{"avx-turbo"},
-			Sequential: true,
-		},
-		{
-			Name:       TurboFrequencyPowerAndTemperatureScriptName,
-			Script:     `((turbostat -i 2 2>/dev/null &) ; stress-ng --cpu 1 -t 20s 2>&1 ; stress-ng --cpu 0 -t 60s 2>&1 ; pkill -9 -f turbostat) | awk '$0~"stress" {print $0} $1=="Package" || $1=="CPU" || $1=="Core" || $1=="Node" {if(f!=1) print $0;f=1} $1=="-" {print $0}'		`,
-			Superuser:  true,
-			Lkms:       []string{"msr"},
-			Depends:    []string{"turbostat", "stress-ng"},
-			Sequential: true,
-		},
-		{
-			Name:       IdlePowerScriptName,
-			Script:     `turbostat --show PkgWatt -n 1 | sed -n 2p`,
-			Superuser:  true,
-			Lkms:       []string{"msr"},
-			Depends:    []string{"turbostat"},
-			Sequential: true,
+			Superuser:    true,
+			Lkms:         []string{"msr"},
+			Capabilities: []string{CapabilityMSR},
+			Depends:      []string{"avx-turbo"},
+			Resources:    []string{ResourceSystem},
+			Requires:     []string{RequireBash},
+		},
+		{
+			Name:         TurboFrequencyPowerAndTemperatureScriptName,
+			Script:       `((turbostat -i 2 2>/dev/null &) ; stress-ng --cpu 1 -t 20s 2>&1 ; stress-ng --cpu 0 -t 60s 2>&1 ; pkill -9 -f turbostat) | awk '$0~"stress" {print $0} $1=="Package" || $1=="CPU" || $1=="Core" || $1=="Node" {if(f!=1) print $0;f=1} $1=="-" {print $0}'		`,
+			Superuser:    true,
+			Lkms:         []string{"msr"},
+			Capabilities: []string{CapabilityMSR},
+			Depends:      []string{"turbostat", "stress-ng"},
+			Resources:    []string{ResourceSystem},
+			After:        []string{IdlePowerScriptName}, // measure idle power before the system is heated by the stress test
+		},
+		{
+			Name:         IdlePowerScriptName,
+			Script:       `turbostat --show PkgWatt -n 1 | sed -n 2p`,
+			Superuser:    true,
+			Lkms:         []string{"msr"},
+			Capabilities: []string{CapabilityMSR},
+			Depends:      []string{"turbostat"},
+			Resources:    []string{ResourceSystem},
 		},
 		// telemetry scripts
 		{
@@ -879,9 +1013,10 @@ avx-turbo --min-threads=1 --max-threads=$num_cores_per_socket --test scalar_iadd
 				}
 				return fmt.Sprintf(`turbostat -S -s PkgWatt,RAMWatt -q -i %d %s`, interval, count) + ` | awk '{ print strftime("%H:%M:%S"), $0 }'`
 			}(),
-			Superuser: true,
-			Lkms:      []string{"msr"},
-			Depends:   []string{"turbostat"},
+			Superuser:    true,
+			Lkms:         []string{"msr"},
+			Capabilities: []string{CapabilityMSR},
+			Depends:      []string{"turbostat"},
 		},
 
 		// flamegraph scripts
@@ -918,6 +1053,7 @@ done
 			}(),
 			Superuser: true,
 			Depends:   []string{"async-profiler"},
+			Requires:  []string{RequireBash},
 		},
 		{
 			Name: ProfileSystemScriptName,
@@ -926,9 +1062,9 @@ done
 # adjust perf_event_paranoid and kptr_restrict
 PERF_EVENT_PARANOID=$( cat /proc/sys/kernel/perf_event_paranoid )
 echo -1 >/proc/sys/kernel/perf_event_paranoid
-KPTR_RESTRICT=$( cat /proc/sys/kernel/kptr_restrict )
+`+AuditChange("write sysctl", "/proc/sys/kernel/perf_event_paranoid", "$PERF_EVENT_PARANOID", "$( cat /proc/sys/kernel/perf_event_paranoid )", true)+`KPTR_RESTRICT=$( cat /proc/sys/kernel/kptr_restrict )
 echo 0 >/proc/sys/kernel/kptr_restrict
-# system-wide call stack collection - frame pointer mode
+`+AuditChange("write sysctl", "/proc/sys/kernel/kptr_restrict", "$KPTR_RESTRICT", "$( cat /proc/sys/kernel/kptr_restrict )", true)+`# system-wide call stack collection - frame pointer mode
 frequency=%d
 duration=%d
 perf record -F $frequency -a -g -o perf_fp.data -m 129 -- sleep $duration &
@@ -941,8 +1077,8 @@ wait ${PERF_FP_PID}
 wait ${PERF_SYS_PID}
 # restore perf_event_paranoid and kptr_restrict
 echo "$PERF_EVENT_PARANOID" > /proc/sys/kernel/perf_event_paranoid
-echo "$KPTR_RESTRICT" > /proc/sys/kernel/kptr_restrict
-# collapse perf data
+`+AuditRestore("/proc/sys/kernel/perf_event_paranoid", "$( cat /proc/sys/kernel/perf_event_paranoid )")+`echo "$KPTR_RESTRICT" > /proc/sys/kernel/kptr_restrict
+`+AuditRestore("/proc/sys/kernel/kptr_restrict", "$( cat /proc/sys/kernel/kptr_restrict )")+`# collapse perf data
 perf script -i perf_dwarf.data | stackcollapse-perf.pl > perf_dwarf.folded
 perf script -i perf_fp.data | stackcollapse-perf.pl > perf_fp.folded
 if [ -f "perf_dwarf.folded" ]; then
@@ -955,8 +1091,9 @@ if [ -f "perf_fp.folded" ]; then
 fi
 `, frequency, duration)
 			}(),
-			Superuser: true,
-			Depends:   []string{"perf", "stackcollapse-perf.pl"},
+			Superuser:    true,
+			Depends:      []string{"perf", "stackcollapse-perf.pl"},
+			Capabilities: []string{CapabilityPerf},
 		},
 	}
 
@@ -4,10 +4,17 @@ package script
 // SPDX-License-Identifier: BSD-3-Clause
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"perfspect/internal/target"
 )
@@ -136,8 +143,6 @@ mpstat -u -T -I SCPU -P ALL 1 $count`,
 					t.Errorf("unexpected exit code: got %d, want %d", scriptOutput.Exitcode, expectedExitCode)
 				}
 			}
-			scriptDef1.Sequential = false
-			scriptDef2.Sequential = false
 			scriptOutputs, err := RunScripts(tgt, []ScriptDefinition{scriptDef1, scriptDef2}, false, os.TempDir())
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
@@ -156,3 +161,148 @@ mpstat -u -T -I SCPU -P ALL 1 $count`,
 		}
 	}
 }
+
+func TestRunScriptsContext(t *testing.T) {
+	tgt := target.NewLocalTarget()
+	targetTempDir, err := tgt.CreateTempDirectory("/tmp")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tgt.RemoveDirectory(targetTempDir)
+	tempDir := t.TempDir()
+	scripts := []ScriptDefinition{
+		{Name: "unittest quick", Script: "echo quick"},
+		{Name: "unittest slow", Script: "sleep 30"},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(500*time.Millisecond, cancel)
+	start := time.Now()
+	var progress []int
+	scriptOutputs, err := RunScriptsWithProgress(ctx, tgt, scripts, true, tempDir, func(finished int, total int) {
+		progress = append(progress, finished, total)
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled error, got %v", err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Error("scripts were not interrupted")
+	}
+	// the output of the script that finished before the scripts were interrupted is kept
+	if scriptOutputs["unittest quick"].Stdout != "quick\n" {
+		t.Errorf("unexpected outputs: %+v", scriptOutputs)
+	}
+	if !slices.Equal(progress, []int{1, 2}) {
+		t.Errorf("unexpected progress: %v", progress)
+	}
+	// a context that is already done prevents the scripts from running
+	_, err = RunScriptsContext(ctx, tgt, scripts, true, tempDir)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled error, got %v", err)
+	}
+}
+
+func TestRunScriptsDuration(t *testing.T) {
+	tgt := target.NewLocalTarget()
+	targetTempDir, err := tgt.CreateTempDirectory("/tmp")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tgt.RemoveDirectory(targetTempDir)
+	scripts := []ScriptDefinition{
+		{Name: "unittest parallel 1", Script: "sleep 0.2"},
+		{Name: "unittest parallel 2", Script: "exit 3"},
+		{Name: "unittest exclusive", Script: "sleep 0.2", Resources: []string{ResourceSystem}},
+	}
+	var recorded []string
+	SetRecorder(func(targetName string, scriptOutput ScriptOutput) {
+		recorded = append(recorded, scriptOutput.Name)
+	})
+	defer SetRecorder(nil)
+	scriptOutputs, err := RunScripts(tgt, scripts, true, t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, name := range []string{"unittest parallel 1", "unittest exclusive"} {
+		if scriptOutputs[name].Duration < 200*time.Millisecond {
+			t.Errorf("unexpected duration for %s: %s", name, scriptOutputs[name].Duration)
+		}
+	}
+	if scriptOutputs["unittest parallel 2"].Exitcode != 3 {
+		t.Errorf("unexpected exit code: %d", scriptOutputs["unittest parallel 2"].Exitcode)
+	}
+	if len(recorded) != len(scripts) {
+		t.Errorf("expected %d recorded scripts, got %v", len(scripts), recorded)
+	}
+}
+
+func TestRunScriptsMasterScriptOutput(t *testing.T) {
+	tgt := target.NewLocalTarget()
+	targetTempDir, err := tgt.CreateTempDirectory("/tmp")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tgt.RemoveDirectory(targetTempDir)
+	scripts := []ScriptDefinition{
+		{Name: "unittest it's quoted", Script: "echo quoted"},
+		{Name: "unittest long line", Script: "head -c 100000 /dev/zero | tr '\\0' x; echo"},
+	}
+	// run twice in the same directory, the files from the first run must not be used by the second
+	for range 2 {
+		scriptOutputs, err := RunScripts(tgt, scripts, false, t.TempDir())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if scriptOutputs["unittest it's quoted"].Stdout != "quoted\n" {
+			t.Errorf("unexpected stdout: %q", scriptOutputs["unittest it's quoted"].Stdout)
+		}
+		if len(scriptOutputs["unittest long line"].Stdout) != 100001 {
+			t.Errorf("unexpected stdout length: %d", len(scriptOutputs["unittest long line"].Stdout))
+		}
+	}
+}
+
+func TestRunScriptsDateWithoutNanoseconds(t *testing.T) {
+	tgt := target.NewLocalTarget()
+	targetTempDir, err := tgt.CreateTempDirectory("/tmp")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tgt.RemoveDirectory(targetTempDir)
+	realDate, err := exec.LookPath("date")
+	if err != nil {
+		t.Skip("date not found")
+	}
+	tests := []struct {
+		name string
+		shim string
+	}{
+		{"unsupported", "case \"$*\" in *%%N*) echo \"date: invalid format\" >&2; exit 1;; esac\nexec %s \"$@\"\n"},
+		{"literal", "case \"$*\" in *%%N*) echo \"$(%s +%%s)N\"; exit 0;; esac\nexec %[1]s \"$@\"\n"},
+		{"missing", "exit 127\n%.0s"},
+	}
+	scripts := []ScriptDefinition{
+		{Name: "unittest succeeds", Script: "echo ok"},
+		{Name: "unittest fails", Script: "exit 3"},
+	}
+	for _, test := range tests {
+		shimDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(shimDir, "date"), []byte("#!/bin/sh\n"+fmt.Sprintf(test.shim, realDate)), 0755); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		t.Setenv("PATH", shimDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		scriptOutputs, err := RunScriptsContext(ctx, tgt, scripts, false, t.TempDir())
+		cancel()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if scriptOutputs["unittest succeeds"].Stdout != "ok\n" || scriptOutputs["unittest succeeds"].Exitcode != 0 {
+			t.Errorf("%s: unexpected output: %+v", test.name, scriptOutputs["unittest succeeds"])
+		}
+		if scriptOutputs["unittest fails"].Exitcode != 3 {
+			t.Errorf("%s: unexpected exit code: %d", test.name, scriptOutputs["unittest fails"].Exitcode)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// toolcache.go keeps the tools that scripts depend on in a cache directory on the targets, so that a tool is only
+// copied to a target when the target doesn't have the same version of it, e.g., for repeated runs over slow links.
+// The tools are cached by their checksums, see Tool.SHA256, and linked into the target's temporary directory.
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"perfspect/internal/target"
+)
+
+// toolCacheDir is the cache directory on the targets, relative to the user's home directory
+const toolCacheDir = ".cache/perfspect/tools"
+
+var toolCacheEnabled bool
+
+// EnableToolCache keeps the tools that are copied to the targets in a cache directory on the targets
+func EnableToolCache() {
+	toolCacheEnabled = true
+}
+
+// cachedTool is a tool that is copied to the target's cache directory
+type cachedTool struct {
+	name      string
+	localPath string
+	checksum  string // see Tool.SHA256
+}
+
+// entry is the tool's path relative to the cache directory
+func (t cachedTool) entry() string {
+	return path.Join(t.checksum, t.name)
+}
+
+// pushCachedTools links the tools into the target's temporary directory from the target's cache directory, after
+// copying the tools that aren't in the cache to it
+func pushCachedTools(myTarget target.Target, tools []cachedTool, localTempDirForTarget string) (err error) {
+	// find the tools that aren't in the cache, their directories are created with a .partial suffix that is removed
+	// once they are copied, so that an interrupted copy isn't used
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("cache=\"$HOME/%s\"\nmkdir -p \"$cache\" && cd \"$cache\" || exit 1\necho \"cache=$(pwd)\"\n", toolCacheDir))
+	for _, tool := range tools {
+		sb.WriteString(fmt.Sprintf("[ -e '%[1]s' ] || { rm -rf '%[2]s.partial' && mkdir -p '%[2]s.partial' && echo 'missing=%[1]s'; }\n", tool.entry(), tool.checksum))
+	}
+	stdout, err := runHelperScript(context.Background(), myTarget, "tool_cache.sh", sb.String(), localTempDirForTarget)
+	if err != nil {
+		return
+	}
+	var cache string
+	missing := make(map[string]bool)
+	for _, line := range strings.Split(stdout, "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		switch key {
+		case "cache":
+			cache = value
+		case "missing":
+			missing[value] = true
+		}
+	}
+	if cache == "" {
+		return fmt.Errorf("failed to find the tool cache directory on the target")
+	}
+	// copy the missing tools and link all the tools into the temporary directory
+	sb.Reset()
+	sb.WriteString(fmt.Sprintf("cd '%s' || exit 1\n", cache))
+	for _, tool := range tools {
+		if missing[tool.entry()] {
+			slog.Debug("copying tool to the target's tool cache", slog.String("target", myTarget.GetName()), slog.String("tool", tool.entry()))
+			if err = myTarget.PushFile(tool.localPath, path.Join(cache, tool.checksum+".partial")); err != nil {
+				return fmt.Errorf("error copying dependency to target: %v", err)
+			}
+			sb.WriteString(fmt.Sprintf("rm -rf '%[1]s' && mv '%[1]s.partial' '%[1]s' || exit 1\n", tool.checksum))
+		}
+		sb.WriteString(fmt.Sprintf("ln -sfn '%s/%s' '%s/%s' || exit 1\n", cache, tool.entry(), myTarget.GetTempDirectory(), tool.name))
+	}
+	_, err = runHelperScript(context.Background(), myTarget, "tool_links.sh", sb.String(), localTempDirForTarget)
+	return
+}
+
+// CleanToolCache removes the cache directory from the target
+func CleanToolCache(ctx context.Context, myTarget target.Target, localTempDir string) (err error) {
+	localTempDirForTarget := path.Join(localTempDir, myTarget.GetName())
+	if err = os.MkdirAll(localTempDirForTarget, 0755); err != nil {
+		return
+	}
+	_, err = runHelperScript(ctx, myTarget, "clean_tool_cache.sh", fmt.Sprintf("rm -rf \"$HOME/%s\"\n", toolCacheDir), localTempDirForTarget)
+	return
+}
+
+// runHelperScript runs the POSIX sh script in the target's temporary directory and returns its output
+func runHelperScript(ctx context.Context, myTarget target.Target, name string, script string, localTempDirForTarget string) (stdout string, err error) {
+	scriptPath := path.Join(localTempDirForTarget, name)
+	if err = os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		return
+	}
+	if err = myTarget.PushFile(scriptPath, myTarget.GetTempDirectory()); err != nil {
+		return
+	}
+	stdout, stderr, _, err := myTarget.RunCommandContext(ctx, exec.Command("sh", path.Join(myTarget.GetTempDirectory(), name)))
+	if err != nil {
+		err = fmt.Errorf("error running %s on target: %v: %s", name, err, strings.TrimSpace(stderr))
+	}
+	return
+}
@@ -0,0 +1,73 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"perfspect/internal/target"
+)
+
+func TestToolCache(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	tgt := target.NewLocalTarget()
+	targetTempDir, err := tgt.CreateTempDirectory("/tmp")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tgt.RemoveDirectory(targetTempDir)
+	localDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(localDir, "profiler", "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range map[string]string{"turbostat": "turbostat", "profiler/bin/profile": "profile"} {
+		if err := os.WriteFile(filepath.Join(localDir, name), []byte(contents), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tools := []cachedTool{
+		{name: "turbostat", localPath: filepath.Join(localDir, "turbostat"), checksum: "1111"},
+		{name: "profiler", localPath: filepath.Join(localDir, "profiler"), checksum: "2222"},
+	}
+	if err := pushCachedTools(tgt, tools, localDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the tools are linked into the temporary directory from the cache
+	for name, contents := range map[string]string{"turbostat": "turbostat", "profiler/bin/profile": "profile"} {
+		if data, err := os.ReadFile(filepath.Join(targetTempDir, name)); err != nil || string(data) != contents {
+			t.Errorf("%s: unexpected contents: %q, error: %v", name, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(home, toolCacheDir, "1111.partial")); !os.IsNotExist(err) {
+		t.Errorf("unexpected partial directory, error: %v", err)
+	}
+	// a tool with the same checksum isn't copied again
+	if err := os.WriteFile(filepath.Join(localDir, "turbostat"), []byte("changed"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := pushCachedTools(tgt, tools, localDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(targetTempDir, "turbostat")); err != nil || string(data) != "turbostat" {
+		t.Errorf("unexpected contents: %q, error: %v", data, err)
+	}
+	// a tool with a new checksum is
+	tools[0].checksum = "3333"
+	if err := pushCachedTools(tgt, tools, localDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(targetTempDir, "turbostat")); err != nil || string(data) != "changed" {
+		t.Errorf("unexpected contents: %q, error: %v", data, err)
+	}
+	if err := CleanToolCache(context.Background(), tgt, localDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, toolCacheDir)); !os.IsNotExist(err) {
+		t.Errorf("tool cache not removed, error: %v", err)
+	}
+}
@@ -0,0 +1,455 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// tools.go lists, verifies, exports, and overrides the tools that scripts depend on, see ScriptDefinition.Depends.
+// Tools are bundled in the resources, by architecture, and can be overridden by the tools in the application's
+// tools directory, e.g., tools/x86_64/turbostat. Each directory may have a checksums file, in sha256sum's format,
+// that lists the SHA-256 of each of its files. The build creates the bundled tools' checksums file, and
+// OverrideTool updates the tools directory's.
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"perfspect/internal/util"
+)
+
+// ChecksumsFileName is the name of the checksums file in each architecture's tools directory
+const ChecksumsFileName = "SHA256SUMS"
+
+// tool sources
+const (
+	ToolSourceBundled  = "bundled"  // the tool is in the application's resources
+	ToolSourceOverride = "override" // the tool is in the application's tools directory
+)
+
+// checksum statuses, see ToolCheck
+const (
+	ChecksumOK       = "ok"
+	ChecksumMismatch = "mismatch"
+	ChecksumMissing  = "no checksum"
+)
+
+// Tool is a tool that scripts depend on, for one architecture. If the tool is a directory, its checksum is the
+// SHA-256 of its files' checksums, in sha256sum's format.
+type Tool struct {
+	Name         string `json:"name"`
+	Architecture string `json:"architecture"`
+	Source       string `json:"source"`            // where the tool that is used comes from, ToolSourceBundled or ToolSourceOverride
+	Path         string `json:"path"`              // the path of the tool, in the resources or the tools directory
+	SHA256       string `json:"sha256"`            // the checksum of the tool that is used
+	Bundled      bool   `json:"bundled"`           // the tool is in the resources, whether or not it is overridden
+	Version      string `json:"version,omitempty"` // the version that the tool reported on a target, if it was used on one
+}
+
+// ToolCheck is the result of verifying one of a tool's files against the checksums file
+type ToolCheck struct {
+	Tool     Tool
+	File     string // relative to the architecture's directory
+	Expected string // empty if the checksums file doesn't list the file
+	Actual   string
+	Status   string // ChecksumOK, ChecksumMismatch, or ChecksumMissing
+}
+
+// ToolsDir returns the application's tools directory, the tools in it override the bundled tools
+func ToolsDir() string {
+	return filepath.Join(util.GetAppDir(), "tools")
+}
+
+// ListTools returns the bundled and overriding tools for the architecture, or for all architectures if arch is empty
+func ListTools(arch string) (tools []Tool, err error) {
+	archs := []string{arch}
+	if arch == "" {
+		if archs, err = toolArchitectures(); err != nil {
+			return
+		}
+	}
+	for _, arch := range archs {
+		names := make(map[string]bool)
+		bundled := make(map[string]bool)
+		if entries, err := fs.ReadDir(Resources, path.Join("resources", arch)); err == nil {
+			for _, entry := range entries {
+				names[entry.Name()] = true
+				bundled[entry.Name()] = true
+			}
+		}
+		if entries, err := os.ReadDir(filepath.Join(ToolsDir(), arch)); err == nil {
+			for _, entry := range entries {
+				names[entry.Name()] = true
+			}
+		}
+		delete(names, ChecksumsFileName)
+		var sortedNames []string
+		for name := range names {
+			sortedNames = append(sortedNames, name)
+		}
+		sort.Strings(sortedNames)
+		for _, name := range sortedNames {
+			var tool Tool
+			if tool, err = getTool(arch, name); err != nil {
+				return
+			}
+			tool.Bundled = bundled[name]
+			tools = append(tools, tool)
+		}
+	}
+	return
+}
+
+// VerifyTools checks the files of the tools that are used for the architecture, or for all architectures if arch
+// is empty, against the checksums files
+func VerifyTools(arch string) (checks []ToolCheck, err error) {
+	tools, err := ListTools(arch)
+	if err != nil {
+		return
+	}
+	checksums := make(map[string]map[string]string) // by the directory's path
+	for _, tool := range tools {
+		fsys, dir := toolFS(tool.Source, tool.Architecture)
+		if _, ok := checksums[dir]; !ok {
+			if checksums[dir], err = readChecksums(fsys, path.Join(dir, ChecksumsFileName)); err != nil {
+				return
+			}
+		}
+		var files map[string]string
+		if files, err = fileChecksums(fsys, dir, tool.Name); err != nil {
+			return
+		}
+		for _, file := range sortedKeys(files) {
+			check := ToolCheck{Tool: tool, File: file, Expected: checksums[dir][file], Actual: files[file]}
+			switch check.Expected {
+			case "":
+				check.Status = ChecksumMissing
+			case check.Actual:
+				check.Status = ChecksumOK
+			default:
+				check.Status = ChecksumMismatch
+			}
+			checks = append(checks, check)
+		}
+	}
+	return
+}
+
+// ExportTools writes the bundled tools for the architecture, or for all architectures if arch is empty, and
+// their checksums files, to dir, in a directory for each architecture. The exported directories can be
+// used as the application's tools directory, e.g., to override some of the tools.
+func ExportTools(arch string, dir string) (paths []string, err error) {
+	archs := []string{arch}
+	if arch == "" {
+		if archs, err = toolArchitectures(); err != nil {
+			return
+		}
+	}
+	for _, arch := range archs {
+		entries, err := fs.ReadDir(Resources, path.Join("resources", arch))
+		if err != nil {
+			return nil, fmt.Errorf("no tools are bundled for %s", arch)
+		}
+		archDir := filepath.Join(dir, arch)
+		if err = os.MkdirAll(archDir, 0755); err != nil {
+			return nil, err
+		}
+		checksums := make(map[string]string)
+		for _, entry := range entries {
+			if entry.Name() == ChecksumsFileName {
+				continue
+			}
+			var outPath string
+			if outPath, err = util.ExtractResource(Resources, path.Join("resources", arch, entry.Name()), archDir); err != nil {
+				return nil, err
+			}
+			var files map[string]string
+			if files, err = fileChecksums(Resources, path.Join("resources", arch), entry.Name()); err != nil {
+				return nil, err
+			}
+			for file, checksum := range files {
+				checksums[file] = checksum
+			}
+			paths = append(paths, outPath)
+		}
+		checksumsPath := filepath.Join(archDir, ChecksumsFileName)
+		if err = writeChecksums(checksumsPath, checksums); err != nil {
+			return nil, err
+		}
+		paths = append(paths, checksumsPath)
+	}
+	return
+}
+
+// OverrideTool copies the file, or directory, at srcPath to the architecture's tools directory, where it
+// overrides the bundled tool with the same name, and adds its checksums to the directory's checksums file.
+// The name of the file must be the name of a tool that scripts depend on.
+func OverrideTool(arch string, srcPath string) (tool Tool, err error) {
+	name := filepath.Base(srcPath)
+	if !isDependency(name) {
+		err = fmt.Errorf("%s is not a tool that scripts depend on", name)
+		return
+	}
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return
+	}
+	archDir := filepath.Join(ToolsDir(), arch)
+	if err = os.MkdirAll(archDir, 0755); err != nil {
+		return
+	}
+	dstPath := filepath.Join(archDir, name)
+	if err = os.RemoveAll(dstPath); err != nil {
+		return
+	}
+	if info.IsDir() {
+		err = os.CopyFS(dstPath, os.DirFS(srcPath))
+	} else {
+		err = copyFile(srcPath, dstPath)
+	}
+	if err != nil {
+		return
+	}
+	// replace the tool's checksums
+	checksumsPath := filepath.Join(archDir, ChecksumsFileName)
+	checksums, err := readChecksums(os.DirFS(archDir), ChecksumsFileName)
+	if err != nil {
+		return
+	}
+	for file := range checksums {
+		if file == name || strings.HasPrefix(file, name+"/") {
+			delete(checksums, file)
+		}
+	}
+	files, err := fileChecksums(os.DirFS(archDir), ".", name)
+	if err != nil {
+		return
+	}
+	for file, checksum := range files {
+		checksums[file] = checksum
+	}
+	if err = writeChecksums(checksumsPath, checksums); err != nil {
+		return
+	}
+	slog.Info("overrode tool", slog.String("tool", name), slog.String("architecture", arch), slog.String("path", dstPath))
+	return getTool(arch, name)
+}
+
+var (
+	usedTools      = make(map[string]map[string]Tool) // by target name, then tool name
+	usedToolsMutex sync.Mutex
+)
+
+// UsedTools returns the tools that were copied to the target to run scripts, and their versions, if known
+func UsedTools(targetName string) (tools []Tool) {
+	usedToolsMutex.Lock()
+	defer usedToolsMutex.Unlock()
+	for _, name := range sortedKeys(usedTools[targetName]) {
+		tools = append(tools, usedTools[targetName][name])
+	}
+	return
+}
+
+// recordUsedTool records that the tool was copied to the target
+func recordUsedTool(targetName string, arch string, name string) {
+	tool, err := getTool(arch, name)
+	if err != nil {
+		slog.Warn("failed to get tool", slog.String("tool", name), slog.String("error", err.Error()))
+		return
+	}
+	usedToolsMutex.Lock()
+	defer usedToolsMutex.Unlock()
+	if usedTools[targetName] == nil {
+		usedTools[targetName] = make(map[string]Tool)
+	}
+	tool.Version = usedTools[targetName][name].Version
+	usedTools[targetName][name] = tool
+}
+
+// recordUsedToolVersion records the version that the tool reported on the target
+func recordUsedToolVersion(targetName string, name string, version string) {
+	usedToolsMutex.Lock()
+	defer usedToolsMutex.Unlock()
+	if tool, ok := usedTools[targetName][name]; ok {
+		tool.Version = version
+		usedTools[targetName][name] = tool
+	}
+}
+
+var (
+	toolChecksumCache      = make(map[string]string) // by the tool's source and path
+	toolChecksumCacheMutex sync.Mutex
+)
+
+// getTool returns the tool that is used for the architecture, the override, if there is one, or the bundled tool
+func getTool(arch string, name string) (tool Tool, err error) {
+	tool = Tool{Name: name, Architecture: arch, Source: ToolSourceBundled, Path: path.Join("resources", arch, name)}
+	if util.Exists(filepath.Join(ToolsDir(), arch, name)) {
+		tool.Source = ToolSourceOverride
+		tool.Path = filepath.Join(ToolsDir(), arch, name)
+	}
+	toolChecksumCacheMutex.Lock()
+	defer toolChecksumCacheMutex.Unlock()
+	key := tool.Source + ":" + tool.Path
+	if checksum, ok := toolChecksumCache[key]; ok {
+		tool.SHA256 = checksum
+		return
+	}
+	fsys, dir := toolFS(tool.Source, arch)
+	files, err := fileChecksums(fsys, dir, name)
+	if err != nil {
+		return
+	}
+	if len(files) == 1 && files[name] != "" {
+		tool.SHA256 = files[name]
+	} else {
+		hash := sha256.New()
+		for _, file := range sortedKeys(files) {
+			fmt.Fprintf(hash, "%s  %s\n", files[file], file)
+		}
+		tool.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	}
+	// overrides may change while the application runs, e.g., with OverrideTool
+	if tool.Source == ToolSourceBundled {
+		toolChecksumCache[key] = tool.SHA256
+	}
+	return
+}
+
+// toolArchitectures returns the architectures that have bundled or overriding tools
+func toolArchitectures() (archs []string, err error) {
+	if entries, err := fs.ReadDir(Resources, "resources"); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() {
+				archs = append(archs, entry.Name())
+			}
+		}
+	}
+	if entries, err := os.ReadDir(ToolsDir()); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() && !slices.Contains(archs, entry.Name()) {
+				archs = append(archs, entry.Name())
+			}
+		}
+	}
+	sort.Strings(archs)
+	return
+}
+
+// toolFS returns the file system and the directory in it of the architecture's tools from the source
+func toolFS(source string, arch string) (fsys fs.FS, dir string) {
+	if source == ToolSourceOverride {
+		return os.DirFS(filepath.Join(ToolsDir(), arch)), "."
+	}
+	return Resources, path.Join("resources", arch)
+}
+
+// fileChecksums returns the checksums of the files of the tool in dir, by their paths relative to dir
+func fileChecksums(fsys fs.FS, dir string, name string) (checksums map[string]string, err error) {
+	checksums = make(map[string]string)
+	err = fs.WalkDir(fsys, path.Join(dir, name), func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		file, err := fsys.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		hash := sha256.New()
+		if _, err := io.Copy(hash, file); err != nil {
+			return err
+		}
+		relPath := strings.TrimPrefix(filePath, dir+"/")
+		if dir == "." {
+			relPath = filePath
+		}
+		checksums[relPath] = hex.EncodeToString(hash.Sum(nil))
+		return nil
+	})
+	return
+}
+
+// readChecksums reads a checksums file, it returns no checksums if the file doesn't exist
+func readChecksums(fsys fs.FS, checksumsPath string) (checksums map[string]string, err error) {
+	checksums = make(map[string]string)
+	file, err := fsys.Open(checksumsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		checksum, name, found := strings.Cut(scanner.Text(), " ")
+		if !found {
+			continue
+		}
+		// the name is preceded by " " for text mode, or " *" for binary mode
+		name = strings.TrimPrefix(strings.TrimPrefix(name, " "), "*")
+		checksums[strings.TrimPrefix(name, "./")] = checksum
+	}
+	err = scanner.Err()
+	return
+}
+
+// writeChecksums writes a checksums file in sha256sum's format
+func writeChecksums(checksumsPath string, checksums map[string]string) error {
+	var sb strings.Builder
+	for _, file := range sortedKeys(checksums) {
+		sb.WriteString(fmt.Sprintf("%s  %s\n", checksums[file], file))
+	}
+	return os.WriteFile(checksumsPath, []byte(sb.String()), 0644)
+}
+
+// isDependency returns true if a script depends on the tool
+func isDependency(name string) bool {
+	for _, script := range append(getCollectionScripts(0, 0, 0), addedScripts...) {
+		if slices.Contains(script.Depends, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyFile copies the file, keeping its permissions, e.g., so that the copy is executable
+func copyFile(srcPath string, dstPath string) error {
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(dstPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+func sortedKeys[V any](m map[string]V) (keys []string) {
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
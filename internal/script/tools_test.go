@@ -0,0 +1,61 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestToolChecksums(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "profiler", "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range map[string]string{"turbostat": "turbostat", "profiler/bin/profile": "profile", "profiler/lib.so": "lib"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a tool may be a file or a directory
+	checksums := make(map[string]string)
+	for _, name := range []string{"turbostat", "profiler"} {
+		files, err := fileChecksums(os.DirFS(dir), ".", name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		maps.Copy(checksums, files)
+	}
+	if len(checksums) != 3 || checksums["profiler/bin/profile"] == "" || checksums["turbostat"] == checksums["profiler/lib.so"] {
+		t.Errorf("unexpected checksums: %v", checksums)
+	}
+	// the checksums file is in sha256sum's format
+	checksumsPath := filepath.Join(dir, ChecksumsFileName)
+	if err := writeChecksums(checksumsPath, checksums); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	read, err := readChecksums(os.DirFS(dir), ChecksumsFileName)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !maps.Equal(read, checksums) {
+		t.Errorf("unexpected checksums: got %v, want %v", read, checksums)
+	}
+	// binary mode and ./ prefixes, as written by sha256sum -b ./file
+	if err := os.WriteFile(checksumsPath, []byte(checksums["turbostat"]+" *./turbostat\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if read, err = readChecksums(os.DirFS(dir), ChecksumsFileName); err != nil || read["turbostat"] != checksums["turbostat"] {
+		t.Errorf("unexpected checksums: %v, error: %v", read, err)
+	}
+	// a missing checksums file has no checksums
+	if read, err = readChecksums(os.DirFS(t.TempDir()), ChecksumsFileName); err != nil || len(read) != 0 {
+		t.Errorf("unexpected checksums: %v, error: %v", read, err)
+	}
+	if !isDependency("turbostat") || isDependency("unittest unknown") {
+		t.Error("unexpected dependency check")
+	}
+}
@@ -0,0 +1,99 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// versions.go gets the versions of the tools that scripts use, see ScriptOutput.ToolVersion
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"perfspect/internal/target"
+)
+
+// toolVersionCommands are the commands that print the versions of the tools that scripts depend on
+var toolVersionCommands = map[string]string{
+	"cpuid":     "cpuid --version",
+	"dmidecode": "dmidecode --version",
+	"iostat":    "iostat -V",
+	"ipmitool":  "ipmitool -V",
+	"lshw":      "lshw -version",
+	"lspci":     "lspci --version",
+	"mpstat":    "mpstat -V",
+	"perf":      "perf --version",
+	"rdmsr":     "rdmsr --version",
+	"sar":       "sar -V",
+	"stress-ng": "stress-ng --version",
+	"turbostat": "turbostat --version",
+}
+
+// scriptTool returns the tool that produces the script's output, i.e., the first of its dependencies
+// whose version can be printed, or an empty string if there is none
+func scriptTool(script ScriptDefinition) string {
+	for _, dependency := range script.Depends {
+		if _, ok := toolVersionCommands[dependency]; ok {
+			return dependency
+		}
+	}
+	return ""
+}
+
+// toolVersionsScript prints a tool=version line for each of the tools whose version command succeeds,
+// it only uses POSIX sh features
+func toolVersionsScript(tools []string) string {
+	var sb strings.Builder
+	for _, tool := range tools {
+		sb.WriteString(fmt.Sprintf("v=$(%s 2>&1) && echo \"%s=$(echo \"$v\" | head -n 1)\"\n", toolVersionCommands[tool], tool))
+	}
+	sb.WriteString("exit 0\n")
+	return sb.String()
+}
+
+// parseToolVersions returns the versions of the tools from the output of toolVersionsScript
+func parseToolVersions(output string) map[string]string {
+	versions := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		tool, version, found := strings.Cut(line, "=")
+		if found && strings.TrimSpace(version) != "" {
+			versions[tool] = strings.TrimSpace(version)
+		}
+	}
+	return versions
+}
+
+// setToolVersions sets the version of the tool that produced each script output, the tools were
+// copied to the target's temporary directory with the scripts
+func setToolVersions(ctx context.Context, myTarget target.Target, scriptOutputs map[string]ScriptOutput, localTempDir string) {
+	var tools []string
+	for _, scriptOutput := range scriptOutputs {
+		if tool := scriptTool(scriptOutput.ScriptDefinition); tool != "" && scriptOutput.SkipReason == "" && !slices.Contains(tools, tool) {
+			tools = append(tools, tool)
+		}
+	}
+	if len(tools) == 0 {
+		return
+	}
+	slices.Sort(tools)
+	outputs, err := RunScriptsContext(ctx, myTarget, []ScriptDefinition{{Name: "tool versions", Script: toolVersionsScript(tools)}}, true, localTempDir)
+	if err != nil {
+		slog.Warn("failed to get tool versions", slog.String("target", myTarget.GetName()), slog.String("error", err.Error()))
+		return
+	}
+	versions := parseToolVersions(outputs["tool versions"].Stdout)
+	for tool, version := range versions {
+		recordUsedToolVersion(myTarget.GetName(), tool, version)
+	}
+	for name, scriptOutput := range scriptOutputs {
+		if scriptOutput.SkipReason != "" {
+			continue
+		}
+		if version, ok := versions[scriptTool(scriptOutput.ScriptDefinition)]; ok {
+			scriptOutput.ToolVersion = version
+			scriptOutputs[name] = scriptOutput
+		}
+	}
+}
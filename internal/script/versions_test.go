@@ -0,0 +1,31 @@
+package script
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestToolVersions(t *testing.T) {
+	if tool := scriptTool(ScriptDefinition{Depends: []string{"stackcollapse-perf.pl", "perf"}}); tool != "perf" {
+		t.Errorf("unexpected tool: %q", tool)
+	}
+	if tool := scriptTool(ScriptDefinition{Depends: []string{"stackcollapse-perf.pl"}}); tool != "" {
+		t.Errorf("unexpected tool: %q", tool)
+	}
+	// a tool whose version command fails isn't included
+	toolVersionCommands["unittest tool"] = "echo 'unittest 1.2.3'; echo 'second line'"
+	toolVersionCommands["unittest missing"] = "unittest-missing-tool --version"
+	defer delete(toolVersionCommands, "unittest tool")
+	defer delete(toolVersionCommands, "unittest missing")
+	out, err := exec.Command("sh", "-c", toolVersionsScript([]string{"unittest missing", "unittest tool"})).Output()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	versions := parseToolVersions(string(out))
+	if len(versions) != 1 || versions["unittest tool"] != "unittest 1.2.3" {
+		t.Errorf("unexpected versions: %v", versions)
+	}
+}
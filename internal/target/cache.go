@@ -0,0 +1,225 @@
+package target
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+// cache.go is an optional on-disk cache of what was discovered about targets, e.g., the CPU's
+// architecture, family, and model, so that it isn't rediscovered on every run
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// cacheFile holds the cached entries for a target. There is one file per host, boot, and user,
+// so the entries are invalidated when the host reboots, and entries that depend on the user's
+// privileges aren't shared between users. Entries from other versions of the application are
+// discarded.
+type cacheFile struct {
+	Host    string                     `json:"host"`
+	BootID  string                     `json:"boot_id"`
+	UID     string                     `json:"uid"`
+	Version string                     `json:"version"`
+	Entries map[string]json.RawMessage `json:"entries"`
+	path    string
+	mutex   sync.Mutex
+}
+
+var targetCache = struct {
+	mutex   sync.Mutex
+	dir     string
+	version string
+	files   map[string]*cacheFile // by target name, nil if the target can't be cached
+}{
+	files: make(map[string]*cacheFile),
+}
+
+// EnableCache enables the on-disk cache in dir for the given version of the application. The
+// cache is disabled by default, i.e., CacheGet always misses and CachePut does nothing.
+func EnableCache(dir string, version string) {
+	targetCache.mutex.Lock()
+	defer targetCache.mutex.Unlock()
+	targetCache.dir = dir
+	targetCache.version = version
+	targetCache.files = make(map[string]*cacheFile)
+}
+
+// CacheEnabled returns true if the on-disk cache is enabled.
+func CacheEnabled() bool {
+	targetCache.mutex.Lock()
+	defer targetCache.mutex.Unlock()
+	return targetCache.dir != ""
+}
+
+// CacheGet sets value to the target's cached entry for key and returns true, or returns false if
+// there is no such entry or the cache is disabled.
+func CacheGet(t Target, key string, value any) bool {
+	file := getCacheFile(t)
+	if file == nil {
+		return false
+	}
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+	entry, ok := file.Entries[key]
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(entry, value); err != nil {
+		slog.Warn("failed to decode cached entry", slog.String("target", t.GetName()), slog.String("key", key), slog.String("error", err.Error()))
+		return false
+	}
+	slog.Debug("using cached entry", slog.String("target", t.GetName()), slog.String("key", key))
+	return true
+}
+
+// CachePut sets the target's cached entry for key to value and writes the cache file. Errors are
+// logged, the cache is an optimization.
+func CachePut(t Target, key string, value any) {
+	file := getCacheFile(t)
+	if file == nil {
+		return
+	}
+	entry, err := json.Marshal(value)
+	if err != nil {
+		slog.Warn("failed to encode cache entry", slog.String("target", t.GetName()), slog.String("key", key), slog.String("error", err.Error()))
+		return
+	}
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+	file.Entries[key] = entry
+	if err := file.write(); err != nil {
+		slog.Warn("failed to write cache file", slog.String("path", file.path), slog.String("error", err.Error()))
+	}
+}
+
+// getCacheFile returns the target's cache file, or nil if the cache is disabled or the target
+// can't be identified. The target is identified once per run.
+func getCacheFile(t Target) *cacheFile {
+	targetCache.mutex.Lock()
+	dir, version := targetCache.dir, targetCache.version
+	file, ok := targetCache.files[t.GetName()]
+	targetCache.mutex.Unlock()
+	if dir == "" || ok {
+		return file
+	}
+	file, err := loadCacheFile(t, dir, version)
+	if err != nil {
+		slog.Warn("target details will not be cached", slog.String("target", t.GetName()), slog.String("error", err.Error()))
+	}
+	targetCache.mutex.Lock()
+	defer targetCache.mutex.Unlock()
+	if existing, ok := targetCache.files[t.GetName()]; ok { // identified concurrently
+		return existing
+	}
+	targetCache.files[t.GetName()] = file
+	return file
+}
+
+// loadCacheFile identifies the target and loads its cache file, if there is one.
+func loadCacheFile(t Target, dir string, version string) (file *cacheFile, err error) {
+	// one command, without a shell, works on all types of targets
+	cmd := exec.Command("cat", "/proc/sys/kernel/random/boot_id", "/proc/sys/kernel/hostname", "/proc/self/status")
+	stdout, _, _, err := t.RunCommand(cmd, 0)
+	if err != nil {
+		err = fmt.Errorf("failed to identify target: %v", err)
+		return
+	}
+	file = &cacheFile{Version: version, Entries: make(map[string]json.RawMessage)}
+	if file.BootID, file.Host, file.UID, err = parseCacheIdentity(stdout); err != nil {
+		return nil, err
+	}
+	file.path = filepath.Join(dir, file.prefix()+file.BootID+".json")
+	contents, err := os.ReadFile(file.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	var cached cacheFile
+	if err := json.Unmarshal(contents, &cached); err != nil {
+		slog.Warn("ignoring invalid cache file", slog.String("path", file.path), slog.String("error", err.Error()))
+		return file, nil
+	}
+	if cached.Version != version || cached.Entries == nil {
+		slog.Debug("ignoring cache file from another version", slog.String("path", file.path), slog.String("version", cached.Version))
+		return file, nil
+	}
+	file.Entries = cached.Entries
+	return
+}
+
+// parseCacheIdentity parses the output of the command that identifies the target, i.e., the
+// boot ID, the host name, and the process status, which includes the user's IDs.
+func parseCacheIdentity(output string) (bootID string, host string, uid string, err error) {
+	lines := strings.Split(output, "\n")
+	if len(lines) < 3 {
+		err = fmt.Errorf("unexpected target identity: %q", output)
+		return
+	}
+	bootID = strings.TrimSpace(lines[0])
+	host = strings.TrimSpace(lines[1])
+	for _, line := range lines[2:] {
+		// Uid: real effective saved filesystem
+		if fields := strings.Fields(line); len(fields) > 2 && fields[0] == "Uid:" {
+			uid = fields[2]
+		}
+	}
+	if bootID == "" || host == "" || uid == "" {
+		err = fmt.Errorf("unexpected target identity: %q", output)
+	}
+	return
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9.-]`)
+
+// prefix returns the start of the names of the host's cache files for the user. Underscores separate
+// the host, user, and boot ID, so they are replaced in the host name.
+func (f *cacheFile) prefix() string {
+	return unsafeFileNameChars.ReplaceAllString(f.Host, "-") + "_" + f.UID + "_"
+}
+
+// write writes the cache file and removes the files from the host's previous boots
+func (f *cacheFile) write() (err error) {
+	dir := filepath.Dir(f.path)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	contents, err := json.MarshalIndent(f, "", " ")
+	if err != nil {
+		return
+	}
+	// write and rename so that concurrent runs never read a partial file
+	tempFile, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	if _, err = tempFile.Write(contents); err != nil {
+		tempFile.Close()
+		os.Remove(tempFile.Name())
+		return
+	}
+	if err = tempFile.Close(); err != nil {
+		os.Remove(tempFile.Name())
+		return
+	}
+	if err = os.Rename(tempFile.Name(), f.path); err != nil {
+		os.Remove(tempFile.Name())
+		return
+	}
+	stalePaths, _ := filepath.Glob(filepath.Join(dir, f.prefix()+"*.json"))
+	for _, stalePath := range stalePaths {
+		if stalePath != f.path {
+			slog.Debug("removing cache file from previous boot", slog.String("path", stalePath))
+			os.Remove(stalePath)
+		}
+	}
+	return
+}
@@ -0,0 +1,73 @@
+package target
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCacheIdentity(t *testing.T) {
+	bootID, host, uid, err := parseCacheIdentity("6f1c0a52-9b1e-4f55-8f0e-0d2b1f2d4c11\nweb_01\nName:\tcat\nUid:\t1000\t1001\t1000\t1000\nGid:\t1000\t1000\t1000\t1000\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bootID != "6f1c0a52-9b1e-4f55-8f0e-0d2b1f2d4c11" || host != "web_01" || uid != "1001" {
+		t.Errorf("unexpected identity: %s %s %s", bootID, host, uid)
+	}
+	file := &cacheFile{Host: host, UID: uid}
+	if prefix := file.prefix(); prefix != "web-01_1001_" {
+		t.Errorf("unexpected prefix: %s", prefix)
+	}
+	if _, _, _, err := parseCacheIdentity("6f1c0a52-9b1e-4f55-8f0e-0d2b1f2d4c11\n"); err == nil {
+		t.Error("expected error for incomplete identity")
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	defer EnableCache("", "")
+	myTarget := NewLocalTarget()
+	// disabled
+	CachePut(myTarget, "key", "value")
+	var value string
+	if CacheGet(myTarget, "key", &value) || CacheEnabled() {
+		t.Fatal("cache should be disabled")
+	}
+	EnableCache(dir, "1.0")
+	if !CacheEnabled() {
+		t.Fatal("cache should be enabled")
+	}
+	if CacheGet(myTarget, "key", &value) {
+		t.Fatal("unexpected entry in empty cache")
+	}
+	file := getCacheFile(myTarget)
+	if file == nil {
+		t.Fatal("local target not identified")
+	}
+	// a file from a previous boot is removed when the cache is written
+	stalePath := filepath.Join(dir, file.prefix()+"00000000-0000-0000-0000-000000000000.json")
+	if err := os.WriteFile(stalePath, []byte("{}"), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	CachePut(myTarget, "key", "value")
+	CachePut(myTarget, "architecture", "cached-arch")
+	if _, err := os.Stat(stalePath); !os.IsNotExist(err) {
+		t.Errorf("file from previous boot not removed: %v", err)
+	}
+	// a new run uses the entries
+	EnableCache(dir, "1.0")
+	if !CacheGet(myTarget, "key", &value) || value != "value" {
+		t.Errorf("unexpected cached value: %q", value)
+	}
+	if arch, err := NewLocalTarget().GetArchitecture(); err != nil || arch != "cached-arch" {
+		t.Errorf("architecture not from cache: %q, %v", arch, err)
+	}
+	// another version ignores the entries
+	EnableCache(dir, "2.0")
+	if CacheGet(myTarget, "key", &value) {
+		t.Error("entries from another version should be ignored")
+	}
+}
@@ -0,0 +1,319 @@
+package target
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// container engines
+const (
+	ContainerEngineDocker  = "docker"
+	ContainerEngineKubectl = "kubectl"
+)
+
+// ContainerTarget is a container managed by docker, or a container in a Kubernetes pod
+// managed by kubectl. Commands are run with 'docker exec' or 'kubectl exec' and files are
+// transferred with 'docker cp' or 'kubectl cp'.
+type ContainerTarget struct {
+	name       string
+	engine     string
+	container  string // container name or ID (docker), or container in the pod (kubectl, optional)
+	pod        string
+	namespace  string
+	tempDir    string
+	arch       string
+	family     string
+	model      string
+	userPath   string
+	canElevate int
+	isRoot     int // zero indicates unknown, 1 indicates yes, -1 indicates no
+}
+
+// NewContainerTarget creates a new ContainerTarget for a container managed by docker.
+func NewContainerTarget(name string, container string) *ContainerTarget {
+	t := &ContainerTarget{
+		name:      name,
+		engine:    ContainerEngineDocker,
+		container: container,
+	}
+	return t
+}
+
+// NewPodTarget creates a new ContainerTarget for a Kubernetes pod managed by kubectl.
+// The namespace and container are optional. If not specified, kubectl's defaults are used.
+func NewPodTarget(name string, pod string, namespace string, container string) *ContainerTarget {
+	t := &ContainerTarget{
+		name:      name,
+		engine:    ContainerEngineKubectl,
+		container: container,
+		pod:       pod,
+		namespace: namespace,
+	}
+	return t
+}
+
+func (t *ContainerTarget) RunCommand(cmd *exec.Cmd, timeout int) (stdout string, stderr string, exitCode int, err error) {
+	ctx, cancel := contextWithTimeout(timeout)
+	defer cancel()
+	return t.RunCommandContext(ctx, cmd)
+}
+
+// When ctx can be done, the command is run with its standard input attached to a pipe that is
+// closed when ctx is done, which interrupts the command in the container, see interruptWatcher.
+func (t *ContainerTarget) RunCommandContext(ctx context.Context, cmd *exec.Cmd) (stdout string, stderr string, exitCode int, err error) {
+	localCommand := t.prepareLocalCommand(cmd, ctx.Done() != nil)
+	interrupt, release, err := stdinInterrupt(ctx, localCommand)
+	if err != nil {
+		return
+	}
+	defer release()
+	return runLocalCommandWithInputWithContext(ctx, localCommand, "", interrupt)
+}
+
+func (t *ContainerTarget) RunCommandAsync(cmd *exec.Cmd, stdoutChannel chan string, stderrChannel chan string, exitcodeChannel chan int, timeout int, cmdChannel chan *exec.Cmd) (err error) {
+	return runCommandAsyncWithTimeout(t, cmd, stdoutChannel, stderrChannel, exitcodeChannel, timeout, cmdChannel)
+}
+
+func (t *ContainerTarget) RunCommandAsyncContext(ctx context.Context, cmd *exec.Cmd, stdoutChannel chan string, stderrChannel chan string, exitcodeChannel chan int) (err error) {
+	localCommand := t.prepareLocalCommand(cmd, ctx.Done() != nil)
+	interrupt, release, err := stdinInterrupt(ctx, localCommand)
+	if err != nil {
+		return
+	}
+	defer release()
+	return runLocalCommandWithInputWithContextAsync(ctx, localCommand, stdoutChannel, stderrChannel, exitcodeChannel, "", interrupt)
+}
+
+func (t *ContainerTarget) GetArchitecture() (arch string, err error) {
+	if t.arch == "" {
+		t.arch, err = getArchitecture(t)
+	}
+	return t.arch, err
+}
+
+func (t *ContainerTarget) GetFamily() (family string, err error) {
+	if t.family == "" {
+		t.family, err = getFamily(t)
+	}
+	return t.family, err
+}
+
+func (t *ContainerTarget) GetModel() (family string, err error) {
+	if t.model == "" {
+		t.model, err = getModel(t)
+	}
+	return t.model, err
+}
+
+func (t *ContainerTarget) CreateTempDirectory(rootDir string) (tempDir string, err error) {
+	var root string
+	if rootDir != "" {
+		root = fmt.Sprintf("--tmpdir=%s", rootDir)
+	}
+	cmd := exec.Command("mktemp", "-d", "-t", root, "perfspect.tmp.XXXXXXXXXX", "|", "xargs", "realpath")
+	tempDir, _, _, err = t.RunCommand(cmd, 0)
+	tempDir = strings.TrimSpace(tempDir)
+	t.tempDir = tempDir
+	return
+}
+
+func (t *ContainerTarget) GetTempDirectory() (tempDir string) {
+	return t.tempDir
+}
+
+// PushFile copies a file or directory to the container. Like scp, if the destination is an
+// existing directory, the file or directory is copied into it.
+func (t *ContainerTarget) PushFile(srcPath string, dstPath string) (err error) {
+	if t.engine == ContainerEngineKubectl {
+		// unlike 'docker cp', 'kubectl cp' always copies to the given path
+		if _, _, _, err := t.RunCommand(exec.Command("test", "-d", dstPath), 0); err == nil {
+			dstPath = path.Join(dstPath, filepath.Base(srcPath))
+		}
+	}
+	localCommand := exec.Command(t.engine, t.prepareCopyArgs(srcPath, t.containerPath(dstPath))...)
+	stdout, stderr, exitCode, err := runLocalCommandWithInputWithTimeout(localCommand, "", 0)
+	slog.Debug("push file", slog.String("srcPath", srcPath), slog.String("dstPath", dstPath), slog.String("stdout", stdout), slog.String("stderr", stderr), slog.Int("exitCode", exitCode))
+	if err != nil {
+		err = fmt.Errorf("failed to push %s to %s: %v: %s", srcPath, t.GetName(), err, strings.TrimSpace(stderr))
+	}
+	return
+}
+
+// PullFile copies a file or directory from the container into the local destination directory.
+func (t *ContainerTarget) PullFile(srcPath string, dstDir string) (err error) {
+	dstPath := dstDir
+	if t.engine == ContainerEngineKubectl {
+		if info, statErr := os.Stat(dstDir); statErr == nil && info.IsDir() {
+			dstPath = filepath.Join(dstDir, path.Base(srcPath))
+		}
+	}
+	localCommand := exec.Command(t.engine, t.prepareCopyArgs(t.containerPath(srcPath), dstPath)...)
+	stdout, stderr, exitCode, err := runLocalCommandWithInputWithTimeout(localCommand, "", 0)
+	slog.Debug("pull file", slog.String("srcPath", srcPath), slog.String("dstDir", dstDir), slog.String("stdout", stdout), slog.String("stderr", stderr), slog.Int("exitCode", exitCode))
+	if err != nil {
+		err = fmt.Errorf("failed to pull %s from %s: %v: %s", srcPath, t.GetName(), err, strings.TrimSpace(stderr))
+	}
+	return
+}
+
+func (t *ContainerTarget) CreateDirectory(baseDir string, targetDir string) (dir string, err error) {
+	dir = filepath.Join(baseDir, targetDir)
+	cmd := exec.Command("mkdir", dir)
+	_, _, _, err = t.RunCommand(cmd, 0)
+	return
+}
+
+func (t *ContainerTarget) RemoveDirectory(targetDir string) (err error) {
+	if targetDir != "" {
+		cmd := exec.Command("rm", "-rf", targetDir)
+		_, _, _, err = t.RunCommand(cmd, 0)
+	}
+	return
+}
+
+func (t *ContainerTarget) CanConnect() bool {
+	cmd := exec.Command("exit", "0")
+	_, _, _, err := t.RunCommand(cmd, 5)
+	return err == nil
+}
+
+// CanElevatePrivileges (on ContainerTarget) checks if the container's user is root or if sudo can be used
+// to elevate privileges. Like on RemoteTarget, password-less sudo is required.
+func (t *ContainerTarget) CanElevatePrivileges() bool {
+	if t.canElevate != 0 {
+		return t.canElevate == 1
+	}
+	if t.runsAsRoot() {
+		t.canElevate = 1
+		return true
+	}
+	cmd := exec.Command("sudo", "-kS", "ls")
+	_, _, _, err := t.RunCommand(cmd, 0)
+	if err == nil { // true - passwordless sudo works
+		t.canElevate = 1
+		return true
+	}
+	t.canElevate = -1
+	return false
+}
+
+func (t *ContainerTarget) InstallLkms(lkms []string) (installedLkms []string, err error) {
+	return installLkms(t, lkms)
+}
+
+func (t *ContainerTarget) UninstallLkms(lkms []string) (err error) {
+	return uninstallLkms(t, lkms)
+}
+
+func (t *ContainerTarget) GetName() (name string) {
+	if t.name != "" {
+		return t.name
+	}
+	if t.engine == ContainerEngineKubectl {
+		return t.pod
+	}
+	return t.container
+}
+
+func (t *ContainerTarget) GetUserPath() (string, error) {
+	if t.userPath == "" {
+		cmd := exec.Command("echo", "$PATH")
+		stdout, _, _, err := t.RunCommand(cmd, 0)
+		if err != nil {
+			return "", err
+		}
+		t.userPath = strings.TrimSpace(stdout)
+	}
+	return t.userPath, nil
+}
+
+// runsAsRoot checks if commands run in the container as the root user.
+func (t *ContainerTarget) runsAsRoot() bool {
+	if t.isRoot == 0 {
+		t.isRoot = -1
+		stdout, _, _, err := t.RunCommand(exec.Command("id", "-u"), 0)
+		if err == nil && strings.TrimSpace(stdout) == "0" {
+			t.isRoot = 1
+		}
+	}
+	return t.isRoot == 1
+}
+
+// prepareLocalCommand forms the local exec command that runs the given command in the container.
+// Like ssh, the command's arguments are joined with spaces and interpreted by the container's shell.
+// Container images often don't include sudo, so it is removed from commands when the container's
+// user is root. Interruptible commands are run with interruptWatcher and need standard input.
+func (t *ContainerTarget) prepareLocalCommand(cmd *exec.Cmd, interruptible bool) *exec.Cmd {
+	args := cmd.Args
+	if len(args) > 1 && args[0] == "sudo" && t.runsAsRoot() {
+		args = args[1:]
+		for len(args) > 1 && strings.HasPrefix(args[0], "-") {
+			args = args[1:]
+		}
+	}
+	commandLine := strings.Join(args, " ")
+	var shellArgs []string
+	if interruptible {
+		shellArgs = interruptibleCommand(commandLine)
+	} else {
+		shellArgs = []string{"sh", "-c", commandLine}
+	}
+	var engineArgs []string
+	if t.engine == ContainerEngineKubectl {
+		engineArgs = append(engineArgs, "exec")
+		if interruptible {
+			engineArgs = append(engineArgs, "-i")
+		}
+		engineArgs = append(engineArgs, t.kubectlArgs()...)
+		engineArgs = append(engineArgs, t.pod)
+		if t.container != "" {
+			engineArgs = append(engineArgs, "-c", t.container)
+		}
+		engineArgs = append(engineArgs, "--")
+	} else {
+		engineArgs = append(engineArgs, "exec")
+		if interruptible {
+			engineArgs = append(engineArgs, "-i")
+		}
+		engineArgs = append(engineArgs, t.container)
+	}
+	engineArgs = append(engineArgs, shellArgs...)
+	return exec.Command(t.engine, engineArgs...)
+}
+
+// prepareCopyArgs forms the arguments to the engine's cp command.
+func (t *ContainerTarget) prepareCopyArgs(src string, dst string) (args []string) {
+	args = append(args, "cp")
+	if t.engine == ContainerEngineKubectl {
+		args = append(args, t.kubectlArgs()...)
+		if t.container != "" {
+			args = append(args, "-c", t.container)
+		}
+	}
+	args = append(args, src, dst)
+	return
+}
+
+// containerPath forms the engine's cp argument for a path in the container.
+func (t *ContainerTarget) containerPath(p string) string {
+	if t.engine == ContainerEngineKubectl {
+		return t.pod + ":" + p
+	}
+	return t.container + ":" + p
+}
+
+func (t *ContainerTarget) kubectlArgs() (args []string) {
+	if t.namespace != "" {
+		args = append(args, "-n", t.namespace)
+	}
+	return
+}
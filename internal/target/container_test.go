@@ -0,0 +1,213 @@
+package target
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeEngine is a stand-in for the docker and kubectl binaries. It logs its arguments, runs
+// exec'd commands with the local shell, and copies files on the local file system.
+const fakeEngine = `#!/bin/sh
+echo "$*" >> "$FAKE_ENGINE_LOG"
+command=$1
+shift
+case "$command" in
+exec)
+	while [ "$1" != "sh" ]; do shift; done
+	exec "$@"
+	;;
+cp)
+	while [ $# -gt 2 ]; do shift; done
+	src=${1#*:}
+	dst=${2#*:}
+	cp -R -p "$src" "$dst"
+	;;
+*)
+	exit 1
+	;;
+esac
+`
+
+// setupFakeEngines puts fake docker and kubectl binaries first in the PATH and returns the path to
+// the log of their arguments.
+func setupFakeEngines(t *testing.T) string {
+	t.Helper()
+	binDir := t.TempDir()
+	for _, engine := range []string{ContainerEngineDocker, ContainerEngineKubectl} {
+		if err := os.WriteFile(filepath.Join(binDir, engine), []byte(fakeEngine), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	logPath := filepath.Join(t.TempDir(), "engine.log")
+	t.Setenv("FAKE_ENGINE_LOG", logPath)
+	return logPath
+}
+
+func readEngineLog(t *testing.T, logPath string) string {
+	t.Helper()
+	contents, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(contents)
+}
+
+func TestContainerRunCommand(t *testing.T) {
+	logPath := setupFakeEngines(t)
+	for _, myTarget := range []*ContainerTarget{
+		NewContainerTarget("", "mycontainer"),
+		NewPodTarget("", "mypod", "myns", "mycontainer"),
+	} {
+		if !myTarget.CanConnect() {
+			t.Fatalf("failed to connect to %s", myTarget.GetName())
+		}
+		stdout, stderr, exitCode, err := myTarget.RunCommand(exec.Command("echo", "hello", "&&", "echo", "oops", ">&2"), 0)
+		if err != nil || stdout != "hello\n" || stderr != "oops\n" || exitCode != 0 {
+			t.Errorf("unexpected output: stdout=%q stderr=%q exitCode=%d err=%v", stdout, stderr, exitCode, err)
+		}
+		_, _, exitCode, err = myTarget.RunCommand(exec.Command("exit", "3"), 0)
+		if err == nil || exitCode != 3 {
+			t.Errorf("expected exit code 3 and an error, got exitCode=%d err=%v", exitCode, err)
+		}
+		arch, err := myTarget.GetArchitecture()
+		if err != nil || arch == "" {
+			t.Errorf("failed to get architecture: %v", err)
+		}
+	}
+	log := readEngineLog(t, logPath)
+	for _, expected := range []string{
+		"exec mycontainer sh -c echo hello && echo oops >&2",
+		"exec -n myns mypod -c mycontainer -- sh -c echo hello && echo oops >&2",
+	} {
+		if !strings.Contains(log, expected) {
+			t.Errorf("expected %q in engine log:\n%s", expected, log)
+		}
+	}
+}
+
+func TestContainerRunCommandContext(t *testing.T) {
+	setupFakeEngines(t)
+	testRunCommandContext(t, NewContainerTarget("", "mycontainer"), false)
+	testRunCommandContext(t, NewPodTarget("", "mypod", "", ""), false)
+}
+
+func TestContainerName(t *testing.T) {
+	if name := NewContainerTarget("", "mycontainer").GetName(); name != "mycontainer" {
+		t.Errorf("unexpected name: %s", name)
+	}
+	if name := NewPodTarget("", "mypod", "", "").GetName(); name != "mypod" {
+		t.Errorf("unexpected name: %s", name)
+	}
+	if name := NewPodTarget("label", "mypod", "", "").GetName(); name != "label" {
+		t.Errorf("unexpected name: %s", name)
+	}
+}
+
+func TestContainerSudo(t *testing.T) {
+	myTarget := NewContainerTarget("", "mycontainer")
+	myTarget.isRoot = 1
+	args := myTarget.prepareLocalCommand(exec.Command("sudo", "-kS", "bash", "script.sh"), false).Args
+	if args[len(args)-1] != "bash script.sh" {
+		t.Errorf("sudo not removed for root user: %v", args)
+	}
+	myTarget.isRoot = -1
+	args = myTarget.prepareLocalCommand(exec.Command("sudo", "bash", "script.sh"), false).Args
+	if args[len(args)-1] != "sudo bash script.sh" {
+		t.Errorf("sudo removed for non-root user: %v", args)
+	}
+}
+
+func TestContainerPushPullFile(t *testing.T) {
+	setupFakeEngines(t)
+	for _, myTarget := range []*ContainerTarget{
+		NewContainerTarget("", "mycontainer"),
+		NewPodTarget("", "mypod", "", ""),
+	} {
+		localDir := t.TempDir()
+		containerDir := t.TempDir() // the container's file system is the local file system
+		srcFile := filepath.Join(localDir, "script.sh")
+		if err := os.WriteFile(srcFile, []byte("echo hi\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		srcDir := filepath.Join(localDir, "tools")
+		if err := os.MkdirAll(filepath.Join(srcDir, "sub"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(srcDir, "sub", "data.txt"), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// push a file and a directory into a directory
+		if err := myTarget.PushFile(srcFile, containerDir); err != nil {
+			t.Fatalf("failed to push file: %v", err)
+		}
+		if err := myTarget.PushFile(srcDir, containerDir); err != nil {
+			t.Fatalf("failed to push directory: %v", err)
+		}
+		info, err := os.Stat(filepath.Join(containerDir, "script.sh"))
+		if err != nil || info.Mode().Perm() != 0755 {
+			t.Errorf("pushed file not found or mode not preserved: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(containerDir, "tools", "sub", "data.txt")); err != nil {
+			t.Errorf("pushed directory not found: %v", err)
+		}
+		// pull them back
+		pullDir := t.TempDir()
+		if err := myTarget.PullFile(filepath.Join(containerDir, "script.sh"), pullDir); err != nil {
+			t.Fatalf("failed to pull file: %v", err)
+		}
+		if err := myTarget.PullFile(filepath.Join(containerDir, "tools"), pullDir); err != nil {
+			t.Fatalf("failed to pull directory: %v", err)
+		}
+		for _, name := range []string{"script.sh", filepath.Join("tools", "sub", "data.txt")} {
+			if _, err := os.Stat(filepath.Join(pullDir, name)); err != nil {
+				t.Errorf("pulled file not found: %v", err)
+			}
+		}
+		if err := myTarget.PullFile(filepath.Join(containerDir, "missing"), pullDir); err == nil {
+			t.Error("expected error when pulling a file that doesn't exist")
+		}
+	}
+}
+
+func TestContainerRunCommandAsync(t *testing.T) {
+	setupFakeEngines(t)
+	myTarget := NewPodTarget("", "mypod", "", "")
+	stdoutChannel := make(chan string)
+	stderrChannel := make(chan string)
+	exitcodeChannel := make(chan int)
+	cmdChannel := make(chan *exec.Cmd)
+	errorChannel := make(chan error)
+	go func() {
+		errorChannel <- myTarget.RunCommandAsync(exec.Command("echo", "started;", "sleep", "0.1;", "echo", "done;", "exit", "5"), stdoutChannel, stderrChannel, exitcodeChannel, 0, cmdChannel)
+	}()
+	<-cmdChannel
+	var stdout []string
+	for {
+		select {
+		case line := <-stdoutChannel:
+			stdout = append(stdout, line)
+			continue
+		case exitCode := <-exitcodeChannel:
+			if exitCode != 5 {
+				t.Errorf("unexpected exit code: %d", exitCode)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for command to exit")
+		}
+		break
+	}
+	if strings.Join(stdout, ",") != "started,done" {
+		t.Errorf("unexpected stdout: %q", stdout)
+	}
+	if err := <-errorChannel; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,607 @@
+package target
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"perfspect/internal/util"
+)
+
+// sshClient is the native (pure Go) SSH transport used by RemoteTarget when
+// native SSH is enabled. It keeps one multiplexed connection to the target and
+// opens a new session on that connection for each command and file transfer.
+//
+// Files are transferred by streaming them through cat or tar in a session, rather
+// than with SFTP. SFTP needs the target's SSH server to provide the sftp subsystem,
+// which hardened sshd configurations disable and dropbear and BusyBox based images
+// don't include, while cat and tar are available on every target that PerfSpect
+// supports, including BusyBox. Transfers run without a context, so they are never
+// wrapped in interruptWatcher and their standard input is the transferred data.
+type sshClient struct {
+	mutex       sync.Mutex
+	jumpHosts   []sshEndpoint // intermediate hosts, in the order they are connected
+	target      sshEndpoint
+	client      *ssh.Client
+	jumpClients []*ssh.Client
+}
+
+// sshEndpoint holds the address and client configuration of one host in the connection chain.
+type sshEndpoint struct {
+	address string
+	config  *ssh.ClientConfig
+}
+
+const (
+	sshConnectTimeout      = 10 * time.Second
+	sshKeepAliveInterval   = 30 * time.Second
+	sshKeepAliveCountMax   = 10 // 30 * 10 = maximum 300 seconds before disconnect on no response
+	sshDefaultPort         = "22"
+	sshKeepAliveRequestMsg = "keepalive@openssh.com"
+)
+
+// newSSHClient creates a native SSH client for the given RemoteTarget. The connection
+// is not established until the first session is requested.
+func newSSHClient(t *RemoteTarget) (*sshClient, error) {
+	client := &sshClient{}
+	for _, jumpHost := range t.jumpHosts {
+		endpoint, err := newSSHEndpoint(jumpHost.Host, jumpHost.Port, jumpHost.User, jumpHost.Key, jumpHost.Pwd)
+		if err != nil {
+			return nil, fmt.Errorf("jump host %s: %v", jumpHost.Host, err)
+		}
+		client.jumpHosts = append(client.jumpHosts, endpoint)
+	}
+	endpoint, err := newSSHEndpoint(t.host, t.port, t.user, t.key, t.sshPass)
+	if err != nil {
+		return nil, err
+	}
+	client.target = endpoint
+	return client, nil
+}
+
+func newSSHEndpoint(host string, port string, userName string, key string, password string) (endpoint sshEndpoint, err error) {
+	authMethods, err := sshAuthMethods(key, password)
+	if err != nil {
+		return
+	}
+	if userName == "" {
+		var currentUser *user.User
+		if currentUser, err = user.Current(); err != nil {
+			err = fmt.Errorf("failed to get current user: %v", err)
+			return
+		}
+		userName = currentUser.Username
+	}
+	if port == "" {
+		port = sshDefaultPort
+	}
+	endpoint = sshEndpoint{
+		address: net.JoinHostPort(host, port),
+		config: &ssh.ClientConfig{
+			User: userName,
+			Auth: authMethods,
+			// matches the StrictHostKeyChecking=no and UserKnownHostsFile=/dev/null options used with the ssh binary
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(), // #nosec G106
+			Timeout:         sshConnectTimeout,
+		},
+	}
+	return
+}
+
+// sshAuthMethods returns the authentication methods to use for the connection.
+// If a key is provided, only public key authentication is used. If a password
+// is provided, password and keyboard-interactive authentication are used.
+// Otherwise, the keys held by the user's SSH agent and the user's default
+// identity files are tried, as the ssh binary would.
+func sshAuthMethods(keyPath string, password string) (methods []ssh.AuthMethod, err error) {
+	if keyPath != "" {
+		var signer ssh.Signer
+		if signer, err = readSigner(util.ExpandUser(keyPath)); err != nil {
+			return
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+		return
+	}
+	if password != "" {
+		methods = append(methods, ssh.Password(password))
+		methods = append(methods, ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+			answers := make([]string, len(questions))
+			for i := range answers {
+				answers[i] = password
+			}
+			return answers, nil
+		}))
+		return
+	}
+	var signers []ssh.Signer
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			slog.Debug("failed to connect to SSH agent", slog.String("error", err.Error()))
+		} else {
+			agentSigners, err := agent.NewClient(conn).Signers()
+			if err != nil {
+				slog.Debug("failed to get signers from SSH agent", slog.String("error", err.Error()))
+			}
+			signers = append(signers, agentSigners...)
+		}
+	}
+	for _, name := range []string{"id_rsa", "id_ecdsa", "id_ed25519"} {
+		identityPath := util.ExpandUser(path.Join("~", ".ssh", name))
+		if !util.Exists(identityPath) {
+			continue
+		}
+		signer, err := readSigner(identityPath)
+		if err != nil {
+			slog.Debug("skipping identity file", slog.String("path", identityPath), slog.String("error", err.Error()))
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+	return
+}
+
+func readSigner(keyPath string) (signer ssh.Signer, err error) {
+	keyBytes, err := os.ReadFile(keyPath)
+	if err != nil {
+		err = fmt.Errorf("failed to read private key file: %v", err)
+		return
+	}
+	signer, err = ssh.ParsePrivateKey(keyBytes)
+	if err != nil {
+		err = fmt.Errorf("failed to parse private key file (%s): %v", keyPath, err)
+	}
+	return
+}
+
+// connect establishes the connection to the target, through the jump hosts if any, if it
+// isn't already established.
+func (c *sshClient) connect() (*ssh.Client, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.client != nil {
+		return c.client, nil
+	}
+	var jumpClients []*ssh.Client
+	closeJumpClients := func() {
+		for i := len(jumpClients) - 1; i >= 0; i-- {
+			_ = jumpClients[i].Close()
+		}
+	}
+	var previous *ssh.Client
+	for _, endpoint := range c.jumpHosts {
+		slog.Debug("establishing SSH connection to jump host", slog.String("address", endpoint.address), slog.String("user", endpoint.config.User))
+		client, err := dialSSH(previous, endpoint)
+		if err != nil {
+			closeJumpClients()
+			return nil, fmt.Errorf("failed to connect to jump host %s: %v", endpoint.address, err)
+		}
+		jumpClients = append(jumpClients, client)
+		previous = client
+	}
+	slog.Debug("establishing SSH connection", slog.String("address", c.target.address), slog.String("user", c.target.config.User))
+	client, err := dialSSH(previous, c.target)
+	if err != nil {
+		closeJumpClients()
+		return nil, err
+	}
+	c.client = client
+	c.jumpClients = jumpClients
+	go c.keepAlive(client)
+	return client, nil
+}
+
+// dialSSH connects to the endpoint directly, if through is nil, or through the connection to a jump host.
+func dialSSH(through *ssh.Client, endpoint sshEndpoint) (*ssh.Client, error) {
+	if through == nil {
+		return ssh.Dial("tcp", endpoint.address, endpoint.config)
+	}
+	conn, err := through.Dial("tcp", endpoint.address)
+	if err != nil {
+		return nil, err
+	}
+	clientConn, channels, requests, err := ssh.NewClientConn(conn, endpoint.address, endpoint.config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(clientConn, channels, requests), nil
+}
+
+// keepAlive periodically sends keep alive requests on the connection and closes it
+// after too many requests go unanswered.
+func (c *sshClient) keepAlive(client *ssh.Client) {
+	ticker := time.NewTicker(sshKeepAliveInterval)
+	defer ticker.Stop()
+	missed := 0
+	for range ticker.C {
+		c.mutex.Lock()
+		current := c.client == client
+		c.mutex.Unlock()
+		if !current {
+			return
+		}
+		if _, _, err := client.SendRequest(sshKeepAliveRequestMsg, true, nil); err != nil {
+			missed++
+			if missed >= sshKeepAliveCountMax {
+				slog.Error("SSH connection is unresponsive, closing", slog.String("address", c.target.address))
+				c.reset(client)
+				return
+			}
+			continue
+		}
+		missed = 0
+	}
+}
+
+// reset closes the given connection and forgets it so that the next session request reconnects.
+func (c *sshClient) reset(client *ssh.Client) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.client == client {
+		c.closeLocked()
+	} else {
+		_ = client.Close()
+	}
+}
+
+// close closes the connection to the target and to the jump hosts.
+func (c *sshClient) close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closeLocked()
+}
+
+func (c *sshClient) closeLocked() (err error) {
+	if c.client != nil {
+		err = c.client.Close()
+	}
+	for i := len(c.jumpClients) - 1; i >= 0; i-- {
+		_ = c.jumpClients[i].Close()
+	}
+	c.client = nil
+	c.jumpClients = nil
+	return
+}
+
+// newSession opens a new session on the connection. If the existing connection
+// has been lost, it is re-established once.
+func (c *sshClient) newSession() (*ssh.Session, error) {
+	client, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		slog.Debug("failed to open SSH session, reconnecting", slog.String("address", c.target.address), slog.String("error", err.Error()))
+		c.reset(client)
+		if client, err = c.connect(); err != nil {
+			return nil, err
+		}
+		if session, err = client.NewSession(); err != nil {
+			return nil, err
+		}
+	}
+	return session, nil
+}
+
+// watchContext interrupts the command run in the session when ctx is done. If the command is run with
+// interruptWatcher, i.e., interruptible is true, its standard input is closed. Otherwise, it is sent
+// SIGINT through the session and, if it hasn't exited commandTerminateDelay later, SIGTERM. If the
+// command hasn't exited commandGracePeriod after it is interrupted, the session is closed. It must be
+// called before the command is started. The returned stop function must be called after the command
+// exits.
+func watchContext(ctx context.Context, session *ssh.Session, interruptible bool) (stop func(), err error) {
+	stop = func() {}
+	if ctx.Done() == nil {
+		return
+	}
+	var stdin io.WriteCloser
+	if interruptible {
+		if stdin, err = session.StdinPipe(); err != nil {
+			err = fmt.Errorf("failed to get stdin pipe: %v", err)
+			return
+		}
+	}
+	exited := make(chan struct{})
+	go func() {
+		select {
+		case <-exited:
+			return
+		case <-ctx.Done():
+		}
+		slog.Debug("interrupting remote command (native ssh)", slog.String("reason", ctx.Err().Error()), slog.Bool("watcher", interruptible))
+		gracePeriod := time.After(commandGracePeriod)
+		if interruptible {
+			_ = stdin.Close()
+		} else {
+			_ = session.Signal(ssh.SIGINT)
+			select {
+			case <-exited:
+				return
+			case <-time.After(commandTerminateDelay):
+				_ = session.Signal(ssh.SIGTERM)
+			}
+		}
+		select {
+		case <-exited:
+		case <-gracePeriod:
+			_ = session.Signal(ssh.SIGKILL)
+			_ = session.Close()
+		}
+	}()
+	stop = func() { close(exited) }
+	return
+}
+
+// runCommand runs the command on the target and waits for it to finish. When ctx is done, the
+// command is interrupted, see watchContext. Providing stdin isn't supported for interruptible commands.
+func (c *sshClient) runCommand(ctx context.Context, command string, stdin io.Reader, interruptible bool) (stdout string, stderr string, exitCode int, err error) {
+	slog.Debug("running remote command (native ssh)", slog.String("address", c.target.address), slog.String("cmd", command))
+	session, err := c.newSession()
+	if err != nil {
+		return
+	}
+	defer session.Close()
+	var outbuf, errbuf strings.Builder
+	session.Stdin = stdin
+	session.Stdout = &outbuf
+	session.Stderr = &errbuf
+	stop, err := watchContext(ctx, session, interruptible)
+	if err != nil {
+		return
+	}
+	err = session.Run(command)
+	stop()
+	stdout = outbuf.String()
+	stderr = errbuf.String()
+	exitCode = sshExitCode(err)
+	err = contextError(ctx, err)
+	return
+}
+
+// runCommandAsync starts the command on the target, sends each line of output to the
+// stdout and stderr channels as it arrives, and sends the exit code to the exitcode channel.
+// When ctx is done, the command is interrupted, see watchContext.
+func (c *sshClient) runCommandAsync(ctx context.Context, command string, stdoutChannel chan string, stderrChannel chan string, exitcodeChannel chan int, interruptible bool) (err error) {
+	slog.Debug("running remote command (native ssh, async)", slog.String("address", c.target.address), slog.String("cmd", command))
+	session, err := c.newSession()
+	if err != nil {
+		return
+	}
+	defer session.Close()
+	stdoutReader, err := session.StdoutPipe()
+	if err != nil {
+		err = fmt.Errorf("failed to get stdout pipe: %v", err)
+		return
+	}
+	stderrReader, err := session.StderrPipe()
+	if err != nil {
+		err = fmt.Errorf("failed to get stderr pipe: %v", err)
+		return
+	}
+	stop, err := watchContext(ctx, session, interruptible)
+	if err != nil {
+		return
+	}
+	defer stop()
+	if err = session.Start(command); err != nil {
+		err = fmt.Errorf("failed to run command (%s): %v", command, err)
+		return
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		scanLines(stdoutReader, stdoutChannel)
+	}()
+	go func() {
+		defer wg.Done()
+		scanLines(stderrReader, stderrChannel)
+	}()
+	waitErr := session.Wait()
+	wg.Wait()
+	exitcodeChannel <- sshExitCode(waitErr)
+	return nil
+}
+
+// sshExitCode returns the exit code of the remote command given the error returned from
+// running it. It returns -1 if the command didn't report an exit status, e.g., if it was
+// killed by a signal or the connection was lost.
+func sshExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	exitError := &ssh.ExitError{}
+	if errors.As(err, &exitError) {
+		if exitError.Signal() != "" {
+			return -1
+		}
+		return exitError.ExitStatus()
+	}
+	return -1
+}
+
+// pushFile copies a local file or directory to the target. It follows the semantics of scp. If dstPath
+// is an existing directory, the source is copied into it. Otherwise, the source is copied to dstPath.
+// Files are written with cat and directories are extracted with tar on the target.
+func (c *sshClient) pushFile(srcPath string, dstPath string) error {
+	srcInfo, err := os.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+	var command string
+	var stdin io.Reader
+	if srcInfo.IsDir() {
+		// stream a tar archive of the directory and extract it on the target
+		command = fmt.Sprintf("if [ -d %[1]s ]; then tar -x -C %[1]s; else mkdir -p %[1]s && tar -x -C %[1]s --strip-components=1; fi", shellQuote(dstPath))
+		pipeReader, pipeWriter := io.Pipe()
+		go func() {
+			pipeWriter.CloseWithError(writeTar(pipeWriter, srcPath))
+		}()
+		stdin = pipeReader
+	} else {
+		command = fmt.Sprintf("dst=%s; if [ -d \"$dst\" ]; then dst=\"$dst\"/%s; fi; cat > \"$dst\" && chmod %o \"$dst\"", shellQuote(dstPath), shellQuote(filepath.Base(srcPath)), srcInfo.Mode().Perm())
+		file, err := os.Open(srcPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		stdin = file
+	}
+	stdout, stderr, exitCode, err := c.runCommand(context.Background(), command, stdin, false)
+	if err != nil {
+		slog.Debug("push file failed", slog.String("srcPath", srcPath), slog.String("dstPath", dstPath), slog.String("stdout", stdout), slog.String("stderr", stderr), slog.Int("exitCode", exitCode))
+		return fmt.Errorf("failed to push %s to %s: %v: %s", srcPath, dstPath, err, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// pullFile copies a file or directory from the target to the local system. It follows the semantics of
+// scp. If dstDir is an existing directory, the source is copied into it. Otherwise, the source is copied
+// to dstDir. The source is archived with tar on the target.
+func (c *sshClient) pullFile(srcPath string, dstDir string) error {
+	command := fmt.Sprintf("tar -c -C %s %s", shellQuote(path.Dir(srcPath)), shellQuote(path.Base(srcPath)))
+	destination, rename := dstDir, ""
+	if exists, _ := util.DirectoryExists(dstDir); !exists {
+		destination, rename = filepath.Dir(dstDir), filepath.Base(dstDir)
+	}
+	session, err := c.newSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	var errbuf strings.Builder
+	session.Stderr = &errbuf
+	stdoutReader, err := session.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get stdout pipe: %v", err)
+	}
+	if err = session.Start(command); err != nil {
+		return fmt.Errorf("failed to run command (%s): %v", command, err)
+	}
+	extractErr := extractTar(stdoutReader, destination, rename)
+	if extractErr != nil {
+		// drain the remaining output so that the remote command can exit
+		_, _ = io.Copy(io.Discard, stdoutReader)
+	}
+	if err = session.Wait(); err != nil {
+		return fmt.Errorf("failed to pull %s: %v: %s", srcPath, err, strings.TrimSpace(errbuf.String()))
+	}
+	return extractErr
+}
+
+// writeTar writes a tar archive of the srcDir directory to w. Entry names are prefixed with the base
+// name of srcDir.
+func writeTar(w io.Writer, srcDir string) error {
+	tarWriter := tar.NewWriter(w)
+	baseDir := filepath.Dir(srcDir)
+	err := filepath.Walk(srcDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsDir() && !info.Mode().IsRegular() {
+			return nil // skip links, devices, etc.
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(baseDir, filePath)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if err = tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tarWriter, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tarWriter.Close()
+}
+
+// extractTar extracts the tar archive read from r into dstDir. If rename is not empty, the
+// first component of each entry's path is replaced with rename.
+func extractTar(r io.Reader, dstDir string, rename string) error {
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// there should never be a ".." in the path
+		if strings.Contains(header.Name, "..") {
+			return fmt.Errorf("archive contains invalid path: %s", header.Name)
+		}
+		name := header.Name
+		if rename != "" {
+			parts := strings.SplitN(strings.TrimPrefix(name, "./"), "/", 2)
+			parts[0] = rename
+			name = strings.Join(parts, "/")
+		}
+		target := filepath.Join(dstDir, filepath.FromSlash(name))
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.FileMode(header.Mode)|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tarReader); err != nil {
+				f.Close()
+				return err
+			}
+			f.Close()
+		}
+	}
+}
+
+// shellQuote quotes s so that it is interpreted literally by the remote shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// remoteCommandLine forms the command line that is sent to the remote shell. Like the ssh binary,
+// the arguments are joined with spaces and interpreted by the remote user's shell.
+func remoteCommandLine(cmd *exec.Cmd) string {
+	return strings.Join(cmd.Args, " ")
+}
@@ -0,0 +1,449 @@
+package target
+
+// Copyright (C) 2021-2024 Intel Corporation
+// SPDX-License-Identifier: BSD-3-Clause
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	testSSHUser     = "tester"
+	testSSHPassword = "secret"
+)
+
+var (
+	testClientKeyOnce   sync.Once
+	testClientKeyPEM    []byte
+	testClientPublicKey ssh.PublicKey
+)
+
+// testClientKey returns the path to a private key file that the test SSH servers accept.
+func testClientKey(t *testing.T) string {
+	t.Helper()
+	testClientKeyOnce.Do(func() {
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return
+		}
+		block, err := ssh.MarshalPrivateKey(privateKey, "")
+		if err != nil {
+			return
+		}
+		signer, err := ssh.NewSignerFromKey(privateKey)
+		if err != nil {
+			return
+		}
+		testClientKeyPEM = pem.EncodeToMemory(block)
+		testClientPublicKey = signer.PublicKey()
+	})
+	if testClientKeyPEM == nil {
+		t.Fatal("failed to generate client key")
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyPath, testClientKeyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return keyPath
+}
+
+// startTestSSHServer starts an in-process SSH server that runs exec requests with the local shell.
+// It returns the port the server is listening on.
+func startTestSSHServer(t *testing.T) string {
+	t.Helper()
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatalf("failed to create host key signer: %v", err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == testSSHUser && string(password) == testSSHPassword {
+				return nil, nil
+			}
+			return nil, errors.New("access denied")
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == testSSHUser && testClientPublicKey != nil && bytes.Equal(key.Marshal(), testClientPublicKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("access denied")
+		},
+	}
+	config.AddHostKey(signer)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSSHConn(conn, config)
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port
+}
+
+func serveTestSSHConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() == "direct-tcpip" {
+			go serveTestSSHForward(newChannel)
+			continue
+		}
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go serveTestSSHSession(channel, channelRequests)
+	}
+}
+
+// serveTestSSHForward forwards a direct-tcpip channel, as requested by a client using the server as a jump host.
+func serveTestSSHForward(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	go func() {
+		_, _ = io.Copy(channel, conn)
+		channel.Close()
+	}()
+	_, _ = io.Copy(conn, channel)
+	conn.Close()
+}
+
+func serveTestSSHSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	var cmd *exec.Cmd
+	done := make(chan struct{})
+	for request := range requests {
+		switch request.Type {
+		case "exec":
+			length := binary.BigEndian.Uint32(request.Payload[:4])
+			command := string(request.Payload[4 : 4+length])
+			cmd = exec.Command("sh", "-c", command)
+			cmd.Stdin = channel
+			cmd.Stdout = channel
+			cmd.Stderr = channel.Stderr()
+			if err := cmd.Start(); err != nil {
+				_ = request.Reply(false, nil)
+				return
+			}
+			_ = request.Reply(true, nil)
+			go func() {
+				exitCode := 0
+				if err := cmd.Wait(); err != nil {
+					exitCode = 255
+					exitErr := &exec.ExitError{}
+					if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+						exitCode = exitErr.ExitCode()
+					}
+				}
+				status := make([]byte, 4)
+				binary.BigEndian.PutUint32(status, uint32(exitCode))
+				_, _ = channel.SendRequest("exit-status", false, status)
+				close(done)
+				channel.Close()
+			}()
+		case "signal":
+			var payload struct{ Signal string }
+			_ = ssh.Unmarshal(request.Payload, &payload)
+			signal, ok := map[string]syscall.Signal{"INT": syscall.SIGINT, "TERM": syscall.SIGTERM, "KILL": syscall.SIGKILL}[payload.Signal]
+			if ok && cmd != nil && cmd.Process != nil {
+				_ = cmd.Process.Signal(signal)
+			}
+		default:
+			if request.WantReply {
+				_ = request.Reply(false, nil)
+			}
+		}
+	}
+	<-done
+}
+
+func newTestNativeTarget(t *testing.T) *RemoteTarget {
+	port := startTestSSHServer(t)
+	myTarget := NewRemoteTarget("native", "127.0.0.1", port, testSSHUser, "")
+	myTarget.SetSshPass(testSSHPassword)
+	myTarget.SetNativeSSH(true)
+	t.Cleanup(myTarget.resetSSHClient)
+	return myTarget
+}
+
+func TestNativeSSHRunCommand(t *testing.T) {
+	myTarget := newTestNativeTarget(t)
+	if !myTarget.CanConnect() {
+		t.Fatal("failed to connect to test SSH server")
+	}
+	stdout, stderr, exitCode, err := myTarget.RunCommand(exec.Command("echo", "hello", "&&", "echo", "oops", ">&2"), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdout != "hello\n" || stderr != "oops\n" || exitCode != 0 {
+		t.Errorf("unexpected output: stdout=%q stderr=%q exitCode=%d", stdout, stderr, exitCode)
+	}
+	_, _, exitCode, err = myTarget.RunCommand(exec.Command("exit", "3"), 0)
+	if err == nil || exitCode != 3 {
+		t.Errorf("expected exit code 3 and an error, got exitCode=%d err=%v", exitCode, err)
+	}
+	start := time.Now()
+	_, _, _, err = myTarget.RunCommand(exec.Command("sleep", "10"), 1)
+	if err == nil {
+		t.Error("expected error from command that timed out")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("command did not time out")
+	}
+	// the connection is reused after the timeout
+	stdout, _, _, err = myTarget.RunCommand(exec.Command("echo", "again"), 0)
+	if err != nil || stdout != "again\n" {
+		t.Errorf("unexpected result after timeout: stdout=%q err=%v", stdout, err)
+	}
+}
+
+func TestNativeSSHRunCommandContext(t *testing.T) {
+	testRunCommandContext(t, newTestNativeTarget(t), false)
+}
+
+func TestNativeSSHRunCommandContextSignal(t *testing.T) {
+	// commands that aren't run with interruptWatcher, because their context has no deadline or the target
+	// doesn't have pgrep, are interrupted with a signal through the session, well before the session is closed
+	withoutPgrep := newTestNativeTarget(t)
+	withoutPgrep.pgrepOnce.Do(func() {})
+	for _, test := range []struct {
+		target *RemoteTarget
+		ctx    func() (context.Context, context.CancelFunc)
+		err    error
+	}{
+		{newTestNativeTarget(t), func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(500*time.Millisecond, cancel)
+			return ctx, cancel
+		}, context.Canceled},
+		{withoutPgrep, func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 500*time.Millisecond)
+		}, context.DeadlineExceeded},
+	} {
+		ctx, cancel := test.ctx()
+		start := time.Now()
+		_, _, _, err := test.target.RunCommandContext(ctx, exec.Command("exec", "sleep", "30"))
+		cancel()
+		if !errors.Is(err, test.err) {
+			t.Errorf("expected %v, got %v", test.err, err)
+		}
+		if elapsed := time.Since(start); elapsed >= commandGracePeriod {
+			t.Errorf("command was not interrupted by a signal, returned after %s", elapsed)
+		}
+	}
+}
+
+func TestNativeSSHBadPassword(t *testing.T) {
+	myTarget := newTestNativeTarget(t)
+	myTarget.SetSshPass("wrong")
+	if myTarget.CanConnect() {
+		t.Fatal("connected with wrong password")
+	}
+}
+
+func TestNativeSSHPushPullFile(t *testing.T) {
+	myTarget := newTestNativeTarget(t)
+	localDir := t.TempDir()
+	remoteDir := t.TempDir() // the "remote" target is the local host
+	// push a single file into a directory
+	srcFile := filepath.Join(localDir, "script.sh")
+	if err := os.WriteFile(srcFile, []byte("echo hi\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := myTarget.PushFile(srcFile, remoteDir); err != nil {
+		t.Fatalf("failed to push file: %v", err)
+	}
+	// push a single file to a full path
+	if err := myTarget.PushFile(srcFile, filepath.Join(remoteDir, "renamed.sh")); err != nil {
+		t.Fatalf("failed to push file: %v", err)
+	}
+	for _, name := range []string{"script.sh", "renamed.sh"} {
+		contents, err := os.ReadFile(filepath.Join(remoteDir, name))
+		if err != nil || string(contents) != "echo hi\n" {
+			t.Errorf("unexpected contents of pushed file %s: %q, %v", name, contents, err)
+		}
+	}
+	info, err := os.Stat(filepath.Join(remoteDir, "script.sh"))
+	if err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("file mode not preserved: %v, %v", info.Mode(), err)
+	}
+	// push a directory
+	srcDir := filepath.Join(localDir, "tools")
+	if err := os.MkdirAll(filepath.Join(srcDir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "sub", "data.txt"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := myTarget.PushFile(srcDir, remoteDir); err != nil {
+		t.Fatalf("failed to push directory: %v", err)
+	}
+	contents, err := os.ReadFile(filepath.Join(remoteDir, "tools", "sub", "data.txt"))
+	if err != nil || string(contents) != "data" {
+		t.Errorf("unexpected contents of pushed directory: %q, %v", contents, err)
+	}
+	// pull a file and a directory back
+	pullDir := t.TempDir()
+	if err := myTarget.PullFile(filepath.Join(remoteDir, "renamed.sh"), pullDir); err != nil {
+		t.Fatalf("failed to pull file: %v", err)
+	}
+	if err := myTarget.PullFile(filepath.Join(remoteDir, "tools"), pullDir); err != nil {
+		t.Fatalf("failed to pull directory: %v", err)
+	}
+	for _, name := range []string{"renamed.sh", filepath.Join("tools", "sub", "data.txt")} {
+		if _, err := os.Stat(filepath.Join(pullDir, name)); err != nil {
+			t.Errorf("pulled file not found: %v", err)
+		}
+	}
+	// pull a file that doesn't exist
+	if err := myTarget.PullFile(filepath.Join(remoteDir, "missing"), pullDir); err == nil {
+		t.Error("expected error when pulling a file that doesn't exist")
+	}
+}
+
+func TestNativeSSHRunCommandAsync(t *testing.T) {
+	myTarget := newTestNativeTarget(t)
+	stdoutChannel := make(chan string)
+	stderrChannel := make(chan string)
+	exitcodeChannel := make(chan int)
+	cmdChannel := make(chan *exec.Cmd)
+	errorChannel := make(chan error)
+	go func() {
+		errorChannel <- myTarget.RunCommandAsync(exec.Command("trap", "'echo interrupted >&2; exit 7'", "INT;", "echo", "started;", "while", "true;", "do", "sleep", "0.1;", "done"), stdoutChannel, stderrChannel, exitcodeChannel, 0, cmdChannel)
+	}()
+	var cmd *exec.Cmd
+	select {
+	case cmd = <-cmdChannel:
+	case err := <-errorChannel:
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if line := <-stdoutChannel; line != "started" {
+		t.Fatalf("unexpected stdout: %q", line)
+	}
+	if cmd.Cancel == nil {
+		t.Fatal("expected cancel function")
+	}
+	if err := cmd.Cancel(); err != nil {
+		t.Fatalf("failed to cancel command: %v", err)
+	}
+	var stderr []string
+	for {
+		select {
+		case line := <-stderrChannel:
+			stderr = append(stderr, line)
+			continue
+		case exitCode := <-exitcodeChannel:
+			if exitCode != 7 {
+				t.Errorf("unexpected exit code: %d", exitCode)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for command to exit")
+		}
+		break
+	}
+	if strings.Join(stderr, "\n") != "interrupted" {
+		t.Errorf("unexpected stderr: %q", stderr)
+	}
+	if err := <-errorChannel; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNativeSSHJumpHosts(t *testing.T) {
+	myTarget := newTestNativeTarget(t)
+	firstPort := startTestSSHServer(t)
+	secondPort := startTestSSHServer(t)
+	myTarget.SetJumpHosts([]JumpHost{
+		{Host: "127.0.0.1", Port: firstPort, User: testSSHUser, Pwd: testSSHPassword},
+		{Host: "127.0.0.1", Port: secondPort, User: testSSHUser, Key: testClientKey(t)},
+	})
+	stdout, _, _, err := myTarget.RunCommand(exec.Command("echo", "hello"), 0)
+	if err != nil || stdout != "hello\n" {
+		t.Fatalf("unexpected result through jump hosts: stdout=%q err=%v", stdout, err)
+	}
+	client, err := myTarget.getSSHClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(client.jumpClients) != 2 {
+		t.Errorf("expected 2 jump host connections, got %d", len(client.jumpClients))
+	}
+	// a jump host that rejects the credentials
+	myTarget.SetJumpHosts([]JumpHost{{Host: "127.0.0.1", Port: firstPort, User: testSSHUser, Pwd: "wrong"}})
+	if myTarget.CanConnect() {
+		t.Error("connected through jump host with wrong password")
+	}
+}
+
+func TestSystemSSHJumpHosts(t *testing.T) {
+	if _, err := exec.LookPath("ssh"); err != nil {
+		t.Skip("ssh not found")
+	}
+	keyPath := testClientKey(t)
+	myTarget := NewRemoteTarget("system", "127.0.0.1", startTestSSHServer(t), testSSHUser, keyPath)
+	myTarget.SetJumpHosts([]JumpHost{
+		{Host: "127.0.0.1", Port: startTestSSHServer(t), User: testSSHUser, Key: keyPath},
+		{Host: "127.0.0.1", Port: startTestSSHServer(t), User: testSSHUser, Key: keyPath},
+	})
+	stdout, stderr, _, err := myTarget.RunCommand(exec.Command("echo", "hello"), 0)
+	if err != nil || stdout != "hello\n" {
+		t.Fatalf("unexpected result through jump hosts: stdout=%q stderr=%q err=%v", stdout, stderr, err)
+	}
+}
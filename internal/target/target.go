@@ -17,6 +17,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"perfspect/internal/util"
@@ -59,10 +61,21 @@ type Target interface {
 	// It returns the standard output, standard error, exit code, and any error that occurred.
 	RunCommand(cmd *exec.Cmd, timeout int) (stdout string, stderr string, exitCode int, err error)
 
+	// RunCommandContext runs the specified command on the target. If ctx is canceled or its deadline passes
+	// before the command exits, the command and the processes it started are interrupted.
+	// It returns the standard output, standard error, exit code, and any error that occurred.
+	RunCommandContext(ctx context.Context, cmd *exec.Cmd) (stdout string, stderr string, exitCode int, err error)
+
 	// RunCommandAsync runs the specified command on the target in an asynchronous manner.
 	// It returns any error that occurred.
 	RunCommandAsync(cmd *exec.Cmd, stdoutChannel chan string, stderrChannel chan string, exitcodeChannel chan int, timeout int, cmdChannel chan *exec.Cmd) error
 
+	// RunCommandAsyncContext runs the specified command on the target in an asynchronous manner. If ctx is
+	// canceled or its deadline passes before the command exits, the command and the processes it started
+	// are interrupted.
+	// It returns any error that occurred.
+	RunCommandAsyncContext(ctx context.Context, cmd *exec.Cmd, stdoutChannel chan string, stderrChannel chan string, exitcodeChannel chan int) error
+
 	// PushFile transfers a file from the local system to the target.
 	// It returns any error that occurred.
 	PushFile(srcPath string, dstPath string) error
@@ -121,6 +134,22 @@ type RemoteTarget struct {
 	model       string
 	userPath    string
 	canElevate  int
+	nativeSSH   bool
+	jumpHosts   []JumpHost
+	sshClient   *sshClient
+	sshMutex    sync.Mutex
+	pgrepOnce   sync.Once
+	hasPgrep    bool
+}
+
+// JumpHost is an intermediate host (bastion) through which a RemoteTarget is reached.
+// Port, User, Key, and Pwd are optional.
+type JumpHost struct {
+	Host string
+	Port string
+	User string
+	Key  string
+	Pwd  string
 }
 
 // NewLocalTarget creates a new LocalTarget
@@ -163,21 +192,100 @@ func (t *RemoteTarget) SetSshPassPath(sshpassPath string) {
 // SetSshPass sets the ssh password for the target (RemoteTarget only).
 func (t *RemoteTarget) SetSshPass(sshPass string) {
 	t.sshPass = sshPass
+	t.resetSSHClient()
+}
+
+// SetNativeSSH selects the built-in SSH client instead of the system's ssh, scp, and sshpass
+// binaries for all communication with the target (RemoteTarget only).
+func (t *RemoteTarget) SetNativeSSH(nativeSSH bool) {
+	t.nativeSSH = nativeSSH
+	t.resetSSHClient()
+}
+
+// SetJumpHosts sets the chain of jump hosts used to reach the target (RemoteTarget only).
+// The first jump host is connected to directly, each subsequent jump host and, finally,
+// the target are connected to through the previous one.
+func (t *RemoteTarget) SetJumpHosts(jumpHosts []JumpHost) {
+	t.jumpHosts = jumpHosts
+	t.resetSSHClient()
+}
+
+// getSSHClient returns the native SSH client for the target, creating it if necessary.
+func (t *RemoteTarget) getSSHClient() (*sshClient, error) {
+	t.sshMutex.Lock()
+	defer t.sshMutex.Unlock()
+	if t.sshClient == nil {
+		client, err := newSSHClient(t)
+		if err != nil {
+			return nil, err
+		}
+		t.sshClient = client
+	}
+	return t.sshClient, nil
+}
+
+// resetSSHClient closes the native SSH connection, if any, so that it is re-established with the
+// current connection details on next use.
+func (t *RemoteTarget) resetSSHClient() {
+	t.sshMutex.Lock()
+	defer t.sshMutex.Unlock()
+	if t.sshClient != nil {
+		_ = t.sshClient.close()
+		t.sshClient = nil
+	}
 }
 
 // RunCommand executes the given command with a timeout and returns the standard output,
 // standard error, exit code, and any error that occurred.
 func (t *LocalTarget) RunCommand(cmd *exec.Cmd, timeout int) (stdout string, stderr string, exitCode int, err error) {
-	input := ""
-	if t.sudo != "" && len(cmd.Args) > 2 && cmd.Args[0] == "sudo" && strings.HasPrefix(cmd.Args[1], "-") && strings.Contains(cmd.Args[1], "S") { // 'sudo -S' gets password from stdin
+	ctx, cancel := contextWithTimeout(timeout)
+	defer cancel()
+	return t.RunCommandContext(ctx, cmd)
+}
+
+func (t *RemoteTarget) RunCommand(cmd *exec.Cmd, timeout int) (stdout string, stderr string, exitCode int, err error) {
+	ctx, cancel := contextWithTimeout(timeout)
+	defer cancel()
+	return t.RunCommandContext(ctx, cmd)
+}
+
+// RunCommandContext executes the given command and returns the standard output, standard error,
+// exit code, and any error that occurred. When ctx is done, the command and its descendant
+// processes are interrupted. If the command was interrupted, the returned error wraps ctx.Err().
+func (t *LocalTarget) RunCommandContext(ctx context.Context, cmd *exec.Cmd) (stdout string, stderr string, exitCode int, err error) {
+	return runLocalCommandWithInputWithContext(ctx, cmd, t.sudoInput(cmd), interruptProcessTree)
+}
+
+// sudoInput returns the standard input for the command, the sudo password if the command is
+// 'sudo -S', which gets the password from stdin
+func (t *LocalTarget) sudoInput(cmd *exec.Cmd) (input string) {
+	if t.sudo != "" && len(cmd.Args) > 2 && cmd.Args[0] == "sudo" && strings.HasPrefix(cmd.Args[1], "-") && strings.Contains(cmd.Args[1], "S") {
 		input = t.sudo + "\n"
 	}
-	return runLocalCommandWithInputWithTimeout(cmd, input, timeout)
+	return
 }
 
-func (t *RemoteTarget) RunCommand(cmd *exec.Cmd, timeout int) (stdout string, stderr string, exitCode int, err error) {
-	localCommand := t.prepareLocalCommand(cmd, false)
-	return runLocalCommandWithInputWithTimeout(localCommand, "", timeout)
+// When ctx is done, the remote command is interrupted, see interruptible.
+func (t *RemoteTarget) RunCommandContext(ctx context.Context, cmd *exec.Cmd) (stdout string, stderr string, exitCode int, err error) {
+	interruptible := t.interruptible(ctx)
+	args := prepareRemoteArgs(cmd, interruptible)
+	if t.nativeSSH {
+		var client *sshClient
+		if client, err = t.getSSHClient(); err != nil {
+			return
+		}
+		return client.runCommand(ctx, strings.Join(args, " "), nil, interruptible)
+	}
+	localCommand := t.prepareLocalCommand(args, false)
+	if !interruptible {
+		return runLocalCommandWithInputWithContext(ctx, localCommand, "", interruptProcessTree)
+	}
+	interrupt, release, err := stdinInterrupt(ctx, localCommand)
+	if err != nil {
+		return
+	}
+	defer release()
+	return runLocalCommandWithInputWithContext(ctx, localCommand, "", interrupt)
 }
 
 // RunCommandAsync runs the given command asynchronously on the target.
@@ -185,19 +293,44 @@ func (t *RemoteTarget) RunCommand(cmd *exec.Cmd, timeout int) (stdout string, st
 // The output from the command is sent to the stdoutChannel and stderrChannel,
 // and the exit code is sent to the exitcodeChannel.
 // The timeout parameter specifies the maximum time allowed for the command to run.
+// The command sent to the cmdChannel is never started, its Cancel function interrupts the command.
 // Returns an error if there was a problem running the command.
 func (t *LocalTarget) RunCommandAsync(cmd *exec.Cmd, stdoutChannel chan string, stderrChannel chan string, exitcodeChannel chan int, timeout int, cmdChannel chan *exec.Cmd) (err error) {
-	localCommand := cmd
-	cmdChannel <- localCommand
-	err = runLocalCommandWithInputWithTimeoutAsync(localCommand, stdoutChannel, stderrChannel, exitcodeChannel, "", timeout)
-	return
+	return runCommandAsyncWithTimeout(t, cmd, stdoutChannel, stderrChannel, exitcodeChannel, timeout, cmdChannel)
 }
 
 func (t *RemoteTarget) RunCommandAsync(cmd *exec.Cmd, stdoutChannel chan string, stderrChannel chan string, exitcodeChannel chan int, timeout int, cmdChannel chan *exec.Cmd) (err error) {
-	localCommand := t.prepareLocalCommand(cmd, true)
-	cmdChannel <- localCommand
-	err = runLocalCommandWithInputWithTimeoutAsync(localCommand, stdoutChannel, stderrChannel, exitcodeChannel, "", timeout)
-	return
+	return runCommandAsyncWithTimeout(t, cmd, stdoutChannel, stderrChannel, exitcodeChannel, timeout, cmdChannel)
+}
+
+// RunCommandAsyncContext runs the given command asynchronously on the target. The output from the
+// command is sent to the stdoutChannel and stderrChannel, and the exit code is sent to the
+// exitcodeChannel. When ctx is done, the command and its descendant processes are interrupted.
+// Returns an error if there was a problem starting the command.
+func (t *LocalTarget) RunCommandAsyncContext(ctx context.Context, cmd *exec.Cmd, stdoutChannel chan string, stderrChannel chan string, exitcodeChannel chan int) (err error) {
+	return runLocalCommandWithInputWithContextAsync(ctx, cmd, stdoutChannel, stderrChannel, exitcodeChannel, t.sudoInput(cmd), interruptProcessTree)
+}
+
+func (t *RemoteTarget) RunCommandAsyncContext(ctx context.Context, cmd *exec.Cmd, stdoutChannel chan string, stderrChannel chan string, exitcodeChannel chan int) (err error) {
+	interruptible := t.interruptible(ctx)
+	args := prepareRemoteArgs(cmd, interruptible)
+	if t.nativeSSH {
+		var client *sshClient
+		if client, err = t.getSSHClient(); err != nil {
+			return
+		}
+		return client.runCommandAsync(ctx, strings.Join(args, " "), stdoutChannel, stderrChannel, exitcodeChannel, interruptible)
+	}
+	localCommand := t.prepareLocalCommand(args, true)
+	if !interruptible {
+		return runLocalCommandWithInputWithContextAsync(ctx, localCommand, stdoutChannel, stderrChannel, exitcodeChannel, "", interruptProcessTree)
+	}
+	interrupt, release, err := stdinInterrupt(ctx, localCommand)
+	if err != nil {
+		return
+	}
+	defer release()
+	return runLocalCommandWithInputWithContextAsync(ctx, localCommand, stdoutChannel, stderrChannel, exitcodeChannel, "", interrupt)
 }
 
 // GetArchitecture returns the architecture of the target.
@@ -299,6 +432,15 @@ func (t *LocalTarget) PushFile(srcPath string, dstPath string) (err error) {
 }
 
 func (t *RemoteTarget) PushFile(srcPath string, dstDir string) error {
+	if t.nativeSSH {
+		client, err := t.getSSHClient()
+		if err != nil {
+			return err
+		}
+		err = client.pushFile(srcPath, dstDir)
+		slog.Debug("push file (native ssh)", slog.String("srcPath", srcPath), slog.String("dstDir", dstDir), slog.Any("error", err))
+		return err
+	}
 	stdout, stderr, exitCode, err := t.prepareAndRunSCPCommand(srcPath, dstDir, true)
 	slog.Debug("push file", slog.String("srcPath", srcPath), slog.String("dstDir", dstDir), slog.String("stdout", stdout), slog.String("stderr", stderr), slog.Int("exitCode", exitCode))
 	return err
@@ -311,6 +453,15 @@ func (t *LocalTarget) PullFile(srcPath string, dstDir string) error {
 }
 
 func (t *RemoteTarget) PullFile(srcPath string, dstDir string) error {
+	if t.nativeSSH {
+		client, err := t.getSSHClient()
+		if err != nil {
+			return err
+		}
+		err = client.pullFile(srcPath, dstDir)
+		slog.Debug("pull file (native ssh)", slog.String("srcPath", srcPath), slog.String("dstDir", dstDir), slog.Any("error", err))
+		return err
+	}
 	stdout, stderr, exitCode, err := t.prepareAndRunSCPCommand(srcPath, dstDir, false)
 	slog.Debug("pull file", slog.String("srcPath", srcPath), slog.String("dstDir", dstDir), slog.String("stdout", stdout), slog.String("stderr", stderr), slog.Int("exitCode", exitCode))
 	return err
@@ -489,20 +640,188 @@ func (t *RemoteTarget) GetUserPath() (string, error) {
 
 // helpers below
 
+// commandGracePeriod is the time an interrupted command is given to exit before it is killed
+const commandGracePeriod = 5 * time.Second
+
+// maxOutputLineLength is the length of the longest line of output that is sent to the channels of
+// asynchronous commands. Longer lines are dropped, along with the rest of the output.
+const maxOutputLineLength = 16 * 1024 * 1024
+
+// scanLines sends the lines read from reader to channel. If a line is too long, the rest of the
+// output is discarded, so that the command isn't blocked writing to a full pipe.
+func scanLines(reader io.Reader, channel chan string) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxOutputLineLength)
+	for scanner.Scan() {
+		channel <- scanner.Text()
+	}
+	if err := scanner.Err(); err != nil {
+		slog.Error("failed to read command output, discarding the rest", slog.String("error", err.Error()))
+		_, _ = io.Copy(io.Discard, reader)
+	}
+}
+
+// commandTerminateDelay is the time between interrupting a command's processes and terminating
+// those that are still running, e.g., background processes started by a script ignore interrupts
+const commandTerminateDelay = 1 * time.Second
+
+// interruptWatcher is a shell script that runs the command line given as its first argument and
+// interrupts the command when the script's standard input is closed. Stopping the local ssh, docker,
+// or kubectl process doesn't reliably stop the command it runs on a remote target or in a container,
+// so commands that can be interrupted are run with the watcher, see interruptibleCommand and
+// RemoteTarget.interruptible. The script's process group is interrupted, or, if the script isn't a
+// process group leader, the script and its descendants. The processes that are still running a
+// second later, e.g., background processes, which ignore interrupts, are terminated. The watcher
+// reads standard input on fd 3 because background commands get /dev/null as standard input. When
+// the command exits, the script tells the watcher, with SIGUSR1, to exit instead of signaling when
+// standard input ends, so that closing the session doesn't signal the processes that the command
+// left running, or processes that reuse their IDs. The watcher ignores SIGUSR1 once it starts
+// interrupting, because the command exits when it is interrupted.
+const interruptWatcher = `exec 3<&0; sh -c 'trap "exit 0" USR1; cat >/dev/null; trap "" USR1; t() { for c in $(pgrep -P $1); do [ $c = $$ ] || t $c; done; echo $1; }; p=$(t $1); kill -INT -$1 || kill -INT $p; sleep 1; kill -TERM -$1 || kill -TERM $p' sh $$ <&3 >/dev/null 2>&1 & w=$!; exec 3<&-; sh -c "$1" </dev/null; s=$?; kill -USR1 $w 2>/dev/null; exit $s`
+
+// interruptibleCommand returns the arguments that run the command line with interruptWatcher.
+func interruptibleCommand(commandLine string) []string {
+	return []string{"sh", "-c", interruptWatcher, "sh", commandLine}
+}
+
+// interruptible returns true if a command run with ctx is run with interruptWatcher, i.e., if ctx has a
+// deadline and the target has pgrep, which the watcher uses to find the command's descendant processes.
+// Other commands are interrupted through the connection when ctx is done: the native SSH client sends
+// signals to the command through its session, and the system's ssh is interrupted, which closes the
+// session. The processes that those commands started in the background may keep running.
+func (t *RemoteTarget) interruptible(ctx context.Context) bool {
+	if _, ok := ctx.Deadline(); !ok {
+		return false
+	}
+	t.pgrepOnce.Do(func() {
+		// run without a deadline, so the probe itself isn't run with the watcher
+		_, _, _, err := t.RunCommand(exec.Command("command", "-v", "pgrep"), 0)
+		t.hasPgrep = err == nil
+		if !t.hasPgrep {
+			slog.Warn("pgrep not found on target, processes started by interrupted commands may keep running", slog.String("target", t.GetName()))
+		}
+	})
+	return t.hasPgrep
+}
+
+// prepareRemoteArgs returns the arguments that are sent to the remote shell to run the command. If
+// interruptible is true, the command is run with interruptWatcher.
+func prepareRemoteArgs(cmd *exec.Cmd, interruptible bool) []string {
+	if !interruptible {
+		return cmd.Args
+	}
+	var args []string
+	for _, arg := range interruptibleCommand(remoteCommandLine(cmd)) {
+		args = append(args, shellQuote(arg))
+	}
+	return args
+}
+
+// stdinInterrupt attaches a pipe to the standard input of a local command that runs a command with
+// interruptWatcher, e.g., through ssh. The returned interrupt function closes the pipe, which
+// interrupts the command. The returned release function must be called after the command exits.
+func stdinInterrupt(ctx context.Context, cmd *exec.Cmd) (interrupt func(*exec.Cmd) error, release func(), err error) {
+	release = func() {}
+	if ctx.Done() == nil {
+		return
+	}
+	stdinReader, stdinWriter, err := os.Pipe()
+	if err != nil {
+		err = fmt.Errorf("failed to create stdin pipe: %v", err)
+		return
+	}
+	cmd.Stdin = stdinReader
+	interrupt = func(*exec.Cmd) error {
+		return stdinWriter.Close()
+	}
+	release = func() {
+		stdinReader.Close()
+		stdinWriter.Close()
+	}
+	return
+}
+
+// interruptProcessTree interrupts a local command and its descendant processes. The processes that
+// are still running after commandTerminateDelay are terminated.
+func interruptProcessTree(cmd *exec.Cmd) error {
+	if err := cmd.Process.Signal(syscall.Signal(0)); err != nil {
+		return err // os.ErrProcessDone if the command has already exited
+	}
+	// the tree is recorded first, the descendants may ignore SIGINT and outlive the command
+	tree := util.ProcessTree(cmd.Process.Pid)
+	util.SignalProcesses(tree, os.Interrupt)
+	time.AfterFunc(commandTerminateDelay, func() {
+		util.SignalProcesses(tree, syscall.SIGTERM)
+	})
+	return nil
+}
+
+// contextWithTimeout returns a context that is done after timeout seconds, or a context that is
+// never done if timeout isn't greater than zero.
+func contextWithTimeout(timeout int) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	}
+	return context.Background(), func() {}
+}
+
+// contextError wraps the error from a command with ctx's error, if ctx is done, so that callers
+// can check if the command was interrupted with errors.Is.
+func contextError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, ctx.Err()) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ctx.Err(), err)
+}
+
+// commandWithContext returns a copy of the command that is interrupted by calling interrupt when
+// ctx is done. If the command hasn't exited commandGracePeriod after it is interrupted, it is
+// killed. If ctx can't be done, the command is returned as is.
+func commandWithContext(ctx context.Context, cmd *exec.Cmd, interrupt func(*exec.Cmd) error) *exec.Cmd {
+	if ctx.Done() == nil {
+		return cmd
+	}
+	commandWithContext := exec.CommandContext(ctx, cmd.Path, cmd.Args[1:]...)
+	commandWithContext.Env = cmd.Env
+	commandWithContext.Stdin = cmd.Stdin
+	commandWithContext.Dir = cmd.Dir
+	commandWithContext.Cancel = func() error {
+		slog.Debug("interrupting local command", slog.String("cmd", commandWithContext.String()), slog.String("reason", ctx.Err().Error()))
+		return interrupt(commandWithContext)
+	}
+	commandWithContext.WaitDelay = commandGracePeriod
+	return commandWithContext
+}
+
+// runCommandAsyncWithTimeout implements RunCommandAsync with the target's RunCommandAsyncContext.
+func runCommandAsyncWithTimeout(t Target, cmd *exec.Cmd, stdoutChannel chan string, stderrChannel chan string, exitcodeChannel chan int, timeout int, cmdChannel chan *exec.Cmd) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+	cmd.Cancel = func() error {
+		cancel()
+		return nil
+	}
+	cmdChannel <- cmd
+	return t.RunCommandAsyncContext(ctx, cmd, stdoutChannel, stderrChannel, exitcodeChannel)
+}
+
 func runLocalCommandWithInputWithTimeout(cmd *exec.Cmd, input string, timeout int) (stdout string, stderr string, exitCode int, err error) {
+	ctx, cancel := contextWithTimeout(timeout)
+	defer cancel()
+	return runLocalCommandWithInputWithContext(ctx, cmd, input, interruptProcessTree)
+}
+
+func runLocalCommandWithInputWithContext(ctx context.Context, cmd *exec.Cmd, input string, interrupt func(*exec.Cmd) error) (stdout string, stderr string, exitCode int, err error) {
 	logInput := ""
 	if input != "" {
 		logInput = "******"
 	}
-	slog.Debug("running local command", slog.String("cmd", cmd.String()), slog.String("input", logInput), slog.Int("timeout", timeout))
-	if timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
-		defer cancel()
-		commandWithContext := exec.CommandContext(ctx, cmd.Path, cmd.Args[1:]...)
-		commandWithContext.Env = cmd.Env
-		cmd = commandWithContext
-	}
+	slog.Debug("running local command", slog.String("cmd", cmd.String()), slog.String("input", logInput))
+	cmd = commandWithContext(ctx, cmd, interrupt)
 	if input != "" {
 		cmd.Stdin = strings.NewReader(input)
 	}
@@ -518,65 +837,75 @@ func runLocalCommandWithInputWithTimeout(cmd *exec.Cmd, input string, timeout in
 			exitCode = exitError.ExitCode()
 		}
 	}
+	err = contextError(ctx, err)
 	return
 }
 
-// TODO: does timeout make sense with async functions?
-func runLocalCommandWithInputWithTimeoutAsync(cmd *exec.Cmd, stdoutChannel chan string, stderrChannel chan string, exitcodeChannel chan int, input string, timeout int) (err error) {
+func runLocalCommandWithInputWithContextAsync(ctx context.Context, cmd *exec.Cmd, stdoutChannel chan string, stderrChannel chan string, exitcodeChannel chan int, input string, interrupt func(*exec.Cmd) error) (err error) {
 	logInput := ""
 	if input != "" {
 		logInput = "******"
 	}
-	slog.Debug("running local command (async)", slog.String("cmd", cmd.String()), slog.String("input", logInput), slog.Int("timeout", timeout))
-	if timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
-		defer cancel()
-		commandWithContext := exec.CommandContext(ctx, cmd.Path, cmd.Args[1:]...)
-		commandWithContext.Env = cmd.Env
-		cmd = commandWithContext
-	}
+	slog.Debug("running local command (async)", slog.String("cmd", cmd.String()), slog.String("input", logInput))
+	cmd = commandWithContext(ctx, cmd, interrupt)
 	if input != "" {
 		cmd.Stdin = strings.NewReader(input)
 	}
-	stdoutReader, err := cmd.StdoutPipe()
+	// the command writes to pipes that we own, rather than to those from cmd.StdoutPipe and
+	// cmd.StderrPipe, so that the output isn't discarded when the command exits
+	stdoutReader, stdoutWriter, err := os.Pipe()
 	if err != nil {
 		err = fmt.Errorf("failed to get stdout pipe: %v", err)
 		return
 	}
-	stdoutScanner := bufio.NewScanner(stdoutReader)
-	stderrReader, err := cmd.StderrPipe()
+	defer stdoutReader.Close()
+	stderrReader, stderrWriter, err := os.Pipe()
 	if err != nil {
+		stdoutWriter.Close()
 		err = fmt.Errorf("failed to get stderr pipe: %v", err)
 		return
 	}
-	stderrScanner := bufio.NewScanner(stderrReader)
-	if err = cmd.Start(); err != nil {
+	defer stderrReader.Close()
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+	err = cmd.Start()
+	// the command has its own copies of the write ends
+	stdoutWriter.Close()
+	stderrWriter.Close()
+	if err != nil {
 		err = fmt.Errorf("failed to run command (%s): %v", cmd, err)
 		return
 	}
+	scanned := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, scan := range []struct {
+		reader  *os.File
+		channel chan string
+	}{{stdoutReader, stdoutChannel}, {stderrReader, stderrChannel}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scanLines(scan.reader, scan.channel)
+		}()
+	}
 	go func() {
-		for stdoutScanner.Scan() {
-			text := stdoutScanner.Text()
-			stdoutChannel <- text
-		}
-	}()
-	go func() {
-		for stderrScanner.Scan() {
-			text := stderrScanner.Text()
-			stderrChannel <- text
-		}
+		wg.Wait()
+		close(scanned)
 	}()
-	err = cmd.Wait()
-	if err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			exitcodeChannel <- exitError.ExitCode()
-		} else {
-			panic(fmt.Sprintf("err from cmd.Wait is not type exec.ExitError: %v", err))
-		}
-	} else {
-		exitcodeChannel <- 0
+	if err = cmd.Wait(); err != nil {
+		slog.Debug("local command (async) exited", slog.String("cmd", cmd.String()), slog.String("error", err.Error()))
+	}
+	// send all of the output before the exit code, unless processes started by the command hold
+	// the pipes open
+	select {
+	case <-scanned:
+	case <-time.After(commandGracePeriod):
+		stdoutReader.Close()
+		stderrReader.Close()
+		<-scanned
 	}
+	// the exit code is -1 if the command was killed by a signal
+	exitcodeChannel <- cmd.ProcessState.ExitCode()
 	return nil
 }
 
@@ -637,9 +966,45 @@ func (t *RemoteTarget) prepareSSHFlags(scp bool, async bool, prompt bool) (flags
 		}
 		flags = append(flags, t.port)
 	}
+	if len(t.jumpHosts) > 0 {
+		flags = append(flags, "-o", "ProxyCommand="+t.prepareProxyCommand(len(t.jumpHosts)))
+	}
 	return
 }
 
+// prepareProxyCommand forms the ProxyCommand that forwards the ssh connection through the
+// first n jump hosts. Each jump host is reached through the ones before it by nesting
+// ProxyCommands. The ProxyCommand is run by a shell after ssh expands its % tokens, so the
+// nested ProxyCommand is quoted and its % characters are escaped.
+func (t *RemoteTarget) prepareProxyCommand(n int) string {
+	jumpHost := t.jumpHosts[n-1]
+	cmd := []string{
+		"ssh",
+		"-o", "UserKnownHostsFile=/dev/null",
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "ConnectTimeout=10",
+		"-o", "GSSAPIAuthentication=no",
+		"-o", "LogLevel=ERROR",
+		"-o", "BatchMode=yes",
+	}
+	if jumpHost.Key != "" {
+		cmd = append(cmd, "-o", "PreferredAuthentications=publickey", "-o", "PasswordAuthentication=no", "-i", shellQuote(jumpHost.Key))
+	}
+	if jumpHost.Port != "" {
+		cmd = append(cmd, "-p", shellQuote(jumpHost.Port))
+	}
+	if n > 1 {
+		nested := strings.ReplaceAll(t.prepareProxyCommand(n-1), "%", "%%")
+		cmd = append(cmd, "-o", shellQuote("ProxyCommand="+nested))
+	}
+	destination := jumpHost.Host
+	if jumpHost.User != "" {
+		destination = jumpHost.User + "@" + destination
+	}
+	cmd = append(cmd, "-W", "%h:%p", shellQuote(destination))
+	return strings.Join(cmd, " ")
+}
+
 func (t *RemoteTarget) prepareSSHCommand(command []string, async bool, prompt bool) []string {
 	var cmd []string
 	cmd = append(cmd, "ssh")
@@ -684,11 +1049,11 @@ func (t *RemoteTarget) prepareSCPCommand(src string, dstDir string, push bool) [
 	return cmd
 }
 
-func (t *RemoteTarget) prepareLocalCommand(cmd *exec.Cmd, async bool) *exec.Cmd {
+func (t *RemoteTarget) prepareLocalCommand(command []string, async bool) *exec.Cmd {
 	var name string
 	var args []string
 	usePass := t.key == "" && t.sshPass != ""
-	sshCommand := t.prepareSSHCommand(cmd.Args, async, usePass)
+	sshCommand := t.prepareSSHCommand(command, async, usePass)
 	if usePass {
 		name = t.sshpassPath
 		args = []string{"-e", "--"}
@@ -726,32 +1091,44 @@ func (t *RemoteTarget) prepareAndRunSCPCommand(srcPath string, dstDir string, is
 }
 
 func getArchitecture(t Target) (arch string, err error) {
+	if CacheGet(t, "architecture", &arch) {
+		return
+	}
 	cmd := exec.Command("uname", "-m")
 	arch, _, _, err = t.RunCommand(cmd, 0)
 	if err != nil {
 		return
 	}
 	arch = strings.TrimSpace(arch)
+	CachePut(t, "architecture", arch)
 	return
 }
 
 func getFamily(t Target) (family string, err error) {
+	if CacheGet(t, "family", &family) {
+		return
+	}
 	cmd := exec.Command("bash", "-c", "lscpu | grep -i \"^CPU family:\" | awk '{print $NF}'")
 	family, _, _, err = t.RunCommand(cmd, 0)
 	if err != nil {
 		return
 	}
 	family = strings.TrimSpace(family)
+	CachePut(t, "family", family)
 	return
 }
 
 func getModel(t Target) (model string, err error) {
+	if CacheGet(t, "model", &model) {
+		return
+	}
 	cmd := exec.Command("bash", "-c", "lscpu | grep -i model: | awk '{print $NF}'")
 	model, _, _, err = t.RunCommand(cmd, 0)
 	if err != nil {
 		return
 	}
 	model = strings.TrimSpace(model)
+	CachePut(t, "model", model)
 	return
 }
 
@@ -778,14 +1155,19 @@ func uninstallLkms(t Target, lkms []string) (err error) {
 		err = fmt.Errorf("can't elevate privileges; elevated privileges required to uninstall lkms")
 		return
 	}
+	var failed []string
 	for _, lkm := range lkms {
 		slog.Debug("attempting to uninstall kernel module", slog.String("lkm", lkm))
 		_, _, _, err := t.RunCommand(exec.Command("modprobe", "-r", lkm), 10)
 		if err != nil {
 			slog.Error("error uninstalling kernel module", slog.String("lkm", lkm), slog.String("error", err.Error()))
+			failed = append(failed, lkm)
 			continue
 		}
 		slog.Debug("kernel module uninstalled", slog.String("lkm", lkm))
 	}
+	if len(failed) > 0 {
+		err = fmt.Errorf("failed to uninstall kernel module(s): %s", strings.Join(failed, ", "))
+	}
 	return
 }
@@ -4,7 +4,16 @@ package target
 // SPDX-License-Identifier: BSD-3-Clause
 
 import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestNew(t *testing.T) {
@@ -17,3 +26,120 @@ func TestNew(t *testing.T) {
 		t.Fatal("failed to create a remote target")
 	}
 }
+
+func TestProxyCommand(t *testing.T) {
+	remoteTarget := NewRemoteTarget("label", "target", "", "user", "")
+	if flags := strings.Join(remoteTarget.prepareSSHFlags(false, false, false), " "); strings.Contains(flags, "ProxyCommand") {
+		t.Errorf("unexpected ProxyCommand without jump hosts: %s", flags)
+	}
+	remoteTarget.SetJumpHosts([]JumpHost{
+		{Host: "bastion", User: "alice", Key: "/keys/alice"},
+		{Host: "inner", Port: "2222"},
+	})
+	proxyCommand := remoteTarget.prepareProxyCommand(2)
+	if flags := strings.Join(remoteTarget.prepareSSHFlags(true, false, false), " "); !strings.Contains(flags, "ProxyCommand="+proxyCommand) {
+		t.Fatalf("ProxyCommand not in scp flags: %s", flags)
+	}
+	// the outer ProxyCommand connects to the last jump host and forwards to the target
+	if !strings.HasSuffix(proxyCommand, "-W %h:%p 'inner'") || !strings.Contains(proxyCommand, "-p '2222'") {
+		t.Errorf("unexpected ProxyCommand: %s", proxyCommand)
+	}
+	// the nested ProxyCommand connects to the first jump host, with its % tokens escaped
+	if !strings.Contains(proxyCommand, "-W %%h:%%p") || !strings.Contains(proxyCommand, "alice@bastion") {
+		t.Errorf("unexpected nested ProxyCommand: %s", proxyCommand)
+	}
+}
+
+// testRunCommandContext checks that a context's deadline interrupts a command running on the target,
+// including the processes it started. The target must share the local file system and process table.
+// Commands on local targets aren't run by a shell, so the shell is started explicitly.
+func testRunCommandContext(t *testing.T, myTarget Target, local bool) {
+	t.Helper()
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	cmd := exec.Command("sleep", "30", "&", "echo", "$!", ">", pidFile, ";", "wait")
+	if local {
+		cmd = exec.Command("sh", "-c", strings.Join(cmd.Args, " "))
+	}
+	_, _, _, err := myTarget.RunCommandContext(ctx, cmd)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context deadline exceeded error, got %v", err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Error("command was not interrupted")
+	}
+	contents, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("command did not start: %v", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	if err != nil {
+		t.Fatalf("failed to parse pid: %v", err)
+	}
+	// the child process is signaled after the command returns, give it a moment to exit
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if syscall.Kill(pid, 0) != nil {
+			return
+		}
+	}
+	t.Errorf("child process %d is still running", pid)
+}
+
+func TestLocalRunCommandContext(t *testing.T) {
+	testRunCommandContext(t, NewLocalTarget(), true)
+}
+
+func TestInterruptWatcher(t *testing.T) {
+	if _, err := exec.LookPath("pgrep"); err != nil {
+		t.Skip("pgrep not found")
+	}
+	// run the watcher as a remote shell is run, i.e., as a process group leader
+	start := func(commandLine string) (*exec.Cmd, *os.File, *strings.Builder) {
+		t.Helper()
+		stdinReader, stdinWriter, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var stdout strings.Builder
+		cmd := exec.Command(interruptibleCommand(commandLine)[0], interruptibleCommand(commandLine)[1:]...)
+		cmd.Stdin = stdinReader
+		cmd.Stdout = &stdout
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		stdinReader.Close()
+		return cmd, stdinWriter, &stdout
+	}
+	t.Run("interrupted", func(t *testing.T) {
+		cmd, stdinWriter, _ := start("sleep 30")
+		begin := time.Now()
+		time.Sleep(200 * time.Millisecond)
+		stdinWriter.Close()
+		_ = cmd.Wait()
+		if time.Since(begin) > 10*time.Second {
+			t.Error("command was not interrupted")
+		}
+	})
+	t.Run("exited", func(t *testing.T) {
+		cmd, stdinWriter, stdout := start("sleep 30 >/dev/null 2>&1 & echo $!; exit 3")
+		err := cmd.Wait()
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+			t.Errorf("expected exit code 3, got %v", err)
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(stdout.String()))
+		if err != nil {
+			t.Fatalf("failed to parse pid: %v", err)
+		}
+		defer syscall.Kill(pid, syscall.SIGKILL)
+		// the session closes after the command exits, the process it left running must not be signaled
+		stdinWriter.Close()
+		time.Sleep(3 * commandTerminateDelay)
+		if syscall.Kill(pid, 0) != nil {
+			t.Errorf("background process %d was signaled after the command exited", pid)
+		}
+	})
+}
@@ -23,6 +23,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
 )
 
 // ExpandUser expands '~' to user's home directory, if found, otherwise returns original path
@@ -178,6 +179,24 @@ func CreateIfNotExists(dir string, perm os.FileMode) error {
 	return nil
 }
 
+// FileRecorder is called with the path of each file that the run writes to its output directory, e.g., to list
+// the files in the run manifest. It may be called concurrently, and more than once for a file.
+type FileRecorder func(path string)
+
+var fileRecorder FileRecorder
+
+// SetFileRecorder sets the function that RecordFile calls.
+func SetFileRecorder(r FileRecorder) {
+	fileRecorder = r
+}
+
+// RecordFile records that the run wrote the file at path to its output directory, see FileRecorder.
+func RecordFile(path string) {
+	if fileRecorder != nil {
+		fileRecorder(path)
+	}
+}
+
 // StringIndexInList returns the index of the given string in the given list of
 // strings and error if not found
 func StringIndexInList(s string, l []string) (idx int, err error) {
@@ -408,14 +427,48 @@ func GetAppDir() string {
 
 // SignalChildren sends a signal to all children of this process
 func SignalChildren(sig os.Signal) {
-	// get list of child processes
-	cmd := exec.Command("pgrep", "-P", strconv.Itoa(os.Getpid()))
-	out, err := cmd.Output()
+	pids, err := childProcesses(os.Getpid())
 	if err != nil {
 		slog.Error("failed to get child processes", slog.String("error", err.Error()))
 		return
 	}
 	// send signal to each child
+	for _, pid := range pids {
+		signalProcess(pid, sig)
+	}
+}
+
+// ProcessTree returns the process ID of a process followed by the process IDs of its descendants.
+// The whole tree can be recorded before any of them are signaled, so that descendants are signaled
+// even if their parent exits first.
+func ProcessTree(pid int) (tree []int) {
+	tree = []int{pid}
+	for i := 0; i < len(tree); i++ {
+		children, err := childProcesses(tree[i])
+		if err != nil {
+			continue // no children
+		}
+		tree = append(tree, children...)
+	}
+	return
+}
+
+// SignalProcesses sends a signal to the processes that are still running.
+func SignalProcesses(pids []int, sig os.Signal) {
+	for _, pid := range pids {
+		if syscall.Kill(pid, 0) == nil {
+			signalProcess(pid, sig)
+		}
+	}
+}
+
+// childProcesses returns the process IDs of the children of the process
+func childProcesses(pid int) (pids []int, err error) {
+	cmd := exec.Command("pgrep", "-P", strconv.Itoa(pid))
+	out, err := cmd.Output()
+	if err != nil {
+		return
+	}
 	for _, pid := range strings.Split(string(out), "\n") {
 		if pid == "" {
 			continue
@@ -425,15 +478,20 @@ func SignalChildren(sig os.Signal) {
 			slog.Error("failed to convert pid to int", slog.String("pid", pid), slog.String("error", err.Error()))
 			continue
 		}
-		proc, err := os.FindProcess(pidInt)
-		if err != nil {
-			slog.Error("failed to find process", slog.Int("pid", pidInt), slog.String("error", err.Error()))
-			continue
-		}
-		slog.Info("sending signal to child process", slog.Int("pid", pidInt), slog.String("signal", sig.String()))
-		err = proc.Signal(sig)
-		if err != nil {
-			slog.Error("failed to send signal to process", slog.Int("pid", pidInt), slog.String("error", err.Error()))
-		}
+		pids = append(pids, pidInt)
+	}
+	return
+}
+
+func signalProcess(pid int, sig os.Signal) {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		slog.Error("failed to find process", slog.Int("pid", pid), slog.String("error", err.Error()))
+		return
+	}
+	slog.Info("sending signal to child process", slog.Int("pid", pid), slog.String("signal", sig.String()))
+	err = proc.Signal(sig)
+	if err != nil {
+		slog.Error("failed to send signal to process", slog.Int("pid", pid), slog.String("error", err.Error()))
 	}
 }